@@ -1,6 +1,11 @@
 package common
 
-import "teo/internal/utils"
+import (
+	"fmt"
+	"strings"
+	"teo/internal/utils"
+	"time"
+)
 
 func RoleSystemDefault() string {
 	return utils.Prompts()[0]["prompt"].(string)
@@ -12,8 +17,23 @@ func CommandStart() string {
 		"**/me** - About me and show current config\n\n" +
 		"**/models** - Change the LLM model\n" +
 		"**/system <prompt>** - Set the system prompt\n" +
+		"**/params** - Tune temperature, top_p, max tokens, seed, stop sequences and reasoning effort\n" +
+		"**/thinking** - Hide, show or stream the reasoning of thinking models\n" +
 		"**/prompts** - List available prompts with specialized tasks\n\n" +
-		"**/reset** - Reset the history context windows\n\n" +
+		"**/reset** - Reset the history context windows\n" +
+		"**/branches** - List or switch between regenerated and edited alternatives\n" +
+		"**/memory** - List, edit or forget what I remember about you\n" +
+		"**/docs** - List or remove documents you sent me\n" +
+		"**/voice on|off** - Reply with voice when you send a voice note\n" +
+		"**/imagine <prompt>** - Generate an image\n" +
+		"**/reminders** - List or cancel your reminders\n" +
+		"**/tasks** - Recurring tasks like a daily briefing, with history\n" +
+		"**/calendar** - Upcoming events, .ics export and your time zone\n" +
+		"**/cashflow** - Monthly cash flow chart, budgets, recurring entries and export\n" +
+		"**/receipt** - Save the expense of a receipt photo\n" +
+		"**/usage** - Show your token usage and cost\n" +
+		"**/quota** - Show your message, token and tool limits\n\n" +
+		"🛡️ Admins: /users, /ban, /promote, /broadcast, /stats, /invite\n\n" +
 		"ℹ️ You can interact using natural language without needing to set commands first."
 }
 
@@ -72,3 +92,586 @@ func CommandPromptsArgsNotInt() string {
 func CommandPromptsNotFound() string {
 	return "4️⃣0️⃣4️⃣ Template Prompt not found"
 }
+
+func CommandMemoryDisabled() string {
+	return "⚠️ Memory is disabled on this bot."
+}
+
+func CommandMemoryEmpty() string {
+	return "🧠 I don't remember anything about you yet."
+}
+
+func CommandMemoryFailed() string {
+	return "❌ Failed to manage memories. Please try again later."
+}
+
+func CommandMemoryUsage() string {
+	return "⚠️ Usage:\n/memory - List memories\n/memory edit <number> <text> - Edit a memory\n/memory forget <number> - Forget a memory\n/memory forget all - Forget everything"
+}
+
+func CommandMemoryArgsNotInt() string {
+	return "⚠️ The memory ID must be an integer. Example: /memory forget 2"
+}
+
+func CommandMemoryNotFound() string {
+	return "4️⃣0️⃣4️⃣ Memory not found"
+}
+
+func CommandMemoryEdit() string {
+	return "✅ Memory has been updated successfully."
+}
+
+func CommandMemoryForget() string {
+	return "✅ Memory has been forgotten."
+}
+
+func CommandMemoryForgetAll() string {
+	return "✅ All memories have been forgotten."
+}
+
+func CommandRemindersEmpty() string {
+	return "⏰ No reminders yet. Ask me something like \"remind me tomorrow at 9 to call the bank\"."
+}
+
+func CommandRemindersFailed() string {
+	return "❌ Failed to manage reminders. Please try again later."
+}
+
+func CommandRemindersUsage() string {
+	return "⚠️ Usage:\n/reminders - List reminders\n/reminders cancel <number> - Cancel a reminder"
+}
+
+func CommandRemindersNotFound() string {
+	return "4️⃣0️⃣4️⃣ Reminder not found"
+}
+
+func CommandRemindersCancel() string {
+	return "✅ Reminder has been cancelled."
+}
+
+func JobReminder(text string) string {
+	return "⏰ **Reminder**\n\n" + text
+}
+
+func CommandTasksEmpty() string {
+	return "📋 No tasks yet. Start a daily briefing with /tasks briefing or add your own with /tasks add <cron> <prompt>."
+}
+
+func CommandTasksFailed() string {
+	return "❌ Failed to manage tasks. Please try again later."
+}
+
+func CommandTasksUsage() string {
+	return "⚠️ Usage:\n/tasks - List tasks\n/tasks briefing [HH:MM] - Daily briefing on weekdays\n/tasks add <cron> <prompt> - Add a task, e.g. /tasks add 0 18 * * 5 plan my weekend\n/tasks edit <number> prompt <text> - Change the prompt\n/tasks edit <number> schedule <cron> - Change the schedule\n/tasks pause|resume|run|history|delete <number>"
+}
+
+func CommandTasksNotFound() string {
+	return "4️⃣0️⃣4️⃣ Task not found"
+}
+
+func CommandTasksInvalid(reason string) string {
+	return "⚠️ Invalid task: " + reason
+}
+
+func CommandTasksAdded(next string) string {
+	return "✅ Task has been added, it runs next on " + next + "."
+}
+
+func CommandTasksUpdated() string {
+	return "✅ Task has been updated."
+}
+
+func CommandTasksPaused() string {
+	return "⏸️ Task has been paused."
+}
+
+func CommandTasksResumed() string {
+	return "▶️ Task has been resumed."
+}
+
+func CommandTasksRunning() string {
+	return "⏳ Running the task now, the result will follow."
+}
+
+func CommandTasksDeleted() string {
+	return "✅ Task and its history have been deleted."
+}
+
+func CommandTasksNoHistory() string {
+	return "📋 This task has not run yet."
+}
+
+func BriefingName() string {
+	return "Daily briefing"
+}
+
+func BriefingPrompt() string {
+	return "Prepare my daily briefing for today. Use the tools you have to look up my calendar for today, the weather where I am and my unread notes, and skip any of them you cannot reach. Keep it short: a greeting, then a few bullet points per section, then anything that needs my attention first."
+}
+
+func JobTaskResult(name string, output string) string {
+	return "📋 **" + name + "**\n\n" + output
+}
+
+func CommandCalendarEmpty() string {
+	return "📅 Nothing on your calendar. Ask me to add an event, or send me an .ics file to import one."
+}
+
+func CommandCalendarFailed() string {
+	return "❌ Failed to manage the calendar. Please try again later."
+}
+
+func CommandCalendarUsage() string {
+	return "⚠️ Usage:\n/calendar - Events of the next 7 days\n/calendar export - Get your calendar as an .ics file\n/calendar timezone [zone] - Show or set your time zone, e.g. Europe/Paris\n\nSend an .ics file to import its events."
+}
+
+func CommandCalendarTimezone(timezone string) string {
+	return "🌍 Your calendar time zone is " + timezone + ". Change it with /calendar timezone <zone>."
+}
+
+func CommandCalendarTimezoneSet(timezone string) string {
+	return "✅ Calendar time zone set to " + timezone + "."
+}
+
+func CommandCalendarInvalidTimezone(timezone string) string {
+	return "⚠️ Unknown time zone " + timezone + ". Use an IANA name such as Europe/Paris or Asia/Jakarta."
+}
+
+func CalendarImported(added int, updated int) string {
+	return fmt.Sprintf("📅 Calendar imported: %d events added, %d updated.", added, updated)
+}
+
+func CalendarImportEmpty() string {
+	return "⚠️ The calendar file has no events."
+}
+
+func CalendarImportInvalid(reason string) string {
+	return "⚠️ The calendar file could not be read: " + reason
+}
+
+func CalendarImportFailed() string {
+	return "❌ Failed to import the calendar. Please try again later."
+}
+
+func CommandCashflowEmpty(month string) string {
+	return "💰 No transactions in " + month + ". Tell me what you earn and spend and I will keep track of it."
+}
+
+func CommandCashflowFailed() string {
+	return "❌ Failed to create the cash flow report. Please try again later."
+}
+
+func CommandCashflowUsage() string {
+	return "⚠️ Usage:\n/cashflow [YYYY-MM] [currency] - Chart of a month, the current one by default\n/cashflow budgets - Spending of this month against your budgets\n/cashflow recurring - Your recurring income and expenses\n/cashflow export <from> <to> [csv|xlsx] - Your transactions between two dates, e.g. /cashflow export 2024-01-01 2024-03-31 xlsx\n\nSend a CSV or OFX bank statement to import its transactions. Ask me to set a budget or add a recurring entry, e.g. \"budget 2,000,000 for food\"."
+}
+
+func CommandCashflowNoBudgets() string {
+	return "💰 You have no budgets. Ask me to set one, e.g. \"budget 2,000,000 a month for food\"."
+}
+
+func CommandCashflowNoRecurring() string {
+	return "🔁 You have no recurring income or expenses. Ask me to add one, e.g. \"my rent of 3,000,000 is due on the 1st of every month\"."
+}
+
+func CashflowBudgetExceeded(category string, spent string, budget string, currency string) string {
+	return fmt.Sprintf("⚠️ Budget alert: you spent %s %s on %s this month, over your budget of %s %s.", spent, currency, category, budget, currency)
+}
+
+func CommandCashflowExportFailed() string {
+	return "❌ Failed to export the transactions. Please try again later."
+}
+
+func CommandCashflowExportEmpty(from string, to string) string {
+	return "💰 No transactions from " + from + " to " + to + "."
+}
+
+func CashflowImportPreview(fileName string, count int, duplicates int, transactions string) string {
+	text := fmt.Sprintf("🏦 %s: %d new transactions", fileName, count)
+	if duplicates > 0 {
+		text += fmt.Sprintf(", %d already recorded were skipped", duplicates)
+	}
+	return text + ".\n\n" + transactions + "\nImport them?"
+}
+
+func CashflowImportEmpty() string {
+	return "⚠️ The bank statement has no transactions."
+}
+
+func CashflowImportNothingNew(duplicates int) string {
+	return fmt.Sprintf("✅ All %d transactions of the bank statement are already recorded.", duplicates)
+}
+
+func CashflowImportTooLarge(max int) string {
+	return fmt.Sprintf("⚠️ The bank statement has more than %d transactions. Please export a shorter period.", max)
+}
+
+func CashflowImportInvalid(reason string) string {
+	return "⚠️ The bank statement could not be read: " + reason
+}
+
+func CashflowImportFailed() string {
+	return "❌ Failed to import the bank statement. Please try again later."
+}
+
+func CashflowImported(count int) string {
+	return fmt.Sprintf("✅ %d transactions imported. See them with /cashflow.", count)
+}
+
+func CashflowImportCancelled() string {
+	return "🗑️ Import cancelled, nothing was saved."
+}
+
+func CashflowImportExpired() string {
+	return "⚠️ This preview has expired or was already answered."
+}
+
+func ReceiptDetails(merchant string, date string, amount string, currency string, category string) string {
+	return fmt.Sprintf("Merchant: %s\nDate: %s\nTotal: %s %s\nCategory: %s", merchant, date, amount, currency, category)
+}
+
+func ReceiptPreview(details string) string {
+	return "🧾 Receipt\n\n" + details + "\n\nSave this expense? Reply to this message to correct it, e.g. \"the total is 52,000\" or \"category groceries\"."
+}
+
+func ReceiptSaved(details string) string {
+	return "✅ Expense saved.\n\n" + details
+}
+
+func ReceiptDiscarded() string {
+	return "🗑️ Receipt discarded, nothing was saved."
+}
+
+func ReceiptUpdated() string {
+	return "✏️ Receipt updated, check it above."
+}
+
+func ReceiptEditHint() string {
+	return "Reply to the receipt message with what to change, e.g. \"the total is 52,000\"."
+}
+
+func ReceiptUsage() string {
+	return "🧾 Send a photo of a receipt with /receipt as caption, or reply /receipt to one, and I will read the expense for you to save."
+}
+
+func ReceiptUnsupported() string {
+	return "⚠️ The current model cannot read images. Choose a model with vision in /models to scan receipts."
+}
+
+func ReceiptUnreadable() string {
+	return "⚠️ I could not find the total of a receipt in this photo. Try a sharper photo of the whole receipt."
+}
+
+func ReceiptFailed() string {
+	return "❌ Failed to read the receipt. Please try again later."
+}
+
+func ButtonImport(count int) string {
+	return fmt.Sprintf("✅ Import %d", count)
+}
+
+func ButtonCancel() string {
+	return "❌ Cancel"
+}
+
+func ButtonReceiptSave() string {
+	return "✅ Save"
+}
+
+func ButtonReceiptEdit() string {
+	return "✏️ Edit"
+}
+
+func ButtonReceiptDiscard() string {
+	return "🗑️ Discard"
+}
+
+func CommandDocsEmpty() string {
+	return "📚 No documents yet. Send me a PDF, TXT, MD, CSV or DOCX file to add it to your knowledge base."
+}
+
+func CommandDocsFailed() string {
+	return "❌ Failed to manage documents. Please try again later."
+}
+
+func CommandDocsUsage() string {
+	return "⚠️ Usage:\n/docs - List documents\n/docs remove <number> - Remove a document"
+}
+
+func CommandDocsArgsNotInt() string {
+	return "⚠️ The document ID must be an integer. Example: /docs remove 0"
+}
+
+func CommandDocsNotFound() string {
+	return "4️⃣0️⃣4️⃣ Document not found"
+}
+
+func CommandDocsRemove() string {
+	return "✅ Document has been removed from your knowledge base."
+}
+
+func DocumentIndexed(fileName string, chunks int) string {
+	return fmt.Sprintf("📄 %s has been added to your knowledge base (%d parts). Ask me anything about it.", fileName, chunks)
+}
+
+func DocumentUnsupported() string {
+	return "⚠️ Unsupported document type. Supported types: PDF, TXT, MD, CSV and DOCX."
+}
+
+func DocumentTooLarge() string {
+	return "⚠️ The document is too large. The maximum size is 20 MB."
+}
+
+func DocumentEmpty() string {
+	return "⚠️ I couldn't find any text in this document."
+}
+
+func DocumentUnavailable() string {
+	return "⚠️ Documents are not available, the current LLM provider does not support embeddings."
+}
+
+func DocumentFailed() string {
+	return "❌ Failed to process the document. Please try again later."
+}
+
+func CommandVoiceDisabled() string {
+	return "⚠️ Voice replies are not available on this bot."
+}
+
+func CommandVoiceStatus(enabled bool) string {
+	if enabled {
+		return "🔊 Voice replies are on. I reply with voice when you send me a voice note.\n\nUsage: /voice off"
+	}
+	return "🔇 Voice replies are off.\n\nUsage: /voice on"
+}
+
+func CommandVoiceUsage() string {
+	return "⚠️ Usage: /voice on or /voice off"
+}
+
+func CommandVoice(enabled bool) string {
+	if enabled {
+		return "✅ Voice replies have been turned on."
+	}
+	return "✅ Voice replies have been turned off."
+}
+
+func CommandVoiceFailed() string {
+	return "❌ Failed to update voice replies. Please try again later."
+}
+
+func AttachmentTooLarge(fileName string) string {
+	return fmt.Sprintf("⚠️ %s is too large to send. The maximum size is 50 MB.", fileName)
+}
+
+func AttachmentFailed(fileName string) string {
+	return fmt.Sprintf("❌ Failed to send %s.", fileName)
+}
+
+func CommandImagineUsage() string {
+	return "🎨 Usage: /imagine <description of the image>\n\nExample: /imagine a watercolor fox in a snowy forest at dawn"
+}
+
+func CommandImagineDisabled() string {
+	return "⚠️ Image generation is not available on this bot."
+}
+
+func CommandImagineGenerating() string {
+	return "🎨 Generating image..."
+}
+
+func CommandImagineRejected() string {
+	return "⚠️ The image provider refused this prompt. Please try a different description."
+}
+
+func CommandImagineFailed() string {
+	return "❌ Failed to generate the image. Please try again later."
+}
+
+func ButtonRegenerate() string {
+	return "🔄 Regenerate"
+}
+
+func Regenerating() string {
+	return "🔄 Regenerating..."
+}
+
+func RegenerateUnavailable() string {
+	return "⚠️ This reply is no longer part of the current conversation."
+}
+
+func CommandBranchesEmpty() string {
+	return "🌿 This conversation has no alternatives yet.\n\nTap 🔄 Regenerate on a reply or edit one of your messages to create one."
+}
+
+func CommandBranchesArgsNotInt() string {
+	return "⚠️ Branch must be a number.\n\nUsage: /branches <number>\nExample: /branches 0"
+}
+
+func CommandBranchesNotFound() string {
+	return "4️⃣0️⃣4️⃣ Branch not found"
+}
+
+func CommandBranchesSwitched(index int) string {
+	return fmt.Sprintf("✅ Switched to branch %d. New messages continue from there.", index)
+}
+
+func CommandBranchesFailed() string {
+	return "❌ Failed to switch branch. Please try again later."
+}
+
+func CommandUsageUsage() string {
+	return "⚠️ Usage: /usage\n/usage report (admins only)"
+}
+
+func CommandUsageFailed() string {
+	return "❌ Failed to get usage. Please try again later."
+}
+
+// quotaReset tells when a limit allows the next request again.
+func quotaReset(wait time.Duration) string {
+	if wait < time.Minute {
+		return fmt.Sprintf("in %ds", int(wait.Round(time.Second)/time.Second))
+	}
+	at := time.Now().Add(wait)
+	return fmt.Sprintf("in %s (at %s)", strings.TrimSuffix(wait.Round(time.Minute).String(), "0s"), at.Format("15:04"))
+}
+
+func QuotaMessagesExceeded(limit int, wait time.Duration) string {
+	return fmt.Sprintf("⏳ You can send up to %d messages per minute. Please try again %s.", limit, quotaReset(wait))
+}
+
+func QuotaTokensExceeded(limit int, wait time.Duration) string {
+	return fmt.Sprintf("⏳ You have used your %d tokens for today. Your quota resets %s.", limit, quotaReset(wait))
+}
+
+func QuotaToolsExceeded(limit int, wait time.Duration) string {
+	return fmt.Sprintf("⏳ You have used your %d tool runs for this hour. Your quota resets %s.", limit, quotaReset(wait))
+}
+
+func CommandQuota() string {
+	return "✅ Limits updated."
+}
+
+func CommandQuotaReset() string {
+	return "✅ Limits restored to the role defaults."
+}
+
+func CommandQuotaUsage() string {
+	return "⚠️ Usage:\n/quota - Show your limits\n/quota <user_id> - Show the limits of a user\n/quota <user_id> <messages/minute> <tokens/day> <tools/hour> - Override the limits of a user, 0 is unlimited\n/quota <user_id> reset - Restore the role limits"
+}
+
+func CommandQuotaFailed() string {
+	return "❌ Failed to update limits. Please try again later."
+}
+
+func CommandParams() string {
+	return "✅ Parameter updated."
+}
+
+func CommandParamsReset() string {
+	return "✅ All parameters restored to the model defaults."
+}
+
+func CommandParamsUsage() string {
+	return "⚠️ Usage:\n/params - Show your parameters\n/params <name> <value> - Set a parameter\n/params <name> reset - Restore the model default of a parameter\n/params reset - Restore all model defaults\n\nParameters: temperature (0-2), top_p (0-1), max_tokens, seed, stop (comma separated, up to 4), reasoning_effort (low, medium, high)"
+}
+
+func CommandParamsInvalid(reason string) string {
+	return fmt.Sprintf("⚠️ Invalid value: %s.", reason)
+}
+
+func CommandParamsFailed() string {
+	return "❌ Failed to update parameters. Please try again later."
+}
+
+func CommandThinking(mode string) string {
+	return fmt.Sprintf("✅ Reasoning display set to %s.", mode)
+}
+
+func CommandThinkingShow(mode string) string {
+	return fmt.Sprintf("💭 Reasoning display: %s\n\nhide - Only send the answer\nshow - Send the reasoning as a collapsed quote before the answer\nstream - Show the reasoning live while the model thinks, then collapse it", mode)
+}
+
+func CommandThinkingUsage() string {
+	return "⚠️ Usage:\n/thinking - Show the current reasoning display\n/thinking <hide|show|stream> - Change it"
+}
+
+func CommandThinkingFailed() string {
+	return "❌ Failed to update reasoning display. Please try again later."
+}
+
+func InviteRequired() string {
+	return "🔒 This bot is invite-only. Send your invite code to get started."
+}
+
+func InviteAccepted() string {
+	return "✅ Welcome aboard! You can start chatting now.\n\nSend /help to see what I can do."
+}
+
+func CommandAdminOnly() string {
+	return "⛔ This command is for admins only."
+}
+
+func CommandAdminForbidden() string {
+	return "⛔ You are not allowed to manage this user."
+}
+
+func CommandUserNotFound() string {
+	return "4️⃣0️⃣4️⃣ User not found"
+}
+
+func CommandUsersUsage() string {
+	return "⚠️ Usage: /users <page>\nExample: /users 2"
+}
+
+func CommandUsersFailed() string {
+	return "❌ Failed to list users. Please try again later."
+}
+
+func CommandBanUsage() string {
+	return "⚠️ Usage: /ban <user_id>\nUse /promote <user_id> member to lift a ban."
+}
+
+func CommandPromoteUsage() string {
+	return "⚠️ Usage: /promote <user_id> <admin|member|banned>"
+}
+
+func CommandRole(name string, role string) string {
+	return fmt.Sprintf("✅ %s is now %s.", utils.EscapeMarkdown(name), role)
+}
+
+func CommandRoleFailed() string {
+	return "❌ Failed to change the role. Please try again later."
+}
+
+func CommandBroadcastUsage() string {
+	return "⚠️ Usage: /broadcast <message>"
+}
+
+func CommandBroadcast(recipients int) string {
+	return fmt.Sprintf("📣 Broadcasting to %d users...", recipients)
+}
+
+func CommandBroadcastDone(sent int, failed int) string {
+	return fmt.Sprintf("📣 Broadcast finished: %d delivered, %d failed.", sent, failed)
+}
+
+func CommandBroadcastFailed() string {
+	return "❌ Failed to start the broadcast. Please try again later."
+}
+
+func CommandStatsFailed() string {
+	return "❌ Failed to get stats. Please try again later."
+}
+
+func CommandInvite(code string) string {
+	return fmt.Sprintf("🎟️ Invite code: `%s`\n\nIt works once and expires in 7 days. The new user can send it to me or start with /start %s", code, code)
+}
+
+func CommandInviteFailed() string {
+	return "❌ Failed to create an invite. Please try again later."
+}
+
+func ProvidersUnavailable() string {
+	return "😵 All AI models are unavailable right now. Please try again in a few minutes."
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -27,15 +28,50 @@ var QueueName string
 var MQ *amqp091.Channel
 var OwnerId string
 var BotType string
+var AllowedUsers []int
+var InviteCode string
 var BotToken string
 var RedisClient *redis.Client
 var LLMProviderBaseURL string
 var LLMProviderName string
 var LLMProviderAPIKey string
+var LLMFallbacks []LLMFallback
+var CircuitBreakerThreshold int
+var CircuitBreakerCooldown time.Duration
 var StreamResponse bool
+var ReasoningDisplay string
 var TTSProviderName string
 var TTSProviderAPIKey string
+var TTSProviderBaseURL string
+var TTSModel string
+var TTSLanguage string
+var TTSFallbackProviderName string
+var TTSFallbackProviderBaseURL string
+var TTSFallbackProviderAPIKey string
+var TTSFallbackModel string
+var TTSSegmentSeconds int
+var SpeechProviderName string
+var SpeechProviderBaseURL string
+var SpeechProviderAPIKey string
+var SpeechModel string
+var SpeechVoice string
+var ImageProviderName string
+var ImageProviderBaseURL string
+var ImageProviderAPIKey string
+var ImageModel string
+var ImageSize string
 var WatermarkModel bool
+var TelegramParseMode string
+var MemoryEnabled bool
+var EmbeddingModel string
+var VectorStorePath string
+var PriceTablePath string
+var CapabilitiesPath string
+var ExchangeRatesPath string
+var ExchangeRateURL string
+var CashflowCurrency string
+var Timezone = time.Local
+var SchedulerInterval time.Duration
 
 func envPath() string {
 	_, b, _, _ := runtime.Caller(0)
@@ -64,11 +100,66 @@ func LoadConfig() {
 	rabbitMQURL := os.Getenv("RABBITMQ_URL")
 	OwnerId = os.Getenv("OWNER_ID")
 	BotType = os.Getenv("BOT_TYPE")
+	for _, id := range strings.Split(os.Getenv("ALLOWED_USERS"), ",") {
+		if userId, err := strconv.Atoi(strings.TrimSpace(id)); err == nil {
+			AllowedUsers = append(AllowedUsers, userId)
+		}
+	}
+	InviteCode = os.Getenv("INVITE_CODE")
 	BotToken = os.Getenv("BOT_TOKEN")
 	LLMProviderBaseURL = os.Getenv("LLM_PROVIDER_BASE_URL")
 	LLMProviderName = os.Getenv("LLM_PROVIDER_NAME")
 	LLMProviderAPIKey = os.Getenv("LLM_PROVIDER_API_KEY")
+	LLMFallbacks = parseLLMFallbacks(os.Getenv("LLM_FALLBACKS"))
+	OpenAICompatibles = parseOpenAICompatibles(os.Getenv("OPENAI_COMPATIBLE_PROVIDERS"))
+	CircuitBreakerThreshold, err = strconv.Atoi(os.Getenv("CIRCUIT_BREAKER_THRESHOLD"))
+	if err != nil || CircuitBreakerThreshold <= 0 {
+		CircuitBreakerThreshold = 3
+	}
+	cooldown, err := strconv.Atoi(os.Getenv("CIRCUIT_BREAKER_COOLDOWN_SECONDS"))
+	if err != nil || cooldown <= 0 {
+		cooldown = 60
+	}
+	CircuitBreakerCooldown = time.Duration(cooldown) * time.Second
 	WatermarkModel, _ = strconv.ParseBool(os.Getenv("WATERMARK_MODEL"))
+	MemoryEnabled, _ = strconv.ParseBool(os.Getenv("MEMORY_ENABLED"))
+	EmbeddingModel = os.Getenv("EMBEDDING_MODEL")
+	VectorStorePath = os.Getenv("VECTOR_STORE_PATH")
+	if VectorStorePath == "" {
+		VectorStorePath = filepath.Join("data", "vectors")
+	}
+	PriceTablePath = os.Getenv("PRICE_TABLE_PATH")
+	if PriceTablePath == "" {
+		PriceTablePath = "pricing.json"
+	}
+	CapabilitiesPath = os.Getenv("MODEL_CAPABILITIES_PATH")
+	if CapabilitiesPath == "" {
+		CapabilitiesPath = "capabilities.json"
+	}
+	ExchangeRatesPath = os.Getenv("EXCHANGE_RATES_PATH")
+	if ExchangeRatesPath == "" {
+		ExchangeRatesPath = "exchange_rates.json"
+	}
+	ExchangeRateURL = os.Getenv("EXCHANGE_RATE_URL")
+	CashflowCurrency = strings.ToUpper(os.Getenv("CASHFLOW_CURRENCY"))
+	if CashflowCurrency == "" {
+		CashflowCurrency = "IDR"
+	}
+	loadQuotas()
+
+	if name := os.Getenv("TIMEZONE"); name != "" {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			log.Printf("Invalid TIMEZONE %s: %v, using the server time zone", name, err)
+		} else {
+			Timezone = location
+		}
+	}
+	schedulerInterval, err := strconv.Atoi(os.Getenv("SCHEDULER_INTERVAL_SECONDS"))
+	if err != nil || schedulerInterval <= 0 {
+		schedulerInterval = 30
+	}
+	SchedulerInterval = time.Duration(schedulerInterval) * time.Second
 
 	TTSProviderName = os.Getenv("TTS_PROVIDER_NAME")
 	if TTSProviderName == "" {
@@ -77,6 +168,39 @@ func LoadConfig() {
 	}
 	TTSProviderAPIKey = os.Getenv("TTS_PROVIDER_API_KEY")
 	// No default for API key for security reasons
+	TTSProviderBaseURL = os.Getenv("TTS_PROVIDER_BASE_URL")
+	TTSModel = os.Getenv("TTS_MODEL")
+	TTSLanguage = os.Getenv("TTS_LANGUAGE")
+	TTSFallbackProviderName = os.Getenv("TTS_FALLBACK_PROVIDER_NAME")
+	TTSFallbackProviderBaseURL = os.Getenv("TTS_FALLBACK_PROVIDER_BASE_URL")
+	TTSFallbackProviderAPIKey = os.Getenv("TTS_FALLBACK_PROVIDER_API_KEY")
+	TTSFallbackModel = os.Getenv("TTS_FALLBACK_MODEL")
+	TTSSegmentSeconds, err = strconv.Atoi(os.Getenv("TTS_SEGMENT_SECONDS"))
+	if err != nil || TTSSegmentSeconds <= 0 {
+		TTSSegmentSeconds = 600
+	}
+
+	SpeechProviderName = os.Getenv("SPEECH_PROVIDER_NAME")
+	SpeechProviderBaseURL = os.Getenv("SPEECH_PROVIDER_BASE_URL")
+	SpeechProviderAPIKey = os.Getenv("SPEECH_PROVIDER_API_KEY")
+	SpeechModel = os.Getenv("SPEECH_MODEL")
+	SpeechVoice = os.Getenv("SPEECH_VOICE")
+
+	ReasoningDisplay = strings.ToLower(os.Getenv("REASONING_DISPLAY"))
+	if ReasoningDisplay != "show" && ReasoningDisplay != "stream" {
+		ReasoningDisplay = "hide"
+	}
+
+	TelegramParseMode = "HTML"
+	if strings.EqualFold(os.Getenv("TELEGRAM_PARSE_MODE"), "MarkdownV2") {
+		TelegramParseMode = "MarkdownV2"
+	}
+
+	ImageProviderName = os.Getenv("IMAGE_PROVIDER_NAME")
+	ImageProviderBaseURL = os.Getenv("IMAGE_PROVIDER_BASE_URL")
+	ImageProviderAPIKey = os.Getenv("IMAGE_PROVIDER_API_KEY")
+	ImageModel = os.Getenv("IMAGE_MODEL")
+	ImageSize = os.Getenv("IMAGE_SIZE")
 
 	maxRetries := 10
 	retryDelay := 3 * time.Second
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// LLMFallback is an entry of LLM_FALLBACKS. Its credentials come from
+// LLM_FALLBACK_<PROVIDER>_BASE_URL and LLM_FALLBACK_<PROVIDER>_API_KEY, or
+// from the main provider settings when it is the same provider.
+type LLMFallback struct {
+	Provider string
+	Model    string
+	BaseURL  string
+	APIKey   string
+}
+
+// parseLLMFallbacks reads a comma separated list of provider:model pairs.
+// Only the first colon separates them, so Ollama tags such as
+// ollama:qwen2.5:7b keep their own.
+func parseLLMFallbacks(value string) []LLMFallback {
+	var fallbacks []LLMFallback
+	for _, entry := range strings.Split(value, ",") {
+		providerName, model, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		if providerName == "" {
+			continue
+		}
+
+		prefix := "LLM_FALLBACK_" + strings.ToUpper(providerName) + "_"
+		fallback := LLMFallback{
+			Provider: providerName,
+			Model:    model,
+			BaseURL:  os.Getenv(prefix + "BASE_URL"),
+			APIKey:   os.Getenv(prefix + "API_KEY"),
+		}
+		if providerName == LLMProviderName {
+			if fallback.BaseURL == "" {
+				fallback.BaseURL = LLMProviderBaseURL
+			}
+			if fallback.APIKey == "" {
+				fallback.APIKey = LLMProviderAPIKey
+			}
+		}
+		fallbacks = append(fallbacks, fallback)
+	}
+	return fallbacks
+}
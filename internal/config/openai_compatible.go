@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OpenAICompatible is an entry of OPENAI_COMPATIBLE_PROVIDERS, an extra LLM
+// provider served by any OpenAI-compatible API such as vLLM, LM Studio,
+// OpenRouter or llama.cpp. Its settings come from OPENAI_COMPATIBLE_<NAME>_*
+// variables, where NAME is the upper-cased name with dashes as underscores.
+type OpenAICompatible struct {
+	Name         string
+	DefaultModel string
+	PathPrefix   string
+	Headers      map[string]string
+	Models       []string
+	Tools        bool
+	Vision       bool
+	StreamUsage  bool
+	Embeddings   bool
+	JSONSchema   bool
+}
+
+var OpenAICompatibles []OpenAICompatible
+
+func parseOpenAICompatibles(value string) []OpenAICompatible {
+	var providers []OpenAICompatible
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OPENAI_COMPATIBLE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OpenAICompatible{
+			Name:         name,
+			DefaultModel: os.Getenv(prefix + "DEFAULT_MODEL"),
+			PathPrefix:   os.Getenv(prefix + "PATH_PREFIX"),
+			Headers:      parseHeaders(os.Getenv(prefix + "HEADERS")),
+			Models:       splitList(os.Getenv(prefix + "MODELS")),
+			Tools:        envBool(prefix+"TOOLS", true),
+			Vision:       envBool(prefix+"VISION", false),
+			StreamUsage:  envBool(prefix+"STREAM_USAGE", false),
+			Embeddings:   envBool(prefix+"EMBEDDINGS", false),
+			JSONSchema:   envBool(prefix+"JSON_SCHEMA", true),
+		})
+	}
+	return providers
+}
+
+// parseHeaders reads "Name: value" pairs separated by semicolons.
+func parseHeaders(value string) map[string]string {
+	headers := map[string]string{}
+	for _, entry := range strings.Split(value, ";") {
+		name, headerValue, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(headerValue)
+	}
+	return headers
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"teo/internal/quota"
+)
+
+// QuotaRoles are the roles that can be given limits with
+// QUOTA_<ROLE>_MESSAGES_PER_MINUTE, QUOTA_<ROLE>_TOKENS_PER_DAY and
+// QUOTA_<ROLE>_TOOLS_PER_HOUR.
+var QuotaRoles = []string{"owner", "admin", "member"}
+
+var RoleQuotas map[string]quota.Quota
+
+func loadQuotas() {
+	RoleQuotas = map[string]quota.Quota{}
+	for _, role := range QuotaRoles {
+		prefix := "QUOTA_" + strings.ToUpper(role) + "_"
+		RoleQuotas[role] = quota.Quota{
+			MessagesPerMinute: quotaLimit(prefix + "MESSAGES_PER_MINUTE"),
+			TokensPerDay:      quotaLimit(prefix + "TOKENS_PER_DAY"),
+			ToolsPerHour:      quotaLimit(prefix + "TOOLS_PER_HOUR"),
+		}
+	}
+}
+
+func quotaLimit(key string) int {
+	limit, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || limit < 0 {
+		return 0
+	}
+	return limit
+}
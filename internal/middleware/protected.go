@@ -24,7 +24,7 @@ func Protected(c *fiber.Ctx) error {
 			return utils.ErrorBadRequest(c, "Invalid owner id")
 		}
 
-		if data.Message.From.Id != owner {
+		if data.Sender().Id != owner {
 			fmt.Println("Only the owner is allowed to chat")
 			return utils.ErrorBadRequest(c, "Only the owner is allowed to chat")
 		}
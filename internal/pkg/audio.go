@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// TranscodeToOggOpus converts audio to OGG/Opus with ffmpeg, the only format
+// Telegram renders as a voice message.
+func TranscodeToOggOpus(audio []byte, format string) ([]byte, error) {
+	if format == "ogg" {
+		return audio, nil
+	}
+
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error", "-i", "pipe:0", "-vn", "-c:a", "libopus", "-b:a", "48k", "-f", "ogg", "pipe:1")
+	cmd.Stdin = bytes.NewReader(audio)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error transcoding %s audio to ogg (is ffmpeg installed?): %v %s", format, err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// SplitAudio cuts audio into segments of at most segmentSeconds with ffmpeg,
+// copying the stream so no re-encoding happens.
+func SplitAudio(audio []byte, segmentSeconds int) ([][]byte, error) {
+	tempDir, err := os.MkdirTemp("", "teo-audio-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputPath := filepath.Join(tempDir, "input.ogg")
+	if err := os.WriteFile(inputPath, audio, 0644); err != nil {
+		return nil, fmt.Errorf("error writing audio file: %v", err)
+	}
+
+	outputPattern := filepath.Join(tempDir, "segment-%03d.ogg")
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error", "-i", inputPath, "-f", "segment", "-segment_time", strconv.Itoa(segmentSeconds), "-c", "copy", outputPattern)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error splitting audio (is ffmpeg installed?): %v %s", err, stderr.String())
+	}
+
+	paths, err := filepath.Glob(filepath.Join(tempDir, "segment-*.ogg"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing audio segments: %v", err)
+	}
+	sort.Strings(paths)
+
+	var segments [][]byte
+	for _, path := range paths {
+		segment, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading audio segment: %v", err)
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
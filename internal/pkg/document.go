@@ -0,0 +1,243 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrUnsupportedDocument = errors.New("unsupported document type")
+
+func DocumentType(fileName string, mimeType string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".pdf":
+		return "pdf"
+	case ".txt", ".log":
+		return "txt"
+	case ".md", ".markdown":
+		return "md"
+	case ".csv":
+		return "csv"
+	case ".docx":
+		return "docx"
+	}
+
+	switch mimeType {
+	case "application/pdf":
+		return "pdf"
+	case "text/plain":
+		return "txt"
+	case "text/markdown":
+		return "md"
+	case "text/csv":
+		return "csv"
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return "docx"
+	}
+
+	return ""
+}
+
+func ExtractDocumentText(fileName string, mimeType string, data []byte) (string, error) {
+	switch DocumentType(fileName, mimeType) {
+	case "pdf":
+		return extractPDFText(data)
+	case "txt", "md":
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("document is not valid UTF-8 text")
+		}
+		return string(data), nil
+	case "csv":
+		return extractCSVText(data)
+	case "docx":
+		return extractDocxText(data)
+	}
+
+	return "", ErrUnsupportedDocument
+}
+
+func extractPDFText(data []byte) (string, error) {
+	tempFile, err := os.CreateTemp("", "teo-document-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("error creating temp file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return "", fmt.Errorf("error writing temp file: %v", err)
+	}
+	tempFile.Close()
+
+	cmd := exec.Command("pdftotext", "-layout", "-enc", "UTF-8", tempFile.Name(), "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error extracting pdf text (is poppler-utils installed?): %v %s", err, stderr.String())
+	}
+
+	return string(output), nil
+}
+
+func extractCSVText(data []byte) (string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("error parsing csv: %v", err)
+	}
+
+	if len(records) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	header := records[0]
+	for _, record := range records[1:] {
+		var fields []string
+		for i, value := range record {
+			if i < len(header) && header[i] != "" {
+				fields = append(fields, fmt.Sprintf("%s: %s", header[i], value))
+			} else {
+				fields = append(fields, value)
+			}
+		}
+		sb.WriteString(strings.Join(fields, ", "))
+		sb.WriteString("\n\n")
+	}
+
+	if len(records) == 1 {
+		sb.WriteString(strings.Join(header, ", "))
+	}
+
+	return sb.String(), nil
+}
+
+func extractDocxText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("error opening docx: %v", err)
+	}
+
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return "", fmt.Errorf("error reading docx: %v", err)
+		}
+		defer rc.Close()
+
+		return docxXMLText(rc)
+	}
+
+	return "", fmt.Errorf("error reading docx: word/document.xml not found")
+}
+
+func docxXMLText(r io.Reader) (string, error) {
+	decoder := xml.NewDecoder(r)
+	var sb strings.Builder
+	inText := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("error parsing docx: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// ChunkText splits text on paragraph boundaries into chunks of at most
+// size characters, repeating the tail of the previous chunk as overlap.
+func ChunkText(text string, size int, overlap int) []string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		for len([]rune(paragraph)) > size {
+			window := string([]rune(paragraph)[:size])
+			if i := strings.LastIndexAny(window, ".!?\n "); i > len(window)/2 {
+				window = window[:i+1]
+			}
+			paragraphs = append(paragraphs, strings.TrimSpace(window))
+			paragraph = strings.TrimSpace(paragraph[len(window):])
+		}
+		if paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+
+	var chunks []string
+	current := ""
+	for _, paragraph := range paragraphs {
+		if current != "" && len([]rune(current))+len([]rune(paragraph))+2 > size {
+			chunks = append(chunks, current)
+
+			tail := []rune(current)
+			if len(tail) > overlap {
+				tail = tail[len(tail)-overlap:]
+			}
+			current = strings.TrimSpace(string(tail))
+			if len([]rune(current))+len([]rune(paragraph))+2 > size {
+				current = ""
+			}
+		}
+
+		if current == "" {
+			current = paragraph
+		} else {
+			current += "\n\n" + paragraph
+		}
+	}
+
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
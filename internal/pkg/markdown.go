@@ -0,0 +1,523 @@
+package pkg
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ParseModeHTML       = "HTML"
+	ParseModeMarkdownV2 = "MarkdownV2"
+
+	// Telegram counts message length in UTF-16 code units after entity parsing.
+	TelegramMessageLimit = 4096
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	bulletPattern  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	orderedPattern = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	rulePattern    = regexp.MustCompile(`^\s*([-*_]\s*){3,}$`)
+)
+
+// markdownV2Special are the characters MarkdownV2 requires escaping in text.
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+type markdownBlock struct {
+	code     bool
+	fence    string
+	language string
+	lines    []string
+}
+
+// telegramFormat renders the Markdown subset LLMs produce into one of
+// Telegram's parse modes.
+type telegramFormat interface {
+	text(s string) string
+	code(s string) string
+	pre(language string, code string) string
+	bold(inner string) string
+	italic(inner string) string
+	strike(inner string) string
+	link(label string, url string) string
+	quote(inner string) string
+}
+
+type htmlFormat struct{}
+
+func (htmlFormat) text(s string) string { return html.EscapeString(s) }
+func (htmlFormat) code(s string) string { return "<code>" + html.EscapeString(s) + "</code>" }
+func (htmlFormat) pre(language string, code string) string {
+	if language != "" {
+		return "<pre><code class=\"language-" + html.EscapeString(language) + "\">" + html.EscapeString(code) + "</code></pre>"
+	}
+	return "<pre>" + html.EscapeString(code) + "</pre>"
+}
+func (htmlFormat) bold(inner string) string   { return "<b>" + inner + "</b>" }
+func (htmlFormat) italic(inner string) string { return "<i>" + inner + "</i>" }
+func (htmlFormat) strike(inner string) string { return "<s>" + inner + "</s>" }
+func (htmlFormat) link(label string, url string) string {
+	return "<a href=\"" + html.EscapeString(url) + "\">" + label + "</a>"
+}
+func (htmlFormat) quote(inner string) string { return "<blockquote>" + inner + "</blockquote>" }
+
+type markdownV2Format struct{}
+
+func escapeMarkdownV2(s string, special string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (markdownV2Format) text(s string) string { return escapeMarkdownV2(s, markdownV2Special) }
+func (markdownV2Format) code(s string) string { return "`" + escapeMarkdownV2(s, "`\\") + "`" }
+func (markdownV2Format) pre(language string, code string) string {
+	return "```" + language + "\n" + escapeMarkdownV2(code, "`\\") + "\n```"
+}
+func (markdownV2Format) bold(inner string) string   { return "*" + inner + "*" }
+func (markdownV2Format) italic(inner string) string { return "_" + inner + "_" }
+func (markdownV2Format) strike(inner string) string { return "~" + inner + "~" }
+func (markdownV2Format) link(label string, url string) string {
+	return "[" + label + "](" + escapeMarkdownV2(url, ")\\") + ")"
+}
+func (markdownV2Format) quote(inner string) string {
+	lines := strings.Split(inner, "\n")
+	for i, line := range lines {
+		lines[i] = ">" + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func telegramFormatFor(parseMode string) telegramFormat {
+	if parseMode == ParseModeMarkdownV2 {
+		return markdownV2Format{}
+	}
+	return htmlFormat{}
+}
+
+// RenderTelegram converts LLM Markdown into Telegram HTML or MarkdownV2.
+// Unclosed fences and entities are closed or left literal, so partial
+// streaming output always renders to valid markup.
+func RenderTelegram(text string, parseMode string) string {
+	format := telegramFormatFor(parseMode)
+	blocks := parseMarkdownBlocks(text)
+
+	rendered := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		rendered = append(rendered, renderBlock(block, format))
+	}
+
+	return strings.Join(rendered, "\n\n")
+}
+
+// SplitTelegramMessage splits Markdown into chunks whose rendered form fits
+// in a single message. It prefers paragraph boundaries, splits code blocks
+// between lines and repeats the fence in every chunk so each one is
+// balanced on its own.
+func SplitTelegramMessage(text string, parseMode string, limit int) []string {
+	format := telegramFormatFor(parseMode)
+	fits := func(s string) bool {
+		return utf16Length(RenderTelegram(s, parseMode)) <= limit
+	}
+
+	var pieces []string
+	for _, block := range parseMarkdownBlocks(text) {
+		source := blockSource(block)
+		if utf16Length(renderBlock(block, format)) <= limit {
+			pieces = append(pieces, source)
+			continue
+		}
+		pieces = append(pieces, splitBlock(block, fits)...)
+	}
+
+	var chunks []string
+	current := ""
+	for _, piece := range pieces {
+		if current == "" {
+			current = piece
+			continue
+		}
+		if fits(current + "\n\n" + piece) {
+			current += "\n\n" + piece
+			continue
+		}
+		chunks = append(chunks, current)
+		current = piece
+	}
+
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+func parseMarkdownBlocks(text string) []markdownBlock {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var blocks []markdownBlock
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, markdownBlock{lines: paragraph})
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			flush()
+			fence := trimmed[:3]
+			block := markdownBlock{code: true, fence: fence, language: strings.TrimSpace(trimmed[3:])}
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+				block.lines = append(block.lines, lines[i])
+			}
+			blocks = append(blocks, block)
+			continue
+		}
+
+		if trimmed == "" {
+			flush()
+			continue
+		}
+
+		paragraph = append(paragraph, line)
+	}
+	flush()
+
+	return blocks
+}
+
+func blockSource(block markdownBlock) string {
+	if block.code {
+		return block.fence + block.language + "\n" + strings.Join(block.lines, "\n") + "\n" + block.fence
+	}
+	return strings.Join(block.lines, "\n")
+}
+
+func splitBlock(block markdownBlock, fits func(string) bool) []string {
+	wrap := func(lines []string) string {
+		return blockSource(markdownBlock{code: block.code, fence: block.fence, language: block.language, lines: lines})
+	}
+
+	var pieces []string
+	var current []string
+	for _, line := range block.lines {
+		if fits(wrap(append(current, line))) {
+			current = append(current, line)
+			continue
+		}
+
+		if len(current) > 0 {
+			pieces = append(pieces, wrap(current))
+			current = nil
+		}
+
+		if fits(wrap([]string{line})) {
+			current = []string{line}
+			continue
+		}
+
+		// A single line longer than a message, cut it on word boundaries
+		for _, part := range splitLine(line, func(s string) bool { return fits(wrap([]string{s})) }) {
+			pieces = append(pieces, wrap([]string{part}))
+		}
+	}
+
+	if len(current) > 0 {
+		pieces = append(pieces, wrap(current))
+	}
+
+	return pieces
+}
+
+func splitLine(line string, fits func(string) bool) []string {
+	var parts []string
+	runes := []rune(line)
+	for len(runes) > 0 {
+		// Binary search the longest prefix that still fits
+		low, high := 1, len(runes)
+		for low < high {
+			mid := (low + high + 1) / 2
+			if fits(string(runes[:mid])) {
+				low = mid
+			} else {
+				high = mid - 1
+			}
+		}
+
+		end := low
+		if end < len(runes) {
+			for i := end; i > end/2; i-- {
+				if unicode.IsSpace(runes[i-1]) {
+					end = i
+					break
+				}
+			}
+		}
+
+		parts = append(parts, strings.TrimSpace(string(runes[:end])))
+		runes = runes[end:]
+	}
+	return parts
+}
+
+func renderBlock(block markdownBlock, format telegramFormat) string {
+	if block.code {
+		return format.pre(block.language, strings.Join(block.lines, "\n"))
+	}
+
+	if isTable(block.lines) {
+		return format.pre("", strings.Join(block.lines, "\n"))
+	}
+
+	var rendered []string
+	var quote []string
+	flushQuote := func() {
+		if len(quote) > 0 {
+			rendered = append(rendered, format.quote(strings.Join(quote, "\n")))
+			quote = nil
+		}
+	}
+
+	for _, line := range block.lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			quote = append(quote, renderInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">")), format))
+			continue
+		}
+		flushQuote()
+
+		switch {
+		case headingPattern.MatchString(trimmed):
+			rendered = append(rendered, format.bold(renderInline(headingPattern.FindStringSubmatch(trimmed)[1], format)))
+		case rulePattern.MatchString(trimmed):
+			rendered = append(rendered, format.text("──────────"))
+		case bulletPattern.MatchString(line):
+			match := bulletPattern.FindStringSubmatch(line)
+			rendered = append(rendered, format.text(match[1]+"• ")+renderInline(match[2], format))
+		case orderedPattern.MatchString(line):
+			match := orderedPattern.FindStringSubmatch(line)
+			rendered = append(rendered, format.text(match[1]+match[2]+". ")+renderInline(match[3], format))
+		default:
+			rendered = append(rendered, renderInline(line, format))
+		}
+	}
+	flushQuote()
+
+	return strings.Join(rendered, "\n")
+}
+
+func isTable(lines []string) bool {
+	if len(lines) < 2 {
+		return false
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "|") {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// findClosing returns the index in s of the closing delimiter, or -1.
+// Single-character delimiters must not be part of a doubled one.
+func findClosing(s string, delimiter string) int {
+	for offset := 0; offset < len(s); {
+		i := strings.Index(s[offset:], delimiter)
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		if i == 0 {
+			offset = i + len(delimiter)
+			continue
+		}
+		if len(delimiter) == 1 && i+1 < len(s) && s[i+1] == delimiter[0] {
+			offset = i + 2
+			continue
+		}
+		if r, _ := utf8.DecodeLastRuneInString(s[:i]); unicode.IsSpace(r) {
+			offset = i + len(delimiter)
+			continue
+		}
+		if delimiter == "_" && i+1 < len(s) {
+			if r, _ := utf8.DecodeRuneInString(s[i+1:]); isWordRune(r) {
+				offset = i + 1
+				continue
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+func renderInline(s string, format telegramFormat) string {
+	var out strings.Builder
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			out.WriteString(format.text(plain.String()))
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+
+		if rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune(markdownV2Special, rune(rest[1])) {
+			plain.WriteByte(rest[1])
+			i += 2
+			continue
+		}
+
+		if rest[0] == '`' {
+			if end := strings.Index(rest[1:], "`"); end > 0 {
+				flush()
+				out.WriteString(format.code(rest[1 : end+1]))
+				i += end + 2
+				continue
+			}
+		}
+
+		if rest[0] == '[' {
+			if mid := strings.Index(rest, "]("); mid > 0 {
+				if end := closingParen(rest[mid+2:]); end > 0 {
+					flush()
+					url := rest[mid+2 : mid+2+end]
+					out.WriteString(format.link(renderInline(rest[1:mid], format), url))
+					i += mid + 3 + end
+					continue
+				}
+			}
+		}
+
+		matched := false
+		for _, delimiter := range []string{"**", "__", "~~", "*", "_"} {
+			if !strings.HasPrefix(rest, delimiter) {
+				continue
+			}
+			if delimiter == "_" || delimiter == "__" {
+				// snake_case identifiers are not emphasis
+				if r, _ := utf8.DecodeLastRuneInString(s[:i]); i > 0 && isWordRune(r) {
+					break
+				}
+			}
+
+			inner := rest[len(delimiter):]
+			if inner == "" || unicode.IsSpace(rune(inner[0])) {
+				break
+			}
+
+			end := findClosing(inner, delimiter)
+			if end <= 0 {
+				break
+			}
+
+			flush()
+			content := renderInline(inner[:end], format)
+			switch delimiter {
+			case "**", "__":
+				out.WriteString(format.bold(content))
+			case "~~":
+				out.WriteString(format.strike(content))
+			default:
+				out.WriteString(format.italic(content))
+			}
+			i += len(delimiter)*2 + end
+			matched = true
+			break
+		}
+		if matched {
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		plain.WriteRune(r)
+		i += size
+	}
+	flush()
+
+	return out.String()
+}
+
+// closingParen finds the parenthesis closing a link URL, allowing balanced
+// parentheses inside it as in Wikipedia links.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case ' ', '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+func utf16Length(s string) int {
+	length := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			length += 2
+		} else {
+			length++
+		}
+	}
+	return length
+}
+
+// RenderTelegramReasoning renders the reasoning of a model as an expandable
+// block quote under a heading, so it stays collapsed until the user opens it.
+// Reasoning that does not fit in limit is cut and ends with an ellipsis.
+func RenderTelegramReasoning(reasoning string, parseMode string, limit int) string {
+	text := strings.TrimSpace(reasoning)
+	rendered := renderReasoning(text, parseMode)
+	for utf16Length(rendered) > limit && text != "" {
+		runes := []rune(text)
+		cut := len(runes) - (utf16Length(rendered)-limit)/2 - 1
+		if cut < 0 {
+			cut = 0
+		}
+		text = strings.TrimSpace(string(runes[:cut]))
+		rendered = renderReasoning(text+"…", parseMode)
+	}
+	return rendered
+}
+
+func renderReasoning(text string, parseMode string) string {
+	heading := "💭 Thought process"
+	if parseMode == ParseModeMarkdownV2 {
+		lines := strings.Split(escapeMarkdownV2(text, markdownV2Special), "\n")
+		for i, line := range lines {
+			lines[i] = ">" + line
+		}
+		lines[0] = "**" + lines[0]
+		return "*" + escapeMarkdownV2(heading, markdownV2Special) + "*\n" + strings.Join(lines, "\n") + "||"
+	}
+	return "<b>" + heading + "</b>\n<blockquote expandable>" + html.EscapeString(text) + "</blockquote>"
+}
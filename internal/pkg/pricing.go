@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// PriceTable maps model names, or prefixes of model names, to their price.
+type PriceTable map[string]ModelPrice
+
+func LoadPriceTable(path string) (PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices PriceTable
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// Price looks the model up by exact name first, then by the longest prefix
+// so dated releases such as gpt-4o-2024-08-06 share the price of gpt-4o.
+func (t PriceTable) Price(modelName string) (ModelPrice, bool) {
+	modelName = strings.TrimPrefix(modelName, "models/")
+	if price, ok := t[modelName]; ok {
+		return price, true
+	}
+
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(modelName, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+
+	return t[best], true
+}
+
+func (t PriceTable) Cost(modelName string, promptTokens int, completionTokens int) float64 {
+	price, ok := t.Price(modelName)
+	if !ok {
+		return 0
+	}
+
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1_000_000
+}
@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket refills capacity tokens evenly over period. With force the cost
+// is always taken, even if that leaves the bucket in debt, which is how usage
+// only known after the fact is charged. It returns whether the cost fit and
+// how many milliseconds until the bucket has enough tokens again.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local force = ARGV[5] == "1"
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+local rate = capacity / period
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if force or (tokens > 0 and tokens >= cost) then
+	tokens = tokens - cost
+	allowed = 1
+end
+
+local wait = 0
+local need = math.max(cost, 1)
+if tokens < need then
+	wait = math.ceil((need - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], period + wait)
+return {allowed, wait}
+`)
+
+func runTokenBucket(rd *redis.Client, key string, capacity int, period time.Duration, cost int, force bool) (bool, time.Duration, error) {
+	forceArg := "0"
+	if force {
+		forceArg = "1"
+	}
+
+	res, err := tokenBucket.Run(context.Background(), rd, []string{key},
+		capacity, period.Milliseconds(), cost, time.Now().UnixMilli(), forceArg).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("error running token bucket %s: %w", key, err)
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// TakeTokens takes cost tokens from the bucket when there are enough of them.
+// A zero cost only checks that the bucket is not empty. When the tokens are
+// not available it returns how long until they are.
+func TakeTokens(rd *redis.Client, key string, capacity int, period time.Duration, cost int) (bool, time.Duration, error) {
+	allowed, wait, err := runTokenBucket(rd, key, capacity, period, cost, false)
+	if err != nil || allowed {
+		return allowed, 0, err
+	}
+	return false, wait, nil
+}
+
+// SpendTokens takes cost tokens from the bucket unconditionally.
+func SpendTokens(rd *redis.Client, key string, capacity int, period time.Duration, cost int) error {
+	_, _, err := runTokenBucket(rd, key, capacity, period, cost, true)
+	return err
+}
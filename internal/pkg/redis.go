@@ -208,3 +208,26 @@ func GetConversationsFromRedis(rd *redis.Client, userId int) ([]*model.Conversat
 	}
 	return conversations, nil
 }
+
+func SaveInviteToRedis(rd *redis.Client, code string, createdBy int, expiration time.Duration) error {
+	cacheKey := "invite_" + code
+	err := rd.Set(context.Background(), cacheKey, createdBy, expiration).Err()
+	if err != nil {
+		return fmt.Errorf("error saving invite to Redis: %w", err)
+	}
+	return nil
+}
+
+// RedeemInviteFromRedis deletes a single-use invite code and reports whether
+// it existed.
+func RedeemInviteFromRedis(rd *redis.Client, code string) (bool, error) {
+	cacheKey := "invite_" + code
+	_, err := rd.GetDel(context.Background(), cacheKey).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("error redeeming invite from Redis: %w", err)
+	}
+	return true, nil
+}
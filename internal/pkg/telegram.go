@@ -1,11 +1,13 @@
 package pkg
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"teo/internal/config"
 
@@ -88,8 +90,47 @@ type From struct {
 }
 
 type TelegramIncommingChat struct {
-	Message  UserMessage `json:"message"`
-	UpdateId int64       `json:"update_id"`
+	Message       UserMessage    `json:"message"`
+	EditedMessage *UserMessage   `json:"edited_message,omitempty"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+	UpdateId      int64          `json:"update_id"`
+}
+
+type CallbackQuery struct {
+	Id      string       `json:"id"`
+	From    From         `json:"from"`
+	Message *UserMessage `json:"message,omitempty"`
+	Data    string       `json:"data,omitempty"`
+}
+
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// Sender returns the user behind the update, whatever its kind.
+func (c *TelegramIncommingChat) Sender() From {
+	switch {
+	case c.CallbackQuery != nil:
+		return c.CallbackQuery.From
+	case c.EditedMessage != nil:
+		return c.EditedMessage.From
+	}
+	return c.Message.From
+}
+
+func (c *TelegramIncommingChat) ChatId() int {
+	switch {
+	case c.CallbackQuery != nil && c.CallbackQuery.Message != nil:
+		return c.CallbackQuery.Message.Chat.Id
+	case c.EditedMessage != nil:
+		return c.EditedMessage.Chat.Id
+	}
+	return c.Message.Chat.Id
 }
 
 type TelegramSendMessage struct {
@@ -107,6 +148,12 @@ type TelegramEditMessage struct {
 	ChatID           int    `json:"chat_id"`
 }
 
+type TelegramEditReplyMarkup struct {
+	ChatID      int                   `json:"chat_id"`
+	MessageID   int                   `json:"message_id"`
+	ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
 type TelegramSendMessageStatus struct {
 	Ok          bool        `json:"ok"`
 	Result      UserMessage `json:"result,omitempty"`
@@ -114,6 +161,13 @@ type TelegramSendMessageStatus struct {
 	Description string      `json:"description,omitempty"`
 }
 
+type TelegramSendMediaGroupStatus struct {
+	Ok          bool          `json:"ok"`
+	Result      []UserMessage `json:"result,omitempty"`
+	ErrorCode   int           `json:"error_code,omitempty"`
+	Description string        `json:"description,omitempty"`
+}
+
 type TelegramFileResponse struct {
 	Ok     bool `json:"ok"`
 	Result struct {
@@ -151,11 +205,41 @@ func SendTelegramMessage(chatId int, replyId int, text string, markdown bool) (*
 		body.ReplyToMessageID = replyId
 	}
 
-	if markdown {
-		body.ParseMode = "markdown"
+	if !markdown {
+		return SendTelegramRequest("sendMessage", body, chatId)
+	}
+
+	body.Text = RenderTelegram(text, config.TelegramParseMode)
+	body.ParseMode = config.TelegramParseMode
+	send, err := SendTelegramRequest("sendMessage", body, chatId)
+	if isEntityError(send, err) {
+		log.Printf("Telegram rejected %s entities, sending plain text: %v", body.ParseMode, err)
+		body.Text = text
+		body.ParseMode = ""
+		return SendTelegramRequest("sendMessage", body, chatId)
+	}
+
+	return send, err
+}
+
+// SendTelegramMarkdown sends Markdown split into as many messages as it
+// needs, and returns the status of the last one.
+func SendTelegramMarkdown(chatId int, replyId int, text string) (*TelegramSendMessageStatus, error) {
+	chunks := SplitTelegramMessage(text, config.TelegramParseMode, TelegramMessageLimit)
+	if len(chunks) == 0 {
+		return nil, errors.New("failed to sendMessage message, text is empty")
+	}
+
+	var last *TelegramSendMessageStatus
+	for _, chunk := range chunks {
+		send, err := SendTelegramMessage(chatId, replyId, chunk, true)
+		if err != nil || !send.Ok {
+			return send, err
+		}
+		last = send
 	}
 
-	return SendTelegramRequest("sendMessage", body, chatId)
+	return last, nil
 }
 
 func EditTelegramMessage(chatId int, replyId int, editMessageId int, text string, markdown bool) (*TelegramSendMessageStatus, error) {
@@ -166,15 +250,139 @@ func EditTelegramMessage(chatId int, replyId int, editMessageId int, text string
 		ChatID:           chatId,
 	}
 
-	if markdown {
-		body.ParseMode = "markdown"
+	if !markdown {
+		return SendTelegramRequest("editMessageText", body, chatId)
+	}
+
+	body.Text = RenderTelegram(text, config.TelegramParseMode)
+	body.ParseMode = config.TelegramParseMode
+	send, err := SendTelegramRequest("editMessageText", body, chatId)
+	if isEntityError(send, err) {
+		log.Printf("Telegram rejected %s entities, editing with plain text: %v", body.ParseMode, err)
+		body.Text = text
+		body.ParseMode = ""
+		return SendTelegramRequest("editMessageText", body, chatId)
+	}
+
+	return send, err
+}
+
+// SendTelegramReasoning sends the reasoning of a model as a collapsed quote.
+func SendTelegramReasoning(chatId int, replyId int, reasoning string) (*TelegramSendMessageStatus, error) {
+	body := &TelegramSendMessage{
+		Text:             RenderTelegramReasoning(reasoning, config.TelegramParseMode, TelegramMessageLimit),
+		ParseMode:        config.TelegramParseMode,
+		ReplyToMessageID: replyId,
+		ChatID:           chatId,
+	}
+
+	send, err := SendTelegramRequest("sendMessage", body, chatId)
+	if isEntityError(send, err) {
+		log.Printf("Telegram rejected %s entities, sending plain text: %v", body.ParseMode, err)
+		body.Text = reasoning
+		body.ParseMode = ""
+		return SendTelegramRequest("sendMessage", body, chatId)
+	}
+
+	return send, err
+}
+
+// EditTelegramReasoning turns an existing message into the collapsed
+// reasoning of a model.
+func EditTelegramReasoning(chatId int, replyId int, editMessageId int, reasoning string) (*TelegramSendMessageStatus, error) {
+	body := &TelegramEditMessage{
+		Text:             RenderTelegramReasoning(reasoning, config.TelegramParseMode, TelegramMessageLimit),
+		ParseMode:        config.TelegramParseMode,
+		MessageID:        editMessageId,
+		ReplyToMessageID: replyId,
+		ChatID:           chatId,
+	}
+
+	send, err := SendTelegramRequest("editMessageText", body, chatId)
+	if isEntityError(send, err) {
+		log.Printf("Telegram rejected %s entities, editing with plain text: %v", body.ParseMode, err)
+		body.Text = reasoning
+		body.ParseMode = ""
+		return SendTelegramRequest("editMessageText", body, chatId)
+	}
+
+	return send, err
+}
+
+func isEntityError(send *TelegramSendMessageStatus, err error) bool {
+	return err != nil && send != nil && send.ErrorCode == 400 && strings.Contains(send.Description, "can't parse entities")
+}
+
+func DeleteTelegramMessage(chatId int, messageId int) (bool, error) {
+	client := resty.New()
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/deleteMessage", config.BotToken)
+
+	var response struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description,omitempty"`
+	}
+	resp, err := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]interface{}{
+			"chat_id":    chatId,
+			"message_id": messageId,
+		}).
+		SetResult(&response).
+		SetError(&response).
+		Post(url)
+
+	if err != nil {
+		return false, err
+	}
+
+	if resp.StatusCode() != 200 {
+		return false, fmt.Errorf("failed to deleteMessage, %s %v", resp.Status(), response.Description)
+	}
+
+	return response.Ok, nil
+}
+
+func EditTelegramReplyMarkup(chatId int, messageId int, markup *InlineKeyboardMarkup) (*TelegramSendMessageStatus, error) {
+	body := &TelegramEditReplyMarkup{
+		ChatID:      chatId,
+		MessageID:   messageId,
+		ReplyMarkup: markup,
+	}
+
+	return SendTelegramRequest("editMessageReplyMarkup", body, chatId)
+}
+
+func AnswerTelegramCallbackQuery(callbackQueryId string, text string) error {
+	client := resty.New()
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/answerCallbackQuery", config.BotToken)
+
+	var response struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description,omitempty"`
+	}
+	resp, err := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]interface{}{
+			"callback_query_id": callbackQueryId,
+			"text":              text,
+		}).
+		SetResult(&response).
+		SetError(&response).
+		Post(url)
+
+	if err != nil {
+		return err
 	}
 
-	return SendTelegramRequest("editMessageText", body, chatId)
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("failed to answerCallbackQuery, %s %v", resp.Status(), response.Description)
+	}
+
+	return nil
 }
 
 func SendTelegramRequest(method string, message interface{}, chatId int) (*TelegramSendMessageStatus, error) {
-	if method != "editMessageText" {
+	if method == "sendMessage" {
 		sendTelegramTypingAction(chatId)
 	}
 
@@ -202,6 +410,116 @@ func SendTelegramRequest(method string, message interface{}, chatId int) (*Teleg
 	return &response, nil
 }
 
+func SendTelegramVoice(chatId int, replyId int, audio []byte) (*TelegramSendMessageStatus, error) {
+	return SendTelegramFile("sendVoice", "voice", chatId, replyId, "reply.ogg", audio, "")
+}
+
+// SendTelegramFile uploads data as a multipart form to one of the
+// send* methods (sendPhoto, sendDocument, sendAudio, sendVoice).
+func SendTelegramFile(method string, field string, chatId int, replyId int, fileName string, data []byte, caption string) (*TelegramSendMessageStatus, error) {
+	formData := map[string]string{
+		"chat_id": strconv.Itoa(chatId),
+	}
+
+	if replyId != 0 {
+		formData["reply_to_message_id"] = strconv.Itoa(replyId)
+	}
+
+	if caption != "" {
+		formData["caption"] = caption
+	}
+
+	client := resty.New()
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", config.BotToken, method)
+
+	var response TelegramSendMessageStatus
+	resp, err := client.R().
+		SetHeader("Accept", "application/json").
+		SetFormData(formData).
+		SetFileReader(field, fileName, bytes.NewReader(data)).
+		SetResult(&response).
+		SetError(&response).
+		Post(url)
+
+	if err != nil {
+		return &response, err
+	}
+
+	if resp.StatusCode() != 200 {
+		errMessage := fmt.Sprintf("failed to %s message, %s %v", method, resp.Status(), response.Description)
+		return &response, errors.New(errMessage)
+	}
+
+	return &response, nil
+}
+
+type TelegramMediaFile struct {
+	FileName string
+	Data     []byte
+}
+
+type telegramInputMedia struct {
+	Type  string `json:"type"`
+	Media string `json:"media"`
+}
+
+// SendTelegramMediaGroup sends 2-10 photos as a single album.
+func SendTelegramMediaGroup(chatId int, replyId int, photos []TelegramMediaFile) ([]UserMessage, error) {
+	formData := map[string]string{
+		"chat_id": strconv.Itoa(chatId),
+	}
+
+	if replyId != 0 {
+		formData["reply_to_message_id"] = strconv.Itoa(replyId)
+	}
+
+	client := resty.New()
+	request := client.R().SetHeader("Accept", "application/json")
+
+	media := make([]telegramInputMedia, len(photos))
+	for i, photo := range photos {
+		field := fmt.Sprintf("photo%d", i)
+		media[i] = telegramInputMedia{Type: "photo", Media: "attach://" + field}
+		request.SetFileReader(field, photo.FileName, bytes.NewReader(photo.Data))
+	}
+
+	mediaJSON, err := json.Marshal(media)
+	if err != nil {
+		return nil, err
+	}
+	formData["media"] = string(mediaJSON)
+
+	var response TelegramSendMediaGroupStatus
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMediaGroup", config.BotToken)
+	resp, err := request.
+		SetFormData(formData).
+		SetResult(&response).
+		SetError(&response).
+		Post(url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("failed to sendMediaGroup message, %s %v", resp.Status(), response.Description)
+	}
+
+	return response.Result, nil
+}
+
+// SentFileId returns the file_id Telegram assigned to an uploaded photo or
+// document, so it can be sent again without uploading.
+func SentFileId(message UserMessage) string {
+	if len(message.Photo) > 0 {
+		return message.Photo[len(message.Photo)-1].FileID
+	}
+	if message.Document != nil {
+		return message.Document.FileID
+	}
+	return ""
+}
+
 func GetFilePath(fileID string) (string, error) {
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/getFile?file_id=%s", config.BotToken, fileID)
 	client := resty.New()
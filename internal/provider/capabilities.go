@@ -0,0 +1,284 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Capabilities is what a model supports. A zero ContextLength means unknown.
+type Capabilities struct {
+	Vision        bool
+	Tools         bool
+	Reasoning     bool
+	ContextLength int
+}
+
+// CapabilityProvider is implemented by providers that can find out from their
+// API what a model supports.
+type CapabilityProvider interface {
+	DiscoverCapabilities(modelName string) (Capabilities, error)
+}
+
+// CapabilityOverride replaces what was discovered about a model. Fields that
+// are left out keep the discovered value.
+type CapabilityOverride struct {
+	Vision        *bool `json:"vision,omitempty"`
+	Tools         *bool `json:"tools,omitempty"`
+	Reasoning     *bool `json:"reasoning,omitempty"`
+	ContextLength *int  `json:"context_length,omitempty"`
+}
+
+// CapabilityTable maps model names, or prefixes of model names, to overrides.
+type CapabilityTable map[string]CapabilityOverride
+
+func LoadCapabilityTable(path string) (CapabilityTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table CapabilityTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// lookup matches by exact name first, then by the longest prefix, the same
+// way the price table does.
+func (t CapabilityTable) lookup(modelName string) (CapabilityOverride, bool) {
+	modelName = strings.TrimPrefix(modelName, "models/")
+	if override, ok := t[modelName]; ok {
+		return override, true
+	}
+
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(modelName, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return CapabilityOverride{}, false
+	}
+
+	return t[best], true
+}
+
+func (t CapabilityTable) apply(modelName string, capabilities Capabilities) Capabilities {
+	override, ok := t.lookup(modelName)
+	if !ok {
+		return capabilities
+	}
+
+	if override.Vision != nil {
+		capabilities.Vision = *override.Vision
+	}
+	if override.Tools != nil {
+		capabilities.Tools = *override.Tools
+	}
+	if override.Reasoning != nil {
+		capabilities.Reasoning = *override.Reasoning
+	}
+	if override.ContextLength != nil {
+		capabilities.ContextLength = *override.ContextLength
+	}
+	return capabilities
+}
+
+const capabilityCacheTTL = time.Hour
+
+type cachedCapabilities struct {
+	capabilities Capabilities
+	expiresAt    time.Time
+}
+
+var (
+	capabilityMu        sync.Mutex
+	capabilityOverrides CapabilityTable
+	capabilityCache     = map[string]cachedCapabilities{}
+)
+
+func SetCapabilityOverrides(table CapabilityTable) {
+	capabilityMu.Lock()
+	defer capabilityMu.Unlock()
+
+	capabilityOverrides = table
+	capabilityCache = map[string]cachedCapabilities{}
+}
+
+// ModelCapabilities tells what modelName supports on llm. It is discovered
+// from the API when the provider can, falls back to the provider defaults
+// otherwise, and the configured overrides always win.
+func ModelCapabilities(llm LLMProvider, modelName string) Capabilities {
+	if fallback, ok := llm.(*FallbackLLMProvider); ok {
+		llm = fallback.Primary()
+	}
+	modelName = llm.DefaultModel(modelName)
+	key := llm.ProviderName() + "/" + modelName
+
+	capabilityMu.Lock()
+	cached, ok := capabilityCache[key]
+	overrides := capabilityOverrides
+	capabilityMu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.capabilities
+	}
+
+	capabilities := defaultCapabilities(llm)
+	if discoverer, ok := llm.(CapabilityProvider); ok {
+		discovered, err := discoverer.DiscoverCapabilities(modelName)
+		if err != nil {
+			log.Printf("Error discovering capabilities of %s: %v", key, err)
+		} else {
+			capabilities = discovered
+		}
+	}
+	capabilities = overrides.apply(modelName, capabilities)
+
+	capabilityMu.Lock()
+	capabilityCache[key] = cachedCapabilities{capabilities: capabilities, expiresAt: time.Now().Add(capabilityCacheTTL)}
+	capabilityMu.Unlock()
+
+	return capabilities
+}
+
+// defaultCapabilities assumes the model can do what its API can, which is
+// how every model was treated before capabilities were tracked.
+func defaultCapabilities(llm LLMProvider) Capabilities {
+	if compatible, ok := llm.(*OpenAICompatibleProvider); ok {
+		return Capabilities{Vision: compatible.options.Vision, Tools: compatible.options.Tools}
+	}
+	return Capabilities{Vision: true, Tools: true}
+}
+
+// ImageInput is how a model takes the images of a user message.
+type ImageInput int
+
+const (
+	// ImageInputInline sends base64 images in Message.Images, the way Ollama
+	// and Gemini take them.
+	ImageInputInline ImageInput = iota
+	// ImageInputURL sends images as image_url content items.
+	ImageInputURL
+	// ImageInputNone means the model cannot see images, only the caption
+	// is sent.
+	ImageInputNone
+)
+
+func ImageInputOf(llm LLMProvider, modelName string) ImageInput {
+	if !ModelCapabilities(llm, modelName).Vision {
+		return ImageInputNone
+	}
+
+	if fallback, ok := llm.(*FallbackLLMProvider); ok {
+		llm = fallback.Primary()
+	}
+	if _, ok := llm.(*OpenAICompatibleProvider); ok {
+		return ImageInputURL
+	}
+	return ImageInputInline
+}
+
+func hasImages(messages []Message) bool {
+	for _, message := range messages {
+		if len(message.Images) > 0 {
+			return true
+		}
+		if items, ok := message.Content.([]ContentItem); ok {
+			for _, item := range items {
+				if item.Type == "image_url" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// withImageInput rewrites the images of messages, which were built for
+// another model, the way input takes them. Inline images become data URLs,
+// image URLs are downloaded, and both are left out for models that cannot
+// see images.
+func withImageInput(messages []Message, input ImageInput) []Message {
+	converted := make([]Message, len(messages))
+	for i, message := range messages {
+		items, hasItems := message.Content.([]ContentItem)
+		if len(message.Images) == 0 && !hasItems {
+			converted[i] = message
+			continue
+		}
+
+		text, _ := message.Content.(string)
+		attached := len(message.Images) > 0
+		var images []string
+		images = append(images, message.Images...)
+		for _, item := range items {
+			switch {
+			case item.Type == "text":
+				text = strings.TrimSpace(text + "\n" + item.Text)
+			case item.Type == "image_url" && item.ImageURL != nil:
+				attached = true
+				if input == ImageInputNone {
+					continue
+				}
+				image, err := inlineImage(item.ImageURL.URL)
+				if err != nil {
+					log.Printf("Error converting image for another model: %v", err)
+					continue
+				}
+				images = append(images, image)
+			}
+		}
+
+		message.Images = nil
+		switch input {
+		case ImageInputInline:
+			message.Content = text
+			message.Images = images
+		case ImageInputURL:
+			content := []ContentItem{{Type: "text", Text: text}}
+			for _, image := range images {
+				content = append(content, ContentItem{Type: "image_url", ImageURL: &ImageInfo{URL: "data:image/jpeg;base64," + image}})
+			}
+			message.Content = content
+		default:
+			if attached {
+				text += "\n\n[An image was attached here]"
+			}
+			message.Content = text
+		}
+		converted[i] = message
+	}
+	return converted
+}
+
+// inlineImage returns the base64 data of an image URL, which is either a
+// data URL or a file to download.
+func inlineImage(url string) (string, error) {
+	if strings.HasPrefix(url, "data:") {
+		_, data, found := strings.Cut(url, ";base64,")
+		if !found {
+			return "", fmt.Errorf("image data URL is not base64")
+		}
+		return data, nil
+	}
+
+	res, err := resty.New().SetTimeout(30 * time.Second).R().Get(url)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode() != 200 {
+		return "", fmt.Errorf("failed to fetch image, status code: %d", res.StatusCode())
+	}
+	return base64.StdEncoding.EncodeToString(res.Body()), nil
+}
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrProvidersUnavailable = errors.New("all llm providers are unavailable")
+
+// FallbackLLM is a provider and model to answer with when the ones before it
+// in the chain fail.
+type FallbackLLM struct {
+	Provider LLMProvider
+	Model    string
+}
+
+// FallbackLLMProvider answers with the configured provider and, when it
+// fails, with each fallback in order. Backends that keep failing are skipped
+// by a circuit breaker. Answers from a fallback carry its provider and model
+// in Usage. Messages are built for the primary, their images are converted
+// for fallbacks that take images another way.
+type FallbackLLMProvider struct {
+	primary   LLMProvider
+	fallbacks []FallbackLLM
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+type llmAttempt struct {
+	provider LLMProvider
+	model    string
+	fallback bool
+}
+
+func (a llmAttempt) name() string {
+	return a.provider.ProviderName() + "/" + a.provider.DefaultModel(a.model)
+}
+
+// usage marks usage as produced by attempt
+func (a llmAttempt) usage(usage *Usage) *Usage {
+	var stamped Usage
+	stamped.Add(usage)
+	stamped.Provider = a.provider.ProviderName()
+	stamped.Model = a.provider.DefaultModel(a.model)
+	return &stamped
+}
+
+// messages adapts the images of messages, which are built the way the primary
+// takes them, to a fallback that takes them another way.
+func (f *FallbackLLMProvider) messages(attempt llmAttempt, modelName string, messages []Message) []Message {
+	if !attempt.fallback || !hasImages(messages) {
+		return messages
+	}
+	input := ImageInputOf(attempt.provider, attempt.model)
+	if input == ImageInputOf(f.primary, modelName) {
+		return messages
+	}
+	return withImageInput(messages, input)
+}
+
+func NewFallbackLLMProvider(primary LLMProvider, fallbacks []FallbackLLM, threshold int, cooldown time.Duration) *FallbackLLMProvider {
+	return &FallbackLLMProvider{
+		primary:   primary,
+		fallbacks: fallbacks,
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  map[string]*circuitBreaker{},
+	}
+}
+
+// Primary returns the configured provider, for capabilities such as
+// embeddings that are not part of the chain.
+func (f *FallbackLLMProvider) Primary() LLMProvider {
+	return f.primary
+}
+
+func (f *FallbackLLMProvider) ProviderName() string {
+	return f.primary.ProviderName()
+}
+
+func (f *FallbackLLMProvider) DefaultModel(modelName string) string {
+	return f.primary.DefaultModel(modelName)
+}
+
+func (f *FallbackLLMProvider) Models() ([]string, error) {
+	return f.primary.Models()
+}
+
+func (f *FallbackLLMProvider) attempts(modelName string) []llmAttempt {
+	attempts := []llmAttempt{{provider: f.primary, model: modelName}}
+	for _, fallback := range f.fallbacks {
+		attempts = append(attempts, llmAttempt{provider: fallback.Provider, model: fallback.Model, fallback: true})
+	}
+	return attempts
+}
+
+func (f *FallbackLLMProvider) breaker(attempt llmAttempt) *circuitBreaker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := attempt.name()
+	breaker, ok := f.breakers[name]
+	if !ok {
+		breaker = newCircuitBreaker(f.threshold, f.cooldown)
+		f.breakers[name] = breaker
+	}
+	return breaker
+}
+
+func (f *FallbackLLMProvider) Chat(modelName string, messages []Message, params GenerationParams) (Message, error) {
+	var errs []error
+	for _, attempt := range f.attempts(modelName) {
+		breaker := f.breaker(attempt)
+		if !breaker.allow() {
+			errs = append(errs, fmt.Errorf("%s: circuit open", attempt.name()))
+			continue
+		}
+
+		message, err := attempt.provider.Chat(attempt.model, f.messages(attempt, modelName, messages), params)
+		if err != nil {
+			breaker.failure()
+			log.Printf("LLM backend %s failed: %v", attempt.name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", attempt.name(), err))
+			continue
+		}
+
+		breaker.success()
+		if attempt.fallback {
+			message.Usage = attempt.usage(message.Usage)
+		}
+		return message, nil
+	}
+
+	return Message{}, fmt.Errorf("%w: %w", ErrProvidersUnavailable, errors.Join(errs...))
+}
+
+func (f *FallbackLLMProvider) ChatStream(modelName string, messages []Message, params GenerationParams, callback func(Message) error) error {
+	var errs []error
+	for _, attempt := range f.attempts(modelName) {
+		breaker := f.breaker(attempt)
+		if !breaker.allow() {
+			errs = append(errs, fmt.Errorf("%s: circuit open", attempt.name()))
+			continue
+		}
+
+		emitted := false
+		err := attempt.provider.ChatStream(attempt.model, f.messages(attempt, modelName, messages), params, func(partial Message) error {
+			if text, _ := partial.Content.(string); text != "" || partial.Reasoning != "" || partial.ToolCalls != nil {
+				emitted = true
+			}
+			return callback(partial)
+		})
+		if err != nil {
+			breaker.failure()
+			log.Printf("LLM backend %s failed: %v", attempt.name(), err)
+
+			// Part of this answer was already shown, another model can't finish it
+			if emitted {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", attempt.name(), err))
+			continue
+		}
+
+		breaker.success()
+		if attempt.fallback {
+			return callback(Message{Role: "assistant", Content: "", Usage: attempt.usage(nil)})
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", ErrProvidersUnavailable, errors.Join(errs...))
+}
@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"errors"
+	"log"
+)
+
+type FallbackTTSProvider struct {
+	providers []TTSProvider
+}
+
+func NewFallbackTTSProvider(providers ...TTSProvider) TTSProvider {
+	return &FallbackTTSProvider{providers: providers}
+}
+
+func (f *FallbackTTSProvider) SpeechToText(audioFile []byte, language string) (string, error) {
+	var errs []error
+	for i, provider := range f.providers {
+		text, err := provider.SpeechToText(audioFile, language)
+		if err == nil {
+			return text, nil
+		}
+
+		log.Printf("Transcription backend %d failed: %v", i+1, err)
+		errs = append(errs, err)
+	}
+
+	return "", errors.Join(errs...)
+}
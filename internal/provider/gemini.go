@@ -8,6 +8,7 @@ import (
 	"log"
 	"strings"
 	"teo/internal/tools"
+	"teo/internal/tools/attachment"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -33,6 +34,7 @@ type GeminiPart struct {
 	InlineData       *GeminiInlineData       `json:"inline_data,omitempty"`
 	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
 	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
 }
 
 type GeminiContent struct {
@@ -76,6 +78,99 @@ type GemeniRequest struct {
 	SystemInstruction *GeminiContent           `json:"systemInstruction,omitempty"`
 	ToolConfig        *ToolConfig              `json:"toolConfig,omitempty"`
 	Tools             []map[string]interface{} `json:"tools,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig  `json:"generationConfig,omitempty"`
+}
+
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+
+	ResponseMimeType string                 `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]interface{} `json:"responseSchema,omitempty"`
+
+	ThinkingConfig *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type GeminiThinkingConfig struct {
+	IncludeThoughts bool `json:"includeThoughts"`
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+}
+
+// geminiGenerationConfig maps params to Gemini. Thinking models are asked to
+// include a summary of their thoughts, and take the effort as a token budget.
+func geminiGenerationConfig(params GenerationParams, reasoning bool) *GeminiGenerationConfig {
+	if params.IsZero() && !reasoning {
+		return nil
+	}
+
+	config := &GeminiGenerationConfig{
+		Temperature:     params.Temperature,
+		TopP:            params.TopP,
+		MaxOutputTokens: params.MaxTokens,
+		Seed:            params.Seed,
+		StopSequences:   params.Stop,
+	}
+	if params.Format != nil {
+		config.ResponseMimeType = "application/json"
+		config.ResponseSchema = geminiSchema(params.Format.Schema)
+	}
+	if reasoning {
+		config.ThinkingConfig = &GeminiThinkingConfig{
+			IncludeThoughts: true,
+			ThinkingBudget:  thinkingBudget(params.ReasoningEffort),
+		}
+	}
+	return config
+}
+
+// geminiSchema keeps the part of a JSON Schema that Gemini accepts, it
+// rejects the whole request on keywords such as additionalProperties.
+func geminiSchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+
+	result := map[string]interface{}{}
+	for key, value := range schema {
+		switch key {
+		case "type", "format", "description", "nullable", "enum", "required":
+			result[key] = value
+		case "items":
+			if items, ok := value.(map[string]interface{}); ok {
+				result[key] = geminiSchema(items)
+			}
+		case "properties":
+			properties, _ := value.(map[string]interface{})
+			converted := map[string]interface{}{}
+			for name, property := range properties {
+				property, _ := property.(map[string]interface{})
+				if converted[name] = geminiSchema(property); converted[name] == nil {
+					delete(converted, name)
+				}
+			}
+			result[key] = converted
+		}
+	}
+
+	// Dropped properties can't stay required
+	if properties, ok := result["properties"].(map[string]interface{}); ok {
+		required := []string{}
+		for _, name := range schemaStrings(result["required"]) {
+			if _, ok := properties[name]; ok {
+				required = append(required, name)
+			}
+		}
+		result["required"] = required
+	}
+
+	// Gemini rejects objects without properties, such as free-form maps
+	if properties, ok := result["properties"].(map[string]interface{}); result["type"] == "object" && (!ok || len(properties) == 0) {
+		return nil
+	}
+	return result
 }
 
 type GeminiModel struct {
@@ -86,6 +181,7 @@ type GeminiModel struct {
 	InputTokenLimit            int      `json:"inputTokenLimit"`
 	OutputTokenLimit           int      `json:"outputTokenLimit"`
 	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	Thinking                   bool     `json:"thinking,omitempty"`
 	Temperature                float64  `json:"temperature,omitempty"`
 	TopP                       float64  `json:"topP,omitempty"`
 	TopK                       int      `json:"topK,omitempty"`
@@ -96,6 +192,23 @@ type GeminiModels struct {
 	Models []GeminiModel `json:"models"`
 }
 
+type GeminiEmbedContentRequest struct {
+	Model   string        `json:"model"`
+	Content GeminiContent `json:"content"`
+}
+
+type GeminiBatchEmbedRequest struct {
+	Requests []GeminiEmbedContentRequest `json:"requests"`
+}
+
+type GeminiEmbedding struct {
+	Values []float64 `json:"values"`
+}
+
+type GeminiBatchEmbedResponse struct {
+	Embeddings []GeminiEmbedding `json:"embeddings"`
+}
+
 type GeminiProvider struct {
 	baseURL      string
 	apiKey       string
@@ -172,18 +285,25 @@ func contentToMessage(content GeminiContent) Message {
 	if role == "model" {
 		role = "assistant"
 	}
-	if content.Parts[0].FunctionCall != nil {
-		return Message{
-			Role:      role,
-			Content:   "",
-			ToolCalls: []ToolCall{},
-		}
-	} else {
-		return Message{
-			Role:    role,
-			Content: content.Parts[0].Text,
+
+	message := Message{Role: role, Content: ""}
+	var text, reasoning strings.Builder
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			message.ToolCalls = []ToolCall{}
+		case part.Thought:
+			reasoning.WriteString(part.Text)
+		default:
+			text.WriteString(part.Text)
 		}
 	}
+
+	if message.ToolCalls == nil {
+		message.Content = text.String()
+	}
+	message.Reasoning = reasoning.String()
+	return message
 }
 
 func (g *GeminiProvider) ProviderName() string {
@@ -213,15 +333,33 @@ func (g *GeminiProvider) getToolsTransform() []map[string]interface{} {
 	return flattenedTools
 }
 
+func (g *GeminiProvider) withTools(request *GemeniRequest) {
+	request.ToolConfig = &ToolConfig{
+		FunctionCallingConfig: FunctionCallingConfig{
+			Mode: "AUTO",
+		},
+	}
+	request.Tools = []map[string]interface{}{
+		{
+			"function_declarations": g.getToolsTransform(),
+		},
+	}
+}
+
 func (g *GeminiProvider) hasFunctionCall(response GeminiGenerateContent) bool {
+	return countFunctionCalls(response) > 0
+}
+
+func countFunctionCalls(response GeminiGenerateContent) int {
+	count := 0
 	for _, candidate := range response.Candidates {
 		for _, part := range candidate.Content.Parts {
 			if part.FunctionCall != nil {
-				return true
+				count++
 			}
 		}
 	}
-	return false
+	return count
 }
 
 func (g *GeminiProvider) geminiContentsToMessages(contents []GeminiContent) []Message {
@@ -252,7 +390,8 @@ func (g *GeminiProvider) geminiContentsToMessages(contents []GeminiContent) []Me
 	return messages
 }
 
-func (g *GeminiProvider) geminiToolCalls(messages []GeminiContent, parts []GeminiPart) []Message {
+func (g *GeminiProvider) geminiToolCalls(messages []GeminiContent, parts []GeminiPart, userId int) []Message {
+	var attachments []attachment.Attachment
 	for _, part := range parts {
 		if part.FunctionCall != nil {
 			functionName := part.FunctionCall.Name
@@ -262,7 +401,8 @@ func (g *GeminiProvider) geminiToolCalls(messages []GeminiContent, parts []Gemin
 				fmt.Println("Error marshaling functionArgs:", err)
 				continue
 			}
-			tool := tools.NewTools(functionName, string(argsJSON))
+			tool, toolAttachments := tools.NewTools(userId, functionName, string(argsJSON))
+			attachments = append(attachments, toolAttachments...)
 			responseTool := []GeminiContent{
 				{
 					Role:  "model",
@@ -286,25 +426,25 @@ func (g *GeminiProvider) geminiToolCalls(messages []GeminiContent, parts []Gemin
 		}
 	}
 
-	return g.geminiContentsToMessages(messages)
+	result := g.geminiContentsToMessages(messages)
+	if len(result) > 0 {
+		result[len(result)-1].Attachments = attachments
+	}
+	return result
 }
 
-func (g *GeminiProvider) Chat(modelName string, messages []Message) (Message, error) {
+func (g *GeminiProvider) Chat(modelName string, messages []Message, params GenerationParams) (Message, error) {
 	client := resty.New()
 	client.SetTimeout(120 * time.Second)
 
+	capabilities := ModelCapabilities(g, modelName)
 	request := GemeniRequest{
-		Contents: MessagesToContents(messages),
-		ToolConfig: &ToolConfig{
-			FunctionCallingConfig: FunctionCallingConfig{
-				Mode: "AUTO",
-			},
-		},
-		Tools: []map[string]interface{}{
-			{
-				"function_declarations": g.getToolsTransform(),
-			},
-		},
+		Contents:         MessagesToContents(messages),
+		GenerationConfig: geminiGenerationConfig(params, capabilities.Reasoning),
+	}
+	// Structured answers are final, they never call tools
+	if capabilities.Tools && params.Format == nil {
+		g.withTools(&request)
 	}
 
 	if len(messages) > 0 && messages[0].Role == "system" {
@@ -329,34 +469,38 @@ func (g *GeminiProvider) Chat(modelName string, messages []Message) (Message, er
 		return Message{}, fmt.Errorf("error fetching response: %v", res.String())
 	}
 
+	usage := geminiUsage(response)
 	if g.hasFunctionCall(response) {
-		respTool := g.geminiToolCalls(MessagesToContents(messages), response.Candidates[0].Content.Parts)
-		return g.Chat(modelName, respTool)
+		respTool := g.geminiToolCalls(MessagesToContents(messages), response.Candidates[0].Content.Parts, params.UserId)
+		respTool[len(respTool)-1].Attachments = append(collectAttachments(messages), respTool[len(respTool)-1].Attachments...)
+		next, err := g.Chat(modelName, respTool, params)
+		if err != nil {
+			return Message{}, err
+		}
+		return withUsage(next, usage), nil
 	}
 
 	if response.Candidates[0].FinishReason == "SAFETY" {
 		return Message{}, fmt.Errorf("SAFETY")
 	}
 
-	return contentToMessage(response.Candidates[0].Content), nil
+	message := contentToMessage(response.Candidates[0].Content)
+	message.Attachments = collectAttachments(messages)
+	return withUsage(message, usage), nil
 }
 
-func (g *GeminiProvider) ChatStream(modelName string, messages []Message, callback func(Message) error) error {
+func (g *GeminiProvider) ChatStream(modelName string, messages []Message, params GenerationParams, callback func(Message) error) error {
 	client := resty.New()
 	client.SetTimeout(120 * time.Second)
 
+	capabilities := ModelCapabilities(g, modelName)
 	request := GemeniRequest{
-		Contents: MessagesToContents(messages),
-		ToolConfig: &ToolConfig{
-			FunctionCallingConfig: FunctionCallingConfig{
-				Mode: "AUTO",
-			},
-		},
-		Tools: []map[string]interface{}{
-			{
-				"function_declarations": g.getToolsTransform(),
-			},
-		},
+		Contents:         MessagesToContents(messages),
+		GenerationConfig: geminiGenerationConfig(params, capabilities.Reasoning),
+	}
+	// Structured answers are final, they never call tools
+	if capabilities.Tools && params.Format == nil {
+		g.withTools(&request)
 	}
 
 	if len(messages) > 0 && messages[0].Role == "system" {
@@ -414,12 +558,26 @@ func (g *GeminiProvider) ChatStream(modelName string, messages []Message, callba
 		bufferJSON = ""
 
 		if g.hasFunctionCall(response) {
-			respTool := g.geminiToolCalls(MessagesToContents(messages), response.Candidates[0].Content.Parts)
-			return g.ChatStream(modelName, respTool, callback)
+			if err := emitUsage(geminiUsage(response), callback); err != nil {
+				return fmt.Errorf("error in callback: %w", err)
+			}
+			respTool := g.geminiToolCalls(MessagesToContents(messages), response.Candidates[0].Content.Parts, params.UserId)
+			if err := emitAttachments(respTool[len(respTool)-1:], callback); err != nil {
+				return fmt.Errorf("error in callback: %w", err)
+			}
+			respTool[len(respTool)-1].Attachments = nil
+			return g.ChatStream(modelName, respTool, params, callback)
 		}
 	}
 
-	return nil
+	// Every chunk reports the usage so far, the last one holds the total
+	return emitUsage(geminiUsage(response), callback)
+}
+
+func geminiUsage(response GeminiGenerateContent) Usage {
+	usage := newUsage(response.UsageMetadata.PromptTokenCount, response.UsageMetadata.CandidatesTokenCount)
+	usage.ToolCalls = countFunctionCalls(response)
+	return usage
 }
 
 func (g *GeminiProvider) Models() ([]string, error) {
@@ -457,3 +615,71 @@ func (g *GeminiProvider) geminiModels() (*GeminiModels, error) {
 
 	return &response, nil
 }
+
+func (g *GeminiProvider) DiscoverCapabilities(modelName string) (Capabilities, error) {
+	client := resty.New()
+
+	var response GeminiModel
+	res, err := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetResult(&response).
+		Get(g.baseURL + fmt.Sprintf("/v1beta/%s?key=%s", modelName, g.apiKey))
+
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("error fetching gemini model: %w", err)
+	}
+
+	if res.StatusCode() != 200 {
+		return Capabilities{}, fmt.Errorf("error fetching gemini model: %s", res.String())
+	}
+
+	// Every Gemini chat model takes images; the Gemma models served by the
+	// same API do not support function calling
+	return Capabilities{
+		Vision:        true,
+		Tools:         !strings.Contains(response.Name, "gemma"),
+		Reasoning:     response.Thinking,
+		ContextLength: response.InputTokenLimit,
+	}, nil
+}
+
+func (g *GeminiProvider) Embeddings(modelName string, inputs []string) ([][]float64, error) {
+	model := embeddingModel(g.ProviderName(), modelName)
+
+	return embedInBatches(g.ProviderName(), inputs, func(batch []string) ([][]float64, error) {
+		client := resty.New()
+		client.SetTimeout(120 * time.Second)
+
+		request := GeminiBatchEmbedRequest{}
+		for _, input := range batch {
+			request.Requests = append(request.Requests, GeminiEmbedContentRequest{
+				Model: model,
+				Content: GeminiContent{
+					Parts: []GeminiPart{{Text: input}},
+				},
+			})
+		}
+
+		var response GeminiBatchEmbedResponse
+		res, err := client.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(request).
+			SetResult(&response).
+			Post(g.baseURL + fmt.Sprintf("/v1beta/%s:batchEmbedContents?key=%s", model, g.apiKey))
+
+		if err != nil {
+			return nil, fmt.Errorf("error fetching embeddings: %w", err)
+		}
+
+		if res.StatusCode() != 200 {
+			return nil, fmt.Errorf("error fetching embeddings: %v", res.String())
+		}
+
+		embeddings := make([][]float64, len(response.Embeddings))
+		for i, embedding := range response.Embeddings {
+			embeddings[i] = embedding.Values
+		}
+
+		return embeddings, nil
+	})
+}
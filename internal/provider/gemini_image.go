@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type GeminiImageGenerationConfig struct {
+	ResponseModalities []string `json:"responseModalities"`
+}
+
+type GeminiImageRequest struct {
+	Contents         []GeminiContent             `json:"contents"`
+	GenerationConfig GeminiImageGenerationConfig `json:"generationConfig"`
+}
+
+// Responses use camelCase keys, unlike the snake_case accepted in requests.
+type GeminiImageInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type GeminiImagePart struct {
+	Text       string                 `json:"text,omitempty"`
+	InlineData *GeminiImageInlineData `json:"inlineData,omitempty"`
+}
+
+type GeminiImageResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []GeminiImagePart `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+}
+
+type GeminiImageProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *resty.Client
+}
+
+func NewGeminiImageProvider(baseURL string, apiKey string, model string, size string) ImageProvider {
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com"
+	}
+
+	if !strings.HasPrefix(model, "models/") {
+		model = "models/" + model
+	}
+
+	return &GeminiImageProvider{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		model:   model,
+		client:  resty.New().SetTimeout(180 * time.Second),
+	}
+}
+
+func (g *GeminiImageProvider) GenerateImage(prompt string) (*GeneratedImage, error) {
+	request := GeminiImageRequest{
+		Contents: []GeminiContent{
+			{Role: "user", Parts: []GeminiPart{{Text: prompt}}},
+		},
+		GenerationConfig: GeminiImageGenerationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+		},
+	}
+
+	var response GeminiImageResponse
+	resp, err := g.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(request).
+		SetResult(&response).
+		Post(g.baseURL + fmt.Sprintf("/v1beta/%s:generateContent?key=%s", g.model, g.apiKey))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to Gemini API: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("gemini api request failed with status %s: %s", resp.Status(), resp.String())
+	}
+
+	if len(response.Candidates) == 0 {
+		return nil, fmt.Errorf("gemini returned no candidates")
+	}
+
+	var text []string
+	for _, part := range response.Candidates[0].Content.Parts {
+		if part.InlineData == nil {
+			if part.Text != "" {
+				text = append(text, part.Text)
+			}
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+
+		return &GeneratedImage{
+			Data:          data,
+			MimeType:      part.InlineData.MimeType,
+			RevisedPrompt: strings.Join(text, "\n"),
+			Model:         strings.TrimPrefix(g.model, "models/"),
+		}, nil
+	}
+
+	if response.Candidates[0].FinishReason == "SAFETY" || response.Candidates[0].FinishReason == "PROHIBITED_CONTENT" {
+		return nil, fmt.Errorf("SAFETY")
+	}
+
+	return nil, fmt.Errorf("gemini returned no image: %s", strings.Join(text, "\n"))
+}
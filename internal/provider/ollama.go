@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"teo/internal/tools"
 	"time"
 
@@ -22,20 +24,82 @@ type OllamaRequest struct {
 	Messages []Message                `json:"messages"`
 	Stream   bool                     `json:"stream"`
 	Tools    []map[string]interface{} `json:"tools,omitempty"`
+	Options  *OllamaOptions           `json:"options,omitempty"`
+
+	// Format is "json", or the JSON Schema the answer must match.
+	Format interface{} `json:"format,omitempty"`
+
+	// Think is true, or an effort level for the models that take one, to
+	// have the reasoning returned apart from the answer.
+	Think interface{} `json:"think,omitempty"`
+}
+
+// OllamaMessage is a Message as Ollama returns it, with the reasoning of
+// thinking models in its own field.
+type OllamaMessage struct {
+	Message
+	Thinking string `json:"thinking,omitempty"`
+}
+
+func (m OllamaMessage) message() Message {
+	message := m.Message
+	message.Reasoning = m.Thinking
+	return message
+}
+
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// ollamaThink asks thinking models to return their reasoning separately.
+// Only gpt-oss takes an effort level, the others reject anything but a bool.
+func ollamaThink(modelName string, params GenerationParams) interface{} {
+	if strings.HasPrefix(modelName, "gpt-oss") && params.ReasoningEffort != "" {
+		return params.ReasoningEffort
+	}
+	return true
+}
+
+func ollamaFormat(format *ResponseFormat) interface{} {
+	if format == nil {
+		return nil
+	}
+	if format.Schema == nil {
+		return "json"
+	}
+	return format.Schema
+}
+
+func ollamaOptions(params GenerationParams) *OllamaOptions {
+	if params.IsZero() {
+		return nil
+	}
+
+	return &OllamaOptions{
+		Temperature: params.Temperature,
+		TopP:        params.TopP,
+		NumPredict:  params.MaxTokens,
+		Seed:        params.Seed,
+		Stop:        params.Stop,
+	}
 }
 
 type OllamaResponse struct {
-	Model              string    `json:"model"`
-	CreatedAt          time.Time `json:"created_at"`
-	Message            Message   `json:"message"`
-	DoneReason         string    `json:"done_reason"`
-	Done               bool      `json:"done"`
-	TotalDuration      int64     `json:"total_duration"`
-	LoadDuration       int64     `json:"load_duration"`
-	PromptEvalCount    int       `json:"prompt_eval_count"`
-	PromptEvalDuration int64     `json:"prompt_eval_duration"`
-	EvalCount          int       `json:"eval_count"`
-	EvalDuration       int64     `json:"eval_duration"`
+	Model              string        `json:"model"`
+	CreatedAt          time.Time     `json:"created_at"`
+	Message            OllamaMessage `json:"message"`
+	DoneReason         string        `json:"done_reason"`
+	Done               bool          `json:"done"`
+	TotalDuration      int64         `json:"total_duration"`
+	LoadDuration       int64         `json:"load_duration"`
+	PromptEvalCount    int           `json:"prompt_eval_count"`
+	PromptEvalDuration int64         `json:"prompt_eval_duration"`
+	EvalCount          int           `json:"eval_count"`
+	EvalDuration       int64         `json:"eval_duration"`
 }
 
 type OllamaModels struct {
@@ -66,7 +130,7 @@ func (o *OllamaProvider) DefaultModel(modelName string) string {
 	return modelName
 }
 
-func (o *OllamaProvider) Chat(modelName string, messages []Message) (Message, error) {
+func (o *OllamaProvider) Chat(modelName string, messages []Message, params GenerationParams) (Message, error) {
 	client := resty.New()
 	client.SetTimeout(120 * time.Second)
 	_ = o.apiKey // unused for ollama
@@ -74,8 +138,17 @@ func (o *OllamaProvider) Chat(modelName string, messages []Message) (Message, er
 	request := OllamaRequest{
 		Model:    o.DefaultModel(modelName),
 		Stream:   false,
-		Messages: messages,
-		Tools:    tools.GetTools(),
+		Messages: requestMessages(messages),
+		Options:  ollamaOptions(params),
+		Format:   ollamaFormat(params.Format),
+	}
+	capabilities := ModelCapabilities(o, modelName)
+	// Structured answers are final, they never call tools
+	if capabilities.Tools && params.Format == nil {
+		request.Tools = tools.GetTools()
+	}
+	if capabilities.Reasoning {
+		request.Think = ollamaThink(request.Model, params)
 	}
 
 	var response OllamaResponse
@@ -89,15 +162,24 @@ func (o *OllamaProvider) Chat(modelName string, messages []Message) (Message, er
 		return Message{}, fmt.Errorf("error fetching response: %v", res.String())
 	}
 
-	if response.Message.ToolCalls != nil {
-		resp_tool := toolCalls(messages, response.Message)
-		return o.Chat(modelName, resp_tool)
+	usage := newUsage(response.PromptEvalCount, response.EvalCount)
+	message := withReasoning(response.Message.message())
+	if message.ToolCalls != nil {
+		usage.ToolCalls = len(message.ToolCalls)
+		message.Reasoning = ""
+		resp_tool := toolCalls(messages, message, params.UserId)
+		next, err := o.Chat(modelName, resp_tool, params)
+		if err != nil {
+			return Message{}, err
+		}
+		return withUsage(next, usage), nil
 	}
 
-	return response.Message, nil
+	message.Attachments = collectAttachments(messages)
+	return withUsage(message, usage), nil
 }
 
-func (o *OllamaProvider) ChatStream(modelName string, messages []Message, callback func(Message) error) error {
+func (o *OllamaProvider) ChatStream(modelName string, messages []Message, params GenerationParams, callback func(Message) error) error {
 	client := resty.New()
 	client.SetTimeout(120 * time.Second)
 	_ = o.apiKey // unused for ollama
@@ -105,7 +187,12 @@ func (o *OllamaProvider) ChatStream(modelName string, messages []Message, callba
 	request := OllamaRequest{
 		Model:    o.DefaultModel(modelName),
 		Stream:   true,
-		Messages: messages,
+		Messages: requestMessages(messages),
+		Options:  ollamaOptions(params),
+		Format:   ollamaFormat(params.Format),
+	}
+	if ModelCapabilities(o, modelName).Reasoning {
+		request.Think = ollamaThink(request.Model, params)
 	}
 
 	res, _ := client.R().
@@ -117,7 +204,7 @@ func (o *OllamaProvider) ChatStream(modelName string, messages []Message, callba
 	defer res.RawBody().Close()
 
 	reader := bufio.NewReader(res.RawBody())
-	var response OllamaResponse
+	var splitter thinkSplitter
 
 	for {
 		line, err := reader.ReadBytes('\n')
@@ -133,19 +220,29 @@ func (o *OllamaProvider) ChatStream(modelName string, messages []Message, callba
 			return fmt.Errorf("error reading stream: %w", err)
 		}
 
+		var response OllamaResponse
 		err = json.Unmarshal(line, &response)
 		if err != nil {
 			return fmt.Errorf("error unmarshalling stream data: %w", err)
 		}
 
-		partialMessage := response.Message
+		partialMessage := response.Message.message()
+		content, _ := partialMessage.Content.(string)
+		reasoning, content := splitter.split(content)
+		if response.Done {
+			restReasoning, restContent := splitter.flush()
+			reasoning += restReasoning
+			content += restContent
+		}
+		partialMessage.Content = content
+		partialMessage.Reasoning += reasoning
 		err = callback(partialMessage)
 		if err != nil {
 			return fmt.Errorf("error in callback: %w", err)
 		}
 
 		if response.Done {
-			break
+			return emitUsage(newUsage(response.PromptEvalCount, response.EvalCount), callback)
 		}
 	}
 
@@ -181,3 +278,115 @@ func (o *OllamaProvider) ollamaTags() (*OllamaTagsResponse, error) {
 
 	return &response, nil
 }
+
+type OllamaShowRequest struct {
+	Model string `json:"model"`
+}
+
+type OllamaShowResponse struct {
+	Capabilities []string               `json:"capabilities"`
+	Parameters   string                 `json:"parameters"`
+	ModelInfo    map[string]interface{} `json:"model_info"`
+}
+
+func (o *OllamaProvider) DiscoverCapabilities(modelName string) (Capabilities, error) {
+	client := resty.New()
+
+	var response OllamaShowResponse
+	res, err := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(OllamaShowRequest{Model: modelName}).
+		SetResult(&response).
+		Post(o.baseURL + "/api/show")
+
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("error fetching ollama model: %w", err)
+	}
+
+	if res.StatusCode() != 200 {
+		return Capabilities{}, fmt.Errorf("error fetching ollama model: %s", res.String())
+	}
+
+	capabilities := Capabilities{ContextLength: ollamaContextLength(response)}
+
+	// Ollama releases before 0.6.4 do not report capabilities
+	if len(response.Capabilities) == 0 {
+		capabilities.Vision = true
+		capabilities.Tools = true
+		return capabilities, nil
+	}
+
+	for _, capability := range response.Capabilities {
+		switch capability {
+		case "vision":
+			capabilities.Vision = true
+		case "tools":
+			capabilities.Tools = true
+		case "thinking":
+			// Older releases leave reasoning in <think> tags instead, which
+			// are split from the answer either way
+			capabilities.Reasoning = true
+		}
+	}
+
+	return capabilities, nil
+}
+
+// ollamaContextLength prefers the num_ctx the model runs with, since Ollama
+// truncates the prompt to it, over the length the model was trained for.
+func ollamaContextLength(response OllamaShowResponse) int {
+	for _, line := range strings.Split(response.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if numCtx, err := strconv.Atoi(fields[1]); err == nil {
+				return numCtx
+			}
+		}
+	}
+
+	architecture, _ := response.ModelInfo["general.architecture"].(string)
+	if length, ok := response.ModelInfo[architecture+".context_length"].(float64); ok {
+		return int(length)
+	}
+
+	return 0
+}
+
+type OllamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OllamaEmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+func (o *OllamaProvider) Embeddings(modelName string, inputs []string) ([][]float64, error) {
+	return embedInBatches(o.ProviderName(), inputs, func(batch []string) ([][]float64, error) {
+		client := resty.New()
+		client.SetTimeout(120 * time.Second)
+
+		request := OllamaEmbedRequest{
+			Model: embeddingModel(o.ProviderName(), modelName),
+			Input: batch,
+		}
+
+		var response OllamaEmbedResponse
+		res, err := client.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(request).
+			SetResult(&response).
+			Post(o.baseURL + "/api/embed")
+
+		if err != nil {
+			return nil, fmt.Errorf("error fetching embeddings: %w", err)
+		}
+
+		if res.StatusCode() != 200 {
+			return nil, fmt.Errorf("error fetching embeddings: %v", res.String())
+		}
+
+		return response.Embeddings, nil
+	})
+}
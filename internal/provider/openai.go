@@ -3,20 +3,57 @@ package provider
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"teo/internal/tools"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 )
 
+var ErrEmbeddingsUnsupported = errors.New("llm provider does not support embeddings")
+
 type OpenAIChoice struct {
-	Index        int     `json:"index"`
-	Message      Message `json:"message"`
-	Delta        Message `json:"delta,omitempty"`
-	Logprobs     *string `json:"logprobs,omitempty"`
-	FinishReason string  `json:"finish_reason"`
+	Index        int                   `json:"index"`
+	Message      OpenAIResponseMessage `json:"message"`
+	Delta        OpenAIDelta           `json:"delta,omitempty"`
+	Logprobs     *string               `json:"logprobs,omitempty"`
+	FinishReason string                `json:"finish_reason"`
+}
+
+// OpenAIResponseMessage is a Message as an OpenAI-compatible server returns
+// it. DeepSeek and vLLM put the reasoning in reasoning_content, OpenRouter in
+// reasoning.
+type OpenAIResponseMessage struct {
+	Message
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	ReasoningText    string `json:"reasoning,omitempty"`
+}
+
+func (m OpenAIResponseMessage) message() Message {
+	message := m.Message
+	message.Reasoning = m.ReasoningContent + m.ReasoningText
+	return message
+}
+
+// OpenAIDelta is a streamed fragment of a message. Tool calls arrive in
+// pieces that are put back together by their index.
+type OpenAIDelta struct {
+	Role             string                `json:"role,omitempty"`
+	Content          interface{}           `json:"content,omitempty"`
+	ReasoningContent string                `json:"reasoning_content,omitempty"`
+	ReasoningText    string                `json:"reasoning,omitempty"`
+	ToolCalls        []OpenAIToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+type OpenAIToolCallDelta struct {
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
 }
 
 type OpenAICompletionTokensDetails struct {
@@ -30,6 +67,13 @@ type OpenAIUsage struct {
 	CompletionTokensDetails OpenAICompletionTokensDetails `json:"completion_tokens_details"`
 }
 
+// XGroq holds the Groq extensions of a chunk, which is where Groq reports the
+// usage of a stream.
+type XGroq struct {
+	ID    string       `json:"id"`
+	Usage *OpenAIUsage `json:"usage,omitempty"`
+}
+
 type OpenAIChatCompletion struct {
 	ID                string         `json:"id"`
 	Object            string         `json:"object"`
@@ -37,13 +81,50 @@ type OpenAIChatCompletion struct {
 	Model             string         `json:"model"`
 	SystemFingerprint string         `json:"system_fingerprint"`
 	Choices           []OpenAIChoice `json:"choices"`
-	Usage             OpenAIUsage    `json:"usage"`
+	Usage             *OpenAIUsage   `json:"usage,omitempty"`
+	XGroq             *XGroq         `json:"x_groq,omitempty"`
+}
+
+func (c *OpenAIChatCompletion) usage() *OpenAIUsage {
+	if c.Usage != nil {
+		return c.Usage
+	}
+	if c.XGroq != nil {
+		return c.XGroq.Usage
+	}
+	return nil
+}
+
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type OpenAIRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
+	Model               string                   `json:"model"`
+	Messages            []Message                `json:"messages"`
+	Stream              bool                     `json:"stream"`
+	StreamOptions       *OpenAIStreamOptions     `json:"stream_options,omitempty"`
+	Tools               []map[string]interface{} `json:"tools,omitempty"`
+	ToolChoice          string                   `json:"tool_choice,omitempty"`
+	Temperature         *float64                 `json:"temperature,omitempty"`
+	TopP                *float64                 `json:"top_p,omitempty"`
+	MaxTokens           *int                     `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int                     `json:"max_completion_tokens,omitempty"`
+	Seed                *int                     `json:"seed,omitempty"`
+	RandomSeed          *int                     `json:"random_seed,omitempty"`
+	Stop                []string                 `json:"stop,omitempty"`
+	ReasoningEffort     string                   `json:"reasoning_effort,omitempty"`
+	ResponseFormat      *OpenAIResponseFormat    `json:"response_format,omitempty"`
+}
+
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
 }
 
 type OpenAIModels struct {
@@ -51,89 +132,306 @@ type OpenAIModels struct {
 	Data   []OpenAIModel `json:"data"`
 }
 
+// OpenAIModel also holds the model details some servers add to the listing:
+// Mistral capabilities, OpenRouter architecture, Groq context_window and vLLM
+// max_model_len.
 type OpenAIModel struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	OwnedBy string `json:"owned_by"`
+	ID                  string                   `json:"id"`
+	Object              string                   `json:"object"`
+	Created             int64                    `json:"created"`
+	OwnedBy             string                   `json:"owned_by"`
+	MaxContextLength    int                      `json:"max_context_length,omitempty"`
+	ContextLength       int                      `json:"context_length,omitempty"`
+	ContextWindow       int                      `json:"context_window,omitempty"`
+	MaxModelLen         int                      `json:"max_model_len,omitempty"`
+	Capabilities        *OpenAIModelCapabilities `json:"capabilities,omitempty"`
+	Architecture        *OpenAIModelArchitecture `json:"architecture,omitempty"`
+	SupportedParameters []string                 `json:"supported_parameters,omitempty"`
+}
+
+type OpenAIModelCapabilities struct {
+	FunctionCalling bool `json:"function_calling"`
+	Vision          bool `json:"vision"`
+}
+
+type OpenAIModelArchitecture struct {
+	InputModalities []string `json:"input_modalities"`
+}
+
+type OpenAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OpenAIEmbeddingData struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+type OpenAIEmbeddingResponse struct {
+	Object string                `json:"object"`
+	Data   []OpenAIEmbeddingData `json:"data"`
+	Model  string                `json:"model"`
+	Usage  OpenAIUsage           `json:"usage"`
+}
+
+// OpenAICompatibleOptions describes a server that speaks the OpenAI chat
+// completions API, such as OpenAI itself, Groq, Mistral, vLLM, LM Studio,
+// OpenRouter or llama.cpp.
+type OpenAICompatibleOptions struct {
+	Name string
+
+	// PathPrefix goes between the base URL and the endpoint, "/v1" when empty.
+	PathPrefix string
+
+	// Headers are sent with every request, e.g. HTTP-Referer for OpenRouter.
+	Headers map[string]string
+
+	// Models replaces the /models listing, for servers without one.
+	Models []string
+
+	Tools       bool
+	Vision      bool
+	StreamUsage bool
+	Embeddings  bool
+
+	// JSONSchema sends structured output requests as json_schema. Servers
+	// without it get json_object, and the schema only in the prompt.
+	JSONSchema bool
+
+	// MaxCompletionTokens sends the token limit as max_completion_tokens,
+	// which OpenAI requires for its reasoning models, instead of max_tokens.
+	MaxCompletionTokens bool
+
+	// RandomSeed sends the seed as random_seed, the name Mistral uses, since
+	// it rejects the seed field.
+	RandomSeed bool
+}
+
+var openAIOptions = OpenAICompatibleOptions{
+	Name:                "openai",
+	Tools:               true,
+	Vision:              true,
+	StreamUsage:         true,
+	Embeddings:          true,
+	JSONSchema:          true,
+	MaxCompletionTokens: true,
+}
+
+// Groq reports the usage of a stream in x_groq and rejects stream_options.
+// Most of its models are text only, the vision ones are enabled in the
+// capability overrides.
+var groqOptions = OpenAICompatibleOptions{
+	Name:  "groq",
+	Tools: true,
+}
+
+// Mistral reports the usage of a stream in its last chunk.
+var mistralOptions = OpenAICompatibleOptions{
+	Name:       "mistral",
+	Tools:      true,
+	Vision:     true,
+	Embeddings: true,
+	JSONSchema: true,
+	RandomSeed: true,
 }
 
-type OpenAIProvider struct {
+type OpenAICompatibleProvider struct {
 	baseURL      string
 	apiKey       string
 	defaultModel string
+	options      OpenAICompatibleOptions
 }
 
-func NewOpenAIProvider(baseURL string, apiKey string, defaultModel string) LLMProvider {
-	return &OpenAIProvider{
-		baseURL:      baseURL,
+func NewOpenAICompatibleProvider(baseURL string, apiKey string, defaultModel string, options OpenAICompatibleOptions) LLMProvider {
+	if options.PathPrefix == "" {
+		options.PathPrefix = "/v1"
+	}
+
+	return &OpenAICompatibleProvider{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
 		apiKey:       apiKey,
 		defaultModel: defaultModel,
+		options:      options,
 	}
 }
 
-func (o *OpenAIProvider) ProviderName() string {
-	return "openai"
+func NewOpenAIProvider(baseURL string, apiKey string, defaultModel string) LLMProvider {
+	return NewOpenAICompatibleProvider(baseURL, apiKey, defaultModel, openAIOptions)
+}
+
+func NewGroqProvider(baseURL string, apiKey string, defaultModel string) LLMProvider {
+	return NewOpenAICompatibleProvider(baseURL, apiKey, defaultModel, groqOptions)
 }
 
-func (o *OpenAIProvider) DefaultModel(modelName string) string {
+func NewMistralProvider(baseURL string, apiKey string, defaultModel string) LLMProvider {
+	return NewOpenAICompatibleProvider(baseURL, apiKey, defaultModel, mistralOptions)
+}
+
+// RegisterOpenAICompatible makes an OpenAI-compatible server available as an
+// LLM provider under options.Name. The same kind of server can be registered
+// several times under different names.
+func RegisterOpenAICompatible(options OpenAICompatibleOptions, defaultModel string) {
+	LLMproviderFactories[options.Name] = func(baseURL string, apiKey string, defaultModel string) LLMProvider {
+		return NewOpenAICompatibleProvider(baseURL, apiKey, defaultModel, options)
+	}
+	defaultLLMModels[options.Name] = defaultModel
+}
+
+func (o *OpenAICompatibleProvider) ProviderName() string {
+	return o.options.Name
+}
+
+func (o *OpenAICompatibleProvider) DefaultModel(modelName string) string {
 	if modelName == "" {
 		return o.defaultModel
 	}
 	return modelName
 }
 
-func (o *OpenAIProvider) Chat(modelName string, messages []Message) (Message, error) {
+func (o *OpenAICompatibleProvider) request() *resty.Request {
 	client := resty.New()
 	client.SetTimeout(120 * time.Second)
 
+	request := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(o.options.Headers)
+
+	// Local servers such as llama.cpp run without a key
+	if o.apiKey != "" {
+		request.SetHeader("Authorization", fmt.Sprintf("Bearer %s", o.apiKey))
+	}
+
+	return request
+}
+
+func (o *OpenAICompatibleProvider) url(endpoint string) string {
+	return o.baseURL + o.options.PathPrefix + endpoint
+}
+
+func (o *OpenAICompatibleProvider) chatRequest(modelName string, messages []Message, params GenerationParams, stream bool) OpenAIRequest {
 	request := OpenAIRequest{
-		Model:    o.DefaultModel(modelName),
-		Stream:   false,
-		Messages: messages,
+		Model:       o.DefaultModel(modelName),
+		Stream:      stream,
+		Messages:    requestMessages(messages),
+		Temperature: params.Temperature,
+		TopP:        params.TopP,
+		Stop:        params.Stop,
+	}
+
+	if o.options.RandomSeed {
+		request.RandomSeed = params.Seed
+	} else {
+		request.Seed = params.Seed
 	}
 
+	if o.options.MaxCompletionTokens {
+		request.MaxCompletionTokens = params.MaxTokens
+	} else {
+		request.MaxTokens = params.MaxTokens
+	}
+
+	capabilities := ModelCapabilities(o, modelName)
+	// Structured answers are final, they never call tools
+	if capabilities.Tools && params.Format == nil {
+		request.Tools = tools.GetTools()
+		request.ToolChoice = "auto"
+	}
+	request.ResponseFormat = o.responseFormat(params.Format)
+
+	// Models that don't reason reject the parameter
+	if capabilities.Reasoning {
+		request.ReasoningEffort = params.ReasoningEffort
+	}
+
+	// Adds a final chunk without choices that reports the usage
+	if stream && o.options.StreamUsage {
+		request.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+
+	return request
+}
+
+func (o *OpenAICompatibleProvider) responseFormat(format *ResponseFormat) *OpenAIResponseFormat {
+	if format == nil {
+		return nil
+	}
+	if format.Schema == nil || !o.options.JSONSchema {
+		return &OpenAIResponseFormat{Type: "json_object"}
+	}
+
+	name := format.Name
+	if name == "" {
+		name = "response"
+	}
+	return &OpenAIResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &OpenAIJSONSchema{Name: name, Schema: format.Schema},
+	}
+}
+
+func (o *OpenAICompatibleProvider) Chat(modelName string, messages []Message, params GenerationParams) (Message, error) {
 	var response OpenAIChatCompletion
-	res, _ := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Authorization", fmt.Sprintf("Bearer %s", o.apiKey)).
-		SetBody(request).
+	res, err := o.request().
+		SetBody(o.chatRequest(modelName, messages, params, false)).
 		SetResult(&response).
-		Post(o.baseURL + "/v1/chat/completions")
+		Post(o.url("/chat/completions"))
+
+	if err != nil {
+		return Message{}, fmt.Errorf("error fetching response: %w", err)
+	}
 
 	if res.StatusCode() != 200 {
-		return Message{}, fmt.Errorf("error fetching response: %v", res.String())
+		return Message{}, fmt.Errorf("error fetching response: %s", res.String())
 	}
 
-	return response.Choices[0].Message, nil
-}
+	if len(response.Choices) == 0 {
+		return Message{}, fmt.Errorf("error fetching response: no choices returned")
+	}
 
-func (o *OpenAIProvider) ChatStream(modelName string, messages []Message, callback func(Message) error) error {
-	client := resty.New()
-	client.SetTimeout(120 * time.Second)
+	var usage Usage
+	if u := response.usage(); u != nil {
+		usage = newUsage(u.PromptTokens, u.CompletionTokens)
+	}
 
-	request := OpenAIRequest{
-		Model:    o.DefaultModel(modelName),
-		Stream:   true,
-		Messages: messages,
+	message := withReasoning(response.Choices[0].Message.message())
+	if len(message.ToolCalls) > 0 {
+		usage.ToolCalls = len(message.ToolCalls)
+		message.Reasoning = ""
+		resp_tool := toolCalls(messages, message, params.UserId)
+		next, err := o.Chat(modelName, resp_tool, params)
+		if err != nil {
+			return Message{}, err
+		}
+		return withUsage(next, usage), nil
 	}
 
-	res, _ := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Authorization", fmt.Sprintf("Bearer %s", o.apiKey)).
-		SetBody(request).
+	message.Attachments = collectAttachments(messages)
+	return withUsage(message, usage), nil
+}
+
+func (o *OpenAICompatibleProvider) ChatStream(modelName string, messages []Message, params GenerationParams, callback func(Message) error) error {
+	res, err := o.request().
+		SetBody(o.chatRequest(modelName, messages, params, true)).
 		SetDoNotParseResponse(true).
-		Post(o.baseURL + "/v1/chat/completions")
+		Post(o.url("/chat/completions"))
+
+	if err != nil {
+		return fmt.Errorf("error fetching stream response: %w", err)
+	}
 
 	defer res.RawBody().Close()
 
 	reader := bufio.NewReader(res.RawBody())
-	var response OpenAIChatCompletion
+	var calls []ToolCall
+	var usage Usage
+	var splitter thinkSplitter
 	for {
 		line, err := reader.ReadString('\n')
 
 		if res.StatusCode() != 200 {
-			return fmt.Errorf("error fetching stream response: %v", string(line))
+			return fmt.Errorf("error fetching stream response: %v", line)
 		}
 
 		if err != nil {
@@ -144,36 +442,108 @@ func (o *OpenAIProvider) ChatStream(modelName string, messages []Message, callba
 		}
 
 		line = strings.TrimSpace(line)
-		if line == "[DONE]" {
-			break
-		}
-
 		if !strings.HasPrefix(line, "data: ") {
 			continue
 		}
 
 		jsonData := strings.TrimPrefix(line, "data: ")
+		if jsonData == "[DONE]" {
+			break
+		}
 
+		var response OpenAIChatCompletion
 		err = json.Unmarshal([]byte(jsonData), &response)
 		if err != nil {
 			return fmt.Errorf("error unmarshalling stream data: %w", err)
 		}
 
-		partialMessage := response.Choices[0].Delta
+		// Depending on the server the usage comes with the finishing chunk,
+		// in x_groq or in a last chunk without choices
+		if u := response.usage(); u != nil {
+			usage = newUsage(u.PromptTokens, u.CompletionTokens)
+		}
+
+		if len(response.Choices) == 0 {
+			continue
+		}
+
+		delta := response.Choices[0].Delta
+		calls = mergeToolCallDeltas(calls, delta.ToolCalls)
+
+		content, _ := delta.Content.(string)
+		reasoning, content := splitter.split(content)
+		partialMessage := Message{
+			Role:      delta.Role,
+			Content:   content,
+			Reasoning: delta.ReasoningContent + delta.ReasoningText + reasoning,
+		}
+		if len(delta.ToolCalls) > 0 {
+			partialMessage.ToolCalls = calls
+		}
 		err = callback(partialMessage)
 		if err != nil {
 			return fmt.Errorf("error in callback: %w", err)
 		}
+	}
 
-		if response.Choices[0].FinishReason == "stop" {
-			break
+	if reasoning, content := splitter.flush(); reasoning != "" || content != "" {
+		if err := callback(Message{Role: "assistant", Content: content, Reasoning: reasoning}); err != nil {
+			return fmt.Errorf("error in callback: %w", err)
+		}
+	}
+
+	usage.ToolCalls = len(calls)
+	if err := emitUsage(usage, callback); err != nil {
+		return fmt.Errorf("error in callback: %w", err)
+	}
+
+	if len(calls) > 0 {
+		resp_tool := toolCalls(messages, Message{Role: "assistant", Content: "", ToolCalls: calls}, params.UserId)
+		if err := emitAttachments(resp_tool[len(messages):], callback); err != nil {
+			return fmt.Errorf("error in callback: %w", err)
 		}
+		return o.ChatStream(modelName, resp_tool, params, callback)
 	}
 
 	return nil
 }
 
-func (o *OpenAIProvider) Models() ([]string, error) {
+// mergeToolCallDeltas puts streamed tool calls back together. OpenAI splits
+// the arguments over many chunks tied by index, while servers that send whole
+// calls may leave the index out, in which case the position in the chunk is
+// used instead.
+func mergeToolCallDeltas(calls []ToolCall, deltas []OpenAIToolCallDelta) []ToolCall {
+	for i, delta := range deltas {
+		index := i
+		if delta.Index != nil {
+			index = *delta.Index
+		}
+
+		for len(calls) <= index {
+			calls = append(calls, ToolCall{Type: "function", Function: FunctionCall{Arguments: ""}})
+		}
+
+		call := &calls[index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
+		}
+		call.Function.Name += delta.Function.Name
+		if delta.Function.Arguments != nil {
+			call.Function.Arguments = argsToString(call.Function.Arguments) + argsToString(delta.Function.Arguments)
+		}
+	}
+
+	return calls
+}
+
+func (o *OpenAICompatibleProvider) Models() ([]string, error) {
+	if len(o.options.Models) > 0 {
+		return o.options.Models, nil
+	}
+
 	response, err := o.openAIModels()
 	if err != nil {
 		return nil, err
@@ -187,19 +557,102 @@ func (o *OpenAIProvider) Models() ([]string, error) {
 	return models, nil
 }
 
-func (o *OpenAIProvider) openAIModels() (*OpenAIModels, error) {
-	client := resty.New()
-
+func (o *OpenAICompatibleProvider) openAIModels() (*OpenAIModels, error) {
 	var response OpenAIModels
-	res, _ := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Authorization", fmt.Sprintf("Bearer %s", o.apiKey)).
+	res, err := o.request().
 		SetResult(&response).
-		Get(o.baseURL + "/v1/models")
+		Get(o.url("/models"))
+
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s models: %w", o.ProviderName(), err)
+	}
 
 	if res.StatusCode() != 200 {
-		return nil, fmt.Errorf("error fetching openai models: %s", res.String())
+		return nil, fmt.Errorf("error fetching %s models: %s", o.ProviderName(), res.String())
 	}
 
 	return &response, nil
 }
+
+// DiscoverCapabilities refines the configured flags with what the /models
+// listing says about the model, for the servers that say anything.
+func (o *OpenAICompatibleProvider) DiscoverCapabilities(modelName string) (Capabilities, error) {
+	capabilities := Capabilities{Vision: o.options.Vision, Tools: o.options.Tools}
+	if len(o.options.Models) > 0 {
+		return capabilities, nil
+	}
+
+	response, err := o.openAIModels()
+	if err != nil {
+		return capabilities, err
+	}
+
+	for _, model := range response.Data {
+		if model.ID != modelName {
+			continue
+		}
+
+		for _, length := range []int{model.MaxContextLength, model.ContextLength, model.ContextWindow, model.MaxModelLen} {
+			if length > 0 {
+				capabilities.ContextLength = length
+				break
+			}
+		}
+
+		// The API flags stay the upper bound, a model cannot use what the
+		// server does not accept
+		if model.Capabilities != nil {
+			capabilities.Vision = capabilities.Vision && model.Capabilities.Vision
+			capabilities.Tools = capabilities.Tools && model.Capabilities.FunctionCalling
+		}
+		if model.Architecture != nil {
+			capabilities.Vision = capabilities.Vision && slices.Contains(model.Architecture.InputModalities, "image")
+		}
+		if model.SupportedParameters != nil {
+			capabilities.Tools = capabilities.Tools && slices.Contains(model.SupportedParameters, "tools")
+			capabilities.Reasoning = slices.Contains(model.SupportedParameters, "reasoning")
+		}
+		break
+	}
+
+	return capabilities, nil
+}
+
+func (o *OpenAICompatibleProvider) Embeddings(modelName string, inputs []string) ([][]float64, error) {
+	if !o.options.Embeddings {
+		return nil, ErrEmbeddingsUnsupported
+	}
+
+	return embedInBatches(o.ProviderName(), inputs, func(batch []string) ([][]float64, error) {
+		request := OpenAIEmbeddingRequest{
+			Model: embeddingModel(o.ProviderName(), modelName),
+			Input: batch,
+		}
+
+		var response OpenAIEmbeddingResponse
+		res, err := o.request().
+			SetBody(request).
+			SetResult(&response).
+			Post(o.url("/embeddings"))
+
+		if err != nil {
+			return nil, fmt.Errorf("error fetching embeddings: %w", err)
+		}
+
+		if res.StatusCode() != 200 {
+			return nil, fmt.Errorf("error fetching embeddings: %v", res.String())
+		}
+
+		return openAIEmbeddingVectors(response.Data), nil
+	})
+}
+
+func openAIEmbeddingVectors(data []OpenAIEmbeddingData) [][]float64 {
+	embeddings := make([][]float64, len(data))
+	for _, item := range data {
+		if item.Index >= 0 && item.Index < len(embeddings) {
+			embeddings[item.Index] = item.Embedding
+		}
+	}
+	return embeddings
+}
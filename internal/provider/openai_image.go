@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type OpenAIImageRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n"`
+	Size           string `json:"size,omitempty"`
+	ResponseFormat string `json:"response_format,omitempty"`
+}
+
+type OpenAIImageData struct {
+	B64JSON       string `json:"b64_json,omitempty"`
+	URL           string `json:"url,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+type OpenAIImageResponse struct {
+	Data []OpenAIImageData `json:"data"`
+}
+
+type OpenAIImageProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	size    string
+	client  *resty.Client
+}
+
+func NewOpenAIImageProvider(baseURL string, apiKey string, model string, size string) ImageProvider {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
+	}
+
+	return &OpenAIImageProvider{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		model:   model,
+		size:    size,
+		client:  resty.New().SetTimeout(180 * time.Second),
+	}
+}
+
+func (o *OpenAIImageProvider) GenerateImage(prompt string) (*GeneratedImage, error) {
+	request := OpenAIImageRequest{
+		Model:  o.model,
+		Prompt: prompt,
+		N:      1,
+		Size:   o.size,
+	}
+
+	// gpt-image models always answer with base64 and reject response_format
+	if strings.HasPrefix(o.model, "dall-e") {
+		request.ResponseFormat = "b64_json"
+	}
+
+	var response OpenAIImageResponse
+	resp, err := o.client.R().
+		SetAuthToken(o.apiKey).
+		SetHeader("Content-Type", "application/json").
+		SetBody(request).
+		SetResult(&response).
+		Post(o.baseURL + "/v1/images/generations")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to image API: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("image api request failed with status %s: %s", resp.Status(), resp.String())
+	}
+
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("image api returned no images")
+	}
+
+	image := response.Data[0]
+	var data []byte
+	if image.B64JSON != "" {
+		data, err = base64.StdEncoding.DecodeString(image.B64JSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+	} else if image.URL != "" {
+		download, err := o.client.R().Get(image.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download image: %w", err)
+		}
+		if download.StatusCode() != http.StatusOK {
+			return nil, fmt.Errorf("failed to download image, status %s", download.Status())
+		}
+		data = download.Body()
+	} else {
+		return nil, fmt.Errorf("image api returned an empty image")
+	}
+
+	return &GeneratedImage{
+		Data:          data,
+		MimeType:      http.DetectContentType(data),
+		RevisedPrompt: image.RevisedPrompt,
+		Model:         o.model,
+	}, nil
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type OpenAISpeechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format"`
+}
+
+type OpenAISpeechProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	voice   string
+	client  *resty.Client
+}
+
+func NewOpenAISpeechProvider(baseURL string, apiKey string, model string, voice string) SpeechProvider {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
+	}
+
+	return &OpenAISpeechProvider{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		model:   model,
+		voice:   voice,
+		client:  resty.New().SetTimeout(120 * time.Second),
+	}
+}
+
+func (o *OpenAISpeechProvider) TextToSpeech(text string) ([]byte, string, error) {
+	request := OpenAISpeechRequest{
+		Model:          o.model,
+		Input:          text,
+		Voice:          o.voice,
+		ResponseFormat: "opus",
+	}
+
+	resp, err := o.client.R().
+		SetAuthToken(o.apiKey).
+		SetHeader("Content-Type", "application/json").
+		SetBody(request).
+		Post(o.baseURL + "/v1/audio/speech")
+
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to make request to speech API: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, "", fmt.Errorf("speech api request failed with status %s: %s", resp.Status(), resp.String())
+	}
+
+	return resp.Body(), "ogg", nil
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type TranscriptionResponse struct {
+	Text string `json:"text"`
+}
+
+// OpenAITTSProvider talks to any OpenAI-compatible /v1/audio/transcriptions
+// endpoint: OpenAI, Groq, faster-whisper-server or whisper.cpp server.
+type OpenAITTSProvider struct {
+	baseURL      string
+	apiKey       string
+	defaultModel string
+	client       *resty.Client
+}
+
+func NewOpenAITTSProvider(baseURL string, apiKey string, defaultModel string) TTSProvider {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
+	}
+
+	return &OpenAITTSProvider{
+		baseURL:      baseURL,
+		apiKey:       apiKey,
+		defaultModel: defaultModel,
+		client:       resty.New().SetTimeout(300 * time.Second),
+	}
+}
+
+func NewGroqTTSProvider(baseURL string, apiKey string, defaultModel string) TTSProvider {
+	if baseURL == "" {
+		baseURL = "https://api.groq.com/openai"
+	}
+
+	return NewOpenAITTSProvider(baseURL, apiKey, defaultModel)
+}
+
+func (o *OpenAITTSProvider) SpeechToText(audioFile []byte, language string) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", "audio.ogg")
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	_, err = io.Copy(part, bytes.NewReader(audioFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to copy audio data to form: %w", err)
+	}
+
+	err = writer.WriteField("model", o.defaultModel)
+	if err != nil {
+		return "", fmt.Errorf("failed to write model field: %w", err)
+	}
+
+	if language != "" {
+		err = writer.WriteField("language", language)
+		if err != nil {
+			return "", fmt.Errorf("failed to write language field: %w", err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	resp, err := o.client.R().
+		SetAuthToken(o.apiKey).
+		SetHeader("Content-Type", writer.FormDataContentType()).
+		SetBody(body).
+		Post(o.baseURL + "/v1/audio/transcriptions")
+
+	if err != nil {
+		return "", fmt.Errorf("failed to make request to transcription API: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("transcription api request failed with status %s: %s", resp.Status(), resp.String())
+	}
+
+	var transcriptionResponse TranscriptionResponse
+	err = json.Unmarshal(resp.Body(), &transcriptionResponse)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal transcription API response: %w", err)
+	}
+
+	return transcriptionResponse.Text, nil
+}
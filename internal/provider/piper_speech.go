@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PiperSpeechProvider synthesizes speech locally with the piper CLI
+// (https://github.com/rhasspy/piper). The model is a path to a voice .onnx file.
+type PiperSpeechProvider struct {
+	binary string
+	model  string
+}
+
+func NewPiperSpeechProvider(baseURL string, apiKey string, model string, voice string) SpeechProvider {
+	binary := baseURL // the piper binary path, reusing the base URL setting
+	if binary == "" {
+		binary = "piper"
+	}
+
+	return &PiperSpeechProvider{
+		binary: binary,
+		model:  model,
+	}
+}
+
+func (p *PiperSpeechProvider) TextToSpeech(text string) ([]byte, string, error) {
+	output, err := os.CreateTemp("", "teo-speech-*.wav")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	cmd := exec.Command(p.binary, "--model", p.model, "--output_file", output.Name())
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, "", fmt.Errorf("piper failed: %v %s", err, stderr.String())
+	}
+
+	audio, err := os.ReadFile(output.Name())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read piper output: %w", err)
+	}
+
+	return audio, "wav", nil
+}
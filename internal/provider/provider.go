@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"teo/internal/config"
 	"teo/internal/tools"
+	"teo/internal/tools/attachment"
 )
 
 type Message struct {
@@ -15,6 +16,35 @@ type Message struct {
 	Images     []string    `json:"images,omitempty" bson:"images,omitempty"`
 	ToolCalls  []ToolCall  `json:"tool_calls,omitempty" bson:"tool_calls,omitempty"`
 	ToolCallID string      `json:"tool_call_id,omitempty" bson:"tool_call_id,omitempty"`
+
+	// MessageId is the Telegram message this entry came from or was sent as.
+	// It is kept for the conversation cache and never sent to a provider.
+	MessageId int `json:"message_id,omitempty" bson:"messageId,omitempty"`
+
+	// Usage is the token usage of the completion that produced this entry.
+	// Like MessageId it is never sent to a provider.
+	Usage *Usage `json:"usage,omitempty" bson:"usage,omitempty"`
+
+	// Reasoning is the thinking a reasoning model did before its answer. It
+	// is never sent back to a provider.
+	Reasoning string `json:"-" bson:"-"`
+
+	Attachments []attachment.Attachment `json:"-" bson:"-"`
+}
+
+// Usage is the token usage reported by a provider, normalized across APIs.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens" bson:"promptTokens"`
+	CompletionTokens int `json:"completion_tokens" bson:"completionTokens"`
+	TotalTokens      int `json:"total_tokens" bson:"totalTokens"`
+
+	// ToolCalls counts the tools executed while producing the answer.
+	ToolCalls int `json:"tool_calls,omitempty" bson:"toolCalls,omitempty"`
+
+	// Provider and Model are only set when a fallback answered instead of
+	// the requested model.
+	Provider string `json:"provider,omitempty" bson:"provider,omitempty"`
+	Model    string `json:"model,omitempty" bson:"model,omitempty"`
 }
 
 type ToolCall struct {
@@ -38,20 +68,67 @@ type ImageInfo struct {
 	URL string `json:"url,omitempty"`
 }
 
+// GenerationParams tune how a model samples its answer. Nil fields and an
+// empty Stop leave the provider default in place.
+type GenerationParams struct {
+	Temperature *float64 `json:"temperature,omitempty" bson:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty" bson:"topP,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty" bson:"maxTokens,omitempty"`
+	Seed        *int     `json:"seed,omitempty" bson:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty" bson:"stop,omitempty"`
+
+	// ReasoningEffort is low, medium or high, and only reaches models that
+	// reason.
+	ReasoningEffort string `json:"reasoning_effort,omitempty" bson:"reasoningEffort,omitempty"`
+
+	// Format asks for a JSON answer. It is set by internal calls through
+	// ChatJSON, never by users, so it is not stored.
+	Format *ResponseFormat `json:"-" bson:"-"`
+
+	// UserId is the user the request is made for, the tools the model calls
+	// act on their data. It is set by the bot, never by the model.
+	UserId int `json:"-" bson:"-"`
+}
+
+func (p GenerationParams) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == nil && p.Seed == nil && len(p.Stop) == 0 && p.ReasoningEffort == "" && p.Format == nil
+}
+
 type LLMProvider interface {
 	ProviderName() string
-	Chat(modelName string, messages []Message) (Message, error)
-	ChatStream(modelName string, messages []Message, callback func(Message) error) error
+	Chat(modelName string, messages []Message, params GenerationParams) (Message, error)
+	ChatStream(modelName string, messages []Message, params GenerationParams, callback func(Message) error) error
 	Models() ([]string, error)
 	DefaultModel(modelName string) string
 }
 
+type EmbeddingProvider interface {
+	Embeddings(modelName string, inputs []string) ([][]float64, error)
+}
+
 type TTSProvider interface {
-	SpeechToText(audioFile []byte) (string, error)
+	SpeechToText(audioFile []byte, language string) (string, error)
+}
+
+type SpeechProvider interface {
+	TextToSpeech(text string) ([]byte, string, error)
+}
+
+type GeneratedImage struct {
+	Data          []byte
+	MimeType      string
+	RevisedPrompt string
+	Model         string
+}
+
+type ImageProvider interface {
+	GenerateImage(prompt string) (*GeneratedImage, error)
 }
 
 type factoryLLM func(baseURL string, apiKey string, defaultModel string) LLMProvider
-type factoryTTS func(apiKey string, defaultModel string) TTSProvider
+type factoryTTS func(baseURL string, apiKey string, defaultModel string) TTSProvider
+type factorySpeech func(baseURL string, apiKey string, model string, voice string) SpeechProvider
+type factoryImage func(baseURL string, apiKey string, model string, size string) ImageProvider
 
 var LLMproviderFactories = map[string]factoryLLM{
 	"ollama":  NewOllamaProvider,
@@ -69,32 +146,143 @@ var defaultLLMModels = map[string]string{
 	"mistral": "ministral-3b-latest",
 }
 
+var defaultEmbeddingModels = map[string]string{
+	"ollama":  "nomic-embed-text",
+	"openai":  "text-embedding-3-small",
+	"gemini":  "models/text-embedding-004",
+	"mistral": "mistral-embed",
+}
+
+var embeddingBatchSizes = map[string]int{
+	"ollama":  64,
+	"openai":  512,
+	"gemini":  100,
+	"mistral": 128,
+}
+
 var TTSproviderFactories = map[string]factoryTTS{
-	"groq": NewGroqTTSProvider,
+	"groq":   NewGroqTTSProvider,
+	"openai": NewOpenAITTSProvider,
 }
 
 var defaultTTSMModels = map[string]string{
-	"groq": "whisper-large-v3-turbo",
+	"groq":   "whisper-large-v3-turbo",
+	"openai": "whisper-1",
+}
+
+var SpeechProviderFactories = map[string]factorySpeech{
+	"openai": NewOpenAISpeechProvider,
+	"piper":  NewPiperSpeechProvider,
+}
+
+var defaultSpeechModels = map[string]string{
+	"openai": "tts-1",
+	"piper":  "en_US-lessac-medium.onnx",
+}
+
+var defaultSpeechVoices = map[string]string{
+	"openai": "alloy",
+}
+
+var ImageProviderFactories = map[string]factoryImage{
+	"openai": NewOpenAIImageProvider,
+	"gemini": NewGeminiImageProvider,
+}
+
+var defaultImageModels = map[string]string{
+	"openai": "dall-e-3",
+	"gemini": "models/gemini-2.5-flash-image",
 }
 
 func CreateLLMProvider(providerName string, apiKey string) (LLMProvider, error) {
+	return NewLLMProvider(providerName, config.LLMProviderBaseURL, apiKey)
+}
+
+func NewLLMProvider(providerName string, baseURL string, apiKey string) (LLMProvider, error) {
 	factory, exists := LLMproviderFactories[providerName]
 	if !exists {
 		return nil, errors.New("unknown llm provider")
 	}
 	defaultModel := defaultLLMModels[providerName]
 
-	return factory(config.LLMProviderBaseURL, apiKey, defaultModel), nil
+	return factory(baseURL, apiKey, defaultModel), nil
 }
 
-func CreateTTSProvider(providerName string, apiKey string) (TTSProvider, error) {
+func CreateTTSProvider(providerName string, baseURL string, apiKey string, model string) (TTSProvider, error) {
 	factory, exists := TTSproviderFactories[providerName]
 	if !exists {
 		return nil, errors.New("unknown tts provider")
 	}
-	defaultModel := defaultTTSMModels[providerName]
 
-	return factory(apiKey, defaultModel), nil
+	if model == "" {
+		model = defaultTTSMModels[providerName]
+	}
+
+	return factory(baseURL, apiKey, model), nil
+}
+
+func embeddingModel(providerName string, modelName string) string {
+	if modelName == "" {
+		return defaultEmbeddingModels[providerName]
+	}
+	return modelName
+}
+
+func embedInBatches(providerName string, inputs []string, embed func(batch []string) ([][]float64, error)) ([][]float64, error) {
+	batchSize := embeddingBatchSizes[providerName]
+	if batchSize <= 0 {
+		batchSize = len(inputs)
+	}
+
+	embeddings := make([][]float64, 0, len(inputs))
+	for start := 0; start < len(inputs); start += batchSize {
+		end := start + batchSize
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+
+		batch, err := embed(inputs[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(batch))
+		}
+		embeddings = append(embeddings, batch...)
+	}
+
+	return embeddings, nil
+}
+
+func CreateSpeechProvider(providerName string, baseURL string, apiKey string, model string, voice string) (SpeechProvider, error) {
+	factory, exists := SpeechProviderFactories[providerName]
+	if !exists {
+		return nil, errors.New("unknown speech provider")
+	}
+
+	if model == "" {
+		model = defaultSpeechModels[providerName]
+	}
+
+	if voice == "" {
+		voice = defaultSpeechVoices[providerName]
+	}
+
+	return factory(baseURL, apiKey, model, voice), nil
+}
+
+func CreateImageProvider(providerName string, baseURL string, apiKey string, model string, size string) (ImageProvider, error) {
+	factory, exists := ImageProviderFactories[providerName]
+	if !exists {
+		return nil, errors.New("unknown image provider")
+	}
+
+	if model == "" {
+		model = defaultImageModels[providerName]
+	}
+
+	return factory(baseURL, apiKey, model, size), nil
 }
 
 func argsToString(i interface{}) string {
@@ -110,20 +298,21 @@ func argsToString(i interface{}) string {
 	return string(jsonData)
 }
 
-func toolCalls(messages []Message, response Message) []Message {
+func toolCalls(messages []Message, response Message, userId int) []Message {
 	messages = append(messages, response)
 	for _, toolCall := range response.ToolCalls {
 		toolId := toolCall.ID
 		toolName := toolCall.Function.Name
 		toolArgs := toolCall.Function.Arguments
 
-		tool := tools.NewTools(toolName, argsToString(toolArgs))
+		tool, attachments := tools.NewTools(userId, toolName, argsToString(toolArgs))
 		responseTool := []Message{
 			{
-				Role:       "tool",
-				Name:       toolName,
-				Content:    tool,
-				ToolCallID: toolId,
+				Role:        "tool",
+				Name:        toolName,
+				Content:     tool,
+				ToolCallID:  toolId,
+				Attachments: attachments,
 			},
 		}
 		messages = append(messages, responseTool...)
@@ -131,3 +320,80 @@ func toolCalls(messages []Message, response Message) []Message {
 
 	return messages
 }
+
+// requestMessages copies messages without the fields the bot stores along
+// with them, which strict APIs reject in a request.
+func requestMessages(messages []Message) []Message {
+	request := make([]Message, len(messages))
+	for i, message := range messages {
+		message.MessageId = 0
+		message.Usage = nil
+		request[i] = message
+	}
+	return request
+}
+
+func collectAttachments(messages []Message) []attachment.Attachment {
+	var attachments []attachment.Attachment
+	for _, message := range messages {
+		attachments = append(attachments, message.Attachments...)
+	}
+	return attachments
+}
+
+// emitAttachments forwards files produced by the tool calls of a streaming
+// turn, since they never appear in the streamed deltas.
+func emitAttachments(messages []Message, callback func(Message) error) error {
+	attachments := collectAttachments(messages)
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	return callback(Message{Role: "tool", Content: "", Attachments: attachments})
+}
+
+func newUsage(promptTokens int, completionTokens int) Usage {
+	return Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.ToolCalls += other.ToolCalls
+	if other.Model != "" {
+		u.Provider = other.Provider
+		u.Model = other.Model
+	}
+}
+
+func (u Usage) IsZero() bool {
+	return u == Usage{}
+}
+
+// withUsage adds the usage of the turns that led to message, such as tool
+// call rounds, to the usage of message itself.
+func withUsage(message Message, usage Usage) Message {
+	usage.Add(message.Usage)
+	if !usage.IsZero() {
+		message.Usage = &usage
+	}
+	return message
+}
+
+// emitUsage reports the token usage of a streaming turn as a separate message
+// after its deltas, since most APIs only send it with the last chunk.
+func emitUsage(usage Usage, callback func(Message) error) error {
+	if usage.IsZero() {
+		return nil
+	}
+
+	return callback(Message{Role: "assistant", Content: "", Usage: &usage})
+}
@@ -0,0 +1,118 @@
+package provider
+
+import "strings"
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+// ReasoningEfforts are the accepted values of GenerationParams.ReasoningEffort.
+var ReasoningEfforts = []string{"low", "medium", "high"}
+
+// thinkSplitter separates the <think> blocks that models such as deepseek-r1
+// and qwen3 write into their content from the answer. It keeps state between
+// stream chunks, so a tag split over two chunks is still recognized.
+type thinkSplitter struct {
+	thinking bool
+	pending  string
+	closed   bool
+}
+
+func (s *thinkSplitter) split(chunk string) (reasoning string, content string) {
+	text := s.pending + chunk
+	s.pending = ""
+
+	var reasoningText, contentText strings.Builder
+	write := func(part string) {
+		if s.thinking {
+			reasoningText.WriteString(part)
+			return
+		}
+		// The answer usually starts with blank lines after </think>
+		if s.closed && contentText.Len() == 0 {
+			part = strings.TrimLeft(part, "\r\n ")
+			if part != "" {
+				s.closed = false
+			}
+		}
+		contentText.WriteString(part)
+	}
+
+	for text != "" {
+		tag := thinkOpen
+		if s.thinking {
+			tag = thinkClose
+		}
+
+		if i := strings.Index(text, tag); i >= 0 {
+			write(text[:i])
+			text = text[i+len(tag):]
+			s.closed = s.thinking
+			s.thinking = !s.thinking
+			continue
+		}
+
+		// Hold back what could be the start of a tag cut by the chunk end
+		keep := partialTagLength(text, tag)
+		write(text[:len(text)-keep])
+		s.pending = text[len(text)-keep:]
+		break
+	}
+
+	return reasoningText.String(), contentText.String()
+}
+
+// flush returns what was held back once the stream is over.
+func (s *thinkSplitter) flush() (reasoning string, content string) {
+	pending := s.pending
+	s.pending = ""
+	if s.thinking {
+		return pending, ""
+	}
+	return "", pending
+}
+
+func partialTagLength(text string, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// splitThinking separates the <think> blocks of a whole answer.
+func splitThinking(content string) (reasoning string, answer string) {
+	var splitter thinkSplitter
+	reasoning, answer = splitter.split(content)
+	restReasoning, restAnswer := splitter.flush()
+	return strings.TrimSpace(reasoning + restReasoning), answer + restAnswer
+}
+
+// withReasoning moves the <think> blocks of a string content to
+// message.Reasoning, after any reasoning the API already returned separately.
+func withReasoning(message Message) Message {
+	content, ok := message.Content.(string)
+	if !ok || !strings.Contains(content, thinkOpen) {
+		return message
+	}
+
+	reasoning, answer := splitThinking(content)
+	message.Content = answer
+	if reasoning != "" {
+		message.Reasoning = strings.TrimSpace(message.Reasoning + "\n\n" + reasoning)
+	}
+	return message
+}
+
+// thinkingBudget maps a reasoning effort to a token budget for the APIs that
+// take one instead of an effort level.
+func thinkingBudget(effort string) *int {
+	budgets := map[string]int{"low": 1024, "medium": 8192, "high": 24576}
+	budget, ok := budgets[effort]
+	if !ok {
+		return nil
+	}
+	return &budget
+}
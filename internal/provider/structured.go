@@ -0,0 +1,275 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// structuredAttempts is how many times ChatJSON asks before giving up on a
+// model that keeps answering with invalid JSON.
+const structuredAttempts = 3
+
+// ResponseFormat asks a provider for a JSON answer. Schema is a JSON Schema
+// the answer must match, a nil Schema only asks for valid JSON.
+type ResponseFormat struct {
+	Name   string
+	Schema map[string]interface{}
+}
+
+// ErrInvalidJSON is returned by ChatJSON when no attempt produced JSON that
+// matches the schema.
+var ErrInvalidJSON = errors.New("model did not answer with valid JSON")
+
+// ChatJSON asks llm for an answer in format and decodes it into out. The
+// schema is also described in the system prompt for backends that only have
+// a JSON mode. An answer that does not parse or match the schema is sent
+// back with the error so the model can correct it. The returned message
+// carries the usage of every attempt.
+func ChatJSON(llm LLMProvider, modelName string, messages []Message, params GenerationParams, format ResponseFormat, out interface{}) (Message, error) {
+	params.Format = &format
+	messages = withSchemaInstruction(messages, format)
+
+	var usage Usage
+	var lastErr error
+	for attempt := 0; attempt < structuredAttempts; attempt++ {
+		res, err := llm.Chat(modelName, messages, params)
+		if err != nil {
+			return Message{}, err
+		}
+		usage.Add(res.Usage)
+
+		text, _ := res.Content.(string)
+		lastErr = decodeJSON(text, format.Schema, out)
+		if lastErr == nil {
+			res.Usage = nil
+			return withUsage(res, usage), nil
+		}
+
+		messages = append(messages,
+			Message{Role: "assistant", Content: text},
+			Message{Role: "user", Content: fmt.Sprintf("That answer is invalid: %v. Answer again with only the corrected JSON.", lastErr)},
+		)
+	}
+
+	return Message{Usage: &usage}, fmt.Errorf("%w: %v", ErrInvalidJSON, lastErr)
+}
+
+func withSchemaInstruction(messages []Message, format ResponseFormat) []Message {
+	instruction := "Answer only with JSON, without any other text."
+	if format.Schema != nil {
+		schema, _ := json.Marshal(format.Schema)
+		instruction = "Answer only with JSON matching this JSON Schema, without any other text:\n" + string(schema)
+	}
+
+	messages = append([]Message(nil), messages...)
+	if len(messages) > 0 && messages[0].Role == "system" {
+		if system, ok := messages[0].Content.(string); ok {
+			messages[0].Content = system + "\n\n" + instruction
+			return messages
+		}
+	}
+	return append([]Message{{Role: "system", Content: instruction}}, messages...)
+}
+
+// decodeJSON validates text against schema before decoding it into out, so
+// missing fields are reported instead of silently left empty.
+func decodeJSON(text string, schema map[string]interface{}, out interface{}) error {
+	text = extractJSON(text)
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return err
+	}
+	if schema != nil {
+		if err := validateSchema(schema, value, "$"); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal([]byte(text), out)
+}
+
+// extractJSON strips the code fences and the text around the JSON value that
+// models add even in JSON mode.
+func extractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(text, closing)
+	if end < start {
+		return text
+	}
+	return text[start : end+1]
+}
+
+// validateSchema checks the subset of JSON Schema the providers share: type,
+// properties, required, items and enum.
+func validateSchema(schema map[string]interface{}, value interface{}, path string) error {
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s must be one of %v", path, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, property := range properties {
+			field, ok := object[name]
+			propertySchema, isSchema := property.(map[string]interface{})
+			if !ok || !isSchema {
+				continue
+			}
+			if err := validateSchema(propertySchema, field, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	}
+
+	return nil
+}
+
+func schemaStrings(value interface{}) []string {
+	switch values := value.(type) {
+	case []string:
+		return values
+	case []interface{}:
+		var result []string
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// SchemaOf builds the JSON Schema of the type of v from its json tags.
+// Fields without omitempty are required, and a description tag documents a
+// field for the model.
+func SchemaOf(v interface{}) map[string]interface{} {
+	return schemaOfType(reflect.TypeOf(v))
+}
+
+func schemaOfType(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			property := schemaOfType(field.Type)
+			if description := field.Tag.Get("description"); description != "" {
+				property["description"] = description
+			}
+			if enum := field.Tag.Get("enum"); enum != "" {
+				var values []interface{}
+				for _, value := range strings.Split(enum, ",") {
+					values = append(values, value)
+				}
+				property["enum"] = values
+			}
+			properties[name] = property
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaOfType(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,8 @@
+package quota
+
+// Quota limits what a user can consume, a zero limit means unlimited.
+type Quota struct {
+	MessagesPerMinute int `json:"messages_per_minute" bson:"messagesPerMinute"`
+	TokensPerDay      int `json:"tokens_per_day" bson:"tokensPerDay"`
+	ToolsPerHour      int `json:"tools_per_hour" bson:"toolsPerHour"`
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	_ "teo/internal/common"
+	"teo/internal/common"
 	"teo/internal/pkg"
+	"teo/internal/provider"
 	"teo/internal/services/bot/service"
 	"teo/internal/utils"
 
@@ -51,19 +53,23 @@ func (s *BotHandlerImpl) Webhook(c *fiber.Ctx) error {
 	// 	fmt.Println(string(jsonData))
 	// }
 
-	log.Printf("Received message from user ID %v", data.Message.Chat.Id)
+	log.Printf("Received message from user ID %v", data.ChatId())
 
 	res, err := s.botService.Bot(data)
 	if err != nil {
-		log.Printf("Failed to process incoming chat from user ID %v: %v", data.Message.Chat.Id, err.Error())
+		log.Printf("Failed to process incoming chat from user ID %v: %v", data.ChatId(), err.Error())
 
-		formattedError := utils.FormatErrorMessage(err)
-		s.botService.NotifyError(data.Message.Chat.Id, 0, fmt.Sprintf("❌ Something went wrong\n\n```JSON\n%v```", formattedError), true)
+		if errors.Is(err, provider.ErrProvidersUnavailable) {
+			s.botService.NotifyError(data.ChatId(), 0, common.ProvidersUnavailable(), true)
+		} else {
+			formattedError := utils.FormatErrorMessage(err)
+			s.botService.NotifyError(data.ChatId(), 0, fmt.Sprintf("❌ Something went wrong\n\n```JSON\n%v\n```", formattedError), true)
+		}
 
 		return utils.ErrorInternalServer(c, "failed to process incoming chat: "+err.Error())
 	}
 
-	log.Printf("Successfully processed incoming chat from user ID %v", data.Message.Chat.Id)
+	log.Printf("Successfully processed incoming chat from user ID %v", data.ChatId())
 
 	return c.Status(fiber.StatusCreated).JSON(res)
 }
@@ -2,29 +2,279 @@ package model
 
 import (
 	"teo/internal/provider"
+	"teo/internal/quota"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RoleOwner   = "owner"
+	RoleAdmin   = "admin"
+	RoleMember  = "member"
+	RolePending = "pending"
+	RoleBanned  = "banned"
+)
+
+// How the reasoning of thinking models is shown to the user.
+const (
+	ReasoningHide   = "hide"
+	ReasoningShow   = "show"
+	ReasoningStream = "stream"
+)
+
 type User struct {
+	Id         primitive.ObjectID         `json:"id" bson:"_id,omitempty"`
+	UserId     int                        `json:"user_id" bson:"userId"`
+	Name       string                     `json:"name" bson:"name"`
+	System     string                     `json:"system" bson:"system"`
+	Provider   string                     `json:"provider" bson:"provider"`
+	Model      string                     `json:"model" bson:"model"`
+	Role       string                     `json:"role" bson:"role"`
+	VoiceReply bool                       `json:"voice_reply" bson:"voiceReply"`
+	Quota      *quota.Quota               `json:"quota,omitempty" bson:"quota,omitempty"`
+	Params     *provider.GenerationParams `json:"params,omitempty" bson:"params,omitempty"`
+	Reasoning  string                     `json:"reasoning,omitempty" bson:"reasoning,omitempty"`
+	Timezone   string                     `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	CreatedAt  time.Time                  `json:"created_at" bson:"createdAt"`
+	UpdatedAt  time.Time                  `json:"updated_at" bson:"updatedAt"`
+}
+
+// Conversation.Messages is always the active branch. Branches is filled the
+// first time the user regenerates a reply or edits a message.
+type Conversation struct {
+	Id           primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	UserId       int                  `json:"userId" bson:"userId"`
+	Title        string               `json:"title" bson:"title"`
+	Messages     []provider.Message   `json:"messages" bson:"messages"`
+	Images       []ConversationImage  `json:"images,omitempty" bson:"images,omitempty"`
+	Branches     []ConversationBranch `json:"branches,omitempty" bson:"branches,omitempty"`
+	ActiveBranch int                  `json:"active_branch" bson:"activeBranch"`
+	Active       bool                 `json:"active" bson:"active"`
+	CreatedAt    time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+type ConversationBranch struct {
+	ForkIndex int                `json:"fork_index" bson:"forkIndex"`
+	Messages  []provider.Message `json:"messages" bson:"messages"`
+	CreatedAt time.Time          `json:"created_at" bson:"createdAt"`
+}
+
+type ConversationImage struct {
+	Prompt        string    `json:"prompt" bson:"prompt"`
+	RevisedPrompt string    `json:"revised_prompt,omitempty" bson:"revisedPrompt,omitempty"`
+	Provider      string    `json:"provider" bson:"provider"`
+	Model         string    `json:"model" bson:"model"`
+	MimeType      string    `json:"mime_type" bson:"mimeType"`
+	FileSize      int       `json:"file_size" bson:"fileSize"`
+	FileId        string    `json:"file_id,omitempty" bson:"fileId,omitempty"`
+	CreatedAt     time.Time `json:"created_at" bson:"createdAt"`
+}
+
+type Memory struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId    int                `json:"user_id" bson:"userId"`
+	Content   string             `json:"content" bson:"content"`
+	Category  string             `json:"category" bson:"category"`
+	Embedding []float64          `json:"embedding,omitempty" bson:"embedding,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updatedAt"`
+}
+
+type Document struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId    int                `json:"user_id" bson:"userId"`
+	FileName  string             `json:"file_name" bson:"fileName"`
+	MimeType  string             `json:"mime_type" bson:"mimeType"`
+	FileSize  int                `json:"file_size" bson:"fileSize"`
+	Chunks    int                `json:"chunks" bson:"chunks"`
+	CreatedAt time.Time          `json:"created_at" bson:"createdAt"`
+}
+
+// A reminder job sends its text as is, a prompt job runs it through the model
+// and sends the answer.
+const (
+	JobReminder = "reminder"
+	JobPrompt   = "prompt"
+)
+
+const (
+	JobActive = "active"
+	JobPaused = "paused"
+	JobDone   = "done"
+)
+
+// Job is a scheduled reminder or prompt. One-shot jobs have RunAt, recurring
+// jobs have a Cron expression evaluated in Timezone. LockedUntil is set while
+// a scheduler runs the job, so a crashed run is retried once it expires.
+type Job struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId      int                `json:"user_id" bson:"userId"`
+	ChatId      int                `json:"chat_id" bson:"chatId"`
+	Name        string             `json:"name,omitempty" bson:"name,omitempty"`
+	Kind        string             `json:"kind" bson:"kind"`
+	Text        string             `json:"text" bson:"text"`
+	RunAt       time.Time          `json:"run_at,omitempty" bson:"runAt,omitempty"`
+	Cron        string             `json:"cron,omitempty" bson:"cron,omitempty"`
+	Timezone    string             `json:"timezone" bson:"timezone"`
+	Status      string             `json:"status" bson:"status"`
+	NextRun     time.Time          `json:"next_run" bson:"nextRun"`
+	LastRun     time.Time          `json:"last_run,omitempty" bson:"lastRun,omitempty"`
+	LastError   string             `json:"last_error,omitempty" bson:"lastError,omitempty"`
+	Runs        int                `json:"runs" bson:"runs"`
+	LockedUntil time.Time          `json:"-" bson:"lockedUntil,omitempty"`
+	CreatedAt   time.Time          `json:"created_at" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updatedAt"`
+}
+
+const (
+	JobRunOk     = "ok"
+	JobRunFailed = "failed"
+)
+
+// JobRun is one run of a Job. Output is what was sent to the user.
+type JobRun struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	JobId       primitive.ObjectID `json:"job_id" bson:"jobId"`
+	UserId      int                `json:"user_id" bson:"userId"`
+	Status      string             `json:"status" bson:"status"`
+	Output      string             `json:"output,omitempty" bson:"output,omitempty"`
+	Error       string             `json:"error,omitempty" bson:"error,omitempty"`
+	TotalTokens int                `json:"total_tokens" bson:"totalTokens"`
+	ToolCalls   int                `json:"tool_calls" bson:"toolCalls"`
+	StartedAt   time.Time          `json:"started_at" bson:"startedAt"`
+	FinishedAt  time.Time          `json:"finished_at" bson:"finishedAt"`
+}
+
+// Usage is the token usage of a single LLM request. Kind tells what the
+// request was for: chat, title, memory or job.
+type Usage struct {
+	Id               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId           int                `json:"user_id" bson:"userId"`
+	Provider         string             `json:"provider" bson:"provider"`
+	Model            string             `json:"model" bson:"model"`
+	Kind             string             `json:"kind" bson:"kind"`
+	PromptTokens     int                `json:"prompt_tokens" bson:"promptTokens"`
+	CompletionTokens int                `json:"completion_tokens" bson:"completionTokens"`
+	TotalTokens      int                `json:"total_tokens" bson:"totalTokens"`
+	Cost             float64            `json:"cost" bson:"cost"`
+	CreatedAt        time.Time          `json:"created_at" bson:"createdAt"`
+}
+
+// UsageSummary aggregates Usage by model or by user, only the grouping
+// field is set.
+type UsageSummary struct {
+	UserId           int     `json:"user_id,omitempty" bson:"userId,omitempty"`
+	Model            string  `json:"model,omitempty" bson:"model,omitempty"`
+	Requests         int     `json:"requests" bson:"requests"`
+	PromptTokens     int     `json:"prompt_tokens" bson:"promptTokens"`
+	CompletionTokens int     `json:"completion_tokens" bson:"completionTokens"`
+	TotalTokens      int     `json:"total_tokens" bson:"totalTokens"`
+	Cost             float64 `json:"cost" bson:"cost"`
+}
+
+// CalendarEvent is an event of the calendar tool. Recurring events have an
+// RRULE in Recurrence and Exceptions lists their cancelled occurrences. UID
+// identifies events imported from an .ics file.
+type CalendarEvent struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId      int                `json:"user_id" bson:"userId"`
+	UID         string             `json:"uid,omitempty" bson:"uid,omitempty"`
+	Title       string             `json:"title" bson:"title"`
+	Description string             `json:"description" bson:"description"`
+	StartTime   time.Time          `json:"start_time" bson:"startTime"`
+	EndTime     time.Time          `json:"end_time" bson:"endTime"`
+	Timezone    string             `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	Recurrence  string             `json:"recurrence,omitempty" bson:"recurrence,omitempty"`
+	Exceptions  []time.Time        `json:"exceptions,omitempty" bson:"exceptions,omitempty"`
+	Tags        []string           `json:"tags" bson:"tags"`
+	CreatedAt   time.Time          `json:"created_at" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updatedAt"`
+}
+
+// Transaction is an income or expense of the cash flow tool.
+type Transaction struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId      int                `json:"user_id" bson:"userId"`
+	Type        string             `json:"type" bson:"type"`
+	Amount      float64            `json:"amount" bson:"amount"`
+	Currency    string             `json:"currency" bson:"currency"`
+	CategoryId  primitive.ObjectID `json:"category_id" bson:"categoryId"`
+	Category    string             `json:"category" bson:"category"`
+	Description string             `json:"description" bson:"description"`
+	Date        time.Time          `json:"date" bson:"date"`
+	ImportId    string             `json:"import_id,omitempty" bson:"importId,omitempty"`
+	CreatedAt   time.Time          `json:"created_at" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updatedAt"`
+}
+
+type TransactionCategory struct {
 	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	UserId    int                `json:"user_id" bson:"userId"`
 	Name      string             `json:"name" bson:"name"`
-	System    string             `json:"system" bson:"system"`
-	Provider  string             `json:"provider" bson:"provider"`
-	Model     string             `json:"model" bson:"model"`
-	Role      string             `json:"role" bson:"role"`
+	CreatedAt time.Time          `json:"created_at" bson:"createdAt"`
+}
+
+// Budget is the most a user wants to spend on a category each month, one
+// per category.
+type Budget struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId    int                `json:"user_id" bson:"userId"`
+	Category  string             `json:"category" bson:"category"`
+	Amount    float64            `json:"amount" bson:"amount"`
+	Currency  string             `json:"currency" bson:"currency"`
 	CreatedAt time.Time          `json:"created_at" bson:"createdAt"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updatedAt"`
 }
 
-type Conversation struct {
+// RecurringTransaction adds a transaction every Interval periods of
+// Frequency. Posted counts the entries added so far, NextDate is the date of
+// the next one.
+type RecurringTransaction struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId      int                `json:"user_id" bson:"userId"`
+	Type        string             `json:"type" bson:"type"`
+	Amount      float64            `json:"amount" bson:"amount"`
+	Currency    string             `json:"currency" bson:"currency"`
+	CategoryId  primitive.ObjectID `json:"category_id" bson:"categoryId"`
+	Category    string             `json:"category" bson:"category"`
+	Description string             `json:"description" bson:"description"`
+	Frequency   string             `json:"frequency" bson:"frequency"`
+	Interval    int                `json:"interval" bson:"interval"`
+	StartDate   time.Time          `json:"start_date" bson:"startDate"`
+	EndDate     time.Time          `json:"end_date,omitempty" bson:"endDate,omitempty"`
+	NextDate    time.Time          `json:"next_date" bson:"nextDate"`
+	Posted      int                `json:"posted" bson:"posted"`
+	Ended       bool               `json:"ended" bson:"ended"`
+	CreatedAt   time.Time          `json:"created_at" bson:"createdAt"`
+}
+
+// PendingImport keeps the transactions read from a bank statement or a
+// receipt until the user confirms or cancels the preview. It is deleted at
+// ExpiresAt if the user does neither. MessageId is the preview of a receipt,
+// which the user replies to in order to correct it.
+type PendingImport struct {
+	Id           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId       int                `json:"user_id" bson:"userId"`
+	MessageId    int                `json:"message_id,omitempty" bson:"messageId,omitempty"`
+	FileName     string             `json:"file_name" bson:"fileName"`
+	Transactions []Transaction      `json:"transactions" bson:"transactions"`
+	CreatedAt    time.Time          `json:"created_at" bson:"createdAt"`
+	ExpiresAt    time.Time          `json:"expires_at" bson:"expiresAt"`
+}
+
+// Note is a note of the notes tool, a user has one note per title.
+type Note struct {
 	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	UserId    int                `json:"userId" bson:"userId"`
+	UserId    int                `json:"user_id" bson:"userId"`
 	Title     string             `json:"title" bson:"title"`
-	Messages  []provider.Message `json:"messages" bson:"messages"`
-	Active    bool               `json:"active" bson:"active"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+	Content   string             `json:"content" bson:"content"`
+	CreatedAt time.Time          `json:"created_at" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updatedAt"`
+}
+
+// IsAdmin reports whether the user can run admin commands.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleOwner || u.Role == RoleAdmin
 }
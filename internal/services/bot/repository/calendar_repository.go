@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"teo/internal/services/bot/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CalendarRepository interface {
+	CreateEvent(event *model.CalendarEvent) (*model.CalendarEvent, error)
+	GetEventById(id primitive.ObjectID, userId int) (*model.CalendarEvent, error)
+	UpdateEvent(event *model.CalendarEvent) error
+	DeleteEvent(id primitive.ObjectID, userId int) error
+	AddEventException(id primitive.ObjectID, userId int, start time.Time) error
+	UpsertEventByUID(event *model.CalendarEvent) (bool, error)
+	GetEventsInRange(userId int, from, to time.Time) ([]*model.CalendarEvent, error)
+	GetEventsByTitle(userId int, title string) ([]*model.CalendarEvent, error)
+	GetEventsByTags(userId int, tags []string) ([]*model.CalendarEvent, error)
+	GetEventsByUserId(userId int) ([]*model.CalendarEvent, error)
+}
+
+type CalendarRepositoryImpl struct {
+	events *mongo.Collection
+}
+
+func NewCalendarRepository(db *mongo.Database) CalendarRepository {
+	events := db.Collection("calendar_events")
+	ensureIndexes(events,
+		mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "startTime", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "tags", Value: 1}}},
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "uid", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"uid": bson.M{"$exists": true}}),
+		},
+	)
+	return &CalendarRepositoryImpl{events: events}
+}
+
+// ensureIndexes creates the indexes of a collection at startup. A failure is
+// logged, the collection still works without them.
+func ensureIndexes(collection *mongo.Collection, indexes ...mongo.IndexModel) {
+	if _, err := collection.Indexes().CreateMany(context.Background(), indexes); err != nil {
+		log.Printf("Warning: Error creating indexes of %s: %v", collection.Name(), err)
+	}
+}
+
+func (r *CalendarRepositoryImpl) CreateEvent(event *model.CalendarEvent) (*model.CalendarEvent, error) {
+	event.CreatedAt = time.Now()
+	event.UpdatedAt = time.Now()
+
+	res, err := r.events.InsertOne(context.Background(), event)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Id = res.InsertedID.(primitive.ObjectID)
+	return event, nil
+}
+
+func (r *CalendarRepositoryImpl) GetEventById(id primitive.ObjectID, userId int) (*model.CalendarEvent, error) {
+	var event model.CalendarEvent
+	err := r.events.FindOne(context.Background(), bson.M{"_id": id, "userId": userId}).Decode(&event)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UpdateEvent replaces the fields of an event, it returns ErrNoDocuments when
+// the user has no event with its id.
+func (r *CalendarRepositoryImpl) UpdateEvent(event *model.CalendarEvent) error {
+	event.UpdatedAt = time.Now()
+	fields := bson.M{
+		"title":       event.Title,
+		"description": event.Description,
+		"startTime":   event.StartTime,
+		"endTime":     event.EndTime,
+		"timezone":    event.Timezone,
+		"recurrence":  event.Recurrence,
+		"exceptions":  event.Exceptions,
+		"tags":        event.Tags,
+		"updatedAt":   event.UpdatedAt,
+	}
+	update := bson.M{"$set": fields}
+	if event.UID != "" {
+		fields["uid"] = event.UID
+	} else {
+		// An empty uid would collide in the unique index
+		update["$unset"] = bson.M{"uid": ""}
+	}
+
+	res, err := r.events.UpdateOne(context.Background(), bson.M{"_id": event.Id, "userId": event.UserId}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *CalendarRepositoryImpl) DeleteEvent(id primitive.ObjectID, userId int) error {
+	res, err := r.events.DeleteOne(context.Background(), bson.M{"_id": id, "userId": userId})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *CalendarRepositoryImpl) AddEventException(id primitive.ObjectID, userId int, start time.Time) error {
+	update := bson.M{
+		"$addToSet": bson.M{"exceptions": start},
+		"$set":      bson.M{"updatedAt": time.Now()},
+	}
+	res, err := r.events.UpdateOne(context.Background(), bson.M{"_id": id, "userId": userId}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpsertEventByUID inserts an event, or replaces the event of the user with
+// the same UID. It reports whether the event was inserted.
+func (r *CalendarRepositoryImpl) UpsertEventByUID(event *model.CalendarEvent) (bool, error) {
+	filter := bson.M{"userId": event.UserId, "uid": event.UID}
+	update := bson.M{
+		"$set": bson.M{
+			"title":       event.Title,
+			"description": event.Description,
+			"startTime":   event.StartTime,
+			"endTime":     event.EndTime,
+			"timezone":    event.Timezone,
+			"recurrence":  event.Recurrence,
+			"exceptions":  event.Exceptions,
+			"tags":        event.Tags,
+			"updatedAt":   time.Now(),
+		},
+		"$setOnInsert": bson.M{"createdAt": time.Now()},
+	}
+
+	res, err := r.events.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+// GetEventsInRange lists the events of a user that may occur from from to
+// to: recurring events that start before to, and the others that overlap.
+func (r *CalendarRepositoryImpl) GetEventsInRange(userId int, from, to time.Time) ([]*model.CalendarEvent, error) {
+	filter := bson.M{
+		"userId":    userId,
+		"startTime": bson.M{"$lte": to},
+		"$or": bson.A{
+			bson.M{"recurrence": bson.M{"$exists": true, "$ne": ""}},
+			bson.M{"endTime": bson.M{"$gte": from}},
+		},
+	}
+	return r.findEvents(filter)
+}
+
+func (r *CalendarRepositoryImpl) GetEventsByTitle(userId int, title string) ([]*model.CalendarEvent, error) {
+	filter := bson.M{
+		"userId": userId,
+		"title":  bson.M{"$regex": regexp.QuoteMeta(title), "$options": "i"},
+	}
+	return r.findEvents(filter)
+}
+
+func (r *CalendarRepositoryImpl) GetEventsByTags(userId int, tags []string) ([]*model.CalendarEvent, error) {
+	return r.findEvents(bson.M{"userId": userId, "tags": bson.M{"$in": tags}})
+}
+
+func (r *CalendarRepositoryImpl) GetEventsByUserId(userId int) ([]*model.CalendarEvent, error) {
+	return r.findEvents(bson.M{"userId": userId})
+}
+
+func (r *CalendarRepositoryImpl) findEvents(filter bson.M) ([]*model.CalendarEvent, error) {
+	opts := options.Find().SetSort(bson.M{"startTime": 1})
+	cur, err := r.events.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var events []*model.CalendarEvent
+	for cur.Next(context.Background()) {
+		var event model.CalendarEvent
+		if err := cur.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"context"
+	"teo/internal/services/bot/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CashflowRepository interface {
+	CreateTransaction(transaction *model.Transaction) (*model.Transaction, error)
+	CreateTransactionOnce(transaction *model.Transaction) (bool, error)
+	UpdateTransaction(transaction *model.Transaction) error
+	DeleteTransaction(id primitive.ObjectID, userId int) error
+	GetTransactions(userId int, start, end time.Time) ([]*model.Transaction, error)
+	SaveCategory(userId int, name string) (*model.TransactionCategory, bool, error)
+	GetCategories(userId int) ([]*model.TransactionCategory, error)
+	SetBudget(budget *model.Budget) (*model.Budget, error)
+	DeleteBudget(userId int, category string) error
+	GetBudgets(userId int) ([]*model.Budget, error)
+	CreateRecurring(recurring *model.RecurringTransaction) (*model.RecurringTransaction, error)
+	GetRecurringByUserId(userId int) ([]*model.RecurringTransaction, error)
+	DeleteRecurring(id primitive.ObjectID, userId int) error
+	GetDueRecurring(now time.Time) ([]*model.RecurringTransaction, error)
+	AdvanceRecurring(recurring *model.RecurringTransaction, posted int, nextDate time.Time, ended bool) (bool, error)
+	CreatePendingImport(pending *model.PendingImport) (*model.PendingImport, error)
+	TakePendingImport(id primitive.ObjectID, userId int) (*model.PendingImport, error)
+	GetPendingImportByMessage(userId int, messageId int) (*model.PendingImport, error)
+	UpdatePendingImport(pending *model.PendingImport) error
+}
+
+type CashflowRepositoryImpl struct {
+	transactions *mongo.Collection
+	categories   *mongo.Collection
+	budgets      *mongo.Collection
+	recurring    *mongo.Collection
+	pending      *mongo.Collection
+}
+
+func NewCashflowRepository(db *mongo.Database) CashflowRepository {
+	transactions := db.Collection("transactions")
+	categories := db.Collection("transaction_categories")
+	ensureIndexes(transactions,
+		mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "date", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "category", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "importId", Value: 1}}},
+	)
+	ensureIndexes(categories,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	budgets := db.Collection("budgets")
+	ensureIndexes(budgets,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "category", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	recurring := db.Collection("recurring_transactions")
+	ensureIndexes(recurring,
+		mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "ended", Value: 1}, {Key: "nextDate", Value: 1}}},
+	)
+	pending := db.Collection("pending_imports")
+	ensureIndexes(pending,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "messageId", Value: 1}}},
+	)
+	return &CashflowRepositoryImpl{transactions: transactions, categories: categories, budgets: budgets, recurring: recurring, pending: pending}
+}
+
+func (r *CashflowRepositoryImpl) CreateTransaction(transaction *model.Transaction) (*model.Transaction, error) {
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = time.Now()
+	}
+	transaction.UpdatedAt = time.Now()
+
+	res, err := r.transactions.InsertOne(context.Background(), transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.Id = res.InsertedID.(primitive.ObjectID)
+	return transaction, nil
+}
+
+// CreateTransactionOnce inserts a transaction unless the user already has
+// one with the same import id. It reports whether it was inserted.
+func (r *CashflowRepositoryImpl) CreateTransactionOnce(transaction *model.Transaction) (bool, error) {
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = time.Now()
+	}
+	transaction.UpdatedAt = time.Now()
+
+	filter := bson.M{"userId": transaction.UserId, "importId": transaction.ImportId}
+	update := bson.M{"$setOnInsert": transaction}
+	res, err := r.transactions.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+// UpdateTransaction replaces the fields of a transaction, it returns
+// ErrNoDocuments when the user has no transaction with its id.
+func (r *CashflowRepositoryImpl) UpdateTransaction(transaction *model.Transaction) error {
+	transaction.UpdatedAt = time.Now()
+	update := bson.M{"$set": bson.M{
+		"type":        transaction.Type,
+		"amount":      transaction.Amount,
+		"currency":    transaction.Currency,
+		"categoryId":  transaction.CategoryId,
+		"category":    transaction.Category,
+		"description": transaction.Description,
+		"date":        transaction.Date,
+		"updatedAt":   transaction.UpdatedAt,
+	}}
+
+	res, err := r.transactions.UpdateOne(context.Background(), bson.M{"_id": transaction.Id, "userId": transaction.UserId}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *CashflowRepositoryImpl) DeleteTransaction(id primitive.ObjectID, userId int) error {
+	res, err := r.transactions.DeleteOne(context.Background(), bson.M{"_id": id, "userId": userId})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// GetTransactions lists the transactions of a user from start to end, both
+// included, the oldest first.
+func (r *CashflowRepositoryImpl) GetTransactions(userId int, start, end time.Time) ([]*model.Transaction, error) {
+	filter := bson.M{"userId": userId, "date": bson.M{"$gte": start, "$lte": end}}
+	opts := options.Find().SetSort(bson.M{"date": 1})
+	cur, err := r.transactions.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var transactions []*model.Transaction
+	for cur.Next(context.Background()) {
+		var transaction model.Transaction
+		if err := cur.Decode(&transaction); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, &transaction)
+	}
+
+	return transactions, nil
+}
+
+// SaveCategory returns the category of a user named name, created by an
+// atomic upsert when it does not exist yet, and whether it was created.
+func (r *CashflowRepositoryImpl) SaveCategory(userId int, name string) (*model.TransactionCategory, bool, error) {
+	filter := bson.M{"userId": userId, "name": name}
+	update := bson.M{"$setOnInsert": bson.M{"createdAt": time.Now()}}
+
+	res, err := r.categories.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	created := err == nil && res.UpsertedCount > 0
+	// Two upserts at once, the other one created the category
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	var category model.TransactionCategory
+	if err := r.categories.FindOne(context.Background(), filter).Decode(&category); err != nil {
+		return nil, false, err
+	}
+	return &category, created, nil
+}
+
+func (r *CashflowRepositoryImpl) GetCategories(userId int) ([]*model.TransactionCategory, error) {
+	opts := options.Find().SetSort(bson.M{"name": 1})
+	cur, err := r.categories.Find(context.Background(), bson.M{"userId": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var categories []*model.TransactionCategory
+	for cur.Next(context.Background()) {
+		var category model.TransactionCategory
+		if err := cur.Decode(&category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, &category)
+	}
+
+	return categories, nil
+}
+
+// SetBudget creates the budget of a category, or replaces its amount, in a
+// single upsert.
+func (r *CashflowRepositoryImpl) SetBudget(budget *model.Budget) (*model.Budget, error) {
+	filter := bson.M{"userId": budget.UserId, "category": budget.Category}
+	update := bson.M{
+		"$set": bson.M{
+			"amount":    budget.Amount,
+			"currency":  budget.Currency,
+			"updatedAt": time.Now(),
+		},
+		"$setOnInsert": bson.M{"createdAt": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var saved model.Budget
+	if err := r.budgets.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *CashflowRepositoryImpl) DeleteBudget(userId int, category string) error {
+	res, err := r.budgets.DeleteOne(context.Background(), bson.M{"userId": userId, "category": category})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *CashflowRepositoryImpl) GetBudgets(userId int) ([]*model.Budget, error) {
+	opts := options.Find().SetSort(bson.M{"category": 1})
+	cur, err := r.budgets.Find(context.Background(), bson.M{"userId": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var budgets []*model.Budget
+	for cur.Next(context.Background()) {
+		var budget model.Budget
+		if err := cur.Decode(&budget); err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, &budget)
+	}
+
+	return budgets, nil
+}
+
+func (r *CashflowRepositoryImpl) CreateRecurring(recurring *model.RecurringTransaction) (*model.RecurringTransaction, error) {
+	recurring.CreatedAt = time.Now()
+
+	res, err := r.recurring.InsertOne(context.Background(), recurring)
+	if err != nil {
+		return nil, err
+	}
+
+	recurring.Id = res.InsertedID.(primitive.ObjectID)
+	return recurring, nil
+}
+
+// GetRecurringByUserId lists the recurring transactions of a user that have
+// not ended, the next one first.
+func (r *CashflowRepositoryImpl) GetRecurringByUserId(userId int) ([]*model.RecurringTransaction, error) {
+	return r.findRecurring(bson.M{"userId": userId, "ended": false})
+}
+
+func (r *CashflowRepositoryImpl) DeleteRecurring(id primitive.ObjectID, userId int) error {
+	res, err := r.recurring.DeleteOne(context.Background(), bson.M{"_id": id, "userId": userId})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// GetDueRecurring lists the recurring transactions with an entry due at now.
+func (r *CashflowRepositoryImpl) GetDueRecurring(now time.Time) ([]*model.RecurringTransaction, error) {
+	return r.findRecurring(bson.M{"ended": false, "nextDate": bson.M{"$lte": now}})
+}
+
+// AdvanceRecurring moves a recurring transaction to its next entry. It only
+// applies when nobody advanced it since it was read, and reports whether it
+// did, so two schedulers never post the same entries.
+func (r *CashflowRepositoryImpl) AdvanceRecurring(recurring *model.RecurringTransaction, posted int, nextDate time.Time, ended bool) (bool, error) {
+	filter := bson.M{"_id": recurring.Id, "posted": recurring.Posted}
+	update := bson.M{"$set": bson.M{"posted": posted, "nextDate": nextDate, "ended": ended}}
+
+	res, err := r.recurring.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (r *CashflowRepositoryImpl) findRecurring(filter bson.M) ([]*model.RecurringTransaction, error) {
+	opts := options.Find().SetSort(bson.M{"nextDate": 1})
+	cur, err := r.recurring.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var recurring []*model.RecurringTransaction
+	for cur.Next(context.Background()) {
+		var entry model.RecurringTransaction
+		if err := cur.Decode(&entry); err != nil {
+			return nil, err
+		}
+		recurring = append(recurring, &entry)
+	}
+
+	return recurring, nil
+}
+
+func (r *CashflowRepositoryImpl) CreatePendingImport(pending *model.PendingImport) (*model.PendingImport, error) {
+	pending.CreatedAt = time.Now()
+
+	res, err := r.pending.InsertOne(context.Background(), pending)
+	if err != nil {
+		return nil, err
+	}
+
+	pending.Id = res.InsertedID.(primitive.ObjectID)
+	return pending, nil
+}
+
+// TakePendingImport deletes a pending import of a user and returns it, nil
+// when it expired or was taken before, so a preview answered twice is only
+// imported once.
+func (r *CashflowRepositoryImpl) TakePendingImport(id primitive.ObjectID, userId int) (*model.PendingImport, error) {
+	filter := bson.M{"_id": id, "userId": userId, "expiresAt": bson.M{"$gt": time.Now()}}
+
+	var pending model.PendingImport
+	err := r.pending.FindOneAndDelete(context.Background(), filter).Decode(&pending)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+// GetPendingImportByMessage returns the pending import a user was shown in
+// messageId, nil when there is none or it expired.
+func (r *CashflowRepositoryImpl) GetPendingImportByMessage(userId int, messageId int) (*model.PendingImport, error) {
+	filter := bson.M{"userId": userId, "messageId": messageId, "expiresAt": bson.M{"$gt": time.Now()}}
+
+	var pending model.PendingImport
+	err := r.pending.FindOne(context.Background(), filter).Decode(&pending)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+// UpdatePendingImport replaces the transactions of a pending import, it
+// returns ErrNoDocuments when it was taken or expired.
+func (r *CashflowRepositoryImpl) UpdatePendingImport(pending *model.PendingImport) error {
+	filter := bson.M{"_id": pending.Id, "userId": pending.UserId}
+	update := bson.M{"$set": bson.M{"transactions": pending.Transactions}}
+
+	res, err := r.pending.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -19,6 +19,8 @@ type ConversationRepository interface {
 	CreateConversation(userId int, title string) (*model.Conversation, error)
 	UpdateConversationById(id primitive.ObjectID, messages []provider.Message, title string) error
 	GetActiveConversationByUserId(userId int) (*model.Conversation, error)
+	AddConversationImage(id primitive.ObjectID, image model.ConversationImage) error
+	UpdateConversationBranches(id primitive.ObjectID, messages []provider.Message, branches []model.ConversationBranch, activeBranch int) error
 }
 
 type ConversationRepositoryImpl struct {
@@ -120,3 +122,42 @@ func (r *ConversationRepositoryImpl) GetActiveConversationByUserId(userId int) (
 	conv.UpdatedAt = time.Time{}
 	return &conv, nil
 }
+
+func (r *ConversationRepositoryImpl) AddConversationImage(id primitive.ObjectID, image model.ConversationImage) error {
+	image.CreatedAt = time.Now()
+	filter := bson.M{"_id": id}
+	update := bson.M{
+		"$push": bson.M{"images": image},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	_, err := r.conversations.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	var conv model.Conversation
+	err = r.conversations.FindOne(context.Background(), filter).Decode(&conv)
+	if err == nil {
+		_ = pkg.SaveConversationToRedis(r.rd, &conv)
+	}
+	return err
+}
+
+func (r *ConversationRepositoryImpl) UpdateConversationBranches(id primitive.ObjectID, messages []provider.Message, branches []model.ConversationBranch, activeBranch int) error {
+	update := bson.M{
+		"messages":     messages,
+		"branches":     branches,
+		"activeBranch": activeBranch,
+		"updated_at":   time.Now(),
+	}
+	filter := bson.M{"_id": id}
+	_, err := r.conversations.UpdateOne(context.Background(), filter, bson.M{"$set": update})
+	if err != nil {
+		return err
+	}
+	var conv model.Conversation
+	err = r.conversations.FindOne(context.Background(), filter).Decode(&conv)
+	if err == nil {
+		_ = pkg.SaveConversationToRedis(r.rd, &conv)
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"teo/internal/services/bot/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DocumentRepository interface {
+	CreateDocument(document *model.Document) (*model.Document, error)
+	GetDocumentsByUserId(userId int) ([]*model.Document, error)
+	DeleteDocument(id primitive.ObjectID, userId int) error
+}
+
+type DocumentRepositoryImpl struct {
+	documents *mongo.Collection
+}
+
+func NewDocumentRepository(db *mongo.Database) DocumentRepository {
+	return &DocumentRepositoryImpl{documents: db.Collection("documents")}
+}
+
+func (r *DocumentRepositoryImpl) CreateDocument(document *model.Document) (*model.Document, error) {
+	document.CreatedAt = time.Now()
+
+	res, err := r.documents.InsertOne(context.Background(), document)
+	if err != nil {
+		return nil, err
+	}
+
+	document.Id = res.InsertedID.(primitive.ObjectID)
+	return document, nil
+}
+
+func (r *DocumentRepositoryImpl) GetDocumentsByUserId(userId int) ([]*model.Document, error) {
+	filter := bson.M{"userId": userId}
+	opts := options.Find().SetSort(bson.M{"createdAt": 1})
+	cur, err := r.documents.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var documents []*model.Document
+	for cur.Next(context.Background()) {
+		var document model.Document
+		if err := cur.Decode(&document); err != nil {
+			return nil, err
+		}
+		documents = append(documents, &document)
+	}
+
+	return documents, nil
+}
+
+func (r *DocumentRepositoryImpl) DeleteDocument(id primitive.ObjectID, userId int) error {
+	filter := bson.M{"_id": id, "userId": userId}
+	_, err := r.documents.DeleteOne(context.Background(), filter)
+	return err
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"teo/internal/services/bot/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type JobRepository interface {
+	CreateJob(job *model.Job) (*model.Job, error)
+	GetJobsByUserId(userId int, kind string) ([]*model.Job, error)
+	GetJobById(id primitive.ObjectID, userId int) (*model.Job, error)
+	UpdateJob(id primitive.ObjectID, userId int, fields bson.M) error
+	DeleteJob(id primitive.ObjectID, userId int) error
+	ClaimDueJob(now time.Time, lease time.Duration) (*model.Job, error)
+	FinishJobRun(id primitive.ObjectID, nextRun time.Time, status string, runErr string) error
+	CreateJobRun(run *model.JobRun) (*model.JobRun, error)
+	GetJobRuns(jobId primitive.ObjectID, limit int64) ([]*model.JobRun, error)
+}
+
+type JobRepositoryImpl struct {
+	jobs *mongo.Collection
+	runs *mongo.Collection
+}
+
+func NewJobRepository(db *mongo.Database) JobRepository {
+	jobs := db.Collection("jobs")
+	ensureIndexes(jobs,
+		mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextRun", Value: 1}}},
+	)
+	return &JobRepositoryImpl{jobs: jobs, runs: db.Collection("job_runs")}
+}
+
+func (r *JobRepositoryImpl) CreateJob(job *model.Job) (*model.Job, error) {
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = time.Now()
+
+	res, err := r.jobs.InsertOne(context.Background(), job)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Id = res.InsertedID.(primitive.ObjectID)
+	return job, nil
+}
+
+// GetJobsByUserId returns the active and paused jobs of a user, of kind or of
+// any kind when it is empty, the next one first.
+func (r *JobRepositoryImpl) GetJobsByUserId(userId int, kind string) ([]*model.Job, error) {
+	filter := bson.M{"userId": userId, "status": bson.M{"$ne": model.JobDone}}
+	if kind != "" {
+		filter["kind"] = kind
+	}
+	opts := options.Find().SetSort(bson.M{"nextRun": 1})
+	cur, err := r.jobs.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var jobs []*model.Job
+	for cur.Next(context.Background()) {
+		var job model.Job
+		if err := cur.Decode(&job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, nil
+}
+
+func (r *JobRepositoryImpl) GetJobById(id primitive.ObjectID, userId int) (*model.Job, error) {
+	var job model.Job
+	err := r.jobs.FindOne(context.Background(), bson.M{"_id": id, "userId": userId}).Decode(&job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *JobRepositoryImpl) UpdateJob(id primitive.ObjectID, userId int, fields bson.M) error {
+	fields["updatedAt"] = time.Now()
+
+	res, err := r.jobs.UpdateOne(context.Background(), bson.M{"_id": id, "userId": userId}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteJob deletes a job along with its run history.
+func (r *JobRepositoryImpl) DeleteJob(id primitive.ObjectID, userId int) error {
+	res, err := r.jobs.DeleteOne(context.Background(), bson.M{"_id": id, "userId": userId})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	_, err = r.runs.DeleteMany(context.Background(), bson.M{"jobId": id})
+	return err
+}
+
+// ClaimDueJob locks the most overdue job for lease and returns it, or nil
+// when nothing is due. The update is atomic, so a job is never claimed by
+// two schedulers at once.
+func (r *JobRepositoryImpl) ClaimDueJob(now time.Time, lease time.Duration) (*model.Job, error) {
+	filter := bson.M{
+		"status":  model.JobActive,
+		"nextRun": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"lockedUntil": bson.M{"$exists": false}},
+			bson.M{"lockedUntil": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"lockedUntil": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"nextRun": 1}).
+		SetReturnDocument(options.After)
+
+	var job model.Job
+	err := r.jobs.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// FinishJobRun schedules the next run and releases the lock. A job paused
+// while it ran stays paused.
+func (r *JobRepositoryImpl) FinishJobRun(id primitive.ObjectID, nextRun time.Time, status string, runErr string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"nextRun":   nextRun,
+			"status":    status,
+			"lastRun":   time.Now(),
+			"lastError": runErr,
+			"updatedAt": time.Now(),
+		},
+		"$unset": bson.M{"lockedUntil": ""},
+		"$inc":   bson.M{"runs": 1},
+	}
+
+	res, err := r.jobs.UpdateOne(context.Background(), bson.M{"_id": id, "status": model.JobActive}, update)
+	if err != nil || res.MatchedCount > 0 {
+		return err
+	}
+
+	update = bson.M{
+		"$set":   bson.M{"lastRun": time.Now(), "lastError": runErr},
+		"$unset": bson.M{"lockedUntil": ""},
+		"$inc":   bson.M{"runs": 1},
+	}
+	_, err = r.jobs.UpdateOne(context.Background(), bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *JobRepositoryImpl) CreateJobRun(run *model.JobRun) (*model.JobRun, error) {
+	res, err := r.runs.InsertOne(context.Background(), run)
+	if err != nil {
+		return nil, err
+	}
+
+	run.Id = res.InsertedID.(primitive.ObjectID)
+	return run, nil
+}
+
+// GetJobRuns returns the latest runs of a job, the most recent first.
+func (r *JobRepositoryImpl) GetJobRuns(jobId primitive.ObjectID, limit int64) ([]*model.JobRun, error) {
+	opts := options.Find().SetSort(bson.M{"startedAt": -1}).SetLimit(limit)
+	cur, err := r.runs.Find(context.Background(), bson.M{"jobId": jobId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var runs []*model.JobRun
+	for cur.Next(context.Background()) {
+		var run model.JobRun
+		if err := cur.Decode(&run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, &run)
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"teo/internal/services/bot/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MemoryRepository interface {
+	CreateMemory(memory *model.Memory) (*model.Memory, error)
+	GetMemoriesByUserId(userId int) ([]*model.Memory, error)
+	UpdateMemory(id primitive.ObjectID, userId int, content string, embedding []float64) error
+	DeleteMemory(id primitive.ObjectID, userId int) error
+	DeleteMemoriesByUserId(userId int) error
+}
+
+type MemoryRepositoryImpl struct {
+	memories *mongo.Collection
+}
+
+func NewMemoryRepository(db *mongo.Database) MemoryRepository {
+	return &MemoryRepositoryImpl{memories: db.Collection("memories")}
+}
+
+func (r *MemoryRepositoryImpl) CreateMemory(memory *model.Memory) (*model.Memory, error) {
+	memory.CreatedAt = time.Now()
+	memory.UpdatedAt = time.Now()
+
+	res, err := r.memories.InsertOne(context.Background(), memory)
+	if err != nil {
+		return nil, err
+	}
+
+	memory.Id = res.InsertedID.(primitive.ObjectID)
+	return memory, nil
+}
+
+func (r *MemoryRepositoryImpl) GetMemoriesByUserId(userId int) ([]*model.Memory, error) {
+	filter := bson.M{"userId": userId}
+	opts := options.Find().SetSort(bson.M{"createdAt": 1})
+	cur, err := r.memories.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var memories []*model.Memory
+	for cur.Next(context.Background()) {
+		var memory model.Memory
+		if err := cur.Decode(&memory); err != nil {
+			return nil, err
+		}
+		memories = append(memories, &memory)
+	}
+
+	return memories, nil
+}
+
+func (r *MemoryRepositoryImpl) UpdateMemory(id primitive.ObjectID, userId int, content string, embedding []float64) error {
+	update := bson.M{
+		"content":   content,
+		"embedding": embedding,
+		"updatedAt": time.Now(),
+	}
+	filter := bson.M{"_id": id, "userId": userId}
+	_, err := r.memories.UpdateOne(context.Background(), filter, bson.M{"$set": update})
+	return err
+}
+
+func (r *MemoryRepositoryImpl) DeleteMemory(id primitive.ObjectID, userId int) error {
+	filter := bson.M{"_id": id, "userId": userId}
+	_, err := r.memories.DeleteOne(context.Background(), filter)
+	return err
+}
+
+func (r *MemoryRepositoryImpl) DeleteMemoriesByUserId(userId int) error {
+	filter := bson.M{"userId": userId}
+	_, err := r.memories.DeleteMany(context.Background(), filter)
+	return err
+}
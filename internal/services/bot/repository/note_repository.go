@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"teo/internal/services/bot/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NoteRepository interface {
+	CreateNote(note *model.Note) (*model.Note, error)
+	GetNoteByTitle(userId int, title string) (*model.Note, error)
+	UpdateNoteContent(userId int, title string, content string) error
+	DeleteNote(userId int, title string) error
+	GetNotesByUserId(userId int) ([]*model.Note, error)
+	SearchNotes(userId int, query string) ([]*model.Note, error)
+	GetNotesByDate(userId int, start, end time.Time) ([]*model.Note, error)
+}
+
+type NoteRepositoryImpl struct {
+	notes *mongo.Collection
+}
+
+func NewNoteRepository(db *mongo.Database) NoteRepository {
+	notes := db.Collection("notes")
+	ensureIndexes(notes,
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "title", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}},
+	)
+	return &NoteRepositoryImpl{notes: notes}
+}
+
+// CreateNote fails with a duplicate key error when the user already has a
+// note with the same title.
+func (r *NoteRepositoryImpl) CreateNote(note *model.Note) (*model.Note, error) {
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = time.Now()
+	}
+	if note.UpdatedAt.IsZero() {
+		note.UpdatedAt = note.CreatedAt
+	}
+
+	res, err := r.notes.InsertOne(context.Background(), note)
+	if err != nil {
+		return nil, err
+	}
+
+	note.Id = res.InsertedID.(primitive.ObjectID)
+	return note, nil
+}
+
+func (r *NoteRepositoryImpl) GetNoteByTitle(userId int, title string) (*model.Note, error) {
+	var note model.Note
+	err := r.notes.FindOne(context.Background(), bson.M{"userId": userId, "title": title}).Decode(&note)
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func (r *NoteRepositoryImpl) UpdateNoteContent(userId int, title string, content string) error {
+	update := bson.M{"$set": bson.M{"content": content, "updatedAt": time.Now()}}
+	res, err := r.notes.UpdateOne(context.Background(), bson.M{"userId": userId, "title": title}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *NoteRepositoryImpl) DeleteNote(userId int, title string) error {
+	res, err := r.notes.DeleteOne(context.Background(), bson.M{"userId": userId, "title": title})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *NoteRepositoryImpl) GetNotesByUserId(userId int) ([]*model.Note, error) {
+	return r.findNotes(bson.M{"userId": userId})
+}
+
+// SearchNotes finds the notes of a user with query in their title or
+// content, ignoring case.
+func (r *NoteRepositoryImpl) SearchNotes(userId int, query string) ([]*model.Note, error) {
+	pattern := bson.M{"$regex": regexp.QuoteMeta(query), "$options": "i"}
+	filter := bson.M{
+		"userId": userId,
+		"$or":    bson.A{bson.M{"title": pattern}, bson.M{"content": pattern}},
+	}
+	return r.findNotes(filter)
+}
+
+func (r *NoteRepositoryImpl) GetNotesByDate(userId int, start, end time.Time) ([]*model.Note, error) {
+	return r.findNotes(bson.M{"userId": userId, "createdAt": bson.M{"$gte": start, "$lt": end}})
+}
+
+func (r *NoteRepositoryImpl) findNotes(filter bson.M) ([]*model.Note, error) {
+	opts := options.Find().SetSort(bson.M{"createdAt": 1})
+	cur, err := r.notes.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var notes []*model.Note
+	for cur.Next(context.Background()) {
+		var note model.Note
+		if err := cur.Decode(&note); err != nil {
+			return nil, err
+		}
+		notes = append(notes, &note)
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"teo/internal/services/bot/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UsageRepository interface {
+	CreateUsage(usage *model.Usage) (*model.Usage, error)
+	GetUsageByModel(userId int, since time.Time) ([]*model.UsageSummary, error)
+	GetUsageByUser(since time.Time) ([]*model.UsageSummary, error)
+}
+
+type UsageRepositoryImpl struct {
+	usage *mongo.Collection
+}
+
+func NewUsageRepository(db *mongo.Database) UsageRepository {
+	return &UsageRepositoryImpl{usage: db.Collection("usage")}
+}
+
+func (r *UsageRepositoryImpl) CreateUsage(usage *model.Usage) (*model.Usage, error) {
+	usage.CreatedAt = time.Now()
+
+	res, err := r.usage.InsertOne(context.Background(), usage)
+	if err != nil {
+		return nil, err
+	}
+
+	usage.Id = res.InsertedID.(primitive.ObjectID)
+	return usage, nil
+}
+
+func (r *UsageRepositoryImpl) GetUsageByModel(userId int, since time.Time) ([]*model.UsageSummary, error) {
+	filter := bson.M{"userId": userId, "createdAt": bson.M{"$gte": since}}
+	return r.summarize(filter, "model")
+}
+
+func (r *UsageRepositoryImpl) GetUsageByUser(since time.Time) ([]*model.UsageSummary, error) {
+	filter := bson.M{"createdAt": bson.M{"$gte": since}}
+	return r.summarize(filter, "userId")
+}
+
+// summarize totals the usage matching filter grouped by field, most expensive
+// first.
+func (r *UsageRepositoryImpl) summarize(filter bson.M, field string) ([]*model.UsageSummary, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":              "$" + field,
+			"requests":         bson.M{"$sum": 1},
+			"promptTokens":     bson.M{"$sum": "$promptTokens"},
+			"completionTokens": bson.M{"$sum": "$completionTokens"},
+			"totalTokens":      bson.M{"$sum": "$totalTokens"},
+			"cost":             bson.M{"$sum": "$cost"},
+		}}},
+		{{Key: "$addFields", Value: bson.M{field: "$_id"}}},
+		{{Key: "$sort", Value: bson.D{{Key: "cost", Value: -1}, {Key: "totalTokens", Value: -1}}}},
+	}
+
+	cur, err := r.usage.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var summaries []*model.UsageSummary
+	for cur.Next(context.Background()) {
+		var summary model.UsageSummary
+		if err := cur.Decode(&summary); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, &summary)
+	}
+
+	return summaries, nil
+}
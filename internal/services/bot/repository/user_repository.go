@@ -7,6 +7,8 @@ import (
 	"teo/internal/common"
 	"teo/internal/config"
 	"teo/internal/pkg"
+	"teo/internal/provider"
+	"teo/internal/quota"
 	"teo/internal/services/bot/model"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type UserRepository interface {
@@ -23,6 +26,13 @@ type UserRepository interface {
 	UpdateSystem(userId int, system string) error
 	UpdateModel(userId int, model string) error
 	UpdateProvider(userId int, provider string) error
+	UpdateVoiceReply(userId int, enabled bool) error
+	UpdateQuota(userId int, limits *quota.Quota) error
+	UpdateParams(userId int, params *provider.GenerationParams) error
+	UpdateReasoning(userId int, mode string) error
+	UpdateTimezone(userId int, timezone string) error
+	UpdateRole(userId int, role string) error
+	GetUsers() ([]*model.User, error)
 }
 
 type UserRepositoryImpl struct {
@@ -62,14 +72,18 @@ func (r *UserRepositoryImpl) GetUserById(userId int) (*model.User, error) {
 }
 
 func (r *UserRepositoryImpl) CreateUser(user *model.User) (*model.User, error) {
-	role := "user"
+	role := user.Role
+	if role == "" {
+		role = model.RoleMember
+	}
+
 	owner, err := strconv.Atoi(config.OwnerId)
 	if err != nil {
 		return nil, errors.New("invalid owner id")
 	}
 
 	if user.UserId == owner {
-		role = "owner"
+		role = model.RoleOwner
 	}
 
 	user.System = common.RoleSystemDefault()
@@ -120,6 +134,18 @@ func (r *UserRepositoryImpl) updateUserAndCache(userId int, fields bson.M) error
 			user.Model = value.(string)
 		case "provider":
 			user.Provider = value.(string)
+		case "voiceReply":
+			user.VoiceReply = value.(bool)
+		case "quota":
+			user.Quota = value.(*quota.Quota)
+		case "params":
+			user.Params = value.(*provider.GenerationParams)
+		case "reasoning":
+			user.Reasoning = value.(string)
+		case "timezone":
+			user.Timezone = value.(string)
+		case "role":
+			user.Role = value.(string)
 		}
 	}
 	user.UpdatedAt = timeNow
@@ -141,3 +167,56 @@ func (r *UserRepositoryImpl) UpdateProvider(userId int, provider string) error {
 	fields := bson.M{"provider": provider}
 	return r.updateUserAndCache(userId, fields)
 }
+
+func (r *UserRepositoryImpl) UpdateVoiceReply(userId int, enabled bool) error {
+	fields := bson.M{"voiceReply": enabled}
+	return r.updateUserAndCache(userId, fields)
+}
+
+// UpdateQuota overrides the role limits of a user, nil restores them.
+func (r *UserRepositoryImpl) UpdateQuota(userId int, limits *quota.Quota) error {
+	fields := bson.M{"quota": limits}
+	return r.updateUserAndCache(userId, fields)
+}
+
+// UpdateParams sets the generation parameters of a user, nil restores the
+// provider defaults.
+func (r *UserRepositoryImpl) UpdateParams(userId int, params *provider.GenerationParams) error {
+	fields := bson.M{"params": params}
+	return r.updateUserAndCache(userId, fields)
+}
+
+func (r *UserRepositoryImpl) UpdateReasoning(userId int, mode string) error {
+	fields := bson.M{"reasoning": mode}
+	return r.updateUserAndCache(userId, fields)
+}
+
+func (r *UserRepositoryImpl) UpdateTimezone(userId int, timezone string) error {
+	fields := bson.M{"timezone": timezone}
+	return r.updateUserAndCache(userId, fields)
+}
+
+func (r *UserRepositoryImpl) UpdateRole(userId int, role string) error {
+	fields := bson.M{"role": role}
+	return r.updateUserAndCache(userId, fields)
+}
+
+func (r *UserRepositoryImpl) GetUsers() ([]*model.User, error) {
+	opts := options.Find().SetSort(bson.M{"createdAt": 1})
+	cur, err := r.users.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var users []*model.User
+	for cur.Next(context.Background()) {
+		var user model.User
+		if err := cur.Decode(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
@@ -13,7 +13,14 @@ func BotRouter(router fiber.Router) {
 
 	userRepo := repository.NewUserRepository(config.DB, config.RedisClient)
 	convRepo := repository.NewConversationRepository(config.DB, config.RedisClient)
-	serv := service.NewBotService(userRepo, convRepo)
+	memoryRepo := repository.NewMemoryRepository(config.DB)
+	documentRepo := repository.NewDocumentRepository(config.DB)
+	usageRepo := repository.NewUsageRepository(config.DB)
+	jobRepo := repository.NewJobRepository(config.DB)
+	calendarRepo := repository.NewCalendarRepository(config.DB)
+	cashflowRepo := repository.NewCashflowRepository(config.DB)
+	noteRepo := repository.NewNoteRepository(config.DB)
+	serv := service.NewBotService(userRepo, convRepo, memoryRepo, documentRepo, usageRepo, jobRepo, calendarRepo, cashflowRepo, noteRepo)
 	hand := handler.NewBotHandler(serv)
 
 	router.Post("/webhook/bot", hand.Webhook)
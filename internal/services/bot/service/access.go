@@ -0,0 +1,187 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"slices"
+	"strings"
+	"teo/internal/common"
+	"teo/internal/config"
+	"teo/internal/pkg"
+	"teo/internal/services/bot/model"
+	"teo/internal/utils"
+	"time"
+)
+
+const (
+	inviteExpiration = 7 * 24 * time.Hour
+	usersPerPage     = 30
+)
+
+// newUserRole is the role of someone talking to the bot for the first time.
+// Bots with BOT_TYPE=invite only let in allowlisted users, everyone else has
+// to redeem an invite code first.
+func newUserRole(userId int) string {
+	if config.BotType == "invite" && !slices.Contains(config.AllowedUsers, userId) {
+		return model.RolePending
+	}
+	return model.RoleMember
+}
+
+// canManage reports whether admin may change the role of target to role.
+// Nobody can change the owner and only the owner manages admins.
+func canManage(admin *model.User, target *model.User, role string) bool {
+	if target.Role == model.RoleOwner || role == model.RoleOwner || admin.UserId == target.UserId {
+		return false
+	}
+	if target.Role == model.RoleAdmin || role == model.RoleAdmin {
+		return admin.Role == model.RoleOwner
+	}
+	return admin.IsAdmin()
+}
+
+// access lets members through and reports whether the update was handled
+// here instead, for banned users and users still waiting for an invite.
+func (r *BotServiceImpl) access(user *model.User, chat *pkg.TelegramIncommingChat) (bool, error) {
+	switch user.Role {
+	case model.RoleBanned:
+		log.Printf("Ignoring update from banned user %v", user.UserId)
+		return true, nil
+	case model.RolePending:
+		return true, r.onboard(user, chat)
+	}
+	return false, nil
+}
+
+// onboard turns a pending user into a member once they are allowlisted or
+// send a valid invite code, either alone or as /start <code>.
+func (r *BotServiceImpl) onboard(user *model.User, chat *pkg.TelegramIncommingChat) error {
+	if chat.CallbackQuery != nil || chat.EditedMessage != nil {
+		return nil
+	}
+
+	chatId := chat.Message.Chat.Id
+	replyId := chat.Message.MessageId
+
+	allowed := slices.Contains(config.AllowedUsers, user.UserId)
+	if !allowed {
+		code := strings.TrimSpace(strings.TrimPrefix(chat.Message.Text, "/start"))
+		redeemed, err := r.redeemInvite(code)
+		if err != nil {
+			return err
+		}
+		allowed = redeemed
+	}
+
+	if !allowed {
+		_, err := pkg.SendTelegramMessage(chatId, replyId, common.InviteRequired(), true)
+		return err
+	}
+
+	if err := r.userRepo.UpdateRole(user.UserId, model.RoleMember); err != nil {
+		return err
+	}
+
+	_, err := pkg.SendTelegramMessage(chatId, replyId, common.InviteAccepted(), true)
+	return err
+}
+
+// changeRole gives userId a new role on behalf of admin and returns the
+// reply for the command.
+func (r *BotServiceImpl) changeRole(admin *model.User, userId int, role string) string {
+	target, err := r.userRepo.GetUserById(userId)
+	if err != nil {
+		return common.CommandRoleFailed()
+	}
+	if target == nil {
+		return common.CommandUserNotFound()
+	}
+
+	if !canManage(admin, target, role) {
+		return common.CommandAdminForbidden()
+	}
+
+	if err := r.userRepo.UpdateRole(userId, role); err != nil {
+		return common.CommandRoleFailed()
+	}
+	return common.CommandRole(target.Name, role)
+}
+
+func (r *BotServiceImpl) stats() (string, error) {
+	users, err := r.userRepo.GetUsers()
+	if err != nil {
+		return "", err
+	}
+
+	roles := map[string]int{}
+	for _, user := range users {
+		roles[user.Role]++
+	}
+
+	periods := usagePeriods()
+	today, err := r.usageRepo.GetUsageByUser(periods[0].since)
+	if err != nil {
+		return "", err
+	}
+	allTime, err := r.usageRepo.GetUsageByUser(periods[len(periods)-1].since)
+	if err != nil {
+		return "", err
+	}
+
+	return utils.ShowStats(len(users), roles, len(today), today, allTime), nil
+}
+
+func (r *BotServiceImpl) redeemInvite(code string) (bool, error) {
+	if code == "" {
+		return false, nil
+	}
+	if config.InviteCode != "" && code == config.InviteCode {
+		return true, nil
+	}
+	return pkg.RedeemInviteFromRedis(config.RedisClient, code)
+}
+
+func (r *BotServiceImpl) createInvite(admin *model.User) (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	code := hex.EncodeToString(buf)
+	if err := pkg.SaveInviteToRedis(config.RedisClient, code, admin.UserId, inviteExpiration); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// broadcast sends text to every member, admin and the owner, pacing the
+// messages to stay below the Telegram limits, then reports back to chatId.
+func (r *BotServiceImpl) broadcast(users []*model.User, text string, chatId int) {
+	sent, failed := 0, 0
+	for _, user := range users {
+		send, err := pkg.SendTelegramMessage(user.UserId, 0, text, true)
+		if err != nil || send == nil || !send.Ok {
+			log.Printf("Error broadcasting to user %v: %v", user.UserId, err)
+			failed++
+		} else {
+			sent++
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := pkg.SendTelegramMessage(chatId, 0, common.CommandBroadcastDone(sent, failed), true); err != nil {
+		log.Println("Error sending broadcast report:", err)
+	}
+}
+
+func broadcastRecipients(users []*model.User) []*model.User {
+	var recipients []*model.User
+	for _, user := range users {
+		if user.Role == model.RoleBanned || user.Role == model.RolePending {
+			continue
+		}
+		recipients = append(recipients, user)
+	}
+	return recipients
+}
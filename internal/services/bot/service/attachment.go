@@ -0,0 +1,107 @@
+package service
+
+import (
+	"log"
+	"teo/internal/common"
+	"teo/internal/pkg"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/attachment"
+)
+
+const maxMediaGroupSize = 10
+
+// deliverAttachments sends tool files to the user and records generated
+// images on the active conversation.
+func (r *BotServiceImpl) deliverAttachments(user *model.User, chat *pkg.TelegramIncommingChat, attachments []attachment.Attachment) {
+	fileIds := sendAttachments(chat, attachments)
+	for i, file := range attachments {
+		if file.Metadata["prompt"] == "" {
+			continue
+		}
+		r.recordImage(user.UserId, conversationImage(file, fileIds[i]))
+	}
+}
+
+// sendAttachments uploads files produced by tools next to the assistant's
+// reply and returns the Telegram file_id of each one (empty when not sent).
+// Photos are grouped into albums; anything Telegram would reject as a photo
+// is sent as a document instead.
+func sendAttachments(chat *pkg.TelegramIncommingChat, attachments []attachment.Attachment) []string {
+	chatId := chat.Message.Chat.Id
+	replyId := chat.Message.MessageId
+	fileIds := make([]string, len(attachments))
+
+	var photos []int
+	for i, file := range attachments {
+		if len(file.Data) > attachment.MaxSize {
+			if _, err := pkg.SendTelegramMessage(chatId, replyId, common.AttachmentTooLarge(file.FileName), false); err != nil {
+				log.Println("Error sending attachment notice:", err)
+			}
+			continue
+		}
+
+		switch {
+		case file.Kind() == attachment.KindPhoto && len(file.Data) <= attachment.MaxPhotoSize:
+			photos = append(photos, i)
+		case file.Kind() == attachment.KindAudio:
+			fileIds[i] = sendAttachment(chat, "sendAudio", "audio", file)
+		default:
+			fileIds[i] = sendAttachment(chat, "sendDocument", "document", file)
+		}
+	}
+
+	for len(photos) > 0 {
+		batch := photos
+		if len(batch) > maxMediaGroupSize {
+			batch = batch[:maxMediaGroupSize]
+		}
+		photos = photos[len(batch):]
+
+		if len(batch) == 1 {
+			fileIds[batch[0]] = sendAttachment(chat, "sendPhoto", "photo", attachments[batch[0]])
+			continue
+		}
+
+		media := make([]pkg.TelegramMediaFile, len(batch))
+		for i, index := range batch {
+			media[i] = pkg.TelegramMediaFile{FileName: attachments[index].FileName, Data: attachments[index].Data}
+		}
+		sent, err := pkg.SendTelegramMediaGroup(chatId, replyId, media)
+		if err != nil {
+			log.Println("Error sending media group:", err)
+			for _, index := range batch {
+				fileIds[index] = sendAttachment(chat, "sendDocument", "document", attachments[index])
+			}
+			continue
+		}
+
+		for i, message := range sent {
+			if i < len(batch) {
+				fileIds[batch[i]] = pkg.SentFileId(message)
+			}
+		}
+	}
+
+	return fileIds
+}
+
+func sendAttachment(chat *pkg.TelegramIncommingChat, method string, field string, file attachment.Attachment) string {
+	send, err := pkg.SendTelegramFile(method, field, chat.Message.Chat.Id, chat.Message.MessageId, file.FileName, file.Data, "")
+	if err == nil {
+		return pkg.SentFileId(send.Result)
+	}
+
+	log.Printf("Error sending attachment %s: %v", file.FileName, err)
+	if method == "sendPhoto" {
+		// Telegram rejects photos with unusual dimensions, fall back to a plain file
+		send, err = pkg.SendTelegramFile("sendDocument", "document", chat.Message.Chat.Id, chat.Message.MessageId, file.FileName, file.Data, "")
+		if err == nil {
+			return pkg.SentFileId(send.Result)
+		}
+	}
+
+	if _, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, common.AttachmentFailed(file.FileName), false); err != nil {
+		log.Println("Error sending attachment notice:", err)
+	}
+	return ""
+}
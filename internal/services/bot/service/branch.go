@@ -0,0 +1,196 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"teo/internal/common"
+	"teo/internal/pkg"
+	"teo/internal/provider"
+	"teo/internal/services/bot/model"
+	"time"
+)
+
+const callbackRegenerate = "regenerate"
+
+func regenerateKeyboard() *pkg.InlineKeyboardMarkup {
+	return &pkg.InlineKeyboardMarkup{
+		InlineKeyboard: [][]pkg.InlineKeyboardButton{
+			{{Text: common.ButtonRegenerate(), CallbackData: callbackRegenerate}},
+		},
+	}
+}
+
+// conversationBranches returns the branches of conv with the active one
+// brought up to date, creating the first branch from the linear history.
+func conversationBranches(conv *model.Conversation) []model.ConversationBranch {
+	if len(conv.Branches) == 0 {
+		createdAt := conv.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		return []model.ConversationBranch{{Messages: conv.Messages, CreatedAt: createdAt}}
+	}
+
+	branches := append([]model.ConversationBranch(nil), conv.Branches...)
+	if conv.ActiveBranch >= 0 && conv.ActiveBranch < len(branches) {
+		branches[conv.ActiveBranch].Messages = conv.Messages
+	}
+	return branches
+}
+
+// findBranchMessage looks for a Telegram message in the active branch first,
+// then in the alternatives, and returns the branch and message index.
+func findBranchMessage(branches []model.ConversationBranch, active int, role string, messageId int) (int, int) {
+	order := []int{active}
+	for i := range branches {
+		if i != active {
+			order = append(order, i)
+		}
+	}
+
+	for _, b := range order {
+		if b < 0 || b >= len(branches) {
+			continue
+		}
+		for i, message := range branches[b].Messages {
+			if message.Role == role && message.MessageId == messageId {
+				return b, i
+			}
+		}
+	}
+
+	return -1, -1
+}
+
+// forkConversation stores path as a new branch diverging at forkIndex and
+// makes it the active one.
+func (r *BotServiceImpl) forkConversation(conv *model.Conversation, branches []model.ConversationBranch, forkIndex int, path []provider.Message) error {
+	branches = append(branches, model.ConversationBranch{
+		ForkIndex: forkIndex,
+		Messages:  path,
+		CreatedAt: time.Now(),
+	})
+
+	return r.conversationRepo.UpdateConversationBranches(conv.Id, path, branches, len(branches)-1)
+}
+
+func (r *BotServiceImpl) switchBranch(userId int, index int) (bool, error) {
+	conv, err := r.conversationRepo.GetActiveConversationByUserId(userId)
+	if err != nil || conv == nil {
+		return false, err
+	}
+
+	branches := conversationBranches(conv)
+	if index < 0 || index >= len(branches) {
+		return false, nil
+	}
+
+	return true, r.conversationRepo.UpdateConversationBranches(conv.Id, branches[index].Messages, branches, index)
+}
+
+func (r *BotServiceImpl) callbackQuery(user *model.User, chat *pkg.TelegramIncommingChat) (*pkg.TelegramSendMessageStatus, error) {
+	callback := chat.CallbackQuery
+
+	action, id, _ := strings.Cut(callback.Data, ":")
+	switch action {
+	case callbackRegenerate:
+		return r.regenerate(user, chat)
+	case callbackImportConfirm, callbackImportCancel:
+		return r.answerImport(user, chat, action, id)
+	case callbackReceiptSave, callbackReceiptEdit, callbackReceiptDiscard:
+		return r.answerReceipt(user, chat, action, id)
+	}
+
+	if err := pkg.AnswerTelegramCallbackQuery(callback.Id, ""); err != nil {
+		log.Println("Error answering callback query:", err)
+	}
+	return nil, nil
+}
+
+func (r *BotServiceImpl) regenerate(user *model.User, chat *pkg.TelegramIncommingChat) (*pkg.TelegramSendMessageStatus, error) {
+	callback := chat.CallbackQuery
+
+	conv, err := r.conversationRepo.GetActiveConversationByUserId(user.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	branch, index := -1, -1
+	var branches []model.ConversationBranch
+	if conv != nil && callback.Message != nil {
+		branches = conversationBranches(conv)
+		branch, index = findBranchMessage(branches, conv.ActiveBranch, "assistant", callback.Message.MessageId)
+	}
+
+	if index < 1 {
+		if err := pkg.AnswerTelegramCallbackQuery(callback.Id, common.RegenerateUnavailable()); err != nil {
+			log.Println("Error answering callback query:", err)
+		}
+		return nil, nil
+	}
+
+	if notice := r.checkQuota(user); notice != "" {
+		if err := pkg.AnswerTelegramCallbackQuery(callback.Id, notice); err != nil {
+			log.Println("Error answering callback query:", err)
+		}
+		return nil, nil
+	}
+
+	if err := pkg.AnswerTelegramCallbackQuery(callback.Id, common.Regenerating()); err != nil {
+		log.Println("Error answering callback query:", err)
+	}
+
+	path := append([]provider.Message(nil), branches[branch].Messages[:index]...)
+	if err := r.forkConversation(conv, branches, index, path); err != nil {
+		return nil, err
+	}
+
+	// Reply to the original prompt as if it had just been sent
+	replay := &pkg.TelegramIncommingChat{
+		Message: pkg.UserMessage{
+			Chat:      callback.Message.Chat,
+			From:      callback.From,
+			MessageId: path[len(path)-1].MessageId,
+		},
+		UpdateId: chat.UpdateId,
+	}
+
+	messages := append([]provider.Message{r.systemMessage(user, messageText(path[len(path)-1]))}, path...)
+	return r.respond(user, replay, messages)
+}
+
+func (r *BotServiceImpl) editedMessage(user *model.User, chat *pkg.TelegramIncommingChat) (*pkg.TelegramSendMessageStatus, error) {
+	edited := chat.EditedMessage
+	if strings.HasPrefix(edited.Text, "/") {
+		return nil, nil
+	}
+
+	conv, err := r.conversationRepo.GetActiveConversationByUserId(user.UserId)
+	if err != nil || conv == nil {
+		return nil, err
+	}
+
+	branches := conversationBranches(conv)
+	branch, index := findBranchMessage(branches, conv.ActiveBranch, "user", edited.MessageId)
+	if index < 0 {
+		log.Printf("Ignoring edit of message %v from user %v, it is not part of the active conversation", edited.MessageId, user.UserId)
+		return nil, nil
+	}
+
+	replay := &pkg.TelegramIncommingChat{Message: *edited, UpdateId: chat.UpdateId}
+	if r.quotaExceeded(user, replay) {
+		return nil, nil
+	}
+
+	newMessage := NewMessage(replay, r.imageInput(user), r.ttsProvider)
+	newMessage.MessageId = edited.MessageId
+
+	path := append([]provider.Message(nil), branches[branch].Messages[:index]...)
+	path = append(path, newMessage)
+	if err := r.forkConversation(conv, branches, index, path); err != nil {
+		return nil, err
+	}
+
+	messages := append([]provider.Message{r.systemMessage(user, messageText(newMessage))}, path...)
+	return r.respond(user, replay, messages)
+}
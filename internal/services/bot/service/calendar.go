@@ -0,0 +1,300 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"teo/internal/common"
+	"teo/internal/pkg"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/calendar"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// calendarAgendaDays is how far ahead /calendar lists events.
+const calendarAgendaDays = 7
+
+func isCalendarFile(chat *pkg.TelegramIncommingChat) bool {
+	document := chat.Message.Document
+	if document == nil {
+		return false
+	}
+	return strings.EqualFold(filepath.Ext(document.FileName), ".ics") || document.MimeType == "text/calendar"
+}
+
+// importCalendar adds the events of an .ics file to the calendar of the
+// user. Events imported before, by UID, are updated instead.
+func (r *BotServiceImpl) importCalendar(user *model.User, chat *pkg.TelegramIncommingChat) error {
+	document := chat.Message.Document
+	notify := func(text string) error {
+		_, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, text, false)
+		return err
+	}
+
+	if document.FileSize > maxDocumentSize {
+		return notify(common.DocumentTooLarge())
+	}
+
+	filePath, err := pkg.GetFilePath(document.FileID)
+	if err != nil {
+		log.Printf("Error getting file path for calendar %s: %v", document.FileName, err)
+		return notify(common.CalendarImportFailed())
+	}
+
+	data, err := pkg.DownloadTgFile(filePath)
+	if err != nil {
+		log.Printf("Error downloading calendar %s: %v", document.FileName, err)
+		return notify(common.CalendarImportFailed())
+	}
+
+	manager, err := calendar.NewCalendarManager()
+	if err != nil {
+		return notify(common.CalendarImportFailed())
+	}
+	userID := strconv.Itoa(user.UserId)
+
+	schedules, err := calendar.ParseICS(data, manager.Location(userID))
+	if err != nil {
+		log.Printf("Error parsing calendar %s: %v", document.FileName, err)
+		return notify(common.CalendarImportInvalid(err.Error()))
+	}
+	if len(schedules) == 0 {
+		return notify(common.CalendarImportEmpty())
+	}
+
+	added, updated, err := manager.ImportSchedules(userID, schedules)
+	if err != nil {
+		log.Printf("Error importing calendar %s: %v", document.FileName, err)
+		return notify(common.CalendarImportFailed())
+	}
+	return notify(common.CalendarImported(added, updated))
+}
+
+// toolUserId reads the user id the tools pass as a string.
+func toolUserId(userID string) (int, error) {
+	userId, err := strconv.Atoi(userID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user_id %s", userID)
+	}
+	return userId, nil
+}
+
+// calendarStore gives the calendar tool access to the events of a user.
+type calendarStore struct {
+	r *BotServiceImpl
+}
+
+func (s *calendarStore) CreateSchedule(schedule calendar.Schedule) (calendar.Schedule, error) {
+	userId, err := toolUserId(schedule.UserID)
+	if err != nil {
+		return calendar.Schedule{}, err
+	}
+
+	event, err := s.r.calendarRepo.CreateEvent(calendarEvent(userId, schedule))
+	if err != nil {
+		return calendar.Schedule{}, err
+	}
+	return toolSchedule(event), nil
+}
+
+func (s *calendarStore) GetSchedule(id string, userID string) (*calendar.Schedule, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	event, err := s.r.calendarRepo.GetEventById(objectId, userId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	schedule := toolSchedule(event)
+	return &schedule, nil
+}
+
+func (s *calendarStore) UpdateSchedule(schedule calendar.Schedule) error {
+	userId, err := toolUserId(schedule.UserID)
+	if err != nil {
+		return err
+	}
+	event := calendarEvent(userId, schedule)
+	if event.Id, err = primitive.ObjectIDFromHex(schedule.ID); err != nil {
+		return fmt.Errorf("schedule with ID %s not found", schedule.ID)
+	}
+
+	err = s.r.calendarRepo.UpdateEvent(event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("schedule with ID %s not found", schedule.ID)
+	}
+	return err
+}
+
+func (s *calendarStore) DeleteSchedule(id string, userID string) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("schedule with ID %s not found", id)
+	}
+
+	err = s.r.calendarRepo.DeleteEvent(objectId, userId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("schedule with ID %s not found", id)
+	}
+	return err
+}
+
+func (s *calendarStore) AddException(id string, userID string, start time.Time) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("schedule with ID %s not found", id)
+	}
+	err = s.r.calendarRepo.AddEventException(objectId, userId, start)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("schedule with ID %s not found", id)
+	}
+	return err
+}
+
+func (s *calendarStore) ImportSchedules(userID string, schedules []calendar.Schedule) (int, int, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	added, updated := 0, 0
+	for _, schedule := range schedules {
+		event := calendarEvent(userId, schedule)
+		if event.UID == "" {
+			if _, err := s.r.calendarRepo.CreateEvent(event); err != nil {
+				return added, updated, err
+			}
+			added++
+			continue
+		}
+
+		inserted, err := s.r.calendarRepo.UpsertEventByUID(event)
+		if err != nil {
+			return added, updated, err
+		}
+		if inserted {
+			added++
+		} else {
+			updated++
+		}
+	}
+	return added, updated, nil
+}
+
+func (s *calendarStore) FindSchedules(userID string, from, to time.Time) ([]calendar.Schedule, error) {
+	return s.schedules(userID, func(userId int) ([]*model.CalendarEvent, error) {
+		return s.r.calendarRepo.GetEventsInRange(userId, from, to)
+	})
+}
+
+func (s *calendarStore) SearchByTitle(userID string, title string) ([]calendar.Schedule, error) {
+	return s.schedules(userID, func(userId int) ([]*model.CalendarEvent, error) {
+		return s.r.calendarRepo.GetEventsByTitle(userId, title)
+	})
+}
+
+func (s *calendarStore) SearchByTags(userID string, tags []string) ([]calendar.Schedule, error) {
+	return s.schedules(userID, func(userId int) ([]*model.CalendarEvent, error) {
+		return s.r.calendarRepo.GetEventsByTags(userId, tags)
+	})
+}
+
+func (s *calendarStore) UserSchedules(userID string) ([]calendar.Schedule, error) {
+	return s.schedules(userID, s.r.calendarRepo.GetEventsByUserId)
+}
+
+func (s *calendarStore) schedules(userID string, find func(userId int) ([]*model.CalendarEvent, error)) ([]calendar.Schedule, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := find(userId)
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]calendar.Schedule, 0, len(events))
+	for _, event := range events {
+		schedules = append(schedules, toolSchedule(event))
+	}
+	return schedules, nil
+}
+
+func (s *calendarStore) Timezone(userID string) (string, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.r.userRepo.GetUserById(userId)
+	if err != nil || user == nil {
+		return "", err
+	}
+	return user.Timezone, nil
+}
+
+func (s *calendarStore) SetTimezone(userID string, timezone string) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+	return s.r.userRepo.UpdateTimezone(userId, timezone)
+}
+
+func calendarEvent(userId int, schedule calendar.Schedule) *model.CalendarEvent {
+	tags := schedule.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return &model.CalendarEvent{
+		UserId:      userId,
+		UID:         schedule.UID,
+		Title:       schedule.Title,
+		Description: schedule.Description,
+		StartTime:   schedule.StartTime,
+		EndTime:     schedule.EndTime,
+		Timezone:    schedule.Timezone,
+		Recurrence:  schedule.Recurrence,
+		Exceptions:  schedule.Exceptions,
+		Tags:        tags,
+	}
+}
+
+func toolSchedule(event *model.CalendarEvent) calendar.Schedule {
+	return calendar.Schedule{
+		ID:          event.Id.Hex(),
+		UID:         event.UID,
+		UserID:      strconv.Itoa(event.UserId),
+		Title:       event.Title,
+		Description: event.Description,
+		StartTime:   event.StartTime,
+		EndTime:     event.EndTime,
+		Timezone:    event.Timezone,
+		Recurrence:  event.Recurrence,
+		Exceptions:  event.Exceptions,
+		Tags:        event.Tags,
+	}
+}
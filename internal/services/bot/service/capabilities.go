@@ -0,0 +1,74 @@
+package service
+
+import (
+	"teo/internal/provider"
+	"teo/internal/services/bot/model"
+)
+
+// imageTokens is a rough cost of an image in the prompt, most APIs charge
+// between a few hundred and about a thousand tokens per image.
+const imageTokens = 1000
+
+func (r *BotServiceImpl) capabilities(user *model.User) provider.Capabilities {
+	return provider.ModelCapabilities(r.llmProvider, user.Model)
+}
+
+func (r *BotServiceImpl) imageInput(user *model.User) provider.ImageInput {
+	return provider.ImageInputOf(r.llmProvider, user.Model)
+}
+
+// fitContext adapts the context window to the model: images are dropped for
+// models that cannot see them and the oldest messages are left out until the
+// prompt fits in the context length, keeping room for the answer.
+func (r *BotServiceImpl) fitContext(user *model.User, context []provider.Message) []provider.Message {
+	capabilities := r.capabilities(user)
+
+	if !capabilities.Vision {
+		for i := range context {
+			context[i] = withoutImages(context[i])
+		}
+	}
+
+	if capabilities.ContextLength <= 0 {
+		return context
+	}
+
+	budget := capabilities.ContextLength * 3 / 4
+	total := 0
+	for _, message := range context {
+		total += estimateTokens(message)
+	}
+
+	// The system message and the last message are always kept
+	for total > budget && len(context) > 2 {
+		total -= estimateTokens(context[1])
+		context = append(context[:1], context[2:]...)
+
+		// A tool result cannot be sent without the call that asked for it
+		for len(context) > 2 && context[1].Role == "tool" {
+			total -= estimateTokens(context[1])
+			context = append(context[:1], context[2:]...)
+		}
+	}
+
+	return context
+}
+
+func estimateTokens(message provider.Message) int {
+	return len(messageText(message))/4 + 4 + len(message.Images)*imageTokens
+}
+
+func withoutImages(message provider.Message) provider.Message {
+	message.Images = nil
+	if items, ok := message.Content.([]provider.ContentItem); ok {
+		text := messageText(message)
+		for _, item := range items {
+			if item.Type == "image_url" {
+				text += "\n\n[An image was attached here]"
+				break
+			}
+		}
+		message.Content = text
+	}
+	return message
+}
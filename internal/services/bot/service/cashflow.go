@@ -0,0 +1,356 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"teo/internal/common"
+	"teo/internal/pkg"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/cashflow"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxRecurringCatchUp bounds the entries a recurring transaction posts at
+// once, e.g. a daily expense that started years ago.
+const maxRecurringCatchUp = 366
+
+// postRecurringTransactions adds the entries of recurring transactions that
+// are due and alerts the users whose budgets they take over.
+func (r *BotServiceImpl) postRecurringTransactions() {
+	now := time.Now()
+	due, err := r.cashflowRepo.GetDueRecurring(now)
+	if err != nil {
+		log.Printf("Error getting due recurring transactions: %v", err)
+		return
+	}
+
+	for _, recurring := range due {
+		var dates []time.Time
+		posted, next := recurring.Posted, recurring.NextDate
+		for !next.After(now) && (recurring.EndDate.IsZero() || !next.After(recurring.EndDate)) && len(dates) < maxRecurringCatchUp {
+			dates = append(dates, next)
+			posted++
+			next = cashflow.Occurrence(recurring.StartDate, recurring.Frequency, recurring.Interval, posted)
+		}
+		ended := !recurring.EndDate.IsZero() && next.After(recurring.EndDate)
+
+		advanced, err := r.cashflowRepo.AdvanceRecurring(recurring, posted, next, ended)
+		if err != nil {
+			log.Printf("Error advancing recurring transaction %s: %v", recurring.Id.Hex(), err)
+			continue
+		}
+		if !advanced {
+			continue
+		}
+
+		for _, date := range dates {
+			_, err := r.cashflowRepo.CreateTransaction(&model.Transaction{
+				UserId:      recurring.UserId,
+				Type:        recurring.Type,
+				Amount:      recurring.Amount,
+				Currency:    recurring.Currency,
+				CategoryId:  recurring.CategoryId,
+				Category:    recurring.Category,
+				Description: recurring.Description,
+				Date:        date,
+			})
+			if err != nil {
+				log.Printf("Error posting recurring transaction %s: %v", recurring.Id.Hex(), err)
+			}
+		}
+		if len(dates) > 0 && recurring.Type == string(cashflow.Expense) {
+			r.alertBudget(recurring.UserId, recurring.Category, dates[len(dates)-1])
+		}
+	}
+}
+
+// alertBudget tells the user when spending on category in the month of date
+// is over its budget.
+func (r *BotServiceImpl) alertBudget(userId int, category string, date time.Time) {
+	status, err := cashflow.CheckBudget(strconv.Itoa(userId), category, date)
+	if err != nil {
+		log.Printf("Error checking budget of %s for user %d: %v", category, userId, err)
+		return
+	}
+	if status == nil || !status.Over {
+		return
+	}
+
+	text := common.CashflowBudgetExceeded(category, cashflow.FormatAmount(status.Spent), cashflow.FormatAmount(status.Budget.Amount), string(status.Budget.Currency))
+	// Private chats have the id of the user
+	if _, err := pkg.SendTelegramMessage(userId, 0, text, false); err != nil {
+		log.Printf("Error sending budget alert to user %d: %v", userId, err)
+	}
+}
+
+// cashflowStore gives the cash flow tool access to the transactions of a user.
+type cashflowStore struct {
+	r *BotServiceImpl
+}
+
+func (s *cashflowStore) CreateTransaction(transaction cashflow.Transaction) (cashflow.Transaction, error) {
+	record, err := s.record(transaction)
+	if err != nil {
+		return cashflow.Transaction{}, err
+	}
+
+	record, err = s.r.cashflowRepo.CreateTransaction(record)
+	if err != nil {
+		return cashflow.Transaction{}, err
+	}
+	return toolTransaction(record), nil
+}
+
+func (s *cashflowStore) UpdateTransaction(transaction cashflow.Transaction) error {
+	record, err := s.record(transaction)
+	if err != nil {
+		return err
+	}
+	if record.Id, err = primitive.ObjectIDFromHex(transaction.ID); err != nil {
+		return cashflow.ErrTransactionNotFound
+	}
+
+	err = s.r.cashflowRepo.UpdateTransaction(record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return cashflow.ErrTransactionNotFound
+	}
+	return err
+}
+
+func (s *cashflowStore) DeleteTransaction(id string, userID string) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return cashflow.ErrTransactionNotFound
+	}
+
+	err = s.r.cashflowRepo.DeleteTransaction(objectId, userId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return cashflow.ErrTransactionNotFound
+	}
+	return err
+}
+
+func (s *cashflowStore) GetTransactions(userID string, start, end time.Time) ([]cashflow.Transaction, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.r.cashflowRepo.GetTransactions(userId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	transactions := make([]cashflow.Transaction, 0, len(records))
+	for _, record := range records {
+		transactions = append(transactions, toolTransaction(record))
+	}
+	return transactions, nil
+}
+
+func (s *cashflowStore) SaveCategory(userID string, name string) (cashflow.Category, bool, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return cashflow.Category{}, false, err
+	}
+
+	category, created, err := s.r.cashflowRepo.SaveCategory(userId, name)
+	if err != nil {
+		return cashflow.Category{}, false, err
+	}
+	return cashflow.Category{ID: category.Id.Hex(), Name: category.Name}, created, nil
+}
+
+func (s *cashflowStore) GetCategories(userID string) ([]cashflow.Category, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.r.cashflowRepo.GetCategories(userId)
+	if err != nil {
+		return nil, err
+	}
+	categories := make([]cashflow.Category, 0, len(records))
+	for _, record := range records {
+		categories = append(categories, cashflow.Category{ID: record.Id.Hex(), Name: record.Name})
+	}
+	return categories, nil
+}
+
+func (s *cashflowStore) record(transaction cashflow.Transaction) (*model.Transaction, error) {
+	userId, err := toolUserId(transaction.UserID)
+	if err != nil {
+		return nil, err
+	}
+	categoryId, _ := primitive.ObjectIDFromHex(transaction.Category.ID)
+
+	return &model.Transaction{
+		UserId:      userId,
+		Type:        string(transaction.Type),
+		Amount:      transaction.Amount,
+		Currency:    string(transaction.Currency),
+		CategoryId:  categoryId,
+		Category:    transaction.Category.Name,
+		Description: transaction.Description,
+		Date:        transaction.Date,
+		ImportId:    transaction.ImportID,
+	}, nil
+}
+
+func toolTransaction(record *model.Transaction) cashflow.Transaction {
+	return cashflow.Transaction{
+		ID:          record.Id.Hex(),
+		UserID:      strconv.Itoa(record.UserId),
+		Type:        cashflow.TransactionType(record.Type),
+		Amount:      record.Amount,
+		Currency:    cashflow.CurrencyType(record.Currency),
+		Category:    cashflow.Category{ID: record.CategoryId.Hex(), Name: record.Category},
+		Description: record.Description,
+		Date:        record.Date,
+		ImportID:    record.ImportId,
+	}
+}
+
+func (s *cashflowStore) SetBudget(budget cashflow.Budget) (cashflow.Budget, error) {
+	userId, err := toolUserId(budget.UserID)
+	if err != nil {
+		return cashflow.Budget{}, err
+	}
+
+	record, err := s.r.cashflowRepo.SetBudget(&model.Budget{
+		UserId:   userId,
+		Category: budget.Category,
+		Amount:   budget.Amount,
+		Currency: string(budget.Currency),
+	})
+	if err != nil {
+		return cashflow.Budget{}, err
+	}
+	return toolBudget(record), nil
+}
+
+func (s *cashflowStore) DeleteBudget(userID string, category string) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.r.cashflowRepo.DeleteBudget(userId, category)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return cashflow.ErrBudgetNotFound
+	}
+	return err
+}
+
+func (s *cashflowStore) GetBudgets(userID string) ([]cashflow.Budget, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.r.cashflowRepo.GetBudgets(userId)
+	if err != nil {
+		return nil, err
+	}
+	budgets := make([]cashflow.Budget, 0, len(records))
+	for _, record := range records {
+		budgets = append(budgets, toolBudget(record))
+	}
+	return budgets, nil
+}
+
+func (s *cashflowStore) CreateRecurring(recurring cashflow.Recurring) (cashflow.Recurring, error) {
+	userId, err := toolUserId(recurring.UserID)
+	if err != nil {
+		return cashflow.Recurring{}, err
+	}
+	categoryId, _ := primitive.ObjectIDFromHex(recurring.Category.ID)
+
+	record, err := s.r.cashflowRepo.CreateRecurring(&model.RecurringTransaction{
+		UserId:      userId,
+		Type:        string(recurring.Type),
+		Amount:      recurring.Amount,
+		Currency:    string(recurring.Currency),
+		CategoryId:  categoryId,
+		Category:    recurring.Category.Name,
+		Description: recurring.Description,
+		Frequency:   recurring.Frequency,
+		Interval:    recurring.Interval,
+		StartDate:   recurring.StartDate,
+		EndDate:     recurring.EndDate,
+		NextDate:    recurring.NextDate,
+	})
+	if err != nil {
+		return cashflow.Recurring{}, err
+	}
+	return toolRecurring(record), nil
+}
+
+func (s *cashflowStore) GetRecurring(userID string) ([]cashflow.Recurring, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.r.cashflowRepo.GetRecurringByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	recurring := make([]cashflow.Recurring, 0, len(records))
+	for _, record := range records {
+		recurring = append(recurring, toolRecurring(record))
+	}
+	return recurring, nil
+}
+
+func (s *cashflowStore) DeleteRecurring(id string, userID string) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return cashflow.ErrRecurringNotFound
+	}
+
+	err = s.r.cashflowRepo.DeleteRecurring(objectId, userId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return cashflow.ErrRecurringNotFound
+	}
+	return err
+}
+
+func toolBudget(record *model.Budget) cashflow.Budget {
+	return cashflow.Budget{
+		ID:       record.Id.Hex(),
+		UserID:   strconv.Itoa(record.UserId),
+		Category: record.Category,
+		Amount:   record.Amount,
+		Currency: cashflow.CurrencyType(record.Currency),
+	}
+}
+
+func toolRecurring(record *model.RecurringTransaction) cashflow.Recurring {
+	return cashflow.Recurring{
+		ID:          record.Id.Hex(),
+		UserID:      strconv.Itoa(record.UserId),
+		Type:        cashflow.TransactionType(record.Type),
+		Amount:      record.Amount,
+		Currency:    cashflow.CurrencyType(record.Currency),
+		Category:    cashflow.Category{ID: record.CategoryId.Hex(), Name: record.Category},
+		Description: record.Description,
+		Frequency:   record.Frequency,
+		Interval:    record.Interval,
+		StartDate:   record.StartDate,
+		EndDate:     record.EndDate,
+		NextDate:    record.NextDate,
+	}
+}
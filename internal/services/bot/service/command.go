@@ -1,12 +1,22 @@
 package service
 
 import (
+	"fmt"
+	"log"
 	"strconv"
+	"strings"
 	"teo/internal/common"
 	"teo/internal/config"
 	"teo/internal/pkg"
+	"teo/internal/quota"
 	"teo/internal/services/bot/model"
+	"teo/internal/tools/calendar"
+	"teo/internal/tools/cashflow"
+	"teo/internal/tools/scheduler"
 	"teo/internal/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type CommandFactory interface {
@@ -171,6 +181,891 @@ func (c *MeCommand) HandleCommand(user *model.User, args string) (bool, string,
 	return true, utils.CommandMe(user), nil
 }
 
+type MemoryCommand struct {
+	r *BotServiceImpl
+}
+
+func NewMemoryCommand(r *BotServiceImpl) CommandFactory {
+	return &MemoryCommand{r: r}
+}
+
+func (c *MemoryCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	if !config.MemoryEnabled {
+		return true, common.CommandMemoryDisabled(), nil
+	}
+
+	memories, err := c.r.memoryRepo.GetMemoriesByUserId(user.UserId)
+	if err != nil {
+		return true, common.CommandMemoryFailed(), nil
+	}
+
+	if args == "" {
+		if len(memories) == 0 {
+			return true, common.CommandMemoryEmpty(), nil
+		}
+		return true, utils.ListMemories(memories), nil
+	}
+
+	parts := strings.SplitN(args, " ", 3)
+	action := parts[0]
+
+	if action == "forget" && len(parts) > 1 && parts[1] == "all" {
+		if err := c.r.memoryRepo.DeleteMemoriesByUserId(user.UserId); err != nil {
+			return true, common.CommandMemoryFailed(), nil
+		}
+		return true, common.CommandMemoryForgetAll(), nil
+	}
+
+	if (action != "forget" && action != "edit") || len(parts) < 2 {
+		return true, common.CommandMemoryUsage(), nil
+	}
+
+	idMemory, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return true, common.CommandMemoryArgsNotInt(), nil
+	}
+
+	if idMemory < 0 || idMemory >= len(memories) {
+		return true, common.CommandMemoryNotFound(), nil
+	}
+	memory := memories[idMemory]
+
+	if action == "forget" {
+		if err := c.r.memoryRepo.DeleteMemory(memory.Id, user.UserId); err != nil {
+			return true, common.CommandMemoryFailed(), nil
+		}
+		return true, common.CommandMemoryForget(), nil
+	}
+
+	if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+		return true, common.CommandMemoryUsage(), nil
+	}
+
+	content := strings.TrimSpace(parts[2])
+	if err := c.r.memoryRepo.UpdateMemory(memory.Id, user.UserId, content, c.r.embed(content)); err != nil {
+		return true, common.CommandMemoryFailed(), nil
+	}
+
+	return true, common.CommandMemoryEdit(), nil
+}
+
+type DocsCommand struct {
+	r *BotServiceImpl
+}
+
+func NewDocsCommand(r *BotServiceImpl) CommandFactory {
+	return &DocsCommand{r: r}
+}
+
+func (c *DocsCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	documents, err := c.r.documentRepo.GetDocumentsByUserId(user.UserId)
+	if err != nil {
+		return true, common.CommandDocsFailed(), nil
+	}
+
+	if args == "" {
+		if len(documents) == 0 {
+			return true, common.CommandDocsEmpty(), nil
+		}
+		return true, utils.ListDocuments(documents), nil
+	}
+
+	parts := strings.Fields(args)
+	if len(parts) != 2 || parts[0] != "remove" {
+		return true, common.CommandDocsUsage(), nil
+	}
+
+	idDocument, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return true, common.CommandDocsArgsNotInt(), nil
+	}
+
+	if idDocument < 0 || idDocument >= len(documents) {
+		return true, common.CommandDocsNotFound(), nil
+	}
+
+	if err := c.r.removeDocument(user.UserId, documents[idDocument]); err != nil {
+		return true, common.CommandDocsFailed(), nil
+	}
+
+	return true, common.CommandDocsRemove(), nil
+}
+
+type BranchesCommand struct {
+	r *BotServiceImpl
+}
+
+func NewBranchesCommand(r *BotServiceImpl) CommandFactory {
+	return &BranchesCommand{r: r}
+}
+
+func (c *BranchesCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	if args == "" {
+		conv, err := c.r.conversationRepo.GetActiveConversationByUserId(user.UserId)
+		if err != nil || conv == nil || len(conv.Branches) == 0 {
+			return true, common.CommandBranchesEmpty(), nil
+		}
+		return true, utils.ListBranches(conversationBranches(conv), conv.ActiveBranch), nil
+	}
+
+	idBranch, err := strconv.Atoi(args)
+	if err != nil {
+		return true, common.CommandBranchesArgsNotInt(), nil
+	}
+
+	found, err := c.r.switchBranch(user.UserId, idBranch)
+	if err != nil {
+		return true, common.CommandBranchesFailed(), nil
+	}
+
+	if !found {
+		return true, common.CommandBranchesNotFound(), nil
+	}
+
+	return true, common.CommandBranchesSwitched(idBranch), nil
+}
+
+type VoiceCommand struct {
+	r *BotServiceImpl
+}
+
+func NewVoiceCommand(r *BotServiceImpl) CommandFactory {
+	return &VoiceCommand{r: r}
+}
+
+func (c *VoiceCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	if c.r.speechProvider == nil {
+		return true, common.CommandVoiceDisabled(), nil
+	}
+
+	var enabled bool
+	switch strings.ToLower(args) {
+	case "":
+		return true, common.CommandVoiceStatus(user.VoiceReply), nil
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		return true, common.CommandVoiceUsage(), nil
+	}
+
+	if err := c.r.userRepo.UpdateVoiceReply(user.UserId, enabled); err != nil {
+		return true, common.CommandVoiceFailed(), nil
+	}
+
+	return true, common.CommandVoice(enabled), nil
+}
+
+type ImagineCommand struct {
+	r    *BotServiceImpl
+	chat *pkg.TelegramIncommingChat
+}
+
+func NewImagineCommand(r *BotServiceImpl, chat *pkg.TelegramIncommingChat) CommandFactory {
+	return &ImagineCommand{r: r, chat: chat}
+}
+
+func (c *ImagineCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	if c.r.imageProvider == nil {
+		return true, common.CommandImagineDisabled(), nil
+	}
+
+	prompt := strings.TrimSpace(args)
+	if prompt == "" {
+		return true, common.CommandImagineUsage(), nil
+	}
+
+	if notice := c.r.checkQuota(user); notice != "" {
+		return true, notice, nil
+	}
+
+	// The photo is delivered here, an empty response tells Bot not to reply again
+	return true, "", c.r.imagine(user, c.chat, prompt)
+}
+
+type CalendarCommand struct {
+	r    *BotServiceImpl
+	chat *pkg.TelegramIncommingChat
+}
+
+func NewCalendarCommand(r *BotServiceImpl, chat *pkg.TelegramIncommingChat) CommandFactory {
+	return &CalendarCommand{r: r, chat: chat}
+}
+
+func (c *CalendarCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	manager, err := calendar.NewCalendarManager()
+	if err != nil {
+		return true, common.CommandCalendarFailed(), nil
+	}
+	userID := strconv.Itoa(user.UserId)
+	location := manager.Location(userID)
+
+	action, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(action) {
+	case "":
+		from := time.Now().In(location)
+		schedules, err := manager.SearchByDateRange(userID, from, from.AddDate(0, 0, calendarAgendaDays))
+		if err != nil {
+			return true, common.CommandCalendarFailed(), nil
+		}
+		if len(schedules) == 0 {
+			return true, common.CommandCalendarEmpty(), nil
+		}
+		return true, utils.ListSchedules(schedules, location), nil
+	case "export":
+		schedules, err := manager.UserSchedules(userID)
+		if err != nil {
+			return true, common.CommandCalendarFailed(), nil
+		}
+		if len(schedules) == 0 {
+			return true, common.CommandCalendarEmpty(), nil
+		}
+		data := calendar.ExportICS(schedules, location)
+		send, err := pkg.SendTelegramFile("sendDocument", "document", c.chat.Message.Chat.Id, c.chat.Message.MessageId, "calendar.ics", data, "")
+		if err != nil || !send.Ok {
+			return true, common.CommandCalendarFailed(), nil
+		}
+		return true, "", nil
+	case "timezone":
+		if value == "" {
+			return true, common.CommandCalendarTimezone(location.String()), nil
+		}
+		if err := manager.SetTimezone(userID, value); err != nil {
+			return true, common.CommandCalendarInvalidTimezone(value), nil
+		}
+		return true, common.CommandCalendarTimezoneSet(value), nil
+	default:
+		return true, common.CommandCalendarUsage(), nil
+	}
+}
+
+type CashflowCommand struct {
+	r    *BotServiceImpl
+	chat *pkg.TelegramIncommingChat
+}
+
+func NewCashflowCommand(r *BotServiceImpl, chat *pkg.TelegramIncommingChat) CommandFactory {
+	return &CashflowCommand{r: r, chat: chat}
+}
+
+func (c *CashflowCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	userID := strconv.Itoa(user.UserId)
+	fields := strings.Fields(args)
+	action := ""
+	if len(fields) > 0 {
+		action = strings.ToLower(fields[0])
+	}
+
+	switch action {
+	case "", "report":
+		month := time.Now().In(config.Timezone)
+		currency := cashflow.DefaultCurrency()
+		var err error
+		for _, field := range fields[min(1, len(fields)):] {
+			if parsed, err := time.ParseInLocation("2006-01", field, config.Timezone); err == nil {
+				month = parsed
+			} else if currency, err = cashflow.ParseCurrency(field); err != nil {
+				return true, common.CommandCashflowUsage(), nil
+			}
+		}
+
+		report, err := cashflow.MonthlyReport(userID, month, currency)
+		if err != nil {
+			log.Printf("Error creating cash flow report of user %d: %v", user.UserId, err)
+			return true, common.CommandCashflowFailed(), nil
+		}
+		if report.Income == 0 && report.Expense == 0 && len(report.Categories) == 0 {
+			return true, common.CommandCashflowEmpty(report.Month.Format("January 2006")), nil
+		}
+		chart, err := report.Chart()
+		if err != nil {
+			return true, common.CommandCashflowFailed(), nil
+		}
+		send, err := pkg.SendTelegramFile("sendPhoto", "photo", c.chat.Message.Chat.Id, c.chat.Message.MessageId, "cashflow.png", chart, report.Caption())
+		if err != nil || !send.Ok {
+			return true, common.CommandCashflowFailed(), nil
+		}
+		return true, "", nil
+	case "budgets":
+		statuses, err := cashflow.BudgetStatuses(userID, time.Now().In(config.Timezone))
+		if err != nil {
+			return true, common.CommandCashflowFailed(), nil
+		}
+		if len(statuses) == 0 {
+			return true, common.CommandCashflowNoBudgets(), nil
+		}
+		return true, utils.ListBudgets(statuses), nil
+	case "recurring":
+		recurring, err := c.r.cashflowRepo.GetRecurringByUserId(user.UserId)
+		if err != nil {
+			return true, common.CommandCashflowFailed(), nil
+		}
+		if len(recurring) == 0 {
+			return true, common.CommandCashflowNoRecurring(), nil
+		}
+		return true, utils.ListRecurring(recurring), nil
+	case "export":
+		if len(fields) < 3 || len(fields) > 4 {
+			return true, common.CommandCashflowUsage(), nil
+		}
+		start, err := time.ParseInLocation("2006-01-02", fields[1], config.Timezone)
+		if err != nil {
+			return true, common.CommandCashflowUsage(), nil
+		}
+		end, err := time.ParseInLocation("2006-01-02", fields[2], config.Timezone)
+		if err != nil || end.Before(start) {
+			return true, common.CommandCashflowUsage(), nil
+		}
+		format := cashflow.FormatCSV
+		if len(fields) == 4 {
+			format = strings.ToLower(fields[3])
+		}
+		if format != cashflow.FormatCSV && format != cashflow.FormatXLSX {
+			return true, common.CommandCashflowUsage(), nil
+		}
+
+		file, count, err := cashflow.ExportFile(userID, start, end, format)
+		if err != nil {
+			log.Printf("Error exporting transactions of user %d: %v", user.UserId, err)
+			return true, common.CommandCashflowExportFailed(), nil
+		}
+		if count == 0 {
+			return true, common.CommandCashflowExportEmpty(fields[1], fields[2]), nil
+		}
+		send, err := pkg.SendTelegramFile("sendDocument", "document", c.chat.Message.Chat.Id, c.chat.Message.MessageId, file.FileName, file.Data, "")
+		if err != nil || !send.Ok {
+			return true, common.CommandCashflowExportFailed(), nil
+		}
+		return true, "", nil
+	default:
+		return true, common.CommandCashflowUsage(), nil
+	}
+}
+
+type ReceiptCommand struct {
+	r    *BotServiceImpl
+	chat *pkg.TelegramIncommingChat
+}
+
+func NewReceiptCommand(r *BotServiceImpl, chat *pkg.TelegramIncommingChat) CommandFactory {
+	return &ReceiptCommand{r: r, chat: chat}
+}
+
+// HandleCommand reads the photo /receipt replies to, photos sent with
+// /receipt as caption do not go through commands.
+func (c *ReceiptCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	reply := c.chat.Message.ReplyToMessage
+	if reply == nil {
+		return true, common.ReceiptUsage(), nil
+	}
+	fileId := receiptFileId(reply.Photo, reply.Document)
+	if fileId == "" {
+		return true, common.ReceiptUsage(), nil
+	}
+	return true, "", c.r.scanReceipt(user, c.chat, fileId)
+}
+
+type UsageCommand struct {
+	r *BotServiceImpl
+}
+
+func NewUsageCommand(r *BotServiceImpl) CommandFactory {
+	return &UsageCommand{r: r}
+}
+
+func (c *UsageCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		periods, err := c.r.usageByModel(user.UserId)
+		if err != nil {
+			return true, common.CommandUsageFailed(), nil
+		}
+		return true, utils.ListUsage("Your Usage", periods), nil
+	case "report":
+		if !user.IsAdmin() {
+			return true, common.CommandAdminOnly(), nil
+		}
+		periods, err := c.r.usageByUser()
+		if err != nil {
+			return true, common.CommandUsageFailed(), nil
+		}
+		return true, utils.ListUsage("Usage Report", periods), nil
+	default:
+		return true, common.CommandUsageUsage(), nil
+	}
+}
+
+type ParamsCommand struct {
+	r *BotServiceImpl
+}
+
+func NewParamsCommand(r *BotServiceImpl) CommandFactory {
+	return &ParamsCommand{r: r}
+}
+
+func (c *ParamsCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return true, utils.ShowParams(generationParams(user)), nil
+	}
+
+	if args == "reset" {
+		if err := c.r.userRepo.UpdateParams(user.UserId, nil); err != nil {
+			return true, common.CommandParamsFailed(), nil
+		}
+		return true, common.CommandParamsReset(), nil
+	}
+
+	name, value, _ := strings.Cut(args, " ")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return true, common.CommandParamsUsage(), nil
+	}
+
+	params := generationParams(user)
+	params.Stop = append([]string(nil), params.Stop...)
+	if err := setParam(&params, strings.ToLower(name), value); err != nil {
+		return true, common.CommandParamsInvalid(err.Error()), nil
+	}
+
+	if err := c.r.saveParams(user.UserId, params); err != nil {
+		return true, common.CommandParamsFailed(), nil
+	}
+
+	return true, common.CommandParams(), nil
+}
+
+type RemindersCommand struct {
+	r *BotServiceImpl
+}
+
+func NewRemindersCommand(r *BotServiceImpl) CommandFactory {
+	return &RemindersCommand{r: r}
+}
+
+func (c *RemindersCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	jobs, err := c.r.jobRepo.GetJobsByUserId(user.UserId, model.JobReminder)
+	if err != nil {
+		return true, common.CommandRemindersFailed(), nil
+	}
+
+	if args == "" {
+		if len(jobs) == 0 {
+			return true, common.CommandRemindersEmpty(), nil
+		}
+		return true, utils.ListJobs(jobs), nil
+	}
+
+	parts := strings.Fields(args)
+	if len(parts) != 2 || parts[0] != "cancel" {
+		return true, common.CommandRemindersUsage(), nil
+	}
+
+	index, err := strconv.Atoi(parts[1])
+	if err != nil || index < 0 || index >= len(jobs) {
+		return true, common.CommandRemindersNotFound(), nil
+	}
+
+	if err := c.r.jobRepo.DeleteJob(jobs[index].Id, user.UserId); err != nil {
+		return true, common.CommandRemindersFailed(), nil
+	}
+	return true, common.CommandRemindersCancel(), nil
+}
+
+type TasksCommand struct {
+	r *BotServiceImpl
+}
+
+func NewTasksCommand(r *BotServiceImpl) CommandFactory {
+	return &TasksCommand{r: r}
+}
+
+func (c *TasksCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	action, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+
+	switch strings.ToLower(action) {
+	case "":
+		jobs, err := c.r.jobRepo.GetJobsByUserId(user.UserId, model.JobPrompt)
+		if err != nil {
+			return true, common.CommandTasksFailed(), nil
+		}
+		if len(jobs) == 0 {
+			return true, common.CommandTasksEmpty(), nil
+		}
+		return true, utils.ListTasks(jobs), nil
+	case "briefing":
+		cron, err := briefingCron(rest)
+		if err != nil {
+			return true, common.CommandTasksInvalid(err.Error()), nil
+		}
+		return c.add(user, scheduler.JobRequest{Name: common.BriefingName(), Kind: model.JobPrompt, Text: common.BriefingPrompt(), Cron: cron})
+	case "add":
+		cron, prompt := splitCron(rest)
+		if cron == "" || prompt == "" {
+			return true, common.CommandTasksUsage(), nil
+		}
+		return c.add(user, scheduler.JobRequest{Kind: model.JobPrompt, Text: prompt, Cron: cron})
+	case "pause", "resume", "run", "history", "delete", "edit":
+	default:
+		return true, common.CommandTasksUsage(), nil
+	}
+
+	number, rest, _ := strings.Cut(rest, " ")
+	job, found, err := c.task(user.UserId, number)
+	if err != nil {
+		return true, common.CommandTasksFailed(), nil
+	}
+	if !found {
+		return true, common.CommandTasksNotFound(), nil
+	}
+
+	switch strings.ToLower(action) {
+	case "pause":
+		if err := c.r.jobRepo.UpdateJob(job.Id, user.UserId, bson.M{"status": model.JobPaused}); err != nil {
+			return true, common.CommandTasksFailed(), nil
+		}
+		return true, common.CommandTasksPaused(), nil
+	case "resume":
+		if err := c.r.jobRepo.UpdateJob(job.Id, user.UserId, resumeJob(job)); err != nil {
+			return true, common.CommandTasksFailed(), nil
+		}
+		return true, common.CommandTasksResumed(), nil
+	case "run":
+		if job.Kind == model.JobPrompt {
+			if notice := c.r.checkQuota(user); notice != "" {
+				return true, notice, nil
+			}
+		}
+		// Runs outside the schedule, the next run stays where it was. The
+		// quota was checked above.
+		go c.r.executeJob(job, false)
+		return true, common.CommandTasksRunning(), nil
+	case "history":
+		runs, err := c.r.jobRepo.GetJobRuns(job.Id, 10)
+		if err != nil {
+			return true, common.CommandTasksFailed(), nil
+		}
+		if len(runs) == 0 {
+			return true, common.CommandTasksNoHistory(), nil
+		}
+		return true, utils.ListJobRuns(job, runs), nil
+	case "delete":
+		if err := c.r.jobRepo.DeleteJob(job.Id, user.UserId); err != nil {
+			return true, common.CommandTasksFailed(), nil
+		}
+		return true, common.CommandTasksDeleted(), nil
+	}
+
+	field, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return true, common.CommandTasksUsage(), nil
+	}
+	var request scheduler.JobRequest
+	switch strings.ToLower(field) {
+	case "prompt":
+		request.Text = value
+	case "schedule":
+		request.Cron = value
+	default:
+		return true, common.CommandTasksUsage(), nil
+	}
+
+	fields, err := editJob(job, request)
+	if err != nil {
+		return true, common.CommandTasksInvalid(err.Error()), nil
+	}
+	if err := c.r.jobRepo.UpdateJob(job.Id, user.UserId, fields); err != nil {
+		return true, common.CommandTasksFailed(), nil
+	}
+	return true, common.CommandTasksUpdated(), nil
+}
+
+func (c *TasksCommand) add(user *model.User, request scheduler.JobRequest) (bool, string, error) {
+	job, err := newJob(user.UserId, request)
+	if err != nil {
+		return true, common.CommandTasksInvalid(err.Error()), nil
+	}
+	if _, err := c.r.jobRepo.CreateJob(job); err != nil {
+		return true, common.CommandTasksFailed(), nil
+	}
+	return true, common.CommandTasksAdded(job.NextRun.In(jobLocation(job.Timezone)).Format("Mon, 02 Jan 2006 15:04 MST")), nil
+}
+
+// task finds a task by its number in the /tasks list.
+func (c *TasksCommand) task(userId int, number string) (*model.Job, bool, error) {
+	jobs, err := c.r.jobRepo.GetJobsByUserId(userId, model.JobPrompt)
+	if err != nil {
+		return nil, false, err
+	}
+	index, err := strconv.Atoi(number)
+	if err != nil || index < 0 || index >= len(jobs) {
+		return nil, false, nil
+	}
+	return jobs[index], true, nil
+}
+
+// briefingCron runs at HH:MM, 07:00 by default, Monday to Friday.
+func briefingCron(at string) (string, error) {
+	if at == "" {
+		at = "07:00"
+	}
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		return "", fmt.Errorf("time %q must be formatted as HH:MM", at)
+	}
+	return fmt.Sprintf("%d %d * * 1-5", clock.Minute(), clock.Hour()), nil
+}
+
+// splitCron splits a cron expression, five fields or an @ macro, from the
+// text after it.
+func splitCron(args string) (string, string) {
+	fields := strings.Fields(args)
+	size := 5
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		size = 1
+	}
+	if len(fields) <= size {
+		return "", ""
+	}
+	return strings.Join(fields[:size], " "), strings.Join(fields[size:], " ")
+}
+
+type ThinkingCommand struct {
+	r *BotServiceImpl
+}
+
+func NewThinkingCommand(r *BotServiceImpl) CommandFactory {
+	return &ThinkingCommand{r: r}
+}
+
+func (c *ThinkingCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	mode := strings.ToLower(strings.TrimSpace(args))
+	switch mode {
+	case "":
+		return true, common.CommandThinkingShow(reasoningDisplay(user)), nil
+	case model.ReasoningHide, model.ReasoningShow, model.ReasoningStream:
+		if err := c.r.userRepo.UpdateReasoning(user.UserId, mode); err != nil {
+			return true, common.CommandThinkingFailed(), nil
+		}
+		return true, common.CommandThinking(mode), nil
+	default:
+		return true, common.CommandThinkingUsage(), nil
+	}
+}
+
+type QuotaCommand struct {
+	r *BotServiceImpl
+}
+
+func NewQuotaCommand(r *BotServiceImpl) CommandFactory {
+	return &QuotaCommand{r: r}
+}
+
+func (c *QuotaCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	parts := strings.Fields(args)
+	if len(parts) == 0 {
+		return true, utils.ShowQuota(user.Name, userQuota(user), user.Quota != nil), nil
+	}
+
+	if !user.IsAdmin() {
+		return true, common.CommandAdminOnly(), nil
+	}
+
+	userId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return true, common.CommandQuotaUsage(), nil
+	}
+
+	target, err := c.r.userRepo.GetUserById(userId)
+	if err != nil {
+		return true, common.CommandQuotaFailed(), nil
+	}
+	if target == nil {
+		return true, common.CommandUserNotFound(), nil
+	}
+
+	if len(parts) > 1 && !canManage(user, target, target.Role) {
+		return true, common.CommandAdminForbidden(), nil
+	}
+
+	switch {
+	case len(parts) == 1:
+		return true, utils.ShowQuota(target.Name, userQuota(target), target.Quota != nil), nil
+	case len(parts) == 2 && parts[1] == "reset":
+		if err := c.r.userRepo.UpdateQuota(userId, nil); err != nil {
+			return true, common.CommandQuotaFailed(), nil
+		}
+		return true, common.CommandQuotaReset(), nil
+	case len(parts) == 4:
+		limits := make([]int, 3)
+		for i, part := range parts[1:] {
+			limits[i], err = strconv.Atoi(part)
+			if err != nil || limits[i] < 0 {
+				return true, common.CommandQuotaUsage(), nil
+			}
+		}
+
+		override := &quota.Quota{
+			MessagesPerMinute: limits[0],
+			TokensPerDay:      limits[1],
+			ToolsPerHour:      limits[2],
+		}
+		if err := c.r.userRepo.UpdateQuota(userId, override); err != nil {
+			return true, common.CommandQuotaFailed(), nil
+		}
+		return true, common.CommandQuota(), nil
+	default:
+		return true, common.CommandQuotaUsage(), nil
+	}
+}
+
+// AdminCommand runs the wrapped command for the owner and admins only.
+type AdminCommand struct {
+	next CommandFactory
+}
+
+func NewAdminCommand(next CommandFactory) CommandFactory {
+	return &AdminCommand{next: next}
+}
+
+func (c *AdminCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	if !user.IsAdmin() {
+		return true, common.CommandAdminOnly(), nil
+	}
+	return c.next.HandleCommand(user, args)
+}
+
+type UsersCommand struct {
+	r *BotServiceImpl
+}
+
+func NewUsersCommand(r *BotServiceImpl) CommandFactory {
+	return &UsersCommand{r: r}
+}
+
+func (c *UsersCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	users, err := c.r.userRepo.GetUsers()
+	if err != nil {
+		return true, common.CommandUsersFailed(), nil
+	}
+
+	page := 1
+	if args != "" {
+		page, err = strconv.Atoi(args)
+		if err != nil || page < 1 {
+			return true, common.CommandUsersUsage(), nil
+		}
+	}
+
+	return true, utils.ListUsers(users, page, usersPerPage), nil
+}
+
+type BanCommand struct {
+	r *BotServiceImpl
+}
+
+func NewBanCommand(r *BotServiceImpl) CommandFactory {
+	return &BanCommand{r: r}
+}
+
+func (c *BanCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	userId, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil {
+		return true, common.CommandBanUsage(), nil
+	}
+
+	return true, c.r.changeRole(user, userId, model.RoleBanned), nil
+}
+
+type PromoteCommand struct {
+	r *BotServiceImpl
+}
+
+func NewPromoteCommand(r *BotServiceImpl) CommandFactory {
+	return &PromoteCommand{r: r}
+}
+
+func (c *PromoteCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	parts := strings.Fields(args)
+	if len(parts) != 2 {
+		return true, common.CommandPromoteUsage(), nil
+	}
+
+	userId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return true, common.CommandPromoteUsage(), nil
+	}
+
+	role := strings.ToLower(parts[1])
+	if role != model.RoleAdmin && role != model.RoleMember && role != model.RoleBanned {
+		return true, common.CommandPromoteUsage(), nil
+	}
+
+	return true, c.r.changeRole(user, userId, role), nil
+}
+
+type BroadcastCommand struct {
+	r    *BotServiceImpl
+	chat *pkg.TelegramIncommingChat
+}
+
+func NewBroadcastCommand(r *BotServiceImpl, chat *pkg.TelegramIncommingChat) CommandFactory {
+	return &BroadcastCommand{r: r, chat: chat}
+}
+
+func (c *BroadcastCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	text := strings.TrimSpace(args)
+	if text == "" {
+		return true, common.CommandBroadcastUsage(), nil
+	}
+
+	users, err := c.r.userRepo.GetUsers()
+	if err != nil {
+		return true, common.CommandBroadcastFailed(), nil
+	}
+
+	recipients := broadcastRecipients(users)
+	go c.r.broadcast(recipients, text, c.chat.Message.Chat.Id)
+
+	return true, common.CommandBroadcast(len(recipients)), nil
+}
+
+type StatsCommand struct {
+	r *BotServiceImpl
+}
+
+func NewStatsCommand(r *BotServiceImpl) CommandFactory {
+	return &StatsCommand{r: r}
+}
+
+func (c *StatsCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	stats, err := c.r.stats()
+	if err != nil {
+		return true, common.CommandStatsFailed(), nil
+	}
+	return true, stats, nil
+}
+
+type InviteCommand struct {
+	r *BotServiceImpl
+}
+
+func NewInviteCommand(r *BotServiceImpl) CommandFactory {
+	return &InviteCommand{r: r}
+}
+
+func (c *InviteCommand) HandleCommand(user *model.User, args string) (bool, string, error) {
+	code, err := c.r.createInvite(user)
+	if err != nil {
+		return true, common.CommandInviteFailed(), nil
+	}
+	return true, common.CommandInvite(code), nil
+}
+
 type NotFoundCommand struct {
 	r *BotServiceImpl
 }
@@ -187,18 +1082,39 @@ type CommandExecutor struct {
 	commandMap map[string]CommandFactory
 }
 
-func NewCommandExecutor(r *BotServiceImpl) *CommandExecutor {
+func NewCommandExecutor(r *BotServiceImpl, chat *pkg.TelegramIncommingChat) *CommandExecutor {
 	return &CommandExecutor{
 		commandMap: map[string]CommandFactory{
-			"start":   NewStartCommand(r),
-			"menu":    NewStartCommand(r),
-			"help":    NewStartCommand(r),
-			"about":   NewAboutCommand(r),
-			"system":  NewSystemCommand(r),
-			"reset":   NewResetCommand(r),
-			"models":  NewModelsCommand(r),
-			"prompts": NewPromptsCommand(r),
-			"me":      NewMeCommand(r),
+			"start":     NewStartCommand(r),
+			"menu":      NewStartCommand(r),
+			"help":      NewStartCommand(r),
+			"about":     NewAboutCommand(r),
+			"system":    NewSystemCommand(r),
+			"reset":     NewResetCommand(r),
+			"models":    NewModelsCommand(r),
+			"prompts":   NewPromptsCommand(r),
+			"me":        NewMeCommand(r),
+			"memory":    NewMemoryCommand(r),
+			"docs":      NewDocsCommand(r),
+			"voice":     NewVoiceCommand(r),
+			"imagine":   NewImagineCommand(r, chat),
+			"reminders": NewRemindersCommand(r),
+			"tasks":     NewTasksCommand(r),
+			"calendar":  NewCalendarCommand(r, chat),
+			"cashflow":  NewCashflowCommand(r, chat),
+			"receipt":   NewReceiptCommand(r, chat),
+			"branches":  NewBranchesCommand(r),
+			"usage":     NewUsageCommand(r),
+			"quota":     NewQuotaCommand(r),
+			"params":    NewParamsCommand(r),
+			"thinking":  NewThinkingCommand(r),
+
+			"users":     NewAdminCommand(NewUsersCommand(r)),
+			"ban":       NewAdminCommand(NewBanCommand(r)),
+			"promote":   NewAdminCommand(NewPromoteCommand(r)),
+			"broadcast": NewAdminCommand(NewBroadcastCommand(r, chat)),
+			"stats":     NewAdminCommand(NewStatsCommand(r)),
+			"invite":    NewAdminCommand(NewInviteCommand(r)),
 		},
 	}
 }
@@ -217,6 +1133,6 @@ func (r *BotServiceImpl) command(user *model.User, chat *pkg.TelegramIncommingCh
 		return false, "", nil
 	}
 
-	executor := NewCommandExecutor(r)
+	executor := NewCommandExecutor(r, chat)
 	return executor.ExecuteCommand(command, user, args)
 }
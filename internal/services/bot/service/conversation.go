@@ -8,28 +8,52 @@ import (
 	"teo/internal/pkg"
 	"teo/internal/provider"
 	"teo/internal/services/bot/model"
+	"teo/internal/tools/attachment"
 	"teo/internal/utils"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func (r *BotServiceImpl) conversation(user *model.User, chat *pkg.TelegramIncommingChat) (*pkg.TelegramSendMessageStatus, error) {
+	if r.quotaExceeded(user, chat) {
+		return nil, nil
+	}
+
 	messages := r.buildConversationMessages(user, chat)
-	context := r.contextWindow(messages)
+	return r.respond(user, chat, messages)
+}
+
+// respond answers the last message of messages and saves the exchange as the
+// active branch of the conversation.
+func (r *BotServiceImpl) respond(user *model.User, chat *pkg.TelegramIncommingChat, messages []provider.Message) (*pkg.TelegramSendMessageStatus, error) {
+	context := r.contextWindow(user, messages)
 
 	result, response, err := r.factoryChat(user, chat, context)
 	if err != nil {
 		return nil, err
 	}
 
+	if result != nil && result.Ok {
+		response.MessageId = result.Result.MessageId
+		if _, err := pkg.EditTelegramReplyMarkup(chat.Message.Chat.Id, result.Result.MessageId, regenerateKeyboard()); err != nil {
+			log.Println("Error adding regenerate button:", err)
+		}
+	}
+
+	r.replyWithVoice(user, chat, messageText(response))
+
 	if err := r.updateUserMessages(chat, messages, response); err != nil {
 		return nil, err
 	}
 
+	if config.MemoryEnabled {
+		go r.rememberFacts(user, messages[len(messages)-1], response)
+	}
+
 	return result, nil
 }
 
-func (r *BotServiceImpl) contextWindow(history []provider.Message) []provider.Message {
+func (r *BotServiceImpl) contextWindow(user *model.User, history []provider.Message) []provider.Message {
 	total := 10
 
 	if total >= len(history) {
@@ -43,18 +67,14 @@ func (r *BotServiceImpl) contextWindow(history []provider.Message) []provider.Me
 		context[i] = history[len(history)-total+i-1]
 	}
 
-	return context
+	return r.fitContext(user, context)
 }
 
 func (r *BotServiceImpl) buildConversationMessages(user *model.User, chat *pkg.TelegramIncommingChat) []provider.Message {
-	userSystem := fmt.Sprintf("%s\n\n# User Info\n\nUser ID: %v (you can use this User ID for tools/skills if needed)\nToday's date is: %s", user.System, user.UserId, utils.GetCurrentTime())
-	userSystem += utils.GetSkillsInstruction()
-	messages := []provider.Message{
-		{
-			Role:    "system",
-			Content: userSystem,
-		},
-	}
+	newMessage := NewMessage(chat, r.imageInput(user), r.ttsProvider)
+	newMessage.MessageId = chat.Message.MessageId
+
+	messages := []provider.Message{r.systemMessage(user, messageText(newMessage))}
 
 	conv, err := r.conversationRepo.GetActiveConversationByUserId(user.UserId)
 	var convMessages []provider.Message
@@ -75,12 +95,23 @@ func (r *BotServiceImpl) buildConversationMessages(user *model.User, chat *pkg.T
 	}
 
 	messages = append(messages, convMessages...)
-	newMessage := NewMessage(chat, r.llmProvider.ProviderName(), r.ttsProvider)
 	messages = append(messages, newMessage)
 
 	return messages
 }
 
+func (r *BotServiceImpl) systemMessage(user *model.User, query string) provider.Message {
+	userSystem := fmt.Sprintf("%s\n\n# User Info\n\nUser ID: %v (you can use this User ID for tools/skills if needed)\nToday's date is: %s", user.System, user.UserId, utils.GetCurrentTime())
+	userSystem += utils.GetSkillsInstruction()
+	userSystem += r.memoryInstruction(user.UserId, query)
+	userSystem += r.documentInstruction(user.UserId, query)
+
+	return provider.Message{
+		Role:    "system",
+		Content: userSystem,
+	}
+}
+
 func (r *BotServiceImpl) updateUserMessages(chat *pkg.TelegramIncommingChat, messages []provider.Message, response provider.Message) error {
 	messages = append(messages, response)
 	messages = messages[1:] // exclude system message
@@ -112,133 +143,201 @@ func (r *BotServiceImpl) updateUserMessages(chat *pkg.TelegramIncommingChat, mes
 
 func (r *BotServiceImpl) factoryChat(user *model.User, chat *pkg.TelegramIncommingChat, messages []provider.Message) (*pkg.TelegramSendMessageStatus, provider.Message, error) {
 	var err error
-	var content string
 	var response provider.Message
 	var result *pkg.TelegramSendMessageStatus
 
 	log.Println("Processing incoming message")
 	if config.StreamResponse {
 		log.Println("Starting content streaming")
-		result, content, err = r.chatStream(user, chat, messages)
+		result, response, err = r.chatStream(user, chat, messages)
 	} else {
-		result, content, err = r.chat(user, chat, messages)
+		result, response, err = r.chat(user, chat, messages)
+	}
+
+	if err == nil && len(response.Attachments) > 0 {
+		r.deliverAttachments(user, chat, response.Attachments)
 	}
 
+	// The tokens were spent even when the answer could not be delivered
+	r.recordUsage(user, usageKindChat, response.Usage)
+
 	response.Role = "assistant"
-	response.Content = content
 
 	return result, response, err
 }
 
-func (r *BotServiceImpl) chat(user *model.User, chat *pkg.TelegramIncommingChat, messages []provider.Message) (*pkg.TelegramSendMessageStatus, string, error) {
-	res, err := r.llmProvider.Chat(user.Model, messages)
+func (r *BotServiceImpl) chat(user *model.User, chat *pkg.TelegramIncommingChat, messages []provider.Message) (*pkg.TelegramSendMessageStatus, provider.Message, error) {
+	res, err := r.llmProvider.Chat(user.Model, messages, generationParams(user))
 
 	if err != nil {
-		return nil, "", err
+		return nil, provider.Message{}, err
 	}
 
-	content := res.Content.(string)
-	maxTelegramLength := 4096
+	content := messageText(res)
 
-	if len(content) > maxTelegramLength {
-		var chunks []string
-		for i := 0; i < len(content); i += maxTelegramLength {
-			end := i + maxTelegramLength
-			if end > len(content) {
-				end = len(content)
-			}
-			chunks = append(chunks, content[i:end])
-		}
+	if res.Reasoning != "" && reasoningDisplay(user) != model.ReasoningHide {
+		r.sendReasoning(chat, res.Reasoning)
+	}
 
-		send, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, chunks[0], false)
-		if err != nil || !send.Ok {
-			return nil, "", err
-		}
+	send, err := pkg.SendTelegramMarkdown(chat.Message.Chat.Id, chat.Message.MessageId, watermark(user, content, res.Usage))
+	if err != nil {
+		return nil, provider.Message{Usage: res.Usage}, err
+	}
+	if !send.Ok {
+		return nil, provider.Message{Usage: res.Usage}, fmt.Errorf("failed to deliver the response: %s", send.Description)
+	}
 
-		for i := 1; i < len(chunks)-1; i++ {
-			_, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, chunks[i], false)
-			if err != nil {
-				log.Println("Error sending chunk:", err)
-			}
-		}
+	return send, provider.Message{Content: content, Attachments: res.Attachments, Usage: res.Usage}, nil
+}
+
+func watermark(user *model.User, content string, usage *provider.Usage) string {
+	if usage != nil && usage.Model != "" {
+		return utils.FallbackWatermark(content, usage.Model)
+	}
+	return utils.Watermark(content, user.Model, config.WatermarkModel)
+}
+
+func indicator(text string) string {
+	switch text {
+	case "tool":
+		return "⚙️ Using tool..."
+	case "thinking":
+		return "💭 Thinking..."
+	}
+	return "✨ Typing..."
+}
+
+// streamMessages keeps a streamed answer rendered across as many Telegram
+// messages as it needs, editing only the ones whose text changed.
+type streamMessages struct {
+	chat       *pkg.TelegramIncommingChat
+	messageIds []int
+	texts      []string
+	statuses   []*pkg.TelegramSendMessageStatus
+}
 
-		if len(chunks) > 1 {
-			lastChunk := chunks[len(chunks)-1]
-			watermarkedChunk := utils.Watermark(lastChunk, user.Model, config.WatermarkModel)
+func (s *streamMessages) render(content string, loading string) {
+	chatId := s.chat.Message.Chat.Id
+	replyId := s.chat.Message.MessageId
 
-			if len(watermarkedChunk) > maxTelegramLength {
-				_, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, lastChunk, false)
-				if err != nil {
-					log.Println("Error sending final chunk:", err)
-				}
-			} else {
-				_, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, watermarkedChunk, false)
-				if err != nil {
-					log.Println("Error sending final chunk:", err)
-				}
+	// Leave room for the loading indicator below the partial answer, the
+	// thinking indicator can carry a tail of the reasoning
+	chunks := pkg.SplitTelegramMessage(content, config.TelegramParseMode, pkg.TelegramMessageLimit-100-len(loading))
+	if len(chunks) == 0 {
+		chunks = []string{""}
+	}
+
+	for i, chunk := range chunks {
+		text := chunk
+		if i == len(chunks)-1 && loading != "" {
+			text = strings.TrimSpace(text + "\n\n" + loading)
+		}
+
+		if i < len(s.messageIds) {
+			if s.texts[i] == text || text == "" {
+				continue
+			}
+			edit, err := pkg.EditTelegramMessage(chatId, replyId, s.messageIds[i], text, true)
+			if err != nil || !edit.Ok {
+				log.Println(err)
+				continue
 			}
+			s.texts[i] = text
+			s.statuses[i] = edit
+			continue
 		}
 
-		return send, content, nil
+		send, err := pkg.SendTelegramMessage(chatId, replyId, text, true)
+		if err != nil || !send.Ok {
+			log.Println(err)
+			return
+		}
+		s.messageIds = append(s.messageIds, send.Result.MessageId)
+		s.texts = append(s.texts, text)
+		s.statuses = append(s.statuses, send)
 	}
 
-	send, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, utils.Watermark(content, user.Model, config.WatermarkModel), true)
-	if err != nil || !send.Ok {
-		return nil, "", nil
+	// The final answer can need fewer messages than the partial one did
+	for i := len(chunks); i < len(s.messageIds); i++ {
+		if _, err := pkg.DeleteTelegramMessage(chatId, s.messageIds[i]); err != nil {
+			log.Println(err)
+		}
 	}
-
-	return send, content, nil
-}
-
-func indicator(text string) string {
-	if text == "tool" {
-		return "⚙️ Using tool..."
+	if len(chunks) < len(s.messageIds) {
+		s.messageIds = s.messageIds[:len(chunks)]
+		s.texts = s.texts[:len(chunks)]
+		s.statuses = s.statuses[:len(chunks)]
 	}
-	return "✨ Typing..."
 }
 
-func (r *BotServiceImpl) chatStream(user *model.User, chat *pkg.TelegramIncommingChat, messages []provider.Message) (*pkg.TelegramSendMessageStatus, string, error) {
-	messageId := 0
+func (r *BotServiceImpl) chatStream(user *model.User, chat *pkg.TelegramIncommingChat, messages []provider.Message) (*pkg.TelegramSendMessageStatus, provider.Message, error) {
+	var attachments []attachment.Attachment
+	var usage provider.Usage
 	streamingContent := ""
-	lastStreamingContent := ""
 	bufferThreshold := 500
 	bufferedContent := ""
-	maxTelegramLength := 4096
+	display := reasoningDisplay(user)
+	reasoning := ""
+	bufferedReasoning := ""
+	reasoningMessageId := 0
+	collapsedReasoning := ""
 
+	stream := &streamMessages{chat: chat}
 	send, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, indicator("typing"), false)
 	if err != nil || !send.Ok {
 		log.Println(err)
+	} else {
+		stream.messageIds = []int{send.Result.MessageId}
+		stream.texts = []string{indicator("typing")}
+		stream.statuses = []*pkg.TelegramSendMessageStatus{send}
 	}
 
-	messageId = send.Result.MessageId
-	err = r.llmProvider.ChatStream(user.Model, messages, func(partial provider.Message) error {
-		loading := indicator("typing")
-		chunk := partial.Content
-		if partial.ToolCalls != nil {
-			loading = indicator("tool")
-		} else {
-			streamingContent += chunk.(string)
-			bufferedContent += chunk.(string)
+	err = r.llmProvider.ChatStream(user.Model, messages, generationParams(user), func(partial provider.Message) error {
+		if len(partial.Attachments) > 0 {
+			attachments = append(attachments, partial.Attachments...)
+			return nil
 		}
 
-		if len(streamingContent) >= maxTelegramLength-100 {
-			streamingContent = streamingContent[len(lastStreamingContent):]
-			bufferedContent = ""
+		// Tool call rounds report their usage separately, the total is the sum
+		if partial.Usage != nil {
+			usage.Add(partial.Usage)
+			return nil
+		}
 
-			newSend, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, loading, false)
-			if err != nil || !newSend.Ok {
-				log.Println(err)
-			} else {
-				messageId = newSend.Result.MessageId
+		chunk, _ := partial.Content.(string)
+		if partial.Reasoning != "" {
+			thinking := reasoning == "" || display == model.ReasoningStream
+			reasoning += partial.Reasoning
+			bufferedReasoning += partial.Reasoning
+			if chunk == "" && thinking && (len(bufferedReasoning) >= bufferThreshold/2 || bufferedReasoning == reasoning) {
+				loading := indicator("thinking")
+				if display == model.ReasoningStream {
+					loading = thinkingIndicator(reasoning)
+				}
+				stream.render(streamingContent, loading)
+				bufferedReasoning = ""
 			}
-		} else if len(bufferedContent) >= bufferThreshold || partial.ToolCalls != nil {
-			editMessage, err := pkg.EditTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, messageId, streamingContent+"\n\n"+loading, false)
+		}
 
-			lastStreamingContent = streamingContent
+		// Once the answer starts, the reasoning above it collapses into its
+		// own message and the answer continues in a new one
+		if chunk != "" && streamingContent == "" && reasoning != "" && reasoningMessageId == 0 && display != model.ReasoningHide {
+			reasoningMessageId = r.collapseReasoning(chat, stream, reasoning)
+			collapsedReasoning = reasoning
+			stream = &streamMessages{chat: chat}
+			stream.render("", indicator("typing"))
+		}
 
-			if err != nil || !editMessage.Ok {
-				log.Println(err)
-			}
+		loading := indicator("typing")
+		if partial.ToolCalls != nil {
+			loading = indicator("tool")
+		} else {
+			streamingContent += chunk
+			bufferedContent += chunk
+		}
+
+		if len(bufferedContent) >= bufferThreshold || partial.ToolCalls != nil {
+			stream.render(streamingContent, loading)
 			bufferedContent = ""
 		}
 
@@ -246,19 +345,34 @@ func (r *BotServiceImpl) chatStream(user *model.User, chat *pkg.TelegramIncommin
 	})
 
 	if err != nil {
-		return nil, "", err
+		return nil, provider.Message{}, err
 	}
 
-	editMessage, err := pkg.EditTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, messageId, utils.Watermark(streamingContent, user.Model, config.WatermarkModel), true)
-	if err != nil || !editMessage.Ok {
-		_, err := pkg.EditTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, messageId, utils.Watermark(streamingContent, user.Model, config.WatermarkModel), false)
-		if err != nil {
-			log.Println(err)
-			return nil, "", err
+	// Reasoning can continue after the answer started, between tool rounds
+	if display != model.ReasoningHide && reasoning != collapsedReasoning {
+		if reasoningMessageId == 0 {
+			r.sendReasoning(chat, reasoning)
+		} else if _, err := pkg.EditTelegramReasoning(chat.Message.Chat.Id, chat.Message.MessageId, reasoningMessageId, reasoning); err != nil {
+			log.Println("Error updating reasoning:", err)
 		}
 	}
-	err = nil
-	return editMessage, streamingContent, err
+
+	response := provider.Message{Content: streamingContent, Attachments: attachments}
+	if !usage.IsZero() {
+		response.Usage = &usage
+	}
+
+	stream.render(watermark(user, streamingContent, &usage), "")
+	if len(stream.statuses) == 0 {
+		return nil, provider.Message{Usage: response.Usage}, fmt.Errorf("failed to deliver the streamed response")
+	}
+
+	// The last message carries the watermark and the inline buttons
+	return stream.statuses[len(stream.statuses)-1], response, nil
+}
+
+type conversationTitle struct {
+	Title string `json:"title" description:"Title of the conversation, at most 7 words"`
 }
 
 func (r *BotServiceImpl) GenerateConversationTitle(user *model.User, messages []provider.Message) (string, error) {
@@ -281,15 +395,15 @@ func (r *BotServiceImpl) GenerateConversationTitle(user *model.User, messages []
 		{Role: "system", Content: "You are a conversation title assistant. The title must be short, clear, and a maximum of 7 words."},
 		{Role: "user", Content: prompt},
 	}
-	res, err := r.llmProvider.Chat(user.Model, llmMessages)
-	if err != nil || res.Content == nil {
+	var result conversationTitle
+	format := provider.ResponseFormat{Name: "conversation_title", Schema: provider.SchemaOf(result)}
+	res, err := provider.ChatJSON(r.llmProvider, user.Model, llmMessages, provider.GenerationParams{}, format, &result)
+	r.recordUsage(user, usageKindTitle, res.Usage)
+	if err != nil {
 		return defaultTitle, err
 	}
-	if title, ok := res.Content.(string); ok && title != "" {
-		title = strings.ReplaceAll(title, "\n", " ")
-		title = strings.ReplaceAll(title, "\r", " ")
-		title = strings.ReplaceAll(title, "\"", "")
-		title = strings.TrimSpace(title)
+	title := strings.ReplaceAll(result.Title, "\"", "")
+	if title = strings.Join(strings.Fields(title), " "); title != "" {
 		return title, nil
 	}
 	return defaultTitle, nil
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"teo/internal/common"
+	"teo/internal/pkg"
+	"teo/internal/services/bot/model"
+	"teo/internal/vectorstore"
+)
+
+const (
+	documentChunkSize    = 1000
+	documentChunkOverlap = 150
+	documentRecallLimit  = 4
+	documentMinScore     = 0.35
+	maxDocumentSize      = 20 * 1024 * 1024 // Telegram Bot API download limit
+)
+
+func documentNamespace(userId int) string {
+	return fmt.Sprintf("documents_%d", userId)
+}
+
+func isKnowledgeDocument(chat *pkg.TelegramIncommingChat) bool {
+	document := chat.Message.Document
+	return document != nil && !strings.HasPrefix(document.MimeType, "image/")
+}
+
+func (r *BotServiceImpl) ingestDocument(user *model.User, chat *pkg.TelegramIncommingChat) (bool, error) {
+	document := chat.Message.Document
+	notify := func(text string) (bool, error) {
+		send, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, text, false)
+		if err != nil || !send.Ok {
+			return false, err
+		}
+		return false, nil
+	}
+
+	if r.vectorStore == nil {
+		return notify(common.DocumentUnavailable())
+	}
+
+	if pkg.DocumentType(document.FileName, document.MimeType) == "" {
+		return notify(common.DocumentUnsupported())
+	}
+
+	if document.FileSize > maxDocumentSize {
+		return notify(common.DocumentTooLarge())
+	}
+
+	filePath, err := pkg.GetFilePath(document.FileID)
+	if err != nil {
+		log.Printf("Error getting file path for document %s: %v", document.FileName, err)
+		return notify(common.DocumentFailed())
+	}
+
+	data, err := pkg.DownloadTgFile(filePath)
+	if err != nil {
+		log.Printf("Error downloading document %s: %v", document.FileName, err)
+		return notify(common.DocumentFailed())
+	}
+
+	text, err := pkg.ExtractDocumentText(document.FileName, document.MimeType, data)
+	if err != nil {
+		log.Printf("Error extracting text from document %s: %v", document.FileName, err)
+		return notify(common.DocumentFailed())
+	}
+
+	chunks := pkg.ChunkText(text, documentChunkSize, documentChunkOverlap)
+	if len(chunks) == 0 {
+		return notify(common.DocumentEmpty())
+	}
+
+	vectors, err := r.embedTexts(chunks)
+	if err != nil {
+		log.Printf("Error embedding document %s: %v", document.FileName, err)
+		if errors.Is(err, errEmbeddingsUnsupported) {
+			return notify(common.DocumentUnavailable())
+		}
+		return notify(common.DocumentFailed())
+	}
+
+	newDocument, err := r.documentRepo.CreateDocument(&model.Document{
+		UserId:   user.UserId,
+		FileName: document.FileName,
+		MimeType: document.MimeType,
+		FileSize: document.FileSize,
+		Chunks:   len(chunks),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	documentId := newDocument.Id.Hex()
+	records := make([]vectorstore.Record, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = vectorstore.Record{
+			ID:   fmt.Sprintf("%s_%d", documentId, i),
+			Text: chunk,
+			Metadata: map[string]string{
+				"document_id": documentId,
+				"file_name":   document.FileName,
+				"part":        strconv.Itoa(i + 1),
+			},
+			Vector: vectors[i],
+		}
+	}
+
+	if err := r.vectorStore.Upsert(documentNamespace(user.UserId), records); err != nil {
+		log.Printf("Error indexing document %s: %v", document.FileName, err)
+		_ = r.documentRepo.DeleteDocument(newDocument.Id, user.UserId)
+		return notify(common.DocumentFailed())
+	}
+
+	send, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, common.DocumentIndexed(document.FileName, len(chunks)), false)
+	if err != nil || !send.Ok {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *BotServiceImpl) removeDocument(userId int, document *model.Document) error {
+	if r.vectorStore != nil {
+		err := r.vectorStore.DeleteByMetadata(documentNamespace(userId), "document_id", document.Id.Hex())
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.documentRepo.DeleteDocument(document.Id, userId)
+}
+
+func (r *BotServiceImpl) documentInstruction(userId int, query string) string {
+	if r.vectorStore == nil || strings.TrimSpace(query) == "" {
+		return ""
+	}
+
+	namespace := documentNamespace(userId)
+	records, err := r.vectorStore.List(namespace)
+	if err != nil || len(records) == 0 {
+		return ""
+	}
+
+	vector := r.embed(query)
+	if vector == nil {
+		return ""
+	}
+
+	results, err := r.vectorStore.Search(namespace, vector, documentRecallLimit)
+	if err != nil {
+		log.Printf("Error searching documents for user %v: %v", userId, err)
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, result := range results {
+		if result.Score < documentMinScore {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("[%s #%s]\n%s\n\n", result.Metadata["file_name"], result.Metadata["part"], result.Text))
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+
+	return "\n\n# Knowledge Base\n\n" +
+		"Passages retrieved from documents the user uploaded. When your answer uses a passage, cite it inline as [file name #part]. " +
+		"If the passages do not contain the answer, say so instead of guessing.\n\n" + sb.String()
+}
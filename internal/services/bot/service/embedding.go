@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"teo/internal/config"
+	"teo/internal/provider"
+)
+
+var errEmbeddingsUnsupported = provider.ErrEmbeddingsUnsupported
+
+func (r *BotServiceImpl) embedTexts(texts []string) ([][]float64, error) {
+	llm := r.llmProvider
+	if fallback, ok := llm.(*provider.FallbackLLMProvider); ok {
+		llm = fallback.Primary()
+	}
+
+	embedder, ok := llm.(provider.EmbeddingProvider)
+	if !ok {
+		return nil, errEmbeddingsUnsupported
+	}
+
+	return embedder.Embeddings(config.EmbeddingModel, texts)
+}
+
+func (r *BotServiceImpl) embed(text string) []float64 {
+	embeddings, err := r.embedTexts([]string{text})
+	if err != nil || len(embeddings) == 0 {
+		if !errors.Is(err, errEmbeddingsUnsupported) {
+			log.Printf("Error creating embedding: %v", err)
+		}
+		return nil
+	}
+
+	return embeddings[0]
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"teo/internal/common"
+	"teo/internal/config"
+	"teo/internal/pkg"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/attachment"
+	"teo/internal/tools/imagegen"
+	"unicode/utf8"
+)
+
+const maxPhotoCaptionLength = 1024
+
+func (r *BotServiceImpl) generateImage(prompt string) (*imagegen.Image, error) {
+	if r.imageProvider == nil {
+		return nil, errors.New("image generation is not configured")
+	}
+
+	image, err := r.imageProvider.GenerateImage(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &imagegen.Image{
+		Data:          image.Data,
+		MimeType:      image.MimeType,
+		RevisedPrompt: image.RevisedPrompt,
+		Provider:      config.ImageProviderName,
+		Model:         image.Model,
+	}, nil
+}
+
+func conversationImage(file attachment.Attachment, fileId string) model.ConversationImage {
+	return model.ConversationImage{
+		Prompt:        file.Metadata["prompt"],
+		RevisedPrompt: file.Metadata["revised_prompt"],
+		Provider:      file.Metadata["provider"],
+		Model:         file.Metadata["model"],
+		MimeType:      file.MimeType,
+		FileSize:      len(file.Data),
+		FileId:        fileId,
+	}
+}
+
+func (r *BotServiceImpl) recordImage(userId int, image model.ConversationImage) {
+	conv, err := r.conversationRepo.GetActiveConversationByUserId(userId)
+	if err != nil {
+		log.Printf("Error getting active conversation for user %v: %v", userId, err)
+		return
+	}
+
+	if conv == nil {
+		conv, err = r.conversationRepo.CreateConversation(userId, "")
+		if err != nil {
+			log.Printf("Error creating conversation for user %v: %v", userId, err)
+			return
+		}
+	}
+
+	if err := r.conversationRepo.AddConversationImage(conv.Id, image); err != nil {
+		log.Printf("Error saving generated image for user %v: %v", userId, err)
+	}
+}
+
+func photoCaption(prompt string) string {
+	caption := "🎨 " + strings.TrimSpace(prompt)
+	if utf8.RuneCountInString(caption) > maxPhotoCaptionLength {
+		caption = string([]rune(caption)[:maxPhotoCaptionLength-1]) + "…"
+	}
+	return caption
+}
+
+func (r *BotServiceImpl) imagine(user *model.User, chat *pkg.TelegramIncommingChat, prompt string) error {
+	chatId := chat.Message.Chat.Id
+	replyId := chat.Message.MessageId
+
+	status, err := pkg.SendTelegramMessage(chatId, replyId, common.CommandImagineGenerating(), false)
+	if err != nil {
+		log.Println("Error sending imagine status:", err)
+	}
+
+	image, err := r.generateImage(prompt)
+	if err != nil {
+		log.Printf("Error generating image for user %v: %v", user.UserId, err)
+		text := common.CommandImagineFailed()
+		if err.Error() == "SAFETY" {
+			text = common.CommandImagineRejected()
+		}
+		if status != nil && status.Ok {
+			_, err = pkg.EditTelegramMessage(chatId, replyId, status.Result.MessageId, text, false)
+		} else {
+			_, err = pkg.SendTelegramMessage(chatId, replyId, text, false)
+		}
+		return err
+	}
+
+	file := attachment.Attachment{
+		FileName: imagegen.FileName(image.MimeType),
+		MimeType: image.MimeType,
+		Data:     image.Data,
+		Metadata: imagegen.Metadata(prompt, image),
+	}
+
+	fileId := ""
+	send, err := pkg.SendTelegramFile("sendPhoto", "photo", chatId, replyId, file.FileName, file.Data, photoCaption(prompt))
+	if err != nil {
+		log.Printf("Error sending generated image: %v", err)
+		fileId = sendAttachment(chat, "sendDocument", "document", file)
+	} else {
+		fileId = pkg.SentFileId(send.Result)
+	}
+
+	if status != nil && status.Ok {
+		if _, err := pkg.DeleteTelegramMessage(chatId, status.Result.MessageId); err != nil {
+			log.Println("Error deleting imagine status:", err)
+		}
+	}
+
+	r.recordImage(user.UserId, conversationImage(file, fileId))
+	return nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"teo/internal/config"
+	"teo/internal/provider"
+	"teo/internal/services/bot/model"
+	"teo/internal/vectorstore"
+)
+
+const (
+	memoryRecallLimit        = 5
+	memoryDuplicateThreshold = 0.92
+)
+
+type extractedMemory struct {
+	Content  string `json:"content" description:"A short third-person sentence"`
+	Category string `json:"category" enum:"preference,project,person,personal,other"`
+}
+
+type extractedMemories struct {
+	Facts []extractedMemory `json:"facts"`
+}
+
+func messageText(message provider.Message) string {
+	switch content := message.Content.(type) {
+	case string:
+		return content
+	case []provider.ContentItem:
+		var texts []string
+		for _, item := range content {
+			if item.Type == "text" {
+				texts = append(texts, item.Text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+func keywordSimilarity(query, content string) float64 {
+	queryWords := strings.Fields(strings.ToLower(query))
+	contentWords := strings.Fields(strings.ToLower(content))
+	if len(queryWords) == 0 || len(contentWords) == 0 {
+		return 0
+	}
+
+	words := make(map[string]bool, len(contentWords))
+	for _, word := range contentWords {
+		words[strings.Trim(word, ".,!?;:\"'()")] = true
+	}
+
+	matches := 0
+	for _, word := range queryWords {
+		word = strings.Trim(word, ".,!?;:\"'()")
+		if len(word) > 2 && words[word] {
+			matches++
+		}
+	}
+
+	return float64(matches) / float64(len(queryWords))
+}
+
+func (r *BotServiceImpl) recallMemories(userId int, query string, limit int) []*model.Memory {
+	memories, err := r.memoryRepo.GetMemoriesByUserId(userId)
+	if err != nil || len(memories) == 0 {
+		return nil
+	}
+
+	if len(memories) <= limit {
+		return memories
+	}
+
+	queryEmbedding := r.embed(query)
+	scores := make(map[*model.Memory]float64, len(memories))
+	for _, memory := range memories {
+		if queryEmbedding != nil && memory.Embedding != nil {
+			scores[memory] = vectorstore.CosineSimilarity(queryEmbedding, memory.Embedding)
+		} else {
+			scores[memory] = keywordSimilarity(query, memory.Content)
+		}
+	}
+
+	sort.SliceStable(memories, func(i, j int) bool {
+		return scores[memories[i]] > scores[memories[j]]
+	})
+
+	return memories[:limit]
+}
+
+func (r *BotServiceImpl) memoryInstruction(userId int, query string) string {
+	if !config.MemoryEnabled {
+		return ""
+	}
+
+	memories := r.recallMemories(userId, query, memoryRecallLimit)
+	if len(memories) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n# Memory\n\n")
+	sb.WriteString("Things you remember about the user from previous conversations. Use them only when relevant.\n")
+	for _, memory := range memories {
+		sb.WriteString(fmt.Sprintf("- %s\n", memory.Content))
+	}
+
+	return sb.String()
+}
+
+func (r *BotServiceImpl) isDuplicateMemory(existing []*model.Memory, content string, embedding []float64) bool {
+	for _, memory := range existing {
+		if strings.EqualFold(strings.TrimSpace(memory.Content), content) {
+			return true
+		}
+		if embedding != nil && vectorstore.CosineSimilarity(embedding, memory.Embedding) >= memoryDuplicateThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *BotServiceImpl) rememberFacts(user *model.User, userMessage provider.Message, response provider.Message) {
+	userText := messageText(userMessage)
+	if userText == "" {
+		return
+	}
+
+	existing, err := r.memoryRepo.GetMemoriesByUserId(user.UserId)
+	if err != nil {
+		log.Printf("Error getting memories for user %v: %v", user.UserId, err)
+		return
+	}
+
+	var known strings.Builder
+	for _, memory := range existing {
+		known.WriteString(fmt.Sprintf("- %s\n", memory.Content))
+	}
+
+	prompt := "Extract durable facts about the user from the conversation below, such as preferences, projects, people and personal details that will still be true in future conversations. " +
+		"Ignore small talk, one-off requests and anything already known. " +
+		"Respond with an empty list of facts if there is nothing to remember.\n\n" +
+		"# Already known\n" + known.String() + "\n" +
+		"# Conversation\nUser: " + userText + "\nAssistant: " + messageText(response)
+
+	llmMessages := []provider.Message{
+		{Role: "system", Content: "You are a memory extraction assistant. You only answer with valid JSON."},
+		{Role: "user", Content: prompt},
+	}
+	var extracted extractedMemories
+	format := provider.ResponseFormat{Name: "memories", Schema: provider.SchemaOf(extracted)}
+	res, err := provider.ChatJSON(r.llmProvider, user.Model, llmMessages, provider.GenerationParams{}, format, &extracted)
+	r.recordUsage(user, usageKindMemory, res.Usage)
+	if err != nil {
+		log.Printf("Error extracting memories: %v", err)
+		return
+	}
+
+	for _, fact := range extracted.Facts {
+		content := strings.TrimSpace(fact.Content)
+		if content == "" {
+			continue
+		}
+
+		embedding := r.embed(content)
+		if r.isDuplicateMemory(existing, content, embedding) {
+			continue
+		}
+
+		memory, err := r.memoryRepo.CreateMemory(&model.Memory{
+			UserId:    user.UserId,
+			Content:   content,
+			Category:  fact.Category,
+			Embedding: embedding,
+		})
+		if err != nil {
+			log.Printf("Error saving memory for user %v: %v", user.UserId, err)
+			continue
+		}
+		existing = append(existing, memory)
+	}
+}
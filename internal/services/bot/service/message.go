@@ -3,6 +3,8 @@ package service
 import (
 	"fmt"
 	"log"
+	"strings"
+	"teo/internal/config"
 	"teo/internal/pkg"
 	"teo/internal/provider"
 	"teo/internal/utils"
@@ -45,11 +47,25 @@ func (f *VoiceMessage) CreateMessage(chat *pkg.TelegramIncommingChat) provider.M
 		return provider.Message{Role: "user", Content: fmt.Sprintf("[Error downloading audio file: %s]", filePath)}
 	}
 
-	transcribedText, err := f.TTSProvider.SpeechToText(audioData)
-	if err != nil {
-		log.Printf("Error transcribing audio: %v\n", err)
-		return provider.Message{Role: "user", Content: "[Error transcribing audio]"}
+	segments := [][]byte{audioData}
+	if chat.Message.Voice.Duration > config.TTSSegmentSeconds {
+		segments, err = pkg.SplitAudio(audioData, config.TTSSegmentSeconds)
+		if err != nil {
+			log.Printf("Error splitting audio, transcribing as a whole: %v\n", err)
+			segments = [][]byte{audioData}
+		}
+	}
+
+	var transcripts []string
+	for _, segment := range segments {
+		text, err := f.TTSProvider.SpeechToText(segment, config.TTSLanguage)
+		if err != nil {
+			log.Printf("Error transcribing audio: %v\n", err)
+			return provider.Message{Role: "user", Content: "[Error transcribing audio]"}
+		}
+		transcripts = append(transcripts, strings.TrimSpace(text))
 	}
+	transcribedText := strings.Join(transcripts, " ")
 
 	newMessage.Role = "user"
 	newMessage.Content = transcribedText
@@ -128,6 +144,33 @@ func (f *ImageMessageType2) CreateMessage(chat *pkg.TelegramIncommingChat) provi
 	return newMessage
 }
 
+type ImageCaptionMessage struct{}
+
+func NewImageCaptionMessage() MessageFactory {
+	return &ImageCaptionMessage{}
+}
+
+// CreateMessage keeps only the caption for providers that cannot see images.
+func (f *ImageCaptionMessage) CreateMessage(chat *pkg.TelegramIncommingChat) provider.Message {
+	return provider.Message{
+		Role:    "user",
+		Content: utils.GetUnsupportedImageCaption(chat.Message.Caption),
+	}
+}
+
+type DocumentMessage struct{}
+
+func NewDocumentMessage() MessageFactory {
+	return &DocumentMessage{}
+}
+
+func (f *DocumentMessage) CreateMessage(chat *pkg.TelegramIncommingChat) provider.Message {
+	return provider.Message{
+		Role:    "user",
+		Content: utils.GetDocumentCaption(chat.Message.Caption, chat.Message.Document.FileName),
+	}
+}
+
 type TextMessage struct{}
 
 func NewTextMessage() MessageFactory {
@@ -157,15 +200,12 @@ func (f *ReplyToMessage) CreateMessage(chat *pkg.TelegramIncommingChat) provider
 	}
 }
 
-func NewMessage(chat *pkg.TelegramIncommingChat, llmProviderName string, ttsProvider provider.TTSProvider) provider.Message {
+func NewMessage(chat *pkg.TelegramIncommingChat, imageInput provider.ImageInput, ttsProvider provider.TTSProvider) provider.Message {
 	var factory MessageFactory
 
-	isGroq := llmProviderName == "groq"
-	isOpenAI := llmProviderName == "openai"
-	isMistral := llmProviderName == "mistral"
-
 	hasPhoto := chat.Message.Photo != nil
-	hasDocument := chat.Message.Document != nil
+	hasDocument := chat.Message.Document != nil && strings.HasPrefix(chat.Message.Document.MimeType, "image/")
+	hasKnowledgeDocument := chat.Message.Document != nil && !hasDocument
 	isReplyToMessage := chat.Message.ReplyToMessage != nil
 	isVoiceMessage := chat.Message.Voice != nil
 
@@ -177,7 +217,11 @@ func NewMessage(chat *pkg.TelegramIncommingChat, llmProviderName string, ttsProv
 		}
 		factory = NewVoiceMessage(ttsProvider)
 
-	case (hasPhoto || hasDocument) && (isOpenAI || isMistral || isGroq):
+	case hasKnowledgeDocument:
+		factory = NewDocumentMessage()
+	case (hasPhoto || hasDocument) && imageInput == provider.ImageInputNone:
+		factory = NewImageCaptionMessage()
+	case (hasPhoto || hasDocument) && imageInput == provider.ImageInputURL:
 		factory = NewImageMessageType2()
 	case hasPhoto || hasDocument:
 		factory = NewImageMessage()
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"teo/internal/config"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/calendar"
+	"teo/internal/tools/cashflow"
+	"teo/internal/tools/notes"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migratedSuffix marks a legacy data file or directory that was imported.
+const migratedSuffix = ".migrated"
+
+const (
+	// migrationLock keeps instances started together from importing the same
+	// files at once.
+	migrationLock = "migration_tool_data"
+	// migrationLockTTL frees the lock of an instance that died migrating.
+	migrationLockTTL = 10 * time.Minute
+)
+
+// migrateToolData imports the JSON files the calendar, cash flow and notes
+// tools used to keep under data/. Each source is renamed once imported, so
+// the migration runs a single time; a source that fails is kept and retried
+// on the next start. Every record is imported under a key derived from it,
+// so a retry skips what an earlier attempt imported.
+func (r *BotServiceImpl) migrateToolData() {
+	ctx := context.Background()
+	locked, err := config.RedisClient.SetNX(ctx, migrationLock, 1, migrationLockTTL).Result()
+	if err != nil {
+		log.Printf("Warning: Error locking the data migration: %v", err)
+		return
+	}
+	if !locked {
+		log.Println("Another instance is migrating the legacy data, skipping")
+		return
+	}
+	defer func() {
+		if err := config.RedisClient.Del(ctx, migrationLock).Err(); err != nil {
+			log.Printf("Warning: Error unlocking the data migration: %v", err)
+		}
+	}()
+
+	dataDir := "data"
+	r.migrateFile(filepath.Join(dataDir, "calendar", "timezones.json"), r.migrateTimezones)
+	r.migrateFile(filepath.Join(dataDir, "calendar", "calendar.json"), r.migrateCalendar)
+	r.migrateFile(filepath.Join(dataDir, "cashflow", "cashflow.json"), r.migrateCashflow)
+	r.migrateNotes(filepath.Join(dataDir, "notes"))
+}
+
+func (r *BotServiceImpl) migrateFile(path string, migrate func(data []byte) error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: Error reading %s for migration: %v", path, err)
+		return
+	}
+
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := migrate(data); err != nil {
+			log.Printf("Warning: Error migrating %s: %v", path, err)
+			return
+		}
+	}
+	if err := os.Rename(path, path+migratedSuffix); err != nil {
+		log.Printf("Warning: Error renaming migrated %s: %v", path, err)
+		return
+	}
+	log.Printf("Migrated %s", path)
+}
+
+func (r *BotServiceImpl) migrateTimezones(data []byte) error {
+	var timezones map[string]string
+	if err := json.Unmarshal(data, &timezones); err != nil {
+		return err
+	}
+
+	for userID, timezone := range timezones {
+		userId, err := legacyUserId(userID)
+		if err != nil {
+			continue
+		}
+		if err := r.userRepo.UpdateTimezone(userId, timezone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateCalendar upserts events by UID. Events without one get a UID from
+// their legacy ID, so a retried migration does not duplicate them.
+func (r *BotServiceImpl) migrateCalendar(data []byte) error {
+	var schedules []calendar.Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		userId, err := legacyUserId(schedule.UserID)
+		if err != nil {
+			continue
+		}
+		if schedule.UID == "" {
+			schedule.UID = schedule.ID + "@teo"
+		}
+		if _, err := r.calendarRepo.UpsertEventByUID(calendarEvent(userId, schedule)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateCashflow copies the transactions to the account of their user.
+// Categories were shared by every user; each user gets the ones their
+// transactions use. Transactions are imported once by their legacy ID, or
+// their position in the file when they have none.
+func (r *BotServiceImpl) migrateCashflow(data []byte) error {
+	var legacy struct {
+		Transactions []cashflow.Transaction `json:"transactions"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	for i, transaction := range legacy.Transactions {
+		userId, err := legacyUserId(transaction.UserID)
+		if err != nil {
+			continue
+		}
+
+		record := &model.Transaction{
+			UserId:      userId,
+			Type:        string(transaction.Type),
+			Amount:      transaction.Amount,
+			Currency:    string(transaction.Currency),
+			Description: transaction.Description,
+			Date:        transaction.Date,
+			ImportId:    legacyImportId(i, transaction.ID),
+			CreatedAt:   transaction.Date,
+		}
+		if name := strings.TrimSpace(transaction.Category.Name); name != "" {
+			category, _, err := r.cashflowRepo.SaveCategory(userId, name)
+			if err != nil {
+				return err
+			}
+			record.CategoryId = category.Id
+			record.Category = category.Name
+		}
+		if _, err := r.cashflowRepo.CreateTransactionOnce(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func legacyImportId(index int, id string) string {
+	if id != "" {
+		return "legacy:" + id
+	}
+	return fmt.Sprintf("legacy:#%d", index)
+}
+
+// migrateNotes imports data/notes/<user>/<title>.json. Titles are unique per
+// user, so notes imported by an earlier attempt are skipped.
+func (r *BotServiceImpl) migrateNotes(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Error reading %s for migration: %v", dir, err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasSuffix(entry.Name(), migratedSuffix) {
+			continue
+		}
+		userId, err := legacyUserId(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		userDir := filepath.Join(dir, entry.Name())
+		if err := r.migrateUserNotes(userId, userDir); err != nil {
+			log.Printf("Warning: Error migrating %s: %v", userDir, err)
+			continue
+		}
+		if err := os.Rename(userDir, userDir+migratedSuffix); err != nil {
+			log.Printf("Warning: Error renaming migrated %s: %v", userDir, err)
+			continue
+		}
+		log.Printf("Migrated %s", userDir)
+	}
+}
+
+func (r *BotServiceImpl) migrateUserNotes(userId int, dir string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+
+		var note notes.Note
+		if err := json.Unmarshal(data, &note); err != nil {
+			log.Printf("Warning: Skipping unreadable note %s: %v", file.Name(), err)
+			continue
+		}
+		_, err = r.noteRepo.CreateNote(&model.Note{
+			UserId:    userId,
+			Title:     note.Title,
+			Content:   note.Content,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyUserId reads the user id of a legacy record. Records of ids that are
+// not numeric cannot belong to a Telegram user and are skipped.
+func legacyUserId(userID string) (int, error) {
+	userId, err := strconv.Atoi(userID)
+	if err != nil {
+		log.Printf("Warning: Skipping legacy data of invalid user id %q", userID)
+	}
+	return userId, err
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/notes"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// noteStore gives the notes tool access to the notes of a user.
+type noteStore struct {
+	r *BotServiceImpl
+}
+
+func (s *noteStore) CreateNote(userID string, note notes.Note) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.r.noteRepo.CreateNote(&model.Note{
+		UserId:    userId,
+		Title:     note.Title,
+		Content:   note.Content,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return notes.ErrNoteExists
+	}
+	return err
+}
+
+func (s *noteStore) GetNote(userID string, title string) (*notes.Note, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.r.noteRepo.GetNoteByTitle(userId, title)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, notes.ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	note := toolNote(record)
+	return &note, nil
+}
+
+func (s *noteStore) UpdateNote(userID string, title string, content string) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.r.noteRepo.UpdateNoteContent(userId, title, content)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notes.ErrNoteNotFound
+	}
+	return err
+}
+
+func (s *noteStore) DeleteNote(userID string, title string) error {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.r.noteRepo.DeleteNote(userId, title)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notes.ErrNoteNotFound
+	}
+	return err
+}
+
+func (s *noteStore) GetNotes(userID string) ([]notes.Note, error) {
+	return s.notes(userID, s.r.noteRepo.GetNotesByUserId)
+}
+
+func (s *noteStore) SearchNotes(userID string, query string) ([]notes.Note, error) {
+	return s.notes(userID, func(userId int) ([]*model.Note, error) {
+		return s.r.noteRepo.SearchNotes(userId, query)
+	})
+}
+
+func (s *noteStore) GetNotesByDate(userID string, start, end time.Time) ([]notes.Note, error) {
+	return s.notes(userID, func(userId int) ([]*model.Note, error) {
+		return s.r.noteRepo.GetNotesByDate(userId, start, end)
+	})
+}
+
+func (s *noteStore) notes(userID string, find func(userId int) ([]*model.Note, error)) ([]notes.Note, error) {
+	userId, err := toolUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := find(userId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]notes.Note, 0, len(records))
+	for _, record := range records {
+		result = append(result, toolNote(record))
+	}
+	return result, nil
+}
+
+func toolNote(record *model.Note) notes.Note {
+	return notes.Note{
+		Title:     record.Title,
+		Content:   record.Content,
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"teo/internal/provider"
+	"teo/internal/services/bot/model"
+)
+
+// maxStopSequences is the most stop sequences OpenAI accepts, the smallest
+// limit among the providers.
+const maxStopSequences = 4
+
+var paramNames = []string{"temperature", "top_p", "max_tokens", "seed", "stop", "reasoning_effort"}
+
+func generationParams(user *model.User) provider.GenerationParams {
+	params := provider.GenerationParams{}
+	if user.Params != nil {
+		params = *user.Params
+	}
+	params.UserId = user.UserId
+	return params
+}
+
+// setParam parses value into the parameter called name. "reset" clears it so
+// the provider default applies again.
+func setParam(params *provider.GenerationParams, name string, value string) error {
+	reset := value == "reset"
+
+	switch name {
+	case "temperature":
+		if reset {
+			params.Temperature = nil
+			return nil
+		}
+		temperature, err := strconv.ParseFloat(value, 64)
+		if err != nil || temperature < 0 || temperature > 2 {
+			return errors.New("temperature must be a number between 0 and 2")
+		}
+		params.Temperature = &temperature
+	case "top_p":
+		if reset {
+			params.TopP = nil
+			return nil
+		}
+		topP, err := strconv.ParseFloat(value, 64)
+		if err != nil || topP <= 0 || topP > 1 {
+			return errors.New("top_p must be a number above 0 and up to 1")
+		}
+		params.TopP = &topP
+	case "max_tokens":
+		if reset {
+			params.MaxTokens = nil
+			return nil
+		}
+		maxTokens, err := strconv.Atoi(value)
+		if err != nil || maxTokens <= 0 {
+			return errors.New("max_tokens must be a positive whole number")
+		}
+		params.MaxTokens = &maxTokens
+	case "seed":
+		if reset {
+			params.Seed = nil
+			return nil
+		}
+		seed, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("seed must be a whole number")
+		}
+		params.Seed = &seed
+	case "stop":
+		if reset {
+			params.Stop = nil
+			return nil
+		}
+		var stop []string
+		for _, sequence := range strings.Split(value, ",") {
+			if sequence = strings.TrimSpace(sequence); sequence != "" {
+				stop = append(stop, sequence)
+			}
+		}
+		if len(stop) == 0 || len(stop) > maxStopSequences {
+			return errors.New("stop takes 1 to 4 comma separated sequences")
+		}
+		params.Stop = stop
+	case "reasoning_effort":
+		if reset {
+			params.ReasoningEffort = ""
+			return nil
+		}
+		if !slices.Contains(provider.ReasoningEfforts, value) {
+			return errors.New("reasoning_effort must be one of " + strings.Join(provider.ReasoningEfforts, ", "))
+		}
+		params.ReasoningEffort = value
+	default:
+		return errors.New("unknown parameter, use one of " + strings.Join(paramNames, ", "))
+	}
+
+	return nil
+}
+
+// saveParams stores params for the user, or clears them when nothing is left
+// to override.
+func (r *BotServiceImpl) saveParams(userId int, params provider.GenerationParams) error {
+	if params.IsZero() {
+		return r.userRepo.UpdateParams(userId, nil)
+	}
+	return r.userRepo.UpdateParams(userId, &params)
+}
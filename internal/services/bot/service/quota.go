@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"teo/internal/common"
+	"teo/internal/config"
+	"teo/internal/pkg"
+	"teo/internal/provider"
+	"teo/internal/quota"
+	"teo/internal/services/bot/model"
+	"time"
+)
+
+const (
+	quotaMessages = "messages"
+	quotaTokens   = "tokens"
+	quotaTools    = "tools"
+)
+
+// userQuota returns the override set for user by an admin, or the limits of
+// their role.
+func userQuota(user *model.User) quota.Quota {
+	if user.Quota != nil {
+		return *user.Quota
+	}
+	if limits, ok := config.RoleQuotas[user.Role]; ok {
+		return limits
+	}
+	return config.RoleQuotas[model.RoleMember]
+}
+
+func quotaKey(kind string, userId int) string {
+	return fmt.Sprintf("quota_%s_%d", kind, userId)
+}
+
+// checkQuota counts a new message against the limits of user and returns the
+// notice to send instead of answering when one of them is reached. Errors
+// from Redis let the message through.
+func (r *BotServiceImpl) checkQuota(user *model.User) string {
+	limits := userQuota(user)
+	rd := config.RedisClient
+
+	// Tokens and tool runs are charged after the answer, here they only have
+	// to be left over. They are checked first so a refused message does not
+	// use up a message slot.
+	if limits.TokensPerDay > 0 {
+		ok, wait, err := pkg.TakeTokens(rd, quotaKey(quotaTokens, user.UserId), limits.TokensPerDay, 24*time.Hour, 0)
+		if err != nil {
+			log.Println("Error checking token quota:", err)
+		} else if !ok {
+			return common.QuotaTokensExceeded(limits.TokensPerDay, wait)
+		}
+	}
+
+	if limits.ToolsPerHour > 0 {
+		ok, wait, err := pkg.TakeTokens(rd, quotaKey(quotaTools, user.UserId), limits.ToolsPerHour, time.Hour, 0)
+		if err != nil {
+			log.Println("Error checking tool quota:", err)
+		} else if !ok {
+			return common.QuotaToolsExceeded(limits.ToolsPerHour, wait)
+		}
+	}
+
+	if limits.MessagesPerMinute > 0 {
+		ok, wait, err := pkg.TakeTokens(rd, quotaKey(quotaMessages, user.UserId), limits.MessagesPerMinute, time.Minute, 1)
+		if err != nil {
+			log.Println("Error checking message quota:", err)
+		} else if !ok {
+			return common.QuotaMessagesExceeded(limits.MessagesPerMinute, wait)
+		}
+	}
+
+	return ""
+}
+
+// spendQuota charges the tokens and tool runs of a finished request.
+func (r *BotServiceImpl) spendQuota(user *model.User, usage *provider.Usage) {
+	limits := userQuota(user)
+	rd := config.RedisClient
+
+	if limits.TokensPerDay > 0 && usage.TotalTokens > 0 {
+		if err := pkg.SpendTokens(rd, quotaKey(quotaTokens, user.UserId), limits.TokensPerDay, 24*time.Hour, usage.TotalTokens); err != nil {
+			log.Println("Error charging token quota:", err)
+		}
+	}
+
+	if limits.ToolsPerHour > 0 && usage.ToolCalls > 0 {
+		if err := pkg.SpendTokens(rd, quotaKey(quotaTools, user.UserId), limits.ToolsPerHour, time.Hour, usage.ToolCalls); err != nil {
+			log.Println("Error charging tool quota:", err)
+		}
+	}
+}
+
+// quotaExceeded sends the notice when user is over quota and reports whether
+// the message must be dropped.
+func (r *BotServiceImpl) quotaExceeded(user *model.User, chat *pkg.TelegramIncommingChat) bool {
+	notice := r.checkQuota(user)
+	if notice == "" {
+		return false
+	}
+
+	if _, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, notice, true); err != nil {
+		log.Println("Error sending quota notice:", err)
+	}
+	return true
+}
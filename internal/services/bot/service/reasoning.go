@@ -0,0 +1,64 @@
+package service
+
+import (
+	"log"
+	"teo/internal/config"
+	"teo/internal/pkg"
+	"teo/internal/services/bot/model"
+)
+
+// reasoningTail is how much of the latest reasoning the streamed thinking
+// indicator shows.
+const reasoningTail = 300
+
+// reasoningDisplay is how the user wants the reasoning of thinking models
+// shown, the configured default until they pick one.
+func reasoningDisplay(user *model.User) string {
+	if user.Reasoning != "" {
+		return user.Reasoning
+	}
+	return config.ReasoningDisplay
+}
+
+func (r *BotServiceImpl) sendReasoning(chat *pkg.TelegramIncommingChat, reasoning string) {
+	send, err := pkg.SendTelegramReasoning(chat.Message.Chat.Id, chat.Message.MessageId, reasoning)
+	if err != nil || !send.Ok {
+		log.Println("Error sending reasoning:", err)
+	}
+}
+
+// thinkingIndicator shows the end of the reasoning so far, so the user can
+// follow the model while it thinks.
+func thinkingIndicator(reasoning string) string {
+	runes := []rune(reasoning)
+	if len(runes) > reasoningTail {
+		reasoning = "…" + string(runes[len(runes)-reasoningTail:])
+	}
+	return indicator("thinking") + "\n\n" + reasoning
+}
+
+// collapseReasoning turns the placeholder of a stream into the collapsed
+// reasoning, or sends the reasoning when there is no placeholder, and returns
+// the id of the reasoning message.
+func (r *BotServiceImpl) collapseReasoning(chat *pkg.TelegramIncommingChat, stream *streamMessages, reasoning string) int {
+	chatId := chat.Message.Chat.Id
+	replyId := chat.Message.MessageId
+
+	if len(stream.messageIds) > 0 {
+		edit, err := pkg.EditTelegramReasoning(chatId, replyId, stream.messageIds[0], reasoning)
+		if err == nil && edit.Ok {
+			return stream.messageIds[0]
+		}
+		log.Println("Error collapsing reasoning:", err)
+		if _, err := pkg.DeleteTelegramMessage(chatId, stream.messageIds[0]); err != nil {
+			log.Println(err)
+		}
+	}
+
+	send, err := pkg.SendTelegramReasoning(chatId, replyId, reasoning)
+	if err != nil || !send.Ok {
+		log.Println("Error sending reasoning:", err)
+		return 0
+	}
+	return send.Result.MessageId
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"teo/internal/common"
+	"teo/internal/config"
+	"teo/internal/pkg"
+	"teo/internal/provider"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/cashflow"
+	"teo/internal/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	callbackReceiptSave    = "receipt_save"
+	callbackReceiptEdit    = "receipt_edit"
+	callbackReceiptDiscard = "receipt_discard"
+)
+
+// receiptCommand is the caption of a photo to read as a receipt.
+const receiptCommand = "receipt"
+
+// scannedReceipt is what the vision model reads on a receipt. It is also
+// the format of the corrections the user replies with.
+type scannedReceipt struct {
+	IsReceipt bool    `json:"is_receipt" description:"False when the image is not a receipt, an invoice or a bill"`
+	Merchant  string  `json:"merchant" description:"The name of the shop or company"`
+	Date      string  `json:"date" description:"The date of the purchase as YYYY-MM-DD, empty when it is not on the receipt"`
+	Total     float64 `json:"total" description:"The total paid, with taxes, service and tip, as a plain number"`
+	Currency  string  `json:"currency" description:"ISO 4217 code of the total, e.g. IDR, USD or EUR"`
+	Category  string  `json:"category" description:"A short lowercase category, one of the existing ones when it fits"`
+}
+
+func receiptKeyboard(id string) *pkg.InlineKeyboardMarkup {
+	return &pkg.InlineKeyboardMarkup{
+		InlineKeyboard: [][]pkg.InlineKeyboardButton{{
+			{Text: common.ButtonReceiptSave(), CallbackData: callbackReceiptSave + ":" + id},
+			{Text: common.ButtonReceiptEdit(), CallbackData: callbackReceiptEdit + ":" + id},
+			{Text: common.ButtonReceiptDiscard(), CallbackData: callbackReceiptDiscard + ":" + id},
+		}},
+	}
+}
+
+// receiptFileId returns the file of the largest size of a photo, or of an
+// image sent as a file, empty when there is neither.
+func receiptFileId(photo []pkg.Photo, document *pkg.Document) string {
+	if len(photo) > 0 {
+		return photo[len(photo)-1].FileID
+	}
+	if document != nil && strings.HasPrefix(document.MimeType, "image/") {
+		return document.FileID
+	}
+	return ""
+}
+
+// isReceiptPhoto reports whether a photo was sent with /receipt as caption.
+func isReceiptPhoto(chat *pkg.TelegramIncommingChat) bool {
+	isCommand, command, _ := utils.ParseCommand(chat.Message.Caption)
+	return isCommand && strings.EqualFold(command, receiptCommand) && receiptFileId(chat.Message.Photo, chat.Message.Document) != ""
+}
+
+func receiptDetails(transaction model.Transaction) string {
+	return common.ReceiptDetails(transaction.Description, transaction.Date.In(config.Timezone).Format("02 Jan 2006"),
+		cashflow.FormatAmount(transaction.Amount), transaction.Currency, transaction.Category)
+}
+
+// apply copies what was read on a receipt to transaction, keeping the values
+// that are missing or invalid.
+func (s scannedReceipt) apply(transaction *model.Transaction) {
+	if merchant := strings.TrimSpace(s.Merchant); merchant != "" {
+		transaction.Description = merchant
+	}
+	if date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(s.Date), config.Timezone); err == nil {
+		transaction.Date = date
+	}
+	if s.Total > 0 {
+		transaction.Amount = s.Total
+	}
+	if code := strings.TrimSpace(s.Currency); code != "" {
+		if currency, err := cashflow.ParseCurrency(code); err == nil {
+			transaction.Currency = string(currency)
+		}
+	}
+	if category := strings.ToLower(strings.TrimSpace(s.Category)); category != "" {
+		transaction.Category = category
+	}
+}
+
+// categoryNames lists the categories of a user for a prompt.
+func (r *BotServiceImpl) categoryNames(userId int) string {
+	categories, err := r.cashflowRepo.GetCategories(userId)
+	if err != nil {
+		log.Printf("Error getting categories of user %d: %v", userId, err)
+	}
+	if len(categories) == 0 {
+		return "none yet"
+	}
+	names := make([]string, 0, len(categories))
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// receiptMessage asks about the image of fileId the way the model of the
+// user takes images, by URL or inline.
+func receiptMessage(fileId string, imageInput provider.ImageInput, prompt string) (provider.Message, error) {
+	path, err := pkg.GetFilePath(fileId)
+	if err != nil {
+		return provider.Message{}, err
+	}
+
+	if imageInput == provider.ImageInputURL {
+		return provider.Message{
+			Role: "user",
+			Content: []provider.ContentItem{
+				{Type: "text", Text: prompt},
+				{Type: "image_url", ImageURL: &provider.ImageInfo{URL: pkg.TelegramImageURL(path)}},
+			},
+		}, nil
+	}
+
+	base64, err := pkg.ImageURLToBase64(path)
+	if err != nil {
+		return provider.Message{}, err
+	}
+	return provider.Message{Role: "user", Content: prompt, Images: []string{base64}}, nil
+}
+
+// scanReceipt reads a receipt photo with the vision model and sends the
+// expense it found, to save, correct or discard with the buttons of the
+// preview. Nothing is saved before the user confirms.
+func (r *BotServiceImpl) scanReceipt(user *model.User, chat *pkg.TelegramIncommingChat, fileId string) error {
+	notify := func(text string) error {
+		_, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, text, false)
+		return err
+	}
+
+	imageInput := r.imageInput(user)
+	if imageInput == provider.ImageInputNone {
+		return notify(common.ReceiptUnsupported())
+	}
+	if r.quotaExceeded(user, chat) {
+		return nil
+	}
+
+	prompt := "Read this receipt and give the expense it records. " +
+		"Today is " + time.Now().In(config.Timezone).Format("2006-01-02") + ", amounts without a currency sign are in " + string(cashflow.DefaultCurrency()) + ". " +
+		"Use one of the existing categories whenever one fits, else a new short lowercase category such as food, groceries or transport.\n\n" +
+		"# Existing categories\n" + r.categoryNames(user.UserId)
+	message, err := receiptMessage(fileId, imageInput, prompt)
+	if err != nil {
+		log.Printf("Error getting receipt photo: %v", err)
+		return notify(common.ReceiptFailed())
+	}
+
+	llmMessages := []provider.Message{
+		{Role: "system", Content: "You are a bookkeeping assistant that reads receipts. You only answer with valid JSON."},
+		message,
+	}
+	var scanned scannedReceipt
+	format := provider.ResponseFormat{Name: "receipt", Schema: provider.SchemaOf(scanned)}
+	res, err := provider.ChatJSON(r.llmProvider, user.Model, llmMessages, provider.GenerationParams{}, format, &scanned)
+	r.recordUsage(user, usageKindCashflow, res.Usage)
+	if err != nil {
+		log.Printf("Error reading receipt: %v", err)
+		return notify(common.ReceiptFailed())
+	}
+	if !scanned.IsReceipt || scanned.Total <= 0 {
+		return notify(common.ReceiptUnreadable())
+	}
+
+	today := time.Now().In(config.Timezone)
+	transaction := model.Transaction{
+		UserId:   user.UserId,
+		Type:     string(cashflow.Expense),
+		Currency: string(cashflow.DefaultCurrency()),
+		Category: uncategorized,
+		Date:     time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, config.Timezone),
+	}
+	scanned.apply(&transaction)
+
+	send, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, common.ReceiptPreview(receiptDetails(transaction)), false)
+	if err != nil || !send.Ok {
+		return err
+	}
+	pending, err := r.cashflowRepo.CreatePendingImport(&model.PendingImport{
+		UserId:       user.UserId,
+		MessageId:    send.Result.MessageId,
+		FileName:     receiptCommand,
+		Transactions: []model.Transaction{transaction},
+		ExpiresAt:    time.Now().Add(pendingImportExpiry),
+	})
+	if err != nil {
+		log.Printf("Error saving pending receipt: %v", err)
+		_, err = pkg.EditTelegramMessage(chat.Message.Chat.Id, 0, send.Result.MessageId, common.ReceiptFailed(), false)
+		return err
+	}
+	if _, err := pkg.EditTelegramReplyMarkup(chat.Message.Chat.Id, send.Result.MessageId, receiptKeyboard(pending.Id.Hex())); err != nil {
+		log.Println("Error adding receipt buttons:", err)
+	}
+	return nil
+}
+
+// answerReceipt saves or discards a receipt from the buttons of its preview.
+// Edit only explains how to correct it, by replying to the preview.
+func (r *BotServiceImpl) answerReceipt(user *model.User, chat *pkg.TelegramIncommingChat, action string, id string) (*pkg.TelegramSendMessageStatus, error) {
+	callback := chat.CallbackQuery
+	if action == callbackReceiptEdit {
+		answerCallback(callback, common.ReceiptEditHint())
+		return nil, nil
+	}
+
+	pending := r.takePendingImport(user, callback, id)
+	if pending == nil {
+		return nil, nil
+	}
+
+	answerCallback(callback, "")
+	if action == callbackReceiptDiscard || len(pending.Transactions) == 0 {
+		return editPreview(callback, common.ReceiptDiscarded())
+	}
+	if r.savePendingImport(user, pending) == 0 {
+		return editPreview(callback, common.ReceiptFailed())
+	}
+	return editPreview(callback, common.ReceiptSaved(receiptDetails(pending.Transactions[0])))
+}
+
+// correctReceipt applies the reply of the user to the preview of a receipt,
+// e.g. "the total is 52,000", and updates the preview. It reports false when
+// the message is not a reply to a receipt waiting for confirmation.
+func (r *BotServiceImpl) correctReceipt(user *model.User, chat *pkg.TelegramIncommingChat) (bool, error) {
+	reply := chat.Message.ReplyToMessage
+	if reply == nil || strings.TrimSpace(chat.Message.Text) == "" {
+		return false, nil
+	}
+	pending, err := r.cashflowRepo.GetPendingImportByMessage(user.UserId, reply.MessageId)
+	if err != nil || pending == nil || len(pending.Transactions) != 1 {
+		return false, err
+	}
+	if r.quotaExceeded(user, chat) {
+		return true, nil
+	}
+	notify := func(text string) (bool, error) {
+		_, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, text, false)
+		return true, err
+	}
+
+	transaction := pending.Transactions[0]
+	current, _ := json.Marshal(scannedReceipt{
+		IsReceipt: true,
+		Merchant:  transaction.Description,
+		Date:      transaction.Date.In(config.Timezone).Format("2006-01-02"),
+		Total:     transaction.Amount,
+		Currency:  transaction.Currency,
+		Category:  transaction.Category,
+	})
+	prompt := "Apply the correction of the user to the expense read from their receipt and give the whole expense back. Keep what the correction does not change.\n\n" +
+		"# Existing categories\n" + r.categoryNames(user.UserId) + "\n\n" +
+		"# Expense\n" + string(current) + "\n\n" +
+		"# Correction\n" + chat.Message.Text
+
+	llmMessages := []provider.Message{
+		{Role: "system", Content: "You are a bookkeeping assistant. You only answer with valid JSON."},
+		{Role: "user", Content: prompt},
+	}
+	var corrected scannedReceipt
+	format := provider.ResponseFormat{Name: "receipt", Schema: provider.SchemaOf(corrected)}
+	res, err := provider.ChatJSON(r.llmProvider, user.Model, llmMessages, provider.GenerationParams{}, format, &corrected)
+	r.recordUsage(user, usageKindCashflow, res.Usage)
+	if err != nil {
+		log.Printf("Error correcting receipt: %v", err)
+		return notify(common.ReceiptFailed())
+	}
+
+	corrected.apply(&transaction)
+	pending.Transactions[0] = transaction
+	err = r.cashflowRepo.UpdatePendingImport(pending)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notify(common.CashflowImportExpired())
+	}
+	if err != nil {
+		log.Printf("Error updating pending receipt: %v", err)
+		return notify(common.ReceiptFailed())
+	}
+
+	if _, err := pkg.EditTelegramMessage(chat.Message.Chat.Id, 0, pending.MessageId, common.ReceiptPreview(receiptDetails(transaction)), false); err != nil {
+		log.Println("Error updating receipt preview:", err)
+	}
+	if _, err := pkg.EditTelegramReplyMarkup(chat.Message.Chat.Id, pending.MessageId, receiptKeyboard(pending.Id.Hex())); err != nil {
+		log.Println("Error adding receipt buttons:", err)
+	}
+	return notify(common.ReceiptUpdated())
+}
@@ -0,0 +1,440 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"teo/internal/common"
+	"teo/internal/config"
+	"teo/internal/pkg"
+	"teo/internal/provider"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/scheduler"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// jobLease is how long a run may take before another scheduler retries the
+// job, long enough for a prompt with tool calls.
+const jobLease = 5 * time.Minute
+
+// jobRunOutputLimit is how much of the output of a run its history keeps.
+const jobRunOutputLimit = 2000
+
+var runAtLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+}
+
+// runScheduler runs the due jobs every config.SchedulerInterval. Jobs missed
+// while the bot was down are due on the first tick.
+func (r *BotServiceImpl) runScheduler() {
+	ticker := time.NewTicker(config.SchedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		r.runDueJobs()
+		r.postRecurringTransactions()
+		<-ticker.C
+	}
+}
+
+func (r *BotServiceImpl) runDueJobs() {
+	for {
+		job, err := r.jobRepo.ClaimDueJob(time.Now(), jobLease)
+		if err != nil {
+			log.Printf("Error claiming due job: %v", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+
+		// A slow prompt must not hold back the reminders due after it
+		go r.runJob(job)
+	}
+}
+
+func (r *BotServiceImpl) runJob(job *model.Job) {
+	runErr := r.executeJob(job, true)
+
+	nextRun, status := jobNextRun(job, time.Now())
+	if err := r.jobRepo.FinishJobRun(job.Id, nextRun, status, runErr); err != nil {
+		log.Printf("Error saving run of job %s: %v", job.Id.Hex(), err)
+	}
+}
+
+// executeJob runs a job and adds the run to its history. It returns the
+// error of the run, empty when it succeeded. checkQuota is false when the
+// caller already checked the quota of the user, the usage of the run is
+// charged either way.
+func (r *BotServiceImpl) executeJob(job *model.Job, checkQuota bool) string {
+	log.Printf("Running %s job %s of user %v", job.Kind, job.Id.Hex(), job.UserId)
+
+	run := &model.JobRun{JobId: job.Id, UserId: job.UserId, Status: model.JobRunOk, StartedAt: time.Now()}
+	output, usage, err := r.deliverJob(job, checkQuota)
+	if err != nil {
+		log.Printf("Error running job %s: %v", job.Id.Hex(), err)
+		run.Status = model.JobRunFailed
+		run.Error = err.Error()
+	}
+	run.Output = truncateRunOutput(output)
+	if usage != nil {
+		run.TotalTokens = usage.TotalTokens
+		run.ToolCalls = usage.ToolCalls
+	}
+	run.FinishedAt = time.Now()
+
+	if _, err := r.jobRepo.CreateJobRun(run); err != nil {
+		log.Printf("Error saving history of job %s: %v", job.Id.Hex(), err)
+	}
+	return run.Error
+}
+
+// deliverJob sends a reminder, or runs a prompt as a full agent turn with
+// tools and sends the answer with the files the tools made. Nothing is sent
+// to a banned user, and a prompt of a user over quota is not run, the user
+// gets the quota notice instead.
+func (r *BotServiceImpl) deliverJob(job *model.Job, checkQuota bool) (string, *provider.Usage, error) {
+	user, err := r.userRepo.GetUserById(job.UserId)
+	if err != nil {
+		return "", nil, err
+	}
+	if user == nil || user.Role == model.RoleBanned {
+		return "", nil, errors.New("user can no longer use the bot")
+	}
+
+	if job.Kind != model.JobPrompt {
+		send, err := pkg.SendTelegramMessage(job.ChatId, 0, common.JobReminder(job.Text), true)
+		if err != nil {
+			return "", nil, err
+		}
+		if !send.Ok {
+			return "", nil, errors.New(send.Description)
+		}
+		return job.Text, nil, nil
+	}
+	if checkQuota {
+		if notice := r.checkQuota(user); notice != "" {
+			if _, err := pkg.SendTelegramMessage(job.ChatId, 0, notice, true); err != nil {
+				log.Println("Error sending quota notice:", err)
+			}
+			return "", nil, errors.New(notice)
+		}
+	}
+
+	messages := []provider.Message{
+		r.systemMessage(user, job.Text),
+		{Role: "user", Content: job.Text},
+	}
+	res, err := r.llmProvider.Chat(user.Model, messages, generationParams(user))
+	if err != nil {
+		return "", nil, err
+	}
+	r.recordUsage(user, usageKindJob, res.Usage)
+
+	output := messageText(res)
+	text := output
+	if job.Name != "" {
+		text = common.JobTaskResult(job.Name, output)
+	}
+	send, err := pkg.SendTelegramMarkdown(job.ChatId, 0, watermark(user, text, res.Usage))
+	if err != nil {
+		return output, res.Usage, err
+	}
+	if !send.Ok {
+		return output, res.Usage, errors.New(send.Description)
+	}
+
+	if len(res.Attachments) > 0 {
+		r.deliverAttachments(user, jobChat(job), res.Attachments)
+	}
+	return output, res.Usage, nil
+}
+
+// jobChat stands in for the incoming message the helpers that reply to a
+// chat expect, a job answers nothing.
+func jobChat(job *model.Job) *pkg.TelegramIncommingChat {
+	return &pkg.TelegramIncommingChat{
+		Message: pkg.UserMessage{Chat: pkg.Chat{Id: job.ChatId}},
+	}
+}
+
+func truncateRunOutput(output string) string {
+	runes := []rune(output)
+	if len(runes) <= jobRunOutputLimit {
+		return output
+	}
+	return string(runes[:jobRunOutputLimit]) + "…"
+}
+
+// jobNextRun is when a job runs again after now. Recurring jobs skip the
+// runs they missed, one-shot jobs are done.
+func jobNextRun(job *model.Job, now time.Time) (time.Time, string) {
+	if job.Cron == "" {
+		return job.NextRun, model.JobDone
+	}
+
+	schedule, err := pkg.ParseCron(job.Cron)
+	if err != nil {
+		return job.NextRun, model.JobDone
+	}
+	next := schedule.Next(now.In(jobLocation(job.Timezone)))
+	if next.IsZero() {
+		return job.NextRun, model.JobDone
+	}
+	return next, model.JobActive
+}
+
+func jobLocation(timezone string) *time.Location {
+	if timezone != "" {
+		if location, err := time.LoadLocation(timezone); err == nil {
+			return location
+		}
+	}
+	return config.Timezone
+}
+
+// newJob validates a job request and works out its first run.
+func newJob(userId int, request scheduler.JobRequest) (*model.Job, error) {
+	job := &model.Job{
+		UserId: userId,
+		// Private chats have the id of the user
+		ChatId: userId,
+		Name:   strings.TrimSpace(request.Name),
+		Kind:   strings.ToLower(strings.TrimSpace(request.Kind)),
+		Text:   strings.TrimSpace(request.Text),
+		Cron:   strings.TrimSpace(request.Cron),
+		Status: model.JobActive,
+	}
+	if job.Kind == "" {
+		job.Kind = model.JobReminder
+	}
+	if job.Kind != model.JobReminder && job.Kind != model.JobPrompt {
+		return nil, fmt.Errorf("kind must be %s or %s", model.JobReminder, model.JobPrompt)
+	}
+	if job.Text == "" {
+		return nil, errors.New("text is required")
+	}
+
+	location := config.Timezone
+	if request.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(request.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown time zone %s", request.Timezone)
+		}
+	}
+	job.Timezone = location.String()
+	now := time.Now().In(location)
+
+	if job.Cron != "" {
+		schedule, err := pkg.ParseCron(job.Cron)
+		if err != nil {
+			return nil, err
+		}
+		job.NextRun = schedule.Next(now)
+		if job.NextRun.IsZero() {
+			return nil, errors.New("the cron expression never runs")
+		}
+		return job, nil
+	}
+
+	runAt, err := parseRunAt(request.RunAt, location)
+	if err != nil {
+		return nil, err
+	}
+	if runAt.Before(now.Add(-time.Minute)) {
+		return nil, fmt.Errorf("%s is in the past, it is now %s", runAt.Format("2006-01-02 15:04 MST"), now.Format("2006-01-02 15:04 MST"))
+	}
+	job.RunAt = runAt
+	job.NextRun = runAt
+	return job, nil
+}
+
+// editJob applies the fields of request that are set to job. A new schedule
+// or time zone works out the next run again, a paused job stays paused.
+func editJob(job *model.Job, request scheduler.JobRequest) (bson.M, error) {
+	fields := bson.M{}
+	if name := strings.TrimSpace(request.Name); name != "" {
+		fields["name"] = name
+	}
+	if kind := strings.ToLower(strings.TrimSpace(request.Kind)); kind != "" {
+		if kind != model.JobReminder && kind != model.JobPrompt {
+			return nil, fmt.Errorf("kind must be %s or %s", model.JobReminder, model.JobPrompt)
+		}
+		fields["kind"] = kind
+	}
+	if text := strings.TrimSpace(request.Text); text != "" {
+		fields["text"] = text
+	}
+	if request.Cron == "" && request.RunAt == "" && request.Timezone == "" {
+		return fields, nil
+	}
+
+	schedule := scheduler.JobRequest{Text: job.Text, Kind: job.Kind, Cron: job.Cron, Timezone: job.Timezone}
+	if request.Timezone != "" {
+		schedule.Timezone = request.Timezone
+	}
+	switch {
+	case request.Cron != "":
+		schedule.Cron = request.Cron
+	case request.RunAt != "":
+		schedule.Cron = ""
+		schedule.RunAt = request.RunAt
+	case job.Cron == "":
+		// A one-shot job keeps its wall clock time in the new time zone
+		schedule.RunAt = job.RunAt.In(jobLocation(job.Timezone)).Format("2006-01-02 15:04:05")
+	}
+
+	updated, err := newJob(job.UserId, schedule)
+	if err != nil {
+		return nil, err
+	}
+	fields["cron"] = updated.Cron
+	fields["runAt"] = updated.RunAt
+	fields["timezone"] = updated.Timezone
+	fields["nextRun"] = updated.NextRun
+	return fields, nil
+}
+
+// resumeJob works out when a paused job runs next. A recurring job skips the
+// runs it missed, a one-shot job that came due while paused runs right away.
+func resumeJob(job *model.Job) bson.M {
+	nextRun, status := jobNextRun(job, time.Now())
+	if job.Cron == "" {
+		status = model.JobActive
+	}
+	return bson.M{"status": status, "nextRun": nextRun}
+}
+
+func parseRunAt(value string, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if runAt, err := time.Parse(time.RFC3339, value); err == nil {
+		return runAt, nil
+	}
+	for _, layout := range runAtLayouts {
+		if runAt, err := time.ParseInLocation(layout, value, location); err == nil {
+			return runAt, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("run_at %q must be formatted as 2006-01-02 15:04", value)
+}
+
+func jobSchedule(job *model.Job) string {
+	if job.Cron != "" {
+		return "cron " + job.Cron + " " + job.Timezone
+	}
+	return "once"
+}
+
+// jobStore gives the schedule tool access to the jobs of a user.
+type jobStore struct {
+	r *BotServiceImpl
+}
+
+func (s *jobStore) CreateJob(userId int, request scheduler.JobRequest) (*scheduler.Job, error) {
+	job, err := newJob(userId, request)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err = s.r.jobRepo.CreateJob(job)
+	if err != nil {
+		return nil, err
+	}
+	return toolJob(job), nil
+}
+
+func (s *jobStore) ListJobs(userId int) ([]*scheduler.Job, error) {
+	jobs, err := s.r.jobRepo.GetJobsByUserId(userId, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*scheduler.Job, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, toolJob(job))
+	}
+	return result, nil
+}
+
+func (s *jobStore) UpdateJob(userId int, id string, request scheduler.JobRequest) (*scheduler.Job, error) {
+	job, err := s.job(userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := editJob(job, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.r.jobRepo.UpdateJob(job.Id, userId, fields); err != nil {
+		return nil, err
+	}
+	return s.updated(job)
+}
+
+func (s *jobStore) PauseJob(userId int, id string, paused bool) (*scheduler.Job, error) {
+	job, err := s.job(userId, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == model.JobDone {
+		return nil, errors.New("the job has already run")
+	}
+
+	fields := bson.M{"status": model.JobPaused}
+	if !paused {
+		fields = resumeJob(job)
+	}
+	if err := s.r.jobRepo.UpdateJob(job.Id, userId, fields); err != nil {
+		return nil, err
+	}
+	return s.updated(job)
+}
+
+func (s *jobStore) job(userId int, id string) (*model.Job, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job id %s", id)
+	}
+	job, err := s.r.jobRepo.GetJobById(objectId, userId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("no job with id %s", id)
+	}
+	return job, err
+}
+
+func (s *jobStore) updated(job *model.Job) (*scheduler.Job, error) {
+	job, err := s.r.jobRepo.GetJobById(job.Id, job.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return toolJob(job), nil
+}
+
+func (s *jobStore) DeleteJob(userId int, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid job id %s", id)
+	}
+	return s.r.jobRepo.DeleteJob(objectId, userId)
+}
+
+func toolJob(job *model.Job) *scheduler.Job {
+	return &scheduler.Job{
+		Id:       job.Id.Hex(),
+		Name:     job.Name,
+		Kind:     job.Kind,
+		Text:     job.Text,
+		Schedule: jobSchedule(job),
+		Status:   job.Status,
+		NextRun:  job.NextRun.In(jobLocation(job.Timezone)),
+	}
+}
@@ -7,6 +7,12 @@ import (
 	"teo/internal/provider"
 	"teo/internal/services/bot/model"
 	"teo/internal/services/bot/repository"
+	"teo/internal/tools/calendar"
+	"teo/internal/tools/cashflow"
+	"teo/internal/tools/imagegen"
+	"teo/internal/tools/notes"
+	"teo/internal/tools/scheduler"
+	"teo/internal/vectorstore"
 )
 
 type BotService interface {
@@ -20,43 +26,158 @@ type BotService interface {
 type BotServiceImpl struct {
 	userRepo         repository.UserRepository
 	conversationRepo repository.ConversationRepository
+	memoryRepo       repository.MemoryRepository
+	documentRepo     repository.DocumentRepository
+	usageRepo        repository.UsageRepository
+	jobRepo          repository.JobRepository
+	calendarRepo     repository.CalendarRepository
+	cashflowRepo     repository.CashflowRepository
+	noteRepo         repository.NoteRepository
 	llmProvider      provider.LLMProvider
 	ttsProvider      provider.TTSProvider
+	speechProvider   provider.SpeechProvider
+	imageProvider    provider.ImageProvider
+	vectorStore      vectorstore.VectorStore
+	prices           pkg.PriceTable
 }
 
-func NewBotService(userRepo repository.UserRepository, conversationRepo repository.ConversationRepository) BotService {
+func NewBotService(userRepo repository.UserRepository, conversationRepo repository.ConversationRepository, memoryRepo repository.MemoryRepository, documentRepo repository.DocumentRepository, usageRepo repository.UsageRepository, jobRepo repository.JobRepository, calendarRepo repository.CalendarRepository, cashflowRepo repository.CashflowRepository, noteRepo repository.NoteRepository) BotService {
+	for _, compatible := range config.OpenAICompatibles {
+		provider.RegisterOpenAICompatible(provider.OpenAICompatibleOptions{
+			Name:        compatible.Name,
+			PathPrefix:  compatible.PathPrefix,
+			Headers:     compatible.Headers,
+			Models:      compatible.Models,
+			Tools:       compatible.Tools,
+			Vision:      compatible.Vision,
+			StreamUsage: compatible.StreamUsage,
+			Embeddings:  compatible.Embeddings,
+			JSONSchema:  compatible.JSONSchema,
+		}, compatible.DefaultModel)
+	}
+
+	capabilities, err := provider.LoadCapabilityTable(config.CapabilitiesPath)
+	if err != nil {
+		log.Printf("Warning: Error loading model capabilities %s: %v. Only discovered capabilities will be used.", config.CapabilitiesPath, err)
+	}
+	provider.SetCapabilityOverrides(capabilities)
+
 	llmProvider, err := provider.CreateLLMProvider(config.LLMProviderName, config.LLMProviderAPIKey)
 	if err != nil {
 		log.Fatalf("Error create LLM provider - %s: %v", config.LLMProviderName, err)
 	}
 
-	ttsProvider, err := provider.CreateTTSProvider(config.TTSProviderName, config.TTSProviderAPIKey)
+	if len(config.LLMFallbacks) > 0 {
+		var fallbacks []provider.FallbackLLM
+		for _, fallback := range config.LLMFallbacks {
+			fallbackProvider, err := provider.NewLLMProvider(fallback.Provider, fallback.BaseURL, fallback.APIKey)
+			if err != nil {
+				log.Printf("Warning: Error creating fallback LLM provider %s: %v", fallback.Provider, err)
+				continue
+			}
+			fallbacks = append(fallbacks, provider.FallbackLLM{Provider: fallbackProvider, Model: fallback.Model})
+		}
+		llmProvider = provider.NewFallbackLLMProvider(llmProvider, fallbacks, config.CircuitBreakerThreshold, config.CircuitBreakerCooldown)
+	}
+
+	ttsProvider, err := provider.CreateTTSProvider(config.TTSProviderName, config.TTSProviderBaseURL, config.TTSProviderAPIKey, config.TTSModel)
 	if err != nil {
 		log.Printf("Warning: Error creating TTS provider %s: %v. TTS functionality might be affected or disabled depending on message handling logic.", config.TTSProviderName, err)
 	}
 
-	return &BotServiceImpl{
+	if config.TTSFallbackProviderName != "" {
+		fallback, err := provider.CreateTTSProvider(config.TTSFallbackProviderName, config.TTSFallbackProviderBaseURL, config.TTSFallbackProviderAPIKey, config.TTSFallbackModel)
+		if err != nil {
+			log.Printf("Warning: Error creating fallback TTS provider %s: %v", config.TTSFallbackProviderName, err)
+		} else if ttsProvider != nil {
+			ttsProvider = provider.NewFallbackTTSProvider(ttsProvider, fallback)
+		} else {
+			ttsProvider = fallback
+		}
+	}
+
+	var speechProvider provider.SpeechProvider
+	if config.SpeechProviderName != "" {
+		speechProvider, err = provider.CreateSpeechProvider(config.SpeechProviderName, config.SpeechProviderBaseURL, config.SpeechProviderAPIKey, config.SpeechModel, config.SpeechVoice)
+		if err != nil {
+			log.Printf("Warning: Error creating speech provider %s: %v. Voice replies will be disabled.", config.SpeechProviderName, err)
+		}
+	}
+
+	var imageProvider provider.ImageProvider
+	if config.ImageProviderName != "" {
+		imageProvider, err = provider.CreateImageProvider(config.ImageProviderName, config.ImageProviderBaseURL, config.ImageProviderAPIKey, config.ImageModel, config.ImageSize)
+		if err != nil {
+			log.Printf("Warning: Error creating image provider %s: %v. Image generation will be disabled.", config.ImageProviderName, err)
+		}
+	}
+
+	service := &BotServiceImpl{
 		userRepo:         userRepo,
 		conversationRepo: conversationRepo,
+		memoryRepo:       memoryRepo,
+		documentRepo:     documentRepo,
+		usageRepo:        usageRepo,
+		jobRepo:          jobRepo,
+		calendarRepo:     calendarRepo,
+		cashflowRepo:     cashflowRepo,
+		noteRepo:         noteRepo,
 		llmProvider:      llmProvider,
 		ttsProvider:      ttsProvider,
+		speechProvider:   speechProvider,
+		imageProvider:    imageProvider,
+	}
+
+	if imageProvider != nil {
+		imagegen.SetGenerator(service.generateImage)
+	}
+
+	service.migrateToolData()
+	calendar.SetStore(&calendarStore{r: service})
+	cashflow.SetStore(&cashflowStore{r: service})
+	rates, err := cashflow.LoadRates(config.ExchangeRatesPath)
+	if err != nil {
+		log.Printf("Warning: Error loading exchange rates %s: %v. Built-in rates will be used offline.", config.ExchangeRatesPath, err)
+	}
+	cashflow.SetExchangeRates(cashflow.NewExchangeRates(config.ExchangeRateURL, rates))
+	notes.SetStore(&noteStore{r: service})
+
+	scheduler.SetStore(&jobStore{r: service})
+	go service.runScheduler()
+
+	vectorStore, err := vectorstore.NewFileStore(config.VectorStorePath)
+	if err != nil {
+		log.Printf("Warning: Error creating vector store at %s: %v. Document retrieval will be disabled.", config.VectorStorePath, err)
+	} else {
+		service.vectorStore = vectorStore
+	}
+
+	prices, err := pkg.LoadPriceTable(config.PriceTablePath)
+	if err != nil {
+		log.Printf("Warning: Error loading price table %s: %v. Usage will be recorded without cost.", config.PriceTablePath, err)
+	} else {
+		service.prices = prices
 	}
+
+	return service
 }
 
 func (r *BotServiceImpl) checkUser(chat *pkg.TelegramIncommingChat) (*model.User, error) {
 	var user *model.User
 	var err error
-	user, err = r.userRepo.GetUserById(chat.Message.From.Id)
+	sender := chat.Sender()
+	user, err = r.userRepo.GetUserById(sender.Id)
 	if err != nil {
 		return nil, err
 	}
 
 	if user == nil {
 		newUser := model.User{
-			UserId:   chat.Message.From.Id,
-			Name:     chat.Message.Chat.FirstName,
+			UserId:   sender.Id,
+			Name:     sender.FirstName,
 			Provider: r.llmProvider.ProviderName(),
 			Model:    r.llmProvider.DefaultModel(""),
+			Role:     newUserRole(sender.Id),
 		}
 		user, err = r.userRepo.CreateUser(&newUser)
 
@@ -65,6 +186,14 @@ func (r *BotServiceImpl) checkUser(chat *pkg.TelegramIncommingChat) (*model.User
 		}
 	}
 
+	// Users created before roles existed were all plain users
+	if user.Role == "" || user.Role == "user" {
+		if err := r.userRepo.UpdateRole(user.UserId, model.RoleMember); err != nil {
+			return nil, err
+		}
+		user.Role = model.RoleMember
+	}
+
 	return user, nil
 }
 
@@ -102,6 +231,11 @@ func (r *BotServiceImpl) Bot(chat *pkg.TelegramIncommingChat) (*pkg.TelegramSend
 		return nil, err
 	}
 
+	handled, err := r.access(user, chat)
+	if handled || err != nil {
+		return nil, err
+	}
+
 	if user.Provider != r.llmProvider.ProviderName() {
 		user, err = r.changeProviderAndModel(user)
 		if err != nil {
@@ -109,12 +243,52 @@ func (r *BotServiceImpl) Bot(chat *pkg.TelegramIncommingChat) (*pkg.TelegramSend
 		}
 	}
 
+	if chat.CallbackQuery != nil {
+		return r.callbackQuery(user, chat)
+	}
+
+	if chat.EditedMessage != nil {
+		return r.editedMessage(user, chat)
+	}
+
 	command, response, err = r.command(user, chat)
 	if err != nil {
 		return nil, err
 	}
 
 	if !command {
+		if isReceiptPhoto(chat) {
+			fileId := receiptFileId(chat.Message.Photo, chat.Message.Document)
+			return nil, r.scanReceipt(user, chat, fileId)
+		}
+
+		corrected, err := r.correctReceipt(user, chat)
+		if corrected || err != nil {
+			return nil, err
+		}
+
+		if isCalendarFile(chat) {
+			return nil, r.importCalendar(user, chat)
+		}
+
+		if isStatementFile(chat) {
+			imported, err := r.importStatement(user, chat)
+			if imported || err != nil {
+				return nil, err
+			}
+		}
+
+		if isKnowledgeDocument(chat) {
+			indexed, err := r.ingestDocument(user, chat)
+			if err != nil {
+				return nil, err
+			}
+
+			if !indexed || chat.Message.Caption == "" {
+				return nil, nil
+			}
+		}
+
 		conv, err := r.conversation(user, chat)
 		if err != nil {
 			return nil, err
@@ -123,7 +297,7 @@ func (r *BotServiceImpl) Bot(chat *pkg.TelegramIncommingChat) (*pkg.TelegramSend
 		return conv, nil
 	}
 
-	if command {
+	if command && response != "" {
 		send, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, response, true)
 		if err != nil || !send.Ok {
 			return nil, err
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"teo/internal/common"
+	"teo/internal/config"
+	"teo/internal/pkg"
+	"teo/internal/provider"
+	"teo/internal/services/bot/model"
+	"teo/internal/tools/cashflow"
+	"teo/internal/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	callbackImportConfirm = "import_confirm"
+	callbackImportCancel  = "import_cancel"
+)
+
+const (
+	// maxImportTransactions bounds the transactions of a statement, each one
+	// is categorised by the model.
+	maxImportTransactions = 1000
+	// importCategorizeBatch is how many transactions are categorised by one
+	// request.
+	importCategorizeBatch = 100
+	importPreviewLimit    = 15
+	pendingImportExpiry   = 24 * time.Hour
+	// uncategorized is the category of transactions the model left out.
+	uncategorized = "other"
+)
+
+type importCategory struct {
+	Index    int    `json:"index" description:"The number of the transaction"`
+	Category string `json:"category" description:"A short lowercase category, one of the existing ones when it fits"`
+}
+
+type importCategories struct {
+	Transactions []importCategory `json:"transactions"`
+}
+
+func isStatementFile(chat *pkg.TelegramIncommingChat) bool {
+	document := chat.Message.Document
+	return document != nil && cashflow.IsStatementFile(document.FileName)
+}
+
+func importKeyboard(id string, count int) *pkg.InlineKeyboardMarkup {
+	return &pkg.InlineKeyboardMarkup{
+		InlineKeyboard: [][]pkg.InlineKeyboardButton{{
+			{Text: common.ButtonImport(count), CallbackData: callbackImportConfirm + ":" + id},
+			{Text: common.ButtonCancel(), CallbackData: callbackImportCancel + ":" + id},
+		}},
+	}
+}
+
+// importStatement reads the transactions of a bank statement, drops the ones
+// the user already has and sends a preview of the others, categorised by
+// the model, to confirm before they are saved. It reports false for a CSV
+// file that is not a statement, which is then handled as any document.
+func (r *BotServiceImpl) importStatement(user *model.User, chat *pkg.TelegramIncommingChat) (bool, error) {
+	document := chat.Message.Document
+	notify := func(text string) (bool, error) {
+		_, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, text, false)
+		return true, err
+	}
+
+	if document.FileSize > maxDocumentSize {
+		return notify(common.DocumentTooLarge())
+	}
+
+	filePath, err := pkg.GetFilePath(document.FileID)
+	if err != nil {
+		log.Printf("Error getting file path for statement %s: %v", document.FileName, err)
+		return notify(common.CashflowImportFailed())
+	}
+
+	data, err := pkg.DownloadTgFile(filePath)
+	if err != nil {
+		log.Printf("Error downloading statement %s: %v", document.FileName, err)
+		return notify(common.CashflowImportFailed())
+	}
+
+	transactions, err := cashflow.ParseStatement(document.FileName, data, cashflow.DefaultCurrency())
+	if errors.Is(err, cashflow.ErrNotStatement) && strings.EqualFold(filepath.Ext(document.FileName), ".csv") {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Error parsing statement %s: %v", document.FileName, err)
+		return notify(common.CashflowImportInvalid(err.Error()))
+	}
+	if len(transactions) == 0 {
+		return notify(common.CashflowImportEmpty())
+	}
+	if len(transactions) > maxImportTransactions {
+		return notify(common.CashflowImportTooLarge(maxImportTransactions))
+	}
+
+	fresh, duplicates, err := cashflow.Deduplicate(strconv.Itoa(user.UserId), transactions)
+	if err != nil {
+		log.Printf("Error checking duplicates of statement %s: %v", document.FileName, err)
+		return notify(common.CashflowImportFailed())
+	}
+	if len(fresh) == 0 {
+		return notify(common.CashflowImportNothingNew(duplicates))
+	}
+
+	if r.quotaExceeded(user, chat) {
+		return true, nil
+	}
+	r.categorizeTransactions(user, fresh)
+
+	records := make([]model.Transaction, 0, len(fresh))
+	for _, transaction := range fresh {
+		records = append(records, model.Transaction{
+			UserId:      user.UserId,
+			Type:        string(transaction.Type),
+			Amount:      transaction.Amount,
+			Currency:    string(transaction.Currency),
+			Category:    transaction.Category.Name,
+			Description: transaction.Description,
+			Date:        transaction.Date,
+			ImportId:    transaction.ImportID,
+		})
+	}
+	pending, err := r.cashflowRepo.CreatePendingImport(&model.PendingImport{
+		UserId:       user.UserId,
+		FileName:     document.FileName,
+		Transactions: records,
+		ExpiresAt:    time.Now().Add(pendingImportExpiry),
+	})
+	if err != nil {
+		log.Printf("Error saving pending import of %s: %v", document.FileName, err)
+		return notify(common.CashflowImportFailed())
+	}
+
+	preview := common.CashflowImportPreview(document.FileName, len(fresh), duplicates, utils.ListImportPreview(fresh, importPreviewLimit))
+	send, err := pkg.SendTelegramMessage(chat.Message.Chat.Id, chat.Message.MessageId, preview, false)
+	if err != nil || !send.Ok {
+		return true, err
+	}
+	if _, err := pkg.EditTelegramReplyMarkup(chat.Message.Chat.Id, send.Result.MessageId, importKeyboard(pending.Id.Hex(), len(fresh))); err != nil {
+		log.Println("Error adding import buttons:", err)
+	}
+	return true, nil
+}
+
+// categorizeTransactions asks the model for the category of each
+// transaction, preferring the categories the user already has. Transactions
+// it leaves out, or all of them when it fails, are put in "other".
+func (r *BotServiceImpl) categorizeTransactions(user *model.User, transactions []cashflow.Transaction) {
+	for i := range transactions {
+		transactions[i].Category.Name = uncategorized
+	}
+
+	known := r.categoryNames(user.UserId)
+	for start := 0; start < len(transactions); start += importCategorizeBatch {
+		batch := transactions[start:min(start+importCategorizeBatch, len(transactions))]
+
+		var rows strings.Builder
+		for i, t := range batch {
+			rows.WriteString(fmt.Sprintf("%d. %s | %s | %s %s | %s\n", i, t.Date.Format("2006-01-02"), t.Type, cashflow.FormatAmount(t.Amount), t.Currency, t.Description))
+		}
+		prompt := "Categorise each bank transaction below for a personal cash flow. " +
+			"Use one of the existing categories whenever one fits, else a new short lowercase category such as food, transport, salary or transfer.\n\n" +
+			"# Existing categories\n" + known + "\n\n" +
+			"# Transactions (number. date | type | amount | description)\n" + rows.String()
+
+		llmMessages := []provider.Message{
+			{Role: "system", Content: "You are a bookkeeping assistant. You only answer with valid JSON."},
+			{Role: "user", Content: prompt},
+		}
+		var result importCategories
+		format := provider.ResponseFormat{Name: "transaction_categories", Schema: provider.SchemaOf(result)}
+		res, err := provider.ChatJSON(r.llmProvider, user.Model, llmMessages, provider.GenerationParams{}, format, &result)
+		r.recordUsage(user, usageKindCashflow, res.Usage)
+		if err != nil {
+			log.Printf("Error categorising transactions: %v", err)
+			continue
+		}
+
+		for _, entry := range result.Transactions {
+			category := strings.ToLower(strings.TrimSpace(entry.Category))
+			if entry.Index < 0 || entry.Index >= len(batch) || category == "" {
+				continue
+			}
+			batch[entry.Index].Category.Name = category
+		}
+	}
+}
+
+// answerImport saves or drops the transactions of an import preview, and
+// replaces the preview with the outcome.
+func (r *BotServiceImpl) answerImport(user *model.User, chat *pkg.TelegramIncommingChat, action string, id string) (*pkg.TelegramSendMessageStatus, error) {
+	callback := chat.CallbackQuery
+	pending := r.takePendingImport(user, callback, id)
+	if pending == nil {
+		return nil, nil
+	}
+
+	answerCallback(callback, "")
+	if action == callbackImportCancel {
+		return editPreview(callback, common.CashflowImportCancelled())
+	}
+	return editPreview(callback, common.CashflowImported(r.savePendingImport(user, pending)))
+}
+
+// takePendingImport returns the pending import of a preview button, or tells
+// the user the preview expired and returns nil.
+func (r *BotServiceImpl) takePendingImport(user *model.User, callback *pkg.CallbackQuery, id string) *model.PendingImport {
+	var pending *model.PendingImport
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err == nil {
+		pending, err = r.cashflowRepo.TakePendingImport(objectId, user.UserId)
+	}
+	if err != nil {
+		log.Printf("Error getting pending import %s: %v", id, err)
+	}
+	if pending != nil {
+		return pending
+	}
+
+	answerCallback(callback, common.CashflowImportExpired())
+	if callback.Message != nil {
+		if _, err := pkg.EditTelegramReplyMarkup(callback.Message.Chat.Id, callback.Message.MessageId, nil); err != nil {
+			log.Println("Error removing preview buttons:", err)
+		}
+	}
+	return nil
+}
+
+// savePendingImport saves the transactions of a confirmed preview and returns
+// how many were saved. The user is alerted of the budgets they take over in
+// the current month, older transactions would only bring old alerts.
+func (r *BotServiceImpl) savePendingImport(user *model.User, pending *model.PendingImport) int {
+	imported := 0
+	spent := map[string]time.Time{}
+	month := time.Now().In(config.Timezone).Format("2006-01")
+	for _, transaction := range pending.Transactions {
+		category, _, err := r.cashflowRepo.SaveCategory(user.UserId, transaction.Category)
+		if err != nil {
+			log.Printf("Error saving category %s: %v", transaction.Category, err)
+			continue
+		}
+		transaction.CategoryId = category.Id
+		if _, err := r.cashflowRepo.CreateTransaction(&transaction); err != nil {
+			log.Printf("Error importing transaction of %s: %v", pending.FileName, err)
+			continue
+		}
+		imported++
+		if transaction.Type == string(cashflow.Expense) && transaction.Date.In(config.Timezone).Format("2006-01") == month {
+			spent[transaction.Category] = transaction.Date
+		}
+	}
+
+	for category, date := range spent {
+		r.alertBudget(user.UserId, category, date)
+	}
+	return imported
+}
+
+func answerCallback(callback *pkg.CallbackQuery, text string) {
+	if err := pkg.AnswerTelegramCallbackQuery(callback.Id, text); err != nil {
+		log.Println("Error answering callback query:", err)
+	}
+}
+
+// editPreview replaces the text of the message of a preview button, which
+// also removes its buttons.
+func editPreview(callback *pkg.CallbackQuery, text string) (*pkg.TelegramSendMessageStatus, error) {
+	if callback.Message == nil {
+		return nil, nil
+	}
+	return pkg.EditTelegramMessage(callback.Message.Chat.Id, 0, callback.Message.MessageId, text, false)
+}
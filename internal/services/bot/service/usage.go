@@ -0,0 +1,116 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"teo/internal/provider"
+	"teo/internal/services/bot/model"
+	"teo/internal/utils"
+	"time"
+)
+
+const (
+	usageKindChat     = "chat"
+	usageKindTitle    = "title"
+	usageKindMemory   = "memory"
+	usageKindJob      = "job"
+	usageKindCashflow = "cashflow"
+)
+
+// recordUsage stores the token usage of a request made on behalf of user,
+// priced with the configured price table.
+func (r *BotServiceImpl) recordUsage(user *model.User, kind string, usage *provider.Usage) {
+	if usage == nil || usage.IsZero() {
+		return
+	}
+
+	r.spendQuota(user, usage)
+
+	providerName := r.llmProvider.ProviderName()
+	modelName := r.llmProvider.DefaultModel(user.Model)
+	if usage.Model != "" {
+		providerName, modelName = usage.Provider, usage.Model
+	}
+
+	_, err := r.usageRepo.CreateUsage(&model.Usage{
+		UserId:           user.UserId,
+		Provider:         providerName,
+		Model:            modelName,
+		Kind:             kind,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Cost:             r.prices.Cost(modelName, usage.PromptTokens, usage.CompletionTokens),
+	})
+	if err != nil {
+		log.Printf("Error saving usage for user %v: %v", user.UserId, err)
+	}
+}
+
+type usagePeriod struct {
+	label string
+	since time.Time
+}
+
+func usagePeriods() []usagePeriod {
+	now := time.Now()
+	year, month, day := now.Date()
+	return []usagePeriod{
+		{label: "Today", since: time.Date(year, month, day, 0, 0, 0, 0, now.Location())},
+		{label: "This month", since: time.Date(year, month, 1, 0, 0, 0, 0, now.Location())},
+		{label: "All time", since: time.Time{}},
+	}
+}
+
+// usageByModel summarizes the usage of a user per model for each period.
+func (r *BotServiceImpl) usageByModel(userId int) ([]utils.UsagePeriod, error) {
+	var periods []utils.UsagePeriod
+	for _, period := range usagePeriods() {
+		summaries, err := r.usageRepo.GetUsageByModel(userId, period.since)
+		if err != nil {
+			return nil, err
+		}
+
+		rows := make([]utils.UsageRow, 0, len(summaries))
+		for _, summary := range summaries {
+			rows = append(rows, utils.UsageRow{Label: summary.Model, Summary: summary})
+		}
+		periods = append(periods, utils.UsagePeriod{Label: period.label, Rows: rows})
+	}
+
+	return periods, nil
+}
+
+// usageByUser summarizes the usage of every user for each period, for the
+// admin report.
+func (r *BotServiceImpl) usageByUser() ([]utils.UsagePeriod, error) {
+	names := map[int]string{}
+	var periods []utils.UsagePeriod
+	for _, period := range usagePeriods() {
+		summaries, err := r.usageRepo.GetUsageByUser(period.since)
+		if err != nil {
+			return nil, err
+		}
+
+		rows := make([]utils.UsageRow, 0, len(summaries))
+		for _, summary := range summaries {
+			name, ok := names[summary.UserId]
+			if !ok {
+				name = r.userName(summary.UserId)
+				names[summary.UserId] = name
+			}
+			rows = append(rows, utils.UsageRow{Label: name, Summary: summary})
+		}
+		periods = append(periods, utils.UsagePeriod{Label: period.label, Rows: rows})
+	}
+
+	return periods, nil
+}
+
+func (r *BotServiceImpl) userName(userId int) string {
+	user, err := r.userRepo.GetUserById(userId)
+	if err != nil || user == nil || user.Name == "" {
+		return fmt.Sprintf("%d", userId)
+	}
+	return fmt.Sprintf("%s (%d)", user.Name, userId)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"teo/internal/pkg"
+	"teo/internal/services/bot/model"
+)
+
+const maxSpeechLength = 4000
+
+var (
+	speechCodeBlock = regexp.MustCompile("(?s)```.*?```")
+	speechLink      = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+	speechMarkup    = strings.NewReplacer("**", "", "__", "", "*", "", "_", " ", "`", "", "#", "", ">", "")
+)
+
+func speechText(content string) string {
+	text := speechCodeBlock.ReplaceAllString(content, " ")
+	text = speechLink.ReplaceAllString(text, "$1")
+	text = speechMarkup.Replace(text)
+	text = strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(text)
+	if len(runes) <= maxSpeechLength {
+		return text
+	}
+
+	text = string(runes[:maxSpeechLength])
+	if i := strings.LastIndexAny(text, ".!?"); i > 0 {
+		text = text[:i+1]
+	}
+	return text
+}
+
+func (r *BotServiceImpl) replyWithVoice(user *model.User, chat *pkg.TelegramIncommingChat, content string) {
+	if r.speechProvider == nil || !user.VoiceReply || chat.Message.Voice == nil {
+		return
+	}
+
+	text := speechText(content)
+	if text == "" {
+		return
+	}
+
+	audio, format, err := r.speechProvider.TextToSpeech(text)
+	if err != nil {
+		log.Printf("Error synthesizing voice reply: %v", err)
+		return
+	}
+
+	voice, err := pkg.TranscodeToOggOpus(audio, format)
+	if err != nil {
+		log.Printf("Error transcoding voice reply: %v", err)
+		return
+	}
+
+	send, err := pkg.SendTelegramVoice(chat.Message.Chat.Id, chat.Message.MessageId, voice)
+	if err != nil || !send.Ok {
+		log.Printf("Error sending voice reply: %v", err)
+	}
+}
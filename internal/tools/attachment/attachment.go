@@ -0,0 +1,107 @@
+package attachment
+
+import (
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	KindPhoto    = "photo"
+	KindAudio    = "audio"
+	KindDocument = "document"
+
+	MaxSize      = 50 * 1024 * 1024 // Telegram Bot API upload limit
+	MaxPhotoSize = 10 * 1024 * 1024
+)
+
+type Attachment struct {
+	FileName string
+	MimeType string
+	Data     []byte
+	// Metadata describes how the file was produced, e.g. the prompt of a
+	// generated image.
+	Metadata map[string]string
+}
+
+func New(fileName string, data []byte) Attachment {
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	return Attachment{
+		FileName: filepath.Base(fileName),
+		MimeType: mimeType,
+		Data:     data,
+	}
+}
+
+func (a Attachment) Kind() string {
+	switch {
+	case a.MimeType == "image/jpeg" || a.MimeType == "image/png" || a.MimeType == "image/webp":
+		return KindPhoto
+	case strings.HasPrefix(a.MimeType, "audio/"):
+		return KindAudio
+	default:
+		return KindDocument
+	}
+}
+
+func Read(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	if info.Size() > MaxSize {
+		return Attachment{}, fs.ErrInvalid
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	return New(path, data), nil
+}
+
+func Collect(dir string) []Attachment {
+	var attachments []Attachment
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		attachment, err := Read(path)
+		if err != nil {
+			log.Printf("Skipping attachment %s: %v", path, err)
+			return nil
+		}
+
+		attachments = append(attachments, attachment)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error collecting attachments from %s: %v", dir, err)
+	}
+
+	return attachments
+}
+
+func Note(attachments []Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+
+	names := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		names[i] = attachment.FileName
+	}
+
+	return "\n\n[Files sent to the user: " + strings.Join(names, ", ") + "]"
+}
@@ -3,8 +3,10 @@ package bash
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+	"teo/internal/tools/attachment"
 	"time"
 )
 
@@ -44,18 +46,30 @@ func isCommandSafe(cmd string) bool {
 }
 
 func (b *BashTool) CallTool(arguments string) string {
+	result, _ := b.CallToolWithAttachments(arguments)
+	return result
+}
+
+func (b *BashTool) CallToolWithAttachments(arguments string) (string, []attachment.Attachment) {
 	var args BashArgs
 	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-		return fmt.Sprintf("Error parsing arguments: %v", err)
+		return fmt.Sprintf("Error parsing arguments: %v", err), nil
 	}
 
 	if args.Command == "" {
-		return "Error: 'command' argument is required."
+		return "Error: 'command' argument is required.", nil
 	}
 
 	if !isCommandSafe(args.Command) {
-		return fmt.Sprintf("Error: Command contains forbidden/dangerous keywords. Blocked for security.")
+		return fmt.Sprintf("Error: Command contains forbidden/dangerous keywords. Blocked for security."), nil
+	}
+
+	// Files written to TEO_OUTPUT_DIR are sent back to the user
+	outputDir, err := os.MkdirTemp("", "bash-output-*")
+	if err != nil {
+		return fmt.Sprintf("Error creating output directory: %v", err), nil
 	}
+	defer os.RemoveAll(outputDir)
 
 	// Default timeout to 60 seconds if not specified
 	timeout := 60 * time.Second
@@ -67,6 +81,7 @@ func (b *BashTool) CallTool(arguments string) string {
 	// Using "bash -c" to allow complex commands (pipes, redirects, etc)
 	// If bash is not available, sh could be a fallback, but user requested "bash"
 	cmd := exec.Command("bash", "-c", args.Command)
+	cmd.Env = append(os.Environ(), "TEO_OUTPUT_DIR="+outputDir)
 
 	// Create a timer to kill the process if it runs too long
 	// Simple implementation without context for now, or use time.AfterFunc
@@ -79,7 +94,6 @@ func (b *BashTool) CallTool(arguments string) string {
 	// cmd.CombinedOutput() is simpler if we don't need separate stdout/stderr
 
 	var output []byte
-	var err error
 
 	go func() {
 		output, err = cmd.CombinedOutput()
@@ -91,12 +105,13 @@ func (b *BashTool) CallTool(arguments string) string {
 		if cmd.Process != nil {
 			cmd.Process.Kill()
 		}
-		return fmt.Sprintf("Error: Command execution timed out after %v seconds.", args.Timeout)
+		return fmt.Sprintf("Error: Command execution timed out after %v seconds.", args.Timeout), nil
 	case err := <-done:
+		attachments := attachment.Collect(outputDir)
 		if err != nil {
 			// If it's an exit code error, we still want the output
-			return fmt.Sprintf("Error: %v\nOutput:\n%s", err, string(output))
+			return fmt.Sprintf("Error: %v\nOutput:\n%s", err, string(output)), attachments
 		}
-		return string(output)
+		return string(output), attachments
 	}
 }
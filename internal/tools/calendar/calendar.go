@@ -4,169 +4,276 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"teo/internal/config"
+	"teo/internal/tools/attachment"
 	"time"
 )
 
+// maxConflicts is how many conflicting occurrences an added schedule reports.
+const maxConflicts = 5
+
+// Schedule is an event. A recurring event has an RRULE in Recurrence and
+// repeats the wall clock times of StartTime and EndTime in Timezone, or in
+// the time zone of its user when it is empty.
 type Schedule struct {
-	ID          string    `json:"id"`
-	UserID      string    `json:"user_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	StartTime   time.Time `json:"start_time"`
-	EndTime     time.Time `json:"end_time"`
-	Tags        []string  `json:"tags"`
+	ID          string      `json:"id"`
+	UID         string      `json:"uid,omitempty"`
+	UserID      string      `json:"user_id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	StartTime   time.Time   `json:"start_time"`
+	EndTime     time.Time   `json:"end_time"`
+	Timezone    string      `json:"timezone,omitempty"`
+	Recurrence  string      `json:"recurrence,omitempty"`
+	Exceptions  []time.Time `json:"exceptions,omitempty"`
+	Tags        []string    `json:"tags"`
 }
 
-type CalendarManager struct {
-	dataPath  string
-	schedules []Schedule
+func (s Schedule) location(fallback *time.Location) *time.Location {
+	if s.Timezone != "" {
+		if location, err := time.LoadLocation(s.Timezone); err == nil {
+			return location
+		}
+	}
+	return fallback
 }
 
-func NewCalendarManager() (*CalendarManager, error) {
-	workingDir, err := os.Getwd()
-	if err != nil {
-		log.Printf("Error getting working directory: %v\n", err)
-		return nil, err
+// Occurrences lists the runs of a schedule that overlap from and to, in
+// order. A schedule that does not repeat is its only run.
+func (s Schedule) Occurrences(from, to time.Time, location *time.Location) []Schedule {
+	duration := s.EndTime.Sub(s.StartTime)
+	overlaps := func(start time.Time) bool {
+		return start.Before(to) && start.Add(duration).After(from) || start.Equal(from)
 	}
 
-	dataDir := filepath.Join(workingDir, "data", "calendar")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Printf("Error creating calendar directory: %v\n", err)
-		return nil, err
+	if s.Recurrence == "" {
+		if overlaps(s.StartTime) {
+			return []Schedule{s}
+		}
+		return nil
 	}
 
-	dataPath := filepath.Join(dataDir, "calendar.json")
-	manager := &CalendarManager{
-		dataPath:  dataPath,
-		schedules: []Schedule{},
+	location = s.location(location)
+	rule, err := ParseRRule(s.Recurrence, location)
+	if err != nil {
+		log.Printf("Skipping schedule %s with an invalid recurrence: %v", s.ID, err)
+		return nil
 	}
 
-	if err := manager.loadSchedules(); err != nil {
-		log.Printf("Warning: error loading schedules: %v", err)
+	var occurrences []Schedule
+	rule.each(s.StartTime.In(location), func(start time.Time) bool {
+		if !start.Before(to) {
+			return false
+		}
+		if overlaps(start) && !s.excepted(start) {
+			occurrence := s
+			occurrence.StartTime = start
+			occurrence.EndTime = start.Add(duration)
+			occurrences = append(occurrences, occurrence)
+		}
+		return true
+	})
+	return occurrences
+}
+
+func (s Schedule) excepted(start time.Time) bool {
+	for _, exception := range s.Exceptions {
+		if exception.Equal(start) {
+			return true
+		}
 	}
+	return false
+}
 
-	return manager, nil
+// Store keeps the schedules of each user. It is provided by the bot service
+// at startup, tools cannot import the repositories directly.
+type Store interface {
+	CreateSchedule(schedule Schedule) (Schedule, error)
+	GetSchedule(id string, userID string) (*Schedule, error)
+	UpdateSchedule(schedule Schedule) error
+	DeleteSchedule(id string, userID string) error
+	// AddException cancels the occurrence of a schedule that starts at start
+	AddException(id string, userID string, start time.Time) error
+	// ImportSchedules inserts schedules, or replaces the ones with the same UID
+	ImportSchedules(userID string, schedules []Schedule) (int, int, error)
+	// FindSchedules lists the schedules that may occur between from and to:
+	// recurring ones that start before to and the others that overlap
+	FindSchedules(userID string, from, to time.Time) ([]Schedule, error)
+	SearchByTitle(userID string, title string) ([]Schedule, error)
+	SearchByTags(userID string, tags []string) ([]Schedule, error)
+	UserSchedules(userID string) ([]Schedule, error)
+	Timezone(userID string) (string, error)
+	SetTimezone(userID string, timezone string) error
 }
 
-func (cm *CalendarManager) loadSchedules() error {
-	if _, err := os.Stat(cm.dataPath); err == nil {
-		data, err := os.ReadFile(cm.dataPath)
-		if err != nil {
-			return fmt.Errorf("error reading data file: %v", err)
-		}
+var store Store
 
-		if len(data) == 0 {
-			return nil
-		}
+func SetStore(s Store) {
+	store = s
+}
 
-		if err := json.Unmarshal(data, &cm.schedules); err != nil {
-			log.Printf("Warning: error parsing data file: %v, returning empty data", err)
-			return nil
-		}
-	}
-	return nil
+type CalendarManager struct {
+	store Store
 }
 
-func (cm *CalendarManager) saveSchedules() error {
-	if cm.schedules == nil {
-		cm.schedules = make([]Schedule, 0)
+func NewCalendarManager() (*CalendarManager, error) {
+	if store == nil {
+		return nil, fmt.Errorf("the calendar is not configured on this bot")
 	}
+	return &CalendarManager{store: store}, nil
+}
 
-	data, err := json.MarshalIndent(cm.schedules, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshaling data: %v", err)
+func (cm *CalendarManager) SetTimezone(userID string, timezone string) error {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("unknown time zone %s", timezone)
 	}
+	return cm.store.SetTimezone(userID, timezone)
+}
 
-	dir := filepath.Dir(cm.dataPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("error creating directory: %v", err)
+// Location is the time zone of a user, the bot time zone until they set one.
+func (cm *CalendarManager) Location(userID string) *time.Location {
+	timezone, err := cm.store.Timezone(userID)
+	if err != nil {
+		log.Printf("Error getting time zone of user %s: %v", userID, err)
 	}
-
-	if err := os.WriteFile(cm.dataPath, data, 0644); err != nil {
-		return fmt.Errorf("error writing data file: %v", err)
+	if timezone != "" {
+		if location, err := time.LoadLocation(timezone); err == nil {
+			return location
+		}
 	}
+	return config.Timezone
+}
 
-	return nil
+func (cm *CalendarManager) AddSchedule(schedule Schedule) (Schedule, error) {
+	return cm.store.CreateSchedule(schedule)
 }
 
-func (cm *CalendarManager) AddSchedule(schedule Schedule) error {
-	cm.schedules = append(cm.schedules, schedule)
-	return cm.saveSchedules()
+func (cm *CalendarManager) GetSchedule(id string, userID string) (*Schedule, error) {
+	return cm.store.GetSchedule(id, userID)
 }
 
-func (cm *CalendarManager) UpdateSchedule(id string, userID string, updatedSchedule Schedule) error {
-	for i, s := range cm.schedules {
-		if s.ID == id && s.UserID == userID {
-			cm.schedules[i] = updatedSchedule
-			return cm.saveSchedules()
-		}
-	}
-	return fmt.Errorf("schedule with ID %s not found", id)
+func (cm *CalendarManager) UpdateSchedule(schedule Schedule) error {
+	return cm.store.UpdateSchedule(schedule)
 }
 
 func (cm *CalendarManager) DeleteSchedule(id string, userID string) error {
-	for i, s := range cm.schedules {
-		if s.ID == id && s.UserID == userID {
-			cm.schedules = append(cm.schedules[:i], cm.schedules[i+1:]...)
-			return cm.saveSchedules()
-		}
+	return cm.store.DeleteSchedule(id, userID)
+}
+
+// SkipOccurrence cancels a single run of a recurring schedule.
+func (cm *CalendarManager) SkipOccurrence(id string, userID string, start time.Time) error {
+	s, err := cm.store.GetSchedule(id, userID)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return fmt.Errorf("schedule with ID %s not found", id)
 	}
-	return fmt.Errorf("schedule with ID %s not found", id)
+	if s.Recurrence == "" {
+		return fmt.Errorf("schedule with ID %s does not repeat", id)
+	}
+	if len(s.Occurrences(start, start.Add(time.Second), cm.Location(userID))) == 0 {
+		return fmt.Errorf("schedule with ID %s has no occurrence at %s", id, start.Format(time.RFC3339))
+	}
+	return cm.store.AddException(id, userID, start)
 }
 
-func (cm *CalendarManager) SearchByDateRange(userID string, start, end time.Time) []Schedule {
-	var results []Schedule
-	for _, s := range cm.schedules {
-		if s.UserID == userID && (s.StartTime.Equal(start) || s.StartTime.After(start)) && (s.EndTime.Equal(end) || s.EndTime.Before(end)) {
-			results = append(results, s)
+// ImportSchedules adds the schedules of a user, or updates the ones with the
+// same UID, so importing a file twice does not duplicate its events.
+func (cm *CalendarManager) ImportSchedules(userID string, schedules []Schedule) (int, int, error) {
+	for i := range schedules {
+		schedules[i].UserID = userID
+		if schedules[i].Tags == nil {
+			schedules[i].Tags = []string{}
 		}
 	}
-	return results
+	return cm.store.ImportSchedules(userID, schedules)
 }
 
-func (cm *CalendarManager) SearchByTitle(userID string, title string) []Schedule {
+// SearchByDateRange lists the occurrences of the schedules of a user that
+// overlap start and end, recurring ones expanded, the earliest first.
+func (cm *CalendarManager) SearchByDateRange(userID string, start, end time.Time) ([]Schedule, error) {
+	schedules, err := cm.store.FindSchedules(userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	location := cm.Location(userID)
 	var results []Schedule
-	searchTitle := strings.ToLower(title)
-	for _, s := range cm.schedules {
-		if s.UserID == userID && strings.Contains(strings.ToLower(s.Title), searchTitle) {
-			results = append(results, s)
-		}
+	for _, s := range schedules {
+		results = append(results, s.Occurrences(start, end, location)...)
 	}
-	return results
+	sort.Slice(results, func(i, j int) bool { return results[i].StartTime.Before(results[j].StartTime) })
+	return results, nil
 }
 
-func (cm *CalendarManager) SearchByTags(userID string, tags []string) []Schedule {
-	var results []Schedule
-	for _, s := range cm.schedules {
-		if s.UserID != userID {
+// UserSchedules lists every schedule of a user as stored, recurring ones
+// unexpanded.
+func (cm *CalendarManager) UserSchedules(userID string) ([]Schedule, error) {
+	return cm.store.UserSchedules(userID)
+}
+
+// Conflicts lists the occurrences of other schedules that overlap schedule,
+// checking a recurring one over the year after its start.
+func (cm *CalendarManager) Conflicts(schedule Schedule) ([]Schedule, error) {
+	location := cm.Location(schedule.UserID)
+	to := schedule.EndTime
+	if schedule.Recurrence != "" {
+		to = schedule.StartTime.AddDate(1, 0, 0)
+	}
+	occurrences := schedule.Occurrences(schedule.StartTime, to, location)
+
+	others, err := cm.SearchByDateRange(schedule.UserID, schedule.StartTime, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []Schedule
+	for _, other := range others {
+		if other.ID == schedule.ID {
 			continue
 		}
-		for _, tag := range tags {
-			for _, scheduleTag := range s.Tags {
-				if tag == scheduleTag {
-					results = append(results, s)
-					break
-				}
+		for _, occurrence := range occurrences {
+			if occurrence.StartTime.Before(other.EndTime) && other.StartTime.Before(occurrence.EndTime) {
+				conflicts = append(conflicts, other)
+				break
 			}
 		}
+		if len(conflicts) >= maxConflicts {
+			break
+		}
 	}
-	return results
+	return conflicts, nil
+}
+
+func (cm *CalendarManager) SearchByTitle(userID string, title string) ([]Schedule, error) {
+	return cm.store.SearchByTitle(userID, title)
+}
+
+func (cm *CalendarManager) SearchByTags(userID string, tags []string) ([]Schedule, error) {
+	return cm.store.SearchByTags(userID, tags)
 }
 
+// CalendarTool manages the calendar of the user it was made for, the model
+// cannot pick another user.
 type CalendarTool struct {
 	manager *CalendarManager
+	userID  string
 }
 
-func NewCalendarTool() *CalendarTool {
+func NewCalendarTool(userId int) *CalendarTool {
 	manager, err := NewCalendarManager()
 	if err != nil {
 		log.Printf("Error creating calendar manager: %v", err)
-		return nil
 	}
-	return &CalendarTool{manager: manager}
+	tool := &CalendarTool{manager: manager}
+	if userId != 0 {
+		tool.userID = strconv.Itoa(userId)
+	}
+	return tool
 }
 
 func (ct *CalendarTool) CallTool(arguments string) string {
@@ -175,6 +282,13 @@ func (ct *CalendarTool) CallTool(arguments string) string {
 		return fmt.Sprintf("Error parsing arguments: %v", err)
 	}
 
+	if ct.manager == nil {
+		return "Error: the calendar is not configured on this bot."
+	}
+	if ct.userID == "" {
+		return "Error: the calendar is only available in a chat with a user."
+	}
+
 	action, ok := params["action"].(string)
 	if !ok {
 		return "Error: action not found"
@@ -193,157 +307,279 @@ func (ct *CalendarTool) CallTool(arguments string) string {
 		return ct.handleSearchByTitle(params)
 	case "search_by_tags":
 		return ct.handleSearchByTags(params)
+	case "skip_occurrence":
+		return ct.handleSkipOccurrence(params)
+	case "set_timezone":
+		return ct.handleSetTimezone(params)
+	case "export_ics":
+		return "Error: export_ics returns a file, it is only available to the bot"
 	default:
 		return fmt.Sprintf("Error: invalid action: %s", action)
 	}
 }
 
+// CallToolWithAttachments handles export_ics, which sends the calendar of the
+// user as an .ics file, and every other action like CallTool.
+func (ct *CalendarTool) CallToolWithAttachments(arguments string) (string, []attachment.Attachment) {
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return fmt.Sprintf("Error parsing arguments: %v", err), nil
+	}
+	if action, _ := params["action"].(string); action != "export_ics" {
+		return ct.CallTool(arguments), nil
+	}
+
+	userID := ct.userID
+	if ct.manager == nil {
+		return "Error: the calendar is not configured on this bot.", nil
+	}
+	if userID == "" {
+		return "Error: the calendar is only available in a chat with a user.", nil
+	}
+
+	schedules, err := ct.manager.UserSchedules(userID)
+	if err != nil {
+		return fmt.Sprintf("Error exporting calendar: %v", err), nil
+	}
+	if len(schedules) == 0 {
+		return "The calendar is empty, there is nothing to export", nil
+	}
+	data := ExportICS(schedules, ct.manager.Location(userID))
+	return fmt.Sprintf("Exported %d schedules", len(schedules)), []attachment.Attachment{attachment.New("calendar.ics", data)}
+}
+
 func (ct *CalendarTool) handleAddSchedule(params map[string]interface{}) string {
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
+	userID := ct.userID
+
+	scheduleData, ok := params["schedule"].(map[string]interface{})
+	if !ok {
+		return "Error: invalid schedule data"
+	}
+
+	schedule, err := ct.scheduleFromData(userID, scheduleData)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	conflicts, err := ct.manager.Conflicts(schedule)
+	if err != nil {
+		return fmt.Sprintf("Error checking conflicts: %v", err)
+	}
+	schedule, err = ct.manager.AddSchedule(schedule)
+	if err != nil {
+		return fmt.Sprintf("Error adding schedule: %v", err)
 	}
 
+	return "Schedule added successfully with ID " + schedule.ID + conflictsNote(conflicts, ct.manager.Location(userID))
+}
+
+func (ct *CalendarTool) handleUpdateSchedule(params map[string]interface{}) string {
+	userID := ct.userID
+
 	scheduleData, ok := params["schedule"].(map[string]interface{})
 	if !ok {
 		return "Error: invalid schedule data"
 	}
 
-	title, ok := scheduleData["title"].(string)
+	id, ok := scheduleData["id"].(string)
 	if !ok {
-		return "Error: title is required"
+		return "Error: id is required"
+	}
+
+	existing, err := ct.manager.GetSchedule(id, userID)
+	if err != nil {
+		return fmt.Sprintf("Error updating schedule: %v", err)
+	}
+	if existing == nil {
+		return fmt.Sprintf("Error updating schedule: schedule with ID %s not found", id)
 	}
 
-	description, ok := scheduleData["description"].(string)
+	schedule, err := ct.scheduleFromData(userID, scheduleData)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	schedule.ID = id
+	schedule.UID = existing.UID
+
+	conflicts, err := ct.manager.Conflicts(schedule)
+	if err != nil {
+		return fmt.Sprintf("Error checking conflicts: %v", err)
+	}
+	if err := ct.manager.UpdateSchedule(schedule); err != nil {
+		return fmt.Sprintf("Error updating schedule: %v", err)
+	}
+
+	return "Schedule updated successfully" + conflictsNote(conflicts, ct.manager.Location(userID))
+}
+
+// scheduleFromData reads the schedule object of add_schedule and
+// update_schedule. Times without an offset are read in the time zone of the
+// schedule, or of the user.
+func (ct *CalendarTool) scheduleFromData(userID string, scheduleData map[string]interface{}) (Schedule, error) {
+	title, ok := scheduleData["title"].(string)
 	if !ok {
-		return "Error: description is required"
+		return Schedule{}, fmt.Errorf("title is required")
+	}
+
+	description, _ := scheduleData["description"].(string)
+
+	schedule := Schedule{
+		UserID:      userID,
+		Title:       title,
+		Description: description,
+		Tags:        []string{},
+	}
+
+	location := ct.manager.Location(userID)
+	if timezone, _ := scheduleData["timezone"].(string); timezone != "" {
+		tzLocation, err := time.LoadLocation(timezone)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("unknown time zone %s", timezone)
+		}
+		schedule.Timezone = timezone
+		location = tzLocation
 	}
 
 	startTimeStr, ok := scheduleData["start_time"].(string)
 	if !ok {
-		return "Error: start_time is required"
+		return Schedule{}, fmt.Errorf("start_time is required")
 	}
-	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	startTime, err := parseTime(startTimeStr, location)
 	if err != nil {
-		return fmt.Sprintf("Error: invalid start_time format: %v", err)
+		return Schedule{}, fmt.Errorf("invalid start_time format: %v", err)
 	}
 
 	endTimeStr, ok := scheduleData["end_time"].(string)
 	if !ok {
-		return "Error: end_time is required"
+		return Schedule{}, fmt.Errorf("end_time is required")
 	}
-	endTime, err := time.Parse(time.RFC3339, endTimeStr)
+	endTime, err := parseTime(endTimeStr, location)
 	if err != nil {
-		return fmt.Sprintf("Error: invalid end_time format: %v", err)
+		return Schedule{}, fmt.Errorf("invalid end_time format: %v", err)
 	}
-
-	tagsInterface, ok := scheduleData["tags"].([]interface{})
-	if !ok {
-		return "Error: tags is required"
+	if endTime.Before(startTime) {
+		return Schedule{}, fmt.Errorf("end_time is before start_time")
 	}
-	tags := make([]string, len(tagsInterface))
-	for i, tag := range tagsInterface {
-		tags[i], ok = tag.(string)
-		if !ok {
-			return "Error: invalid tag format"
+	schedule.StartTime = startTime
+	schedule.EndTime = endTime
+
+	if recurrence, _ := scheduleData["recurrence"].(string); recurrence != "" {
+		if _, err := ParseRRule(recurrence, location); err != nil {
+			return Schedule{}, fmt.Errorf("invalid recurrence: %v", err)
 		}
+		schedule.Recurrence = strings.TrimPrefix(recurrence, "RRULE:")
 	}
 
-	schedule := Schedule{
-		ID:          fmt.Sprintf("%d", time.Now().UnixNano()),
-		UserID:      userID,
-		Title:       title,
-		Description: description,
-		StartTime:   startTime,
-		EndTime:     endTime,
-		Tags:        tags,
+	if exceptions, ok := scheduleData["exceptions"].([]interface{}); ok {
+		for _, value := range exceptions {
+			exceptionStr, ok := value.(string)
+			if !ok {
+				return Schedule{}, fmt.Errorf("invalid exception format")
+			}
+			exception, err := parseTime(exceptionStr, location)
+			if err != nil {
+				return Schedule{}, fmt.Errorf("invalid exception format: %v", err)
+			}
+			schedule.Exceptions = append(schedule.Exceptions, exception)
+		}
 	}
 
-	if err := ct.manager.AddSchedule(schedule); err != nil {
-		return fmt.Sprintf("Error adding schedule: %v", err)
+	if tagsInterface, ok := scheduleData["tags"].([]interface{}); ok {
+		for _, tag := range tagsInterface {
+			tagStr, ok := tag.(string)
+			if !ok {
+				return Schedule{}, fmt.Errorf("invalid tag format")
+			}
+			schedule.Tags = append(schedule.Tags, tagStr)
+		}
 	}
 
-	return "Schedule added successfully"
+	return schedule, nil
 }
 
-func (ct *CalendarTool) handleUpdateSchedule(params map[string]interface{}) string {
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+var timeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
 
-	scheduleData, ok := params["schedule"].(map[string]interface{})
-	if !ok {
-		return "Error: invalid schedule data"
+// parseTime reads RFC 3339, or a local date and time in location.
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
 	}
+	return time.Time{}, fmt.Errorf("%q must be RFC 3339 or 2006-01-02T15:04", value)
+}
 
-	id, ok := scheduleData["id"].(string)
-	if !ok {
-		return "Error: id is required"
+func conflictsNote(conflicts []Schedule, location *time.Location) string {
+	if len(conflicts) == 0 {
+		return "."
 	}
 
-	title, ok := scheduleData["title"].(string)
-	if !ok {
-		return "Error: title is required"
+	var sb strings.Builder
+	sb.WriteString(". Warning, it overlaps with:")
+	for _, conflict := range conflicts {
+		start := conflict.StartTime.In(conflict.location(location))
+		sb.WriteString(fmt.Sprintf("\n- %s (ID %s) on %s", conflict.Title, conflict.ID, start.Format("Mon, 02 Jan 2006 15:04 MST")))
 	}
+	return sb.String()
+}
+
+func (ct *CalendarTool) handleSkipOccurrence(params map[string]interface{}) string {
+	userID := ct.userID
 
-	description, ok := scheduleData["description"].(string)
+	id, ok := params["schedule_id"].(string)
 	if !ok {
-		return "Error: description is required"
+		return "Error: schedule_id is required"
 	}
 
-	startTimeStr, ok := scheduleData["start_time"].(string)
+	occurrenceStr, ok := params["occurrence_start"].(string)
 	if !ok {
-		return "Error: start_time is required"
+		return "Error: occurrence_start is required"
 	}
-	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	schedule, err := ct.manager.GetSchedule(id, userID)
 	if err != nil {
-		return fmt.Sprintf("Error: invalid start_time format: %v", err)
+		return fmt.Sprintf("Error skipping occurrence: %v", err)
 	}
-
-	endTimeStr, ok := scheduleData["end_time"].(string)
-	if !ok {
-		return "Error: end_time is required"
+	if schedule == nil {
+		return fmt.Sprintf("Error: schedule with ID %s not found", id)
 	}
-	endTime, err := time.Parse(time.RFC3339, endTimeStr)
+	occurrence, err := parseTime(occurrenceStr, schedule.location(ct.manager.Location(userID)))
 	if err != nil {
-		return fmt.Sprintf("Error: invalid end_time format: %v", err)
+		return fmt.Sprintf("Error: invalid occurrence_start format: %v", err)
 	}
 
-	tagsInterface, ok := scheduleData["tags"].([]interface{})
-	if !ok {
-		return "Error: tags is required"
-	}
-	tags := make([]string, len(tagsInterface))
-	for i, tag := range tagsInterface {
-		tags[i], ok = tag.(string)
-		if !ok {
-			return "Error: invalid tag format"
-		}
+	if err := ct.manager.SkipOccurrence(id, userID, occurrence); err != nil {
+		return fmt.Sprintf("Error skipping occurrence: %v", err)
 	}
 
-	schedule := Schedule{
-		ID:          id,
-		UserID:      userID,
-		Title:       title,
-		Description: description,
-		StartTime:   startTime,
-		EndTime:     endTime,
-		Tags:        tags,
+	return "Occurrence skipped successfully"
+}
+
+func (ct *CalendarTool) handleSetTimezone(params map[string]interface{}) string {
+	userID := ct.userID
+
+	timezone, ok := params["timezone"].(string)
+	if !ok || timezone == "" {
+		return "Error: timezone is required"
 	}
 
-	if err := ct.manager.UpdateSchedule(id, userID, schedule); err != nil {
-		return fmt.Sprintf("Error updating schedule: %v", err)
+	if err := ct.manager.SetTimezone(userID, timezone); err != nil {
+		return fmt.Sprintf("Error setting time zone: %v", err)
 	}
 
-	return "Schedule updated successfully"
+	return "Time zone set to " + timezone
 }
 
 func (ct *CalendarTool) handleDeleteSchedule(params map[string]interface{}) string {
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	id, ok := params["schedule_id"].(string)
 	if !ok {
@@ -358,10 +594,7 @@ func (ct *CalendarTool) handleDeleteSchedule(params map[string]interface{}) stri
 }
 
 func (ct *CalendarTool) handleSearchByDate(params map[string]interface{}) string {
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	dateRange, ok := params["date_range"].(map[string]interface{})
 	if !ok {
@@ -372,7 +605,8 @@ func (ct *CalendarTool) handleSearchByDate(params map[string]interface{}) string
 	if !ok {
 		return "Error: start date is required"
 	}
-	start, err := time.Parse(time.RFC3339, startStr)
+	location := ct.manager.Location(userID)
+	start, err := parseTime(startStr, location)
 	if err != nil {
 		return fmt.Sprintf("Error: invalid start date format: %v", err)
 	}
@@ -381,12 +615,15 @@ func (ct *CalendarTool) handleSearchByDate(params map[string]interface{}) string
 	if !ok {
 		return "Error: end date is required"
 	}
-	end, err := time.Parse(time.RFC3339, endStr)
+	end, err := parseTime(endStr, location)
 	if err != nil {
 		return fmt.Sprintf("Error: invalid end date format: %v", err)
 	}
 
-	schedules := ct.manager.SearchByDateRange(userID, start, end)
+	schedules, err := ct.manager.SearchByDateRange(userID, start, end)
+	if err != nil {
+		return fmt.Sprintf("Error searching schedules: %v", err)
+	}
 	result, err := json.Marshal(schedules)
 	if err != nil {
 		return fmt.Sprintf("Error marshaling results: %v", err)
@@ -396,17 +633,17 @@ func (ct *CalendarTool) handleSearchByDate(params map[string]interface{}) string
 }
 
 func (ct *CalendarTool) handleSearchByTitle(params map[string]interface{}) string {
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	title, ok := params["title"].(string)
 	if !ok {
 		return "Error: title is required"
 	}
 
-	schedules := ct.manager.SearchByTitle(userID, title)
+	schedules, err := ct.manager.SearchByTitle(userID, title)
+	if err != nil {
+		return fmt.Sprintf("Error searching schedules: %v", err)
+	}
 	result, err := json.Marshal(schedules)
 	if err != nil {
 		return fmt.Sprintf("Error marshaling results: %v", err)
@@ -416,10 +653,7 @@ func (ct *CalendarTool) handleSearchByTitle(params map[string]interface{}) strin
 }
 
 func (ct *CalendarTool) handleSearchByTags(params map[string]interface{}) string {
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	tagsInterface, ok := params["tags"].([]interface{})
 	if !ok {
@@ -434,7 +668,10 @@ func (ct *CalendarTool) handleSearchByTags(params map[string]interface{}) string
 		}
 	}
 
-	schedules := ct.manager.SearchByTags(userID, tags)
+	schedules, err := ct.manager.SearchByTags(userID, tags)
+	if err != nil {
+		return fmt.Sprintf("Error searching schedules: %v", err)
+	}
 	result, err := json.Marshal(schedules)
 	if err != nil {
 		return fmt.Sprintf("Error marshaling results: %v", err)
@@ -0,0 +1,356 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	icsDateTime = "20060102T150405"
+	icsDate     = "20060102"
+)
+
+var icsDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// icsProperty is a content line such as DTSTART;TZID=Europe/Paris:20240101T100000.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ExportICS writes schedules as an iCalendar file. Times carry the IANA name
+// of their time zone as TZID, which calendar apps resolve themselves.
+func ExportICS(schedules []Schedule, location *time.Location) []byte {
+	var buf bytes.Buffer
+	write := func(line string) {
+		buf.WriteString(foldICSLine(line))
+		buf.WriteString("\r\n")
+	}
+
+	write("BEGIN:VCALENDAR")
+	write("VERSION:2.0")
+	write("PRODID:-//teo//calendar//EN")
+	write("CALSCALE:GREGORIAN")
+	stamp := time.Now().UTC().Format(icsDateTime) + "Z"
+	for _, s := range schedules {
+		eventLocation := s.location(location)
+		uid := s.UID
+		if uid == "" {
+			uid = s.ID + "@teo"
+		}
+
+		write("BEGIN:VEVENT")
+		write("UID:" + escapeICSText(uid))
+		write("DTSTAMP:" + stamp)
+		write("DTSTART" + formatICSTime(s.StartTime, eventLocation))
+		write("DTEND" + formatICSTime(s.EndTime, eventLocation))
+		write("SUMMARY:" + escapeICSText(s.Title))
+		if s.Description != "" {
+			write("DESCRIPTION:" + escapeICSText(s.Description))
+		}
+		if len(s.Tags) > 0 {
+			tags := make([]string, len(s.Tags))
+			for i, tag := range s.Tags {
+				tags[i] = escapeICSText(tag)
+			}
+			write("CATEGORIES:" + strings.Join(tags, ","))
+		}
+		if s.Recurrence != "" {
+			write("RRULE:" + strings.TrimPrefix(s.Recurrence, "RRULE:"))
+		}
+		for _, exception := range s.Exceptions {
+			write("EXDATE" + formatICSTime(exception, eventLocation))
+		}
+		write("END:VEVENT")
+	}
+	write("END:VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// ParseICS reads the events of an iCalendar file. Floating times and unknown
+// TZIDs are read in location. A modified instance of a recurring event, with
+// a RECURRENCE-ID, becomes an exception of the series and an event of its own.
+func ParseICS(data []byte, location *time.Location) ([]Schedule, error) {
+	var schedules []Schedule
+	var current *Schedule
+	var duration time.Duration
+	var hasEnd, allDay bool
+	var recurrenceId time.Time
+	depth := 0
+
+	for _, line := range unfoldICS(data) {
+		property, err := parseICSProperty(line)
+		if err != nil {
+			continue
+		}
+
+		switch property.name {
+		case "BEGIN":
+			if strings.EqualFold(property.value, "VEVENT") && current == nil {
+				current = &Schedule{}
+				duration, hasEnd, allDay, recurrenceId = 0, false, false, time.Time{}
+				depth = 0
+			} else if current != nil {
+				// Alarms and other components nested in an event
+				depth++
+			}
+			continue
+		case "END":
+			if current == nil {
+				continue
+			}
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if strings.EqualFold(property.value, "VEVENT") {
+				if current.StartTime.IsZero() {
+					return nil, fmt.Errorf("event %q has no DTSTART", current.Title)
+				}
+				if !hasEnd {
+					switch {
+					case duration > 0:
+						current.EndTime = current.StartTime.Add(duration)
+					case allDay:
+						current.EndTime = current.StartTime.AddDate(0, 0, 1)
+					default:
+						current.EndTime = current.StartTime
+					}
+				}
+				if !recurrenceId.IsZero() {
+					schedules = addException(schedules, current.UID, recurrenceId)
+					current.UID = ""
+				}
+				schedules = append(schedules, *current)
+				current = nil
+			}
+			continue
+		}
+		if current == nil || depth > 0 {
+			continue
+		}
+
+		switch property.name {
+		case "UID":
+			current.UID = property.value
+		case "SUMMARY":
+			current.Title = unescapeICSText(property.value)
+		case "DESCRIPTION":
+			current.Description = unescapeICSText(property.value)
+		case "CATEGORIES":
+			for _, tag := range splitICSList(property.value) {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					current.Tags = append(current.Tags, tag)
+				}
+			}
+		case "DTSTART":
+			current.StartTime, allDay, err = parseICSPropertyTime(property, location)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTSTART: %v", err)
+			}
+			if tzid := property.params["TZID"]; tzid != "" {
+				if _, err := time.LoadLocation(tzid); err == nil {
+					current.Timezone = tzid
+				}
+			}
+		case "DTEND":
+			current.EndTime, _, err = parseICSPropertyTime(property, location)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTEND: %v", err)
+			}
+			hasEnd = true
+		case "DURATION":
+			duration, err = parseICSDuration(property.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DURATION: %v", err)
+			}
+		case "RRULE":
+			current.Recurrence = property.value
+		case "EXDATE":
+			for _, value := range strings.Split(property.value, ",") {
+				exception, _, err := parseICSPropertyTime(icsProperty{params: property.params, value: value}, location)
+				if err != nil {
+					return nil, fmt.Errorf("invalid EXDATE: %v", err)
+				}
+				current.Exceptions = append(current.Exceptions, exception)
+			}
+		case "RECURRENCE-ID":
+			recurrenceId, _, err = parseICSPropertyTime(property, location)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RECURRENCE-ID: %v", err)
+			}
+		}
+	}
+
+	return schedules, nil
+}
+
+func addException(schedules []Schedule, uid string, exception time.Time) []Schedule {
+	for i := range schedules {
+		if schedules[i].UID == uid && schedules[i].Recurrence != "" {
+			schedules[i].Exceptions = append(schedules[i].Exceptions, exception)
+		}
+	}
+	return schedules
+}
+
+func unfoldICS(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func parseICSProperty(line string) (icsProperty, error) {
+	// The value starts at the first colon outside a quoted parameter
+	quoted := false
+	split := -1
+	for i, char := range line {
+		if char == '"' {
+			quoted = !quoted
+		}
+		if char == ':' && !quoted {
+			split = i
+			break
+		}
+	}
+	if split < 0 {
+		return icsProperty{}, fmt.Errorf("invalid line %q", line)
+	}
+
+	parts := strings.Split(line[:split], ";")
+	property := icsProperty{
+		name:   strings.ToUpper(parts[0]),
+		params: map[string]string{},
+		value:  line[split+1:],
+	}
+	for _, param := range parts[1:] {
+		if name, value, ok := strings.Cut(param, "="); ok {
+			property.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return property, nil
+}
+
+func parseICSPropertyTime(property icsProperty, location *time.Location) (time.Time, bool, error) {
+	if tzid := property.params["TZID"]; tzid != "" {
+		if tzLocation, err := time.LoadLocation(tzid); err == nil {
+			location = tzLocation
+		}
+	}
+	return parseICSTime(property.value, location)
+}
+
+// parseICSTime reads a UTC, floating or date only value. Dates are all day
+// and start at midnight.
+func parseICSTime(value string, location *time.Location) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsDateTime, strings.TrimSuffix(value, "Z"))
+		return t, false, err
+	}
+	if len(value) == len(icsDate) {
+		t, err := time.ParseInLocation(icsDate, value, location)
+		return t, true, err
+	}
+	t, err := time.ParseInLocation(icsDateTime, value, location)
+	return t, false, err
+}
+
+func formatICSTime(t time.Time, location *time.Location) string {
+	if location == time.UTC || location.String() == "UTC" || location.String() == "Local" {
+		return ":" + t.UTC().Format(icsDateTime) + "Z"
+	}
+	return ";TZID=" + location.String() + ":" + t.In(location).Format(icsDateTime)
+}
+
+func parseICSDuration(value string) (time.Duration, error) {
+	match := icsDuration.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration time.Duration
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+		amount, _ := strconv.Atoi(match[i+2])
+		duration += time.Duration(amount) * unit
+	}
+	if match[1] == "-" {
+		duration = -duration
+	}
+	return duration, nil
+}
+
+func escapeICSText(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(text)
+}
+
+func unescapeICSText(text string) string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return replacer.Replace(text)
+}
+
+// splitICSList splits a comma separated value on the commas that are not
+// escaped.
+func splitICSList(value string) []string {
+	var items []string
+	var item strings.Builder
+	escaped := false
+	for _, char := range value {
+		switch {
+		case escaped:
+			item.WriteRune('\\')
+			item.WriteRune(char)
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == ',':
+			items = append(items, unescapeICSText(item.String()))
+			item.Reset()
+		default:
+			item.WriteRune(char)
+		}
+	}
+	return append(items, unescapeICSText(item.String()))
+}
+
+// foldICSLine breaks lines longer than 75 octets, without splitting a UTF-8
+// character.
+func foldICSLine(line string) string {
+	if len(line) <= 75 {
+		return line
+	}
+
+	var folded strings.Builder
+	size := 0
+	for _, char := range line {
+		width := len(string(char))
+		if size+width > 75 {
+			folded.WriteString("\r\n ")
+			size = 1
+		}
+		folded.WriteRune(char)
+		size += width
+	}
+	return folded.String()
+}
@@ -0,0 +1,329 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods bounds the expansion of a rule that never matches, e.g. the
+// 31st of February.
+const maxPeriods = 10000
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// byDay is a BYDAY entry, e.g. MO, or 1MO and -1FR with an ordinal inside
+// the month or year.
+type byDay struct {
+	ordinal int
+	weekday time.Weekday
+}
+
+// RRule is the subset of RFC 5545 recurrence rules the calendar supports:
+// FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []byDay
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// ParseRRule parses a rule such as FREQ=WEEKLY;BYDAY=MO,WE, with or without
+// the RRULE: prefix.
+func ParseRRule(value string, location *time.Location) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	rule := &RRule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		name, arg, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(arg)
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(arg)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(arg)
+			if err == nil && rule.Count < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "UNTIL":
+			rule.Until, _, err = parseICSTime(arg, location)
+		case "BYDAY":
+			for _, day := range strings.Split(arg, ",") {
+				entry, dayErr := parseByDay(day)
+				if dayErr != nil {
+					err = dayErr
+					break
+				}
+				rule.ByDay = append(rule.ByDay, entry)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(arg, ",") {
+				monthDay, dayErr := strconv.Atoi(day)
+				if dayErr != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+					err = fmt.Errorf("invalid day %q", day)
+					break
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, monthDay)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(arg, ",") {
+				number, monthErr := strconv.Atoi(month)
+				if monthErr != nil || number < 1 || number > 12 {
+					err = fmt.Errorf("invalid month %q", month)
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(number))
+			}
+		case "WKST":
+			// Weeks start on Monday
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return nil, fmt.Errorf("FREQ is required")
+	default:
+		return nil, fmt.Errorf("unsupported FREQ %s", rule.Freq)
+	}
+	return rule, nil
+}
+
+func parseByDay(value string) (byDay, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) < 2 {
+		return byDay{}, fmt.Errorf("invalid day %q", value)
+	}
+
+	weekday, ok := weekdayCodes[value[len(value)-2:]]
+	if !ok {
+		return byDay{}, fmt.Errorf("invalid day %q", value)
+	}
+	entry := byDay{weekday: weekday}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		ordinal, err := strconv.Atoi(prefix)
+		if err != nil || ordinal == 0 || ordinal < -53 || ordinal > 53 {
+			return byDay{}, fmt.Errorf("invalid day %q", value)
+		}
+		entry.ordinal = ordinal
+	}
+	return entry, nil
+}
+
+// each calls fn with the start of every occurrence from start, in order,
+// until fn returns false. Occurrences keep the wall clock time of start in
+// its location, across daylight saving changes.
+func (r *RRule) each(start time.Time, fn func(time.Time) bool) {
+	year, month, day := start.Date()
+	hour, minute, second := start.Clock()
+	location := start.Location()
+	at := func(date time.Time) time.Time {
+		return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, second, 0, location)
+	}
+
+	count := 0
+	for period := 0; period < maxPeriods; period++ {
+		var candidates []time.Time
+		switch r.Freq {
+		case "DAILY":
+			candidates = []time.Time{dateOf(year, month, day+period*r.Interval)}
+		case "WEEKLY":
+			monday := dateOf(year, month, day-(int(start.Weekday())+6)%7+7*period*r.Interval)
+			candidates = r.weekDates(monday, start.Weekday())
+		case "MONTHLY":
+			first := dateOf(year, month+time.Month(period*r.Interval), 1)
+			candidates = r.monthDates(first, day)
+		case "YEARLY":
+			candidates = r.yearDates(year+period*r.Interval, month, day)
+		}
+
+		for _, date := range candidates {
+			if !r.matches(date) {
+				continue
+			}
+			occurrence := at(date)
+			if occurrence.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && occurrence.After(r.Until) {
+				return
+			}
+			if !fn(occurrence) {
+				return
+			}
+			count++
+			if r.Count > 0 && count >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+func dateOf(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// matches applies the BY parts that limit the dates a frequency produces.
+func (r *RRule) matches(date time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, date.Month()) {
+		return false
+	}
+	if r.Freq == "DAILY" && len(r.ByMonthDay) > 0 && !matchesMonthDay(r.ByMonthDay, date) {
+		return false
+	}
+	if r.Freq == "DAILY" && len(r.ByDay) > 0 {
+		for _, entry := range r.ByDay {
+			if entry.weekday == date.Weekday() {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (r *RRule) weekDates(monday time.Time, weekday time.Weekday) []time.Time {
+	if len(r.ByDay) == 0 {
+		return []time.Time{monday.AddDate(0, 0, (int(weekday)+6)%7)}
+	}
+
+	var dates []time.Time
+	for offset := 0; offset < 7; offset++ {
+		date := monday.AddDate(0, 0, offset)
+		for _, entry := range r.ByDay {
+			if entry.weekday == date.Weekday() {
+				dates = append(dates, date)
+				break
+			}
+		}
+	}
+	return dates
+}
+
+func (r *RRule) monthDates(first time.Time, day int) []time.Time {
+	last := first.AddDate(0, 1, -1)
+
+	var dates []time.Time
+	switch {
+	case len(r.ByDay) > 0:
+		dates = weekdaysBetween(first, last, r.ByDay)
+		if len(r.ByMonthDay) > 0 {
+			filtered := dates[:0]
+			for _, date := range dates {
+				if matchesMonthDay(r.ByMonthDay, date) {
+					filtered = append(filtered, date)
+				}
+			}
+			dates = filtered
+		}
+	case len(r.ByMonthDay) > 0:
+		for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+			if matchesMonthDay(r.ByMonthDay, date) {
+				dates = append(dates, date)
+			}
+		}
+	default:
+		// Months without the day of the start are skipped
+		if day <= last.Day() {
+			dates = append(dates, dateOf(first.Year(), first.Month(), day))
+		}
+	}
+	return dates
+}
+
+func (r *RRule) yearDates(year int, month time.Month, day int) []time.Time {
+	if len(r.ByDay) > 0 && len(r.ByMonth) == 0 {
+		return weekdaysBetween(dateOf(year, time.January, 1), dateOf(year, time.December, 31), r.ByDay)
+	}
+
+	months := r.ByMonth
+	if len(months) == 0 {
+		months = []time.Month{month}
+	}
+	var dates []time.Time
+	for _, current := range months {
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			if day <= dateOf(year, current+1, 0).Day() {
+				dates = append(dates, dateOf(year, current, day))
+			}
+			continue
+		}
+		dates = append(dates, r.monthDates(dateOf(year, current, 1), day)...)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// weekdaysBetween lists the dates from first to last that match days, with
+// ordinals counted inside that span.
+func weekdaysBetween(first, last time.Time, days []byDay) []time.Time {
+	var dates []time.Time
+	for _, entry := range days {
+		var matching []time.Time
+		for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+			if date.Weekday() == entry.weekday {
+				matching = append(matching, date)
+			}
+		}
+
+		switch {
+		case entry.ordinal == 0:
+			dates = append(dates, matching...)
+		case entry.ordinal > 0 && entry.ordinal <= len(matching):
+			dates = append(dates, matching[entry.ordinal-1])
+		case entry.ordinal < 0 && -entry.ordinal <= len(matching):
+			dates = append(dates, matching[len(matching)+entry.ordinal])
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+func matchesMonthDay(days []int, date time.Time) bool {
+	last := dateOf(date.Year(), date.Month()+1, 0).Day()
+	for _, day := range days {
+		if day == date.Day() || (day < 0 && last+day+1 == date.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, current := range months {
+		if current == month {
+			return true
+		}
+	}
+	return false
+}
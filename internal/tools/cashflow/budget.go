@@ -0,0 +1,126 @@
+package cashflow
+
+import (
+	"fmt"
+	"time"
+)
+
+// Budget is the most a user wants to spend on a category each month.
+type Budget struct {
+	ID       string       `json:"id"`
+	UserID   string       `json:"user_id"`
+	Category string       `json:"category"`
+	Amount   float64      `json:"amount"`
+	Currency CurrencyType `json:"currency"`
+}
+
+// BudgetStatus is the spending of a month against the budget of a category.
+type BudgetStatus struct {
+	Budget Budget  `json:"budget"`
+	Spent  float64 `json:"spent"`
+	Over   bool    `json:"over"`
+}
+
+// monthRange returns the first and last instants of the month of t.
+func monthRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+}
+
+// spending sums the expenses of transactions by category, in currency.
+func spending(transactions []Transaction, currency CurrencyType) (map[string]float64, error) {
+	spent := map[string]float64{}
+	for _, t := range transactions {
+		if t.Type != Expense {
+			continue
+		}
+		amount, err := exchangeRates.Convert(t.Amount, t.Currency, currency)
+		if err != nil {
+			return nil, err
+		}
+		spent[t.Category.Name] += amount
+	}
+	return spent, nil
+}
+
+// BudgetStatuses compares the spending of the month of date with each budget
+// of the user.
+func BudgetStatuses(userID string, date time.Time) ([]BudgetStatus, error) {
+	if store == nil {
+		return nil, fmt.Errorf("the cash flow is not configured on this bot")
+	}
+
+	budgets, err := store.GetBudgets(userID)
+	if err != nil || len(budgets) == 0 {
+		return nil, err
+	}
+	start, end := monthRange(date)
+	transactions, err := store.GetTransactions(userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for _, budget := range budgets {
+		spent, err := spending(transactions, budget.Currency)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, BudgetStatus{
+			Budget: budget,
+			Spent:  spent[budget.Category],
+			Over:   spent[budget.Category] > budget.Amount,
+		})
+	}
+	return statuses, nil
+}
+
+// CheckBudget returns the status of the budget of category in the month of
+// date, nil when the category has no budget.
+func CheckBudget(userID string, category string, date time.Time) (*BudgetStatus, error) {
+	statuses, err := BudgetStatuses(userID, date)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if status.Budget.Category == category {
+			return &status, nil
+		}
+	}
+	return nil, nil
+}
+
+// budgetWarning tells the model a transaction took its category over budget.
+func budgetWarning(transaction Transaction) string {
+	if transaction.Type != Expense {
+		return ""
+	}
+
+	status, err := CheckBudget(transaction.UserID, transaction.Category.Name, transaction.Date)
+	if err != nil || status == nil || !status.Over {
+		return ""
+	}
+	return fmt.Sprintf("\nWarning: spending on %s in %s is %s %s, over the monthly budget of %s %s. Tell the user.",
+		status.Budget.Category, transaction.Date.Format("January 2006"),
+		FormatAmount(status.Spent), status.Budget.Currency, FormatAmount(status.Budget.Amount), status.Budget.Currency)
+}
+
+// FormatAmount writes an amount with thousands separators and at most two
+// decimals, e.g. 1,250,000 or 12.50.
+func FormatAmount(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	text := fmt.Sprintf("%.2f", amount)
+	whole, cents := text[:len(text)-3], text[len(text)-2:]
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if cents == "00" {
+		return sign + whole
+	}
+	return sign + whole + "." + cents
+}
@@ -2,12 +2,12 @@ package cashflow
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
+	"teo/internal/config"
+	"teo/internal/tools/attachment"
 	"time"
 )
 
@@ -33,6 +33,8 @@ type Category struct {
 	Name string `json:"name"`
 }
 
+// Transaction is an income or expense. ImportID identifies the row of a bank
+// statement it was imported from, so the same row is not imported twice.
 type Transaction struct {
 	ID          string          `json:"id"`
 	UserID      string          `json:"user_id"`
@@ -42,98 +44,68 @@ type Transaction struct {
 	Category    Category        `json:"category"`
 	Description string          `json:"description"`
 	Date        time.Time       `json:"date"`
+	ImportID    string          `json:"import_id,omitempty"`
 }
 
-type CashFlowTool struct {
-	dataPath string
-	mu       sync.RWMutex
-}
+// ErrTransactionNotFound is returned by a Store for a transaction that does
+// not exist or belongs to another user, likewise ErrBudgetNotFound and
+// ErrRecurringNotFound.
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrBudgetNotFound      = errors.New("budget not found")
+	ErrRecurringNotFound   = errors.New("recurring transaction not found")
+)
 
-type CashFlowData struct {
-	Transactions []Transaction `json:"transactions"`
-	Categories   []Category    `json:"categories"`
+// Store keeps the transactions and categories of each user. It is provided
+// by the bot service at startup, tools cannot import the repositories
+// directly.
+type Store interface {
+	CreateTransaction(transaction Transaction) (Transaction, error)
+	UpdateTransaction(transaction Transaction) error
+	DeleteTransaction(id string, userID string) error
+	// GetTransactions lists the transactions of a user from start to end,
+	// both included, the oldest first
+	GetTransactions(userID string, start, end time.Time) ([]Transaction, error)
+	// SaveCategory returns the category of a user named name, and whether it
+	// was created
+	SaveCategory(userID string, name string) (Category, bool, error)
+	GetCategories(userID string) ([]Category, error)
+	// SetBudget creates the budget of a category, or replaces it
+	SetBudget(budget Budget) (Budget, error)
+	DeleteBudget(userID string, category string) error
+	GetBudgets(userID string) ([]Budget, error)
+	CreateRecurring(recurring Recurring) (Recurring, error)
+	GetRecurring(userID string) ([]Recurring, error)
+	DeleteRecurring(id string, userID string) error
 }
 
-func NewCashFlowTool() *CashFlowTool {
-	workingDir, err := os.Getwd()
-	if err != nil {
-		log.Printf("Error getting working directory: %v\n", err)
-		return nil
-	}
+var store Store
 
-	dataDir := filepath.Join(workingDir, "data", "cashflow")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Printf("Error creating cashflow directory: %v\n", err)
-		return nil
-	}
-
-	dataPath := filepath.Join(dataDir, "cashflow.json")
-	return &CashFlowTool{
-		dataPath: dataPath,
-	}
+func SetStore(s Store) {
+	store = s
 }
 
-func (ct *CashFlowTool) loadData() (*CashFlowData, error) {
-	ct.mu.RLock()
-	defer ct.mu.RUnlock()
-
-	data := &CashFlowData{
-		Transactions: make([]Transaction, 0),
-		Categories:   make([]Category, 0),
-	}
-
-	if _, err := os.Stat(ct.dataPath); err == nil {
-		file, err := os.ReadFile(ct.dataPath)
-		if err != nil {
-			return nil, fmt.Errorf("error reading data file: %v", err)
-		}
-
-		if len(file) == 0 {
-			return data, nil
-		}
-
-		if err := json.Unmarshal(file, data); err != nil {
-			log.Printf("Warning: error parsing data file: %v, returning empty data", err)
-			return data, nil
-		}
-	}
-
-	return data, nil
+// CashFlowTool manages the cash flow of the user it was made for, the model
+// cannot pick another user.
+type CashFlowTool struct {
+	userID string
 }
 
-func (ct *CashFlowTool) saveData(data *CashFlowData) error {
-	ct.mu.Lock()
-	defer ct.mu.Unlock()
-
-	if data == nil {
-		data = &CashFlowData{
-			Transactions: make([]Transaction, 0),
-			Categories:   make([]Category, 0),
-		}
-	}
-
-	if data.Transactions == nil {
-		data.Transactions = make([]Transaction, 0)
-	}
-	if data.Categories == nil {
-		data.Categories = make([]Category, 0)
-	}
-
-	file, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshaling data: %v", err)
-	}
-
-	dir := filepath.Dir(ct.dataPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("error creating directory: %v", err)
+// DefaultCurrency is the currency of amounts given without one, and of
+// totals.
+func DefaultCurrency() CurrencyType {
+	if config.CashflowCurrency != "" {
+		return CurrencyType(config.CashflowCurrency)
 	}
+	return IDR
+}
 
-	if err := os.WriteFile(ct.dataPath, file, 0644); err != nil {
-		return fmt.Errorf("error writing data file: %v", err)
+func NewCashFlowTool(userId int) *CashFlowTool {
+	tool := &CashFlowTool{}
+	if userId != 0 {
+		tool.userID = strconv.Itoa(userId)
 	}
-
-	return nil
+	return tool
 }
 
 func toLowerCase(s string) string {
@@ -163,6 +135,13 @@ func (ct *CashFlowTool) CallTool(arguments string) string {
 		return "Error: action not found"
 	}
 
+	if store == nil {
+		return "Error: the cash flow is not configured on this bot."
+	}
+	if ct.userID == "" {
+		return "Error: the cash flow is only available in a chat with a user."
+	}
+
 	switch action {
 	case "add_transaction":
 		return ct.handleAddTransaction(params)
@@ -177,22 +156,32 @@ func (ct *CashFlowTool) CallTool(arguments string) string {
 	case "add_category":
 		return ct.handleAddCategory(params)
 	case "get_categories":
-		return ct.handleGetCategories()
+		return ct.handleGetCategories(params)
+	case "set_budget":
+		return ct.handleSetBudget(params)
+	case "delete_budget":
+		return ct.handleDeleteBudget(params)
+	case "get_budgets":
+		return ct.handleGetBudgets(params)
+	case "add_recurring":
+		return ct.handleAddRecurring(params)
+	case "get_recurring":
+		return ct.handleGetRecurring(params)
+	case "delete_recurring":
+		return ct.handleDeleteRecurring(params)
+	case "monthly_report":
+		report, _ := ct.handleMonthlyReport(params)
+		return report
+	case "export_transactions":
+		export, _ := ct.handleExportTransactions(params)
+		return export
 	default:
 		return fmt.Sprintf("Error: invalid action: %s", action)
 	}
 }
 
 func (ct *CashFlowTool) handleAddTransaction(params map[string]interface{}) string {
-	data, err := ct.loadData()
-	if err != nil {
-		return fmt.Sprintf("Error loading data: %v", err)
-	}
-
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	transactionData, ok := params["transaction"].(map[string]interface{})
 	if !ok {
@@ -209,13 +198,10 @@ func (ct *CashFlowTool) handleAddTransaction(params map[string]interface{}) stri
 		return "Error: transaction amount must be greater than 0"
 	}
 
-	currency, ok := transactionData["currency"].(string)
-	if !ok {
-		currency = string(IDR)
-	}
-	currencyType := CurrencyType(currency)
-	if currencyType != IDR && currencyType != USD && currencyType != EUR && currencyType != JPY && currencyType != GBP {
-		return "Error: invalid currency"
+	currency, _ := transactionData["currency"].(string)
+	currencyType, err := ParseCurrency(currency)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
 	}
 
 	dateStr, ok := transactionData["date"].(string)
@@ -239,51 +225,30 @@ func (ct *CashFlowTool) handleAddTransaction(params map[string]interface{}) stri
 
 	categoryName = toLowerCase(categoryName)
 
-	transaction := Transaction{
-		ID:          fmt.Sprintf("trx_%d", time.Now().UnixNano()),
+	category, _, err := store.SaveCategory(userID, categoryName)
+	if err != nil {
+		return fmt.Sprintf("Error saving category: %v", err)
+	}
+
+	description, _ := transactionData["description"].(string)
+	transaction, err := store.CreateTransaction(Transaction{
 		UserID:      userID,
 		Type:        TransactionType(transactionType),
 		Amount:      amount,
 		Currency:    currencyType,
-		Description: transactionData["description"].(string),
+		Description: description,
 		Date:        date,
-		Category: Category{
-			ID:   fmt.Sprintf("cat_%d", time.Now().UnixNano()),
-			Name: categoryName,
-		},
-	}
-
-	categoryExists := false
-	for _, c := range data.Categories {
-		if c.Name == transaction.Category.Name {
-			transaction.Category.ID = c.ID
-			categoryExists = true
-			break
-		}
-	}
-	if !categoryExists {
-		data.Categories = append(data.Categories, transaction.Category)
-	}
-
-	data.Transactions = append(data.Transactions, transaction)
-
-	if err := ct.saveData(data); err != nil {
+		Category:    category,
+	})
+	if err != nil {
 		return fmt.Sprintf("Error saving data: %v", err)
 	}
 
-	return fmt.Sprintf("Transaction added successfully with ID: %s", transaction.ID)
+	return fmt.Sprintf("Transaction added successfully with ID: %s", transaction.ID) + budgetWarning(transaction)
 }
 
 func (ct *CashFlowTool) handleGetTransactions(params map[string]interface{}) string {
-	data, err := ct.loadData()
-	if err != nil {
-		return fmt.Sprintf("Error loading data: %v", err)
-	}
-
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	dateRange, ok := params["date_range"].(map[string]interface{})
 	if !ok {
@@ -300,11 +265,9 @@ func (ct *CashFlowTool) handleGetTransactions(params map[string]interface{}) str
 		return fmt.Sprintf("Error: invalid end date format: %v", err)
 	}
 
-	var filteredTransactions []Transaction
-	for _, t := range data.Transactions {
-		if t.UserID == userID && (t.Date.Equal(start) || t.Date.After(start)) && (t.Date.Equal(end) || t.Date.Before(end)) {
-			filteredTransactions = append(filteredTransactions, t)
-		}
+	filteredTransactions, err := store.GetTransactions(userID, start, end)
+	if err != nil {
+		return fmt.Sprintf("Error loading data: %v", err)
 	}
 
 	result, err := json.Marshal(filteredTransactions)
@@ -312,23 +275,11 @@ func (ct *CashFlowTool) handleGetTransactions(params map[string]interface{}) str
 		return fmt.Sprintf("Error: failed to convert data: %v", err)
 	}
 
-	if err := ct.saveData(data); err != nil {
-		return fmt.Sprintf("Error saving data: %v", err)
-	}
-
 	return string(result)
 }
 
 func (ct *CashFlowTool) handleUpdateTransaction(params map[string]interface{}) string {
-	data, err := ct.loadData()
-	if err != nil {
-		return fmt.Sprintf("Error loading data: %v", err)
-	}
-
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	transactionID, ok := params["transaction_id"].(string)
 	if !ok {
@@ -350,13 +301,10 @@ func (ct *CashFlowTool) handleUpdateTransaction(params map[string]interface{}) s
 		return "Error: transaction amount must be greater than 0"
 	}
 
-	currency, ok := transactionData["currency"].(string)
-	if !ok {
-		currency = string(IDR)
-	}
-	currencyType := CurrencyType(currency)
-	if currencyType != IDR && currencyType != USD && currencyType != EUR && currencyType != JPY && currencyType != GBP {
-		return "Error: invalid currency"
+	currency, _ := transactionData["currency"].(string)
+	currencyType, err := ParseCurrency(currency)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
 	}
 
 	dateStr, ok := transactionData["date"].(string)
@@ -380,91 +328,46 @@ func (ct *CashFlowTool) handleUpdateTransaction(params map[string]interface{}) s
 
 	categoryName = toLowerCase(categoryName)
 
-	found := false
-	for i, t := range data.Transactions {
-		if t.ID == transactionID && t.UserID == userID {
-			categoryExists := false
-			for _, c := range data.Categories {
-				if c.Name == categoryName {
-					data.Transactions[i] = Transaction{
-						ID:          transactionID,
-						UserID:      userID,
-						Type:        TransactionType(transactionType),
-						Amount:      amount,
-						Currency:    currencyType,
-						Description: transactionData["description"].(string),
-						Date:        date,
-						Category:    c,
-					}
-					categoryExists = true
-					break
-				}
-			}
-
-			if !categoryExists {
-				newCategory := Category{
-					ID:   fmt.Sprintf("cat_%d", time.Now().UnixNano()),
-					Name: categoryName,
-				}
-				data.Categories = append(data.Categories, newCategory)
-				data.Transactions[i] = Transaction{
-					ID:          transactionID,
-					UserID:      userID,
-					Type:        TransactionType(transactionType),
-					Amount:      amount,
-					Currency:    currencyType,
-					Description: transactionData["description"].(string),
-					Date:        date,
-					Category:    newCategory,
-				}
-			}
-
-			found = true
-			break
-		}
+	category, _, err := store.SaveCategory(userID, categoryName)
+	if err != nil {
+		return fmt.Sprintf("Error saving category: %v", err)
 	}
 
-	if !found {
+	description, _ := transactionData["description"].(string)
+	transaction := Transaction{
+		ID:          transactionID,
+		UserID:      userID,
+		Type:        TransactionType(transactionType),
+		Amount:      amount,
+		Currency:    currencyType,
+		Description: description,
+		Date:        date,
+		Category:    category,
+	}
+	err = store.UpdateTransaction(transaction)
+	if errors.Is(err, ErrTransactionNotFound) {
 		return "Error: transaction not found"
 	}
-
-	if err := ct.saveData(data); err != nil {
+	if err != nil {
 		return fmt.Sprintf("Error saving data: %v", err)
 	}
 
-	return fmt.Sprintf("Transaction updated successfully with ID: %s", transactionID)
+	return fmt.Sprintf("Transaction updated successfully with ID: %s", transactionID) + budgetWarning(transaction)
 }
 
 func (ct *CashFlowTool) handleDeleteTransaction(params map[string]interface{}) string {
-	data, err := ct.loadData()
-	if err != nil {
-		return fmt.Sprintf("Error loading data: %v", err)
-	}
-
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	transactionID, ok := params["transaction_id"].(string)
 	if !ok {
 		return "Error: transaction ID not found"
 	}
 
-	found := false
-	for i, t := range data.Transactions {
-		if t.ID == transactionID && t.UserID == userID {
-			data.Transactions = append(data.Transactions[:i], data.Transactions[i+1:]...)
-			found = true
-			break
-		}
-	}
-
-	if !found {
+	err := store.DeleteTransaction(transactionID, userID)
+	if errors.Is(err, ErrTransactionNotFound) {
 		return "Error: transaction not found"
 	}
-
-	if err := ct.saveData(data); err != nil {
+	if err != nil {
 		return fmt.Sprintf("Error saving data: %v", err)
 	}
 
@@ -472,15 +375,7 @@ func (ct *CashFlowTool) handleDeleteTransaction(params map[string]interface{}) s
 }
 
 func (ct *CashFlowTool) handleGetAnalytics(params map[string]interface{}) string {
-	data, err := ct.loadData()
-	if err != nil {
-		return fmt.Sprintf("Error loading data: %v", err)
-	}
-
-	userID, ok := params["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
-	}
+	userID := ct.userID
 
 	dateRange, ok := params["date_range"].(map[string]interface{})
 	if !ok {
@@ -497,25 +392,39 @@ func (ct *CashFlowTool) handleGetAnalytics(params map[string]interface{}) string
 		return fmt.Sprintf("Error: invalid end date format: %v", err)
 	}
 
+	currencyValue, _ := params["currency"].(string)
+	currency, err := ParseCurrency(currencyValue)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	transactions, err := store.GetTransactions(userID, start, end)
+	if err != nil {
+		return fmt.Sprintf("Error loading data: %v", err)
+	}
+
 	var totalIncome, totalExpense float64
 	incomeByCategory := make(map[string]float64)
 	expenseByCategory := make(map[string]float64)
 	transactionCount := 0
 
-	for _, t := range data.Transactions {
-		if t.UserID == userID && (t.Date.Equal(start) || t.Date.After(start)) && (t.Date.Equal(end) || t.Date.Before(end)) {
-			transactionCount++
-			if t.Type == Income {
-				totalIncome += t.Amount
-				incomeByCategory[t.Category.Name] += t.Amount
-			} else {
-				totalExpense += t.Amount
-				expenseByCategory[t.Category.Name] += t.Amount
-			}
+	for _, t := range transactions {
+		transactionCount++
+		amount, err := exchangeRates.Convert(t.Amount, t.Currency, currency)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		if t.Type == Income {
+			totalIncome += amount
+			incomeByCategory[t.Category.Name] += amount
+		} else {
+			totalExpense += amount
+			expenseByCategory[t.Category.Name] += amount
 		}
 	}
 
 	analytics := map[string]interface{}{
+		"currency":            currency,
 		"total_income":        totalIncome,
 		"total_expense":       totalExpense,
 		"balance":             totalIncome - totalExpense,
@@ -533,10 +442,7 @@ func (ct *CashFlowTool) handleGetAnalytics(params map[string]interface{}) string
 }
 
 func (ct *CashFlowTool) handleAddCategory(params map[string]interface{}) string {
-	data, err := ct.loadData()
-	if err != nil {
-		return fmt.Sprintf("Error loading data: %v", err)
-	}
+	userID := ct.userID
 
 	categoryData, ok := params["category"].(map[string]interface{})
 	if !ok {
@@ -550,41 +456,316 @@ func (ct *CashFlowTool) handleAddCategory(params map[string]interface{}) string
 
 	name = toLowerCase(name)
 
-	for _, c := range data.Categories {
-		if c.Name == name {
-			return "Error: category with this name already exists"
+	_, created, err := store.SaveCategory(userID, name)
+	if err != nil {
+		return fmt.Sprintf("Error saving data: %v", err)
+	}
+	if !created {
+		return "Error: category with this name already exists"
+	}
+
+	return "Category added successfully"
+}
+
+func (ct *CashFlowTool) handleGetCategories(params map[string]interface{}) string {
+	userID := ct.userID
+
+	categories, err := store.GetCategories(userID)
+	if err != nil {
+		return fmt.Sprintf("Error loading data: %v", err)
+	}
+
+	result, err := json.Marshal(categories)
+	if err != nil {
+		return fmt.Sprintf("Error: failed to convert data: %v", err)
+	}
+
+	return string(result)
+}
+
+// CallToolWithAttachments sends the chart of monthly_report and the file of
+// export_transactions, the other actions return text only.
+func (ct *CashFlowTool) CallToolWithAttachments(arguments string) (string, []attachment.Attachment) {
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return fmt.Sprintf("Error parsing arguments: %v", err), nil
+	}
+	if store == nil {
+		return ct.CallTool(arguments), nil
+	}
+
+	switch action, _ := params["action"].(string); action {
+	case "monthly_report":
+		return ct.handleMonthlyReport(params)
+	case "export_transactions":
+		return ct.handleExportTransactions(params)
+	default:
+		return ct.CallTool(arguments), nil
+	}
+}
+
+func (ct *CashFlowTool) handleSetBudget(params map[string]interface{}) string {
+	userID := ct.userID
+
+	budgetData, ok := params["budget"].(map[string]interface{})
+	if !ok {
+		return "Error: invalid budget data"
+	}
+
+	category, ok := budgetData["category"].(string)
+	if !ok || category == "" {
+		return "Error: category name cannot be empty"
+	}
+
+	amount, ok := budgetData["amount"].(float64)
+	if !ok || amount <= 0 {
+		return "Error: budget amount must be greater than 0"
+	}
+
+	currencyValue, _ := budgetData["currency"].(string)
+	currency, err := ParseCurrency(currencyValue)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	category = toLowerCase(category)
+	if _, _, err := store.SaveCategory(userID, category); err != nil {
+		return fmt.Sprintf("Error saving category: %v", err)
+	}
+
+	budget, err := store.SetBudget(Budget{UserID: userID, Category: category, Amount: amount, Currency: currency})
+	if err != nil {
+		return fmt.Sprintf("Error saving data: %v", err)
+	}
+
+	result := fmt.Sprintf("Monthly budget of %s set to %s %s", budget.Category, FormatAmount(budget.Amount), budget.Currency)
+	if status, err := CheckBudget(userID, category, time.Now().In(config.Timezone)); err == nil && status != nil {
+		result += fmt.Sprintf(", %s spent this month", FormatAmount(status.Spent))
+		if status.Over {
+			result += ", already over budget"
 		}
 	}
+	return result
+}
+
+func (ct *CashFlowTool) handleDeleteBudget(params map[string]interface{}) string {
+	userID := ct.userID
+
+	budgetData, ok := params["budget"].(map[string]interface{})
+	if !ok {
+		return "Error: invalid budget data"
+	}
+
+	category, ok := budgetData["category"].(string)
+	if !ok || category == "" {
+		return "Error: category name cannot be empty"
+	}
+
+	err := store.DeleteBudget(userID, toLowerCase(category))
+	if errors.Is(err, ErrBudgetNotFound) {
+		return "Error: budget not found"
+	}
+	if err != nil {
+		return fmt.Sprintf("Error saving data: %v", err)
+	}
+
+	return "Budget deleted successfully"
+}
+
+func (ct *CashFlowTool) handleGetBudgets(params map[string]interface{}) string {
+	userID := ct.userID
+
+	statuses, err := BudgetStatuses(userID, time.Now().In(config.Timezone))
+	if err != nil {
+		return fmt.Sprintf("Error loading data: %v", err)
+	}
+	if len(statuses) == 0 {
+		return "No budgets set"
+	}
+
+	result, err := json.Marshal(statuses)
+	if err != nil {
+		return fmt.Sprintf("Error: failed to convert data: %v", err)
+	}
+
+	return string(result)
+}
+
+func (ct *CashFlowTool) handleAddRecurring(params map[string]interface{}) string {
+	userID := ct.userID
 
-	id, _ := categoryData["id"].(string)
-	if id == "" {
-		id = fmt.Sprintf("cat_%d", time.Now().UnixNano())
+	recurringData, ok := params["recurring"].(map[string]interface{})
+	if !ok {
+		return "Error: invalid recurring transaction data"
 	}
 
-	category := Category{
-		ID:   id,
-		Name: name,
+	transactionType, ok := recurringData["type"].(string)
+	if !ok || (transactionType != "income" && transactionType != "expense") {
+		return "Error: invalid transaction type"
 	}
 
-	data.Categories = append(data.Categories, category)
+	amount, ok := recurringData["amount"].(float64)
+	if !ok || amount <= 0 {
+		return "Error: transaction amount must be greater than 0"
+	}
+
+	currencyValue, _ := recurringData["currency"].(string)
+	currency, err := ParseCurrency(currencyValue)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
 
-	if err := ct.saveData(data); err != nil {
+	frequencyValue, _ := recurringData["frequency"].(string)
+	frequency, err := parseFrequency(frequencyValue)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	interval := 1
+	if value, ok := recurringData["interval"].(float64); ok {
+		if value < 1 {
+			return "Error: interval must be at least 1"
+		}
+		interval = int(value)
+	}
+
+	startValue, ok := recurringData["start_date"].(string)
+	if !ok {
+		return "Error: invalid start_date format"
+	}
+	startDate, err := parseDate(startValue)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	var endDate time.Time
+	if endValue, ok := recurringData["end_date"].(string); ok && endValue != "" {
+		if endDate, err = parseDate(endValue); err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		if endDate.Before(startDate) {
+			return "Error: end_date is before start_date"
+		}
+	}
+
+	categoryName, ok := recurringData["category"].(string)
+	if !ok || categoryName == "" {
+		return "Error: category name cannot be empty"
+	}
+	category, _, err := store.SaveCategory(userID, toLowerCase(categoryName))
+	if err != nil {
+		return fmt.Sprintf("Error saving category: %v", err)
+	}
+
+	description, _ := recurringData["description"].(string)
+	recurring, err := store.CreateRecurring(Recurring{
+		UserID:      userID,
+		Type:        TransactionType(transactionType),
+		Amount:      amount,
+		Currency:    currency,
+		Category:    category,
+		Description: description,
+		Frequency:   frequency,
+		Interval:    interval,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		NextDate:    startDate,
+	})
+	if err != nil {
 		return fmt.Sprintf("Error saving data: %v", err)
 	}
 
-	return "Category added successfully"
+	return fmt.Sprintf("Recurring transaction added with ID: %s, first entry on %s", recurring.ID, recurring.NextDate.Format("2006-01-02"))
 }
 
-func (ct *CashFlowTool) handleGetCategories() string {
-	data, err := ct.loadData()
+func (ct *CashFlowTool) handleGetRecurring(params map[string]interface{}) string {
+	userID := ct.userID
+
+	recurring, err := store.GetRecurring(userID)
 	if err != nil {
 		return fmt.Sprintf("Error loading data: %v", err)
 	}
 
-	result, err := json.Marshal(data.Categories)
+	result, err := json.Marshal(recurring)
 	if err != nil {
 		return fmt.Sprintf("Error: failed to convert data: %v", err)
 	}
 
 	return string(result)
 }
+
+func (ct *CashFlowTool) handleDeleteRecurring(params map[string]interface{}) string {
+	userID := ct.userID
+
+	recurringID, ok := params["recurring_id"].(string)
+	if !ok {
+		return "Error: recurring ID not found"
+	}
+
+	err := store.DeleteRecurring(recurringID, userID)
+	if errors.Is(err, ErrRecurringNotFound) {
+		return "Error: recurring transaction not found"
+	}
+	if err != nil {
+		return fmt.Sprintf("Error saving data: %v", err)
+	}
+
+	return "Recurring transaction deleted successfully, past entries are kept"
+}
+
+func (ct *CashFlowTool) handleMonthlyReport(params map[string]interface{}) (string, []attachment.Attachment) {
+	userID := ct.userID
+
+	month := time.Now().In(config.Timezone)
+	if value, ok := params["month"].(string); ok && value != "" {
+		parsed, err := time.ParseInLocation("2006-01", value, config.Timezone)
+		if err != nil {
+			return "Error: month must be formatted as YYYY-MM", nil
+		}
+		month = parsed
+	}
+
+	currencyValue, _ := params["currency"].(string)
+	currency, err := ParseCurrency(currencyValue)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+
+	report, err := MonthlyReport(userID, month, currency)
+	if err != nil {
+		return fmt.Sprintf("Error creating report: %v", err), nil
+	}
+	chart, err := report.Chart()
+	if err != nil {
+		return fmt.Sprintf("Error drawing chart: %v", err), nil
+	}
+
+	return report.Caption() + "\nThe chart was sent to the user.", []attachment.Attachment{attachment.New("cashflow-"+report.Month.Format("2006-01")+".png", chart)}
+}
+
+func (ct *CashFlowTool) handleExportTransactions(params map[string]interface{}) (string, []attachment.Attachment) {
+	userID := ct.userID
+
+	dateRange, ok := params["date_range"].(map[string]interface{})
+	if !ok {
+		return "Error: invalid date range", nil
+	}
+	startStr, _ := dateRange["start"].(string)
+	start, err := parseDate(startStr)
+	if err != nil {
+		return fmt.Sprintf("Error: invalid start date format: %v", err), nil
+	}
+	endStr, _ := dateRange["end"].(string)
+	end, err := parseDate(endStr)
+	if err != nil {
+		return fmt.Sprintf("Error: invalid end date format: %v", err), nil
+	}
+
+	format, _ := params["format"].(string)
+	file, count, err := ExportFile(userID, start, end, format)
+	if err != nil {
+		return fmt.Sprintf("Error exporting transactions: %v", err), nil
+	}
+
+	return fmt.Sprintf("Exported %d transactions to %s, the file was sent to the user.", count, file.FileName), []attachment.Attachment{file}
+}
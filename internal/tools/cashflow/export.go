@@ -0,0 +1,166 @@
+package cashflow
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"teo/internal/config"
+	"teo/internal/tools/attachment"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var exportHeader = []string{"Date", "Type", "Category", "Description", "Amount", "Currency"}
+
+func exportRow(t Transaction) []string {
+	return []string{
+		t.Date.In(config.Timezone).Format("2006-01-02"),
+		string(t.Type),
+		t.Category.Name,
+		t.Description,
+		strconv.FormatFloat(t.Amount, 'f', -1, 64),
+		string(t.Currency),
+	}
+}
+
+// Export writes transactions as a CSV file or an Excel workbook, format
+// being the extension of the file.
+func Export(transactions []Transaction, format string) ([]byte, error) {
+	switch format {
+	case FormatCSV:
+		return ExportCSV(transactions)
+	case FormatXLSX:
+		return ExportXLSX(transactions)
+	default:
+		return nil, fmt.Errorf("format must be %s or %s", FormatCSV, FormatXLSX)
+	}
+}
+
+// ExportFile exports the transactions of a user from the day of start to the
+// day of end, both included, as a file named after the range. It also
+// returns how many transactions the file has.
+func ExportFile(userID string, start, end time.Time, format string) (attachment.Attachment, int, error) {
+	if store == nil {
+		return attachment.Attachment{}, 0, fmt.Errorf("the cash flow is not configured on this bot")
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = FormatCSV
+	}
+
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location()).AddDate(0, 0, 1).Add(-time.Nanosecond)
+	if end.Before(start) {
+		return attachment.Attachment{}, 0, fmt.Errorf("the end date is before the start date")
+	}
+
+	transactions, err := store.GetTransactions(userID, start, end)
+	if err != nil {
+		return attachment.Attachment{}, 0, err
+	}
+	data, err := Export(transactions, format)
+	if err != nil {
+		return attachment.Attachment{}, 0, err
+	}
+
+	fileName := fmt.Sprintf("transactions-%s-%s.%s", start.Format("20060102"), end.Format("20060102"), format)
+	return attachment.New(fileName, data), len(transactions), nil
+}
+
+// ExportCSV writes transactions as CSV with a header row. The file can be
+// imported again as a statement.
+func ExportCSV(transactions []Transaction) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(exportHeader); err != nil {
+		return nil, err
+	}
+	for _, t := range transactions {
+		if err := writer.Write(exportRow(t)); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// xlsxParts are the parts of a workbook with a single sheet, the sheet
+// itself aside.
+var xlsxParts = map[string]string{
+	"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`,
+	"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`,
+	"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Transactions" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+	"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+}
+
+// amountColumn is the index of the column of amounts, written as numbers in
+// a workbook so that they can be summed.
+const amountColumn = 4
+
+// ExportXLSX writes transactions as an Excel workbook. It is the minimal
+// Office Open XML package, with inline strings so it needs no shared string
+// table.
+func ExportXLSX(transactions []Transaction) ([]byte, error) {
+	var sheet strings.Builder
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	rows := [][]string{exportHeader}
+	for _, t := range transactions {
+		rows = append(rows, exportRow(t))
+	}
+	for i, row := range rows {
+		sheet.WriteString(fmt.Sprintf(`<row r="%d">`, i+1))
+		for j, value := range row {
+			ref := fmt.Sprintf("%c%d", 'A'+j, i+1)
+			if i > 0 && j == amountColumn {
+				sheet.WriteString(fmt.Sprintf(`<c r="%s"><v>%s</v></c>`, ref, value))
+				continue
+			}
+			var escaped bytes.Buffer
+			if err := xml.EscapeText(&escaped, []byte(value)); err != nil {
+				return nil, err
+			}
+			sheet.WriteString(fmt.Sprintf(`<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escaped.String()))
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	parts := []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"}
+	for _, name := range parts {
+		if err := writeZipFile(archive, name, xlsxParts[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeZipFile(archive, "xl/worksheets/sheet1.xml", sheet.String()); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeZipFile(archive *zip.Writer, name string, content string) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write([]byte(content))
+	return err
+}
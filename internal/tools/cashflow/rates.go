@@ -0,0 +1,178 @@
+package cashflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateCacheDuration is how long rates fetched online are used before they
+// are fetched again, and rateRetryDelay how long to wait after a failure.
+const (
+	rateCacheDuration = 12 * time.Hour
+	rateRetryDelay    = 10 * time.Minute
+)
+
+// Rates maps currency codes to their value in one unit of a common base
+// currency, e.g. {"USD": 1, "IDR": 16250}.
+type Rates map[string]float64
+
+// defaultRates keeps the currencies the tool always supported convertible
+// when no offline table is found.
+var defaultRates = Rates{
+	"USD": 1,
+	"EUR": 0.92,
+	"GBP": 0.79,
+	"JPY": 150,
+	"IDR": 16000,
+}
+
+// ratesFile is the format of the offline table, and of the common rate APIs
+// such as open.er-api.com.
+type ratesFile struct {
+	Rates Rates `json:"rates"`
+}
+
+// LoadRates reads an offline rate table.
+func LoadRates(path string) (Rates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRates(data)
+}
+
+func parseRates(data []byte) (Rates, error) {
+	var file ratesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Rates) == 0 {
+		return nil, fmt.Errorf("no rates found")
+	}
+
+	rates := Rates{}
+	for code, rate := range file.Rates {
+		if rate > 0 {
+			rates[strings.ToUpper(code)] = rate
+		}
+	}
+	return rates, nil
+}
+
+// ExchangeRates converts amounts with the rates of url, fetched at most every
+// rateCacheDuration, and falls back to the offline table when url is empty or
+// cannot be reached.
+type ExchangeRates struct {
+	url     string
+	offline Rates
+	client  *http.Client
+
+	mu        sync.Mutex
+	online    Rates
+	fetchedAt time.Time
+	retryAt   time.Time
+}
+
+func NewExchangeRates(url string, offline Rates) *ExchangeRates {
+	if len(offline) == 0 {
+		offline = defaultRates
+	}
+	return &ExchangeRates{
+		url:     url,
+		offline: offline,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+var exchangeRates = NewExchangeRates("", nil)
+
+func SetExchangeRates(rates *ExchangeRates) {
+	exchangeRates = rates
+}
+
+// Convert changes amount from one currency to another.
+func (e *ExchangeRates) Convert(amount float64, from CurrencyType, to CurrencyType) (float64, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	for _, rates := range []Rates{e.current(), e.offline} {
+		fromRate, fromOk := rates[string(from)]
+		toRate, toOk := rates[string(to)]
+		if fromOk && toOk {
+			return amount / fromRate * toRate, nil
+		}
+	}
+	return 0, fmt.Errorf("no exchange rate from %s to %s", from, to)
+}
+
+// Known reports whether code can be converted.
+func (e *ExchangeRates) Known(code CurrencyType) bool {
+	if _, ok := e.offline[string(code)]; ok {
+		return true
+	}
+	_, ok := e.current()[string(code)]
+	return ok
+}
+
+// current returns the rates fetched online, nil when there are none.
+func (e *ExchangeRates) current() Rates {
+	if e.url == "" {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.online != nil && time.Since(e.fetchedAt) < rateCacheDuration || time.Now().Before(e.retryAt) {
+		return e.online
+	}
+
+	rates, err := e.fetch()
+	if err != nil {
+		log.Printf("Warning: Error fetching exchange rates from %s: %v, using the offline table", e.url, err)
+		e.retryAt = time.Now().Add(rateRetryDelay)
+		return e.online
+	}
+	e.online = rates
+	e.fetchedAt = time.Now()
+	return e.online
+}
+
+func (e *ExchangeRates) fetch() (Rates, error) {
+	resp, err := e.client.Get(e.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return parseRates(data)
+}
+
+// ParseCurrency reads a currency code, the default currency when it is
+// empty.
+func ParseCurrency(value string) (CurrencyType, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return DefaultCurrency(), nil
+	}
+
+	currency := CurrencyType(value)
+	if len(value) != 3 || !exchangeRates.Known(currency) {
+		return "", fmt.Errorf("unknown currency %s", value)
+	}
+	return currency, nil
+}
@@ -0,0 +1,67 @@
+package cashflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	Yearly  = "yearly"
+)
+
+// Recurring is an income or expense added again every Interval periods of
+// Frequency from StartDate, until EndDate when it is set.
+type Recurring struct {
+	ID          string          `json:"id"`
+	UserID      string          `json:"user_id"`
+	Type        TransactionType `json:"type"`
+	Amount      float64         `json:"amount"`
+	Currency    CurrencyType    `json:"currency"`
+	Category    Category        `json:"category"`
+	Description string          `json:"description"`
+	Frequency   string          `json:"frequency"`
+	Interval    int             `json:"interval"`
+	StartDate   time.Time       `json:"start_date"`
+	EndDate     time.Time       `json:"end_date,omitempty"`
+	NextDate    time.Time       `json:"next_date"`
+}
+
+func parseFrequency(value string) (string, error) {
+	switch frequency := strings.ToLower(strings.TrimSpace(value)); frequency {
+	case Daily, Weekly, Monthly, Yearly:
+		return frequency, nil
+	default:
+		return "", fmt.Errorf("frequency must be %s, %s, %s or %s", Daily, Weekly, Monthly, Yearly)
+	}
+}
+
+// Occurrence returns the date of the nth entry of a recurring transaction,
+// the first being 0. Monthly and yearly entries that fall on a day the month
+// does not have move to its last day, e.g. the 31st becomes the 30th in April.
+func Occurrence(start time.Time, frequency string, interval int, n int) time.Time {
+	if interval < 1 {
+		interval = 1
+	}
+	step := n * interval
+
+	switch frequency {
+	case Daily:
+		return start.AddDate(0, 0, step)
+	case Weekly:
+		return start.AddDate(0, 0, 7*step)
+	case Yearly:
+		step *= 12
+	}
+
+	year, month, day := start.Date()
+	hour, minute, second := start.Clock()
+	first := time.Date(year, month+time.Month(step), 1, hour, minute, second, 0, start.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,226 @@
+package cashflow
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxReportCategories is how many categories the chart shows, the others are
+// added up as "other".
+const maxReportCategories = 7
+
+const (
+	chartWidth     = 800
+	chartPadding   = 30
+	chartBarHeight = 28
+	chartRowHeight = 44
+)
+
+// chartColor pairs a bar color with the emoji that stands for it in the
+// caption, the chart itself has no text.
+type chartColor struct {
+	color color.RGBA
+	mark  string
+}
+
+var (
+	chartColors = []chartColor{
+		{color.RGBA{0xdc, 0x35, 0x45, 0xff}, "🟥"},
+		{color.RGBA{0xfd, 0x7e, 0x14, 0xff}, "🟧"},
+		{color.RGBA{0xff, 0xc1, 0x07, 0xff}, "🟨"},
+		{color.RGBA{0x28, 0xa7, 0x45, 0xff}, "🟩"},
+		{color.RGBA{0x00, 0x7b, 0xff, 0xff}, "🟦"},
+		{color.RGBA{0x6f, 0x42, 0xc1, 0xff}, "🟪"},
+		{color.RGBA{0x79, 0x55, 0x48, 0xff}, "🟫"},
+	}
+	otherColor   = chartColor{color.RGBA{0x34, 0x3a, 0x40, 0xff}, "⬛"}
+	incomeColor  = color.RGBA{0x20, 0xc9, 0x97, 0xff}
+	expenseColor = color.RGBA{0x49, 0x50, 0x57, 0xff}
+	budgetColor  = color.RGBA{0xe9, 0xec, 0xef, 0xff}
+	markerColor  = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	lineColor    = color.RGBA{0xce, 0xd4, 0xda, 0xff}
+)
+
+// CategoryReport is the spending of a month on a category. Budget is 0 when
+// the category has none.
+type CategoryReport struct {
+	Name   string  `json:"name"`
+	Spent  float64 `json:"spent"`
+	Budget float64 `json:"budget,omitempty"`
+	Mark   string  `json:"-"`
+	color  color.RGBA
+}
+
+// Report sums the transactions of a month in one currency.
+type Report struct {
+	Month      time.Time        `json:"month"`
+	Currency   CurrencyType     `json:"currency"`
+	Income     float64          `json:"income"`
+	Expense    float64          `json:"expense"`
+	Categories []CategoryReport `json:"categories"`
+}
+
+// MonthlyReport builds the report of the month of month, amounts converted
+// to currency.
+func MonthlyReport(userID string, month time.Time, currency CurrencyType) (*Report, error) {
+	if store == nil {
+		return nil, fmt.Errorf("the cash flow is not configured on this bot")
+	}
+
+	start, end := monthRange(month)
+	transactions, err := store.GetTransactions(userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Month: start, Currency: currency}
+	for _, t := range transactions {
+		amount, err := exchangeRates.Convert(t.Amount, t.Currency, currency)
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == Income {
+			report.Income += amount
+		} else {
+			report.Expense += amount
+		}
+	}
+
+	spent, err := spending(transactions, currency)
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := store.GetBudgets(userID)
+	if err != nil {
+		return nil, err
+	}
+	limits := map[string]float64{}
+	for _, budget := range budgets {
+		amount, err := exchangeRates.Convert(budget.Amount, budget.Currency, currency)
+		if err != nil {
+			return nil, err
+		}
+		limits[budget.Category] = amount
+		if _, ok := spent[budget.Category]; !ok {
+			spent[budget.Category] = 0
+		}
+	}
+
+	for name, amount := range spent {
+		report.Categories = append(report.Categories, CategoryReport{Name: name, Spent: amount, Budget: limits[name]})
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		a, b := report.Categories[i], report.Categories[j]
+		if a.Spent != b.Spent {
+			return a.Spent > b.Spent
+		}
+		return a.Name < b.Name
+	})
+
+	if len(report.Categories) > maxReportCategories {
+		other := CategoryReport{Name: "other"}
+		for _, category := range report.Categories[maxReportCategories:] {
+			other.Spent += category.Spent
+			other.Budget += category.Budget
+		}
+		report.Categories = append(report.Categories[:maxReportCategories], other)
+	}
+	for i := range report.Categories {
+		shade := otherColor
+		if i < len(chartColors) && report.Categories[i].Name != "other" {
+			shade = chartColors[i]
+		}
+		report.Categories[i].Mark = shade.mark
+		report.Categories[i].color = shade.color
+	}
+	return report, nil
+}
+
+// Summary describes the report in plain text. It doubles as the caption of
+// the chart, with the emoji of each bar color.
+func (r *Report) Summary() string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("📊 Cash flow of %s (%s)\n\n", r.Month.Format("January 2006"), r.Currency))
+	result.WriteString(fmt.Sprintf("Income: %s\nExpenses: %s\nBalance: %s\n", FormatAmount(r.Income), FormatAmount(r.Expense), FormatAmount(r.Income-r.Expense)))
+	if len(r.Categories) == 0 {
+		return result.String()
+	}
+
+	result.WriteString("\nSpending by category:\n")
+	for _, category := range r.Categories {
+		line := fmt.Sprintf("%s %s: %s", category.Mark, category.Name, FormatAmount(category.Spent))
+		if category.Budget > 0 {
+			line += fmt.Sprintf(" / %s (%.0f%%)", FormatAmount(category.Budget), category.Spent/category.Budget*100)
+			if category.Spent > category.Budget {
+				line += " ⚠️"
+			}
+		}
+		result.WriteString(line + "\n")
+	}
+	return result.String()
+}
+
+// Caption is the summary with a key to the chart.
+func (r *Report) Caption() string {
+	return r.Summary() + "\nTop bars: income, then expenses. Grey bands and black marks are budgets."
+}
+
+// Chart draws the report as a PNG: income against expenses on top, then a
+// bar per category over a grey band as long as its budget, with a marker at
+// the budget.
+func (r *Report) Chart() ([]byte, error) {
+	height := chartPadding*2 + chartRowHeight*2 + chartPadding + chartRowHeight*len(r.Categories)
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+
+	width := chartWidth - chartPadding*2
+	bar := func(y int, barHeight int, value float64, scale float64, shade color.Color) {
+		if scale <= 0 || value <= 0 {
+			return
+		}
+		length := int(value / scale * float64(width))
+		if length < 2 {
+			length = 2
+		}
+		fill(img, chartPadding, y, length, barHeight, shade)
+	}
+
+	y := chartPadding
+	totals := max(r.Income, r.Expense)
+	bar(y, chartBarHeight, r.Income, totals, incomeColor)
+	bar(y+chartRowHeight, chartBarHeight, r.Expense, totals, expenseColor)
+	y += chartRowHeight * 2
+	fill(img, chartPadding, y+chartPadding/2-1, width, 2, lineColor)
+	y += chartPadding
+
+	scale := 0.0
+	for _, category := range r.Categories {
+		scale = max(scale, category.Spent, category.Budget)
+	}
+	for _, category := range r.Categories {
+		bar(y, chartBarHeight, category.Budget, scale, budgetColor)
+		bar(y+6, chartBarHeight-12, category.Spent, scale, category.color)
+		if category.Budget > 0 && scale > 0 {
+			x := chartPadding + int(category.Budget/scale*float64(width))
+			fill(img, min(x, chartWidth-chartPadding-3), y-4, 3, chartBarHeight+8, markerColor)
+		}
+		y += chartRowHeight
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fill(img *image.RGBA, x, y, width, height int, shade color.Color) {
+	draw.Draw(img, image.Rect(x, y, x+width, y+height), &image.Uniform{shade}, image.Point{}, draw.Src)
+}
@@ -0,0 +1,492 @@
+package cashflow
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"teo/internal/config"
+	"time"
+)
+
+// ErrNotStatement is returned for a CSV file without the date and amount
+// columns of a bank statement, or a file that is not OFX.
+var ErrNotStatement = errors.New("not a bank statement")
+
+// maxHeaderSearch is how many rows may come before the header of a CSV
+// statement, banks often start with the account details.
+const maxHeaderSearch = 20
+
+// IsStatementFile reports whether a file name is an OFX statement, which is
+// always imported, or a CSV file, which is imported when it reads as one.
+func IsStatementFile(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".ofx", ".qfx", ".csv":
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseStatement reads the transactions of a CSV or OFX bank statement.
+// Amounts without a currency are in currency. The transactions have no user
+// and no category yet.
+func ParseStatement(fileName string, data []byte, currency CurrencyType) ([]Transaction, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".ofx", ".qfx":
+		return ParseOFX(data, currency)
+	default:
+		return ParseCSV(data, currency)
+	}
+}
+
+// statementColumns are the indexes of the columns of a CSV statement, -1
+// for the ones it does not have.
+type statementColumns struct {
+	date, amount, debit, credit, kind, currency, description int
+}
+
+var (
+	dateColumns        = []string{"date", "transaction date", "posting date", "booking date", "value date", "tanggal", "posted"}
+	kindColumns        = []string{"type", "dr/cr", "cr/dr", "d/c", "c/d", "debit/credit", "credit/debit", "db/cr", "mutasi"}
+	debitColumns       = []string{"debit", "withdrawal", "withdrawals", "paid out", "money out", "debit amount", "out"}
+	creditColumns      = []string{"credit", "deposit", "deposits", "paid in", "money in", "credit amount", "in"}
+	amountColumns      = []string{"amount", "jumlah", "nominal"}
+	currencyColumns    = []string{"currency", "ccy", "mata uang"}
+	descriptionColumns = []string{"description", "keterangan", "details", "narrative", "memo", "payee", "name", "reference", "transaction", "particulars"}
+)
+
+// statementHeader finds the columns of a header row. Names are matched
+// exactly first, then as part of longer names, each column used once.
+func statementHeader(row []string) (statementColumns, bool) {
+	names := make([]string, len(row))
+	for i, name := range row {
+		names[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+
+	used := map[int]bool{}
+	find := func(keys []string) int {
+		for _, exact := range []bool{true, false} {
+			for _, key := range keys {
+				for i, name := range names {
+					if used[i] || name == "" {
+						continue
+					}
+					if name == key || !exact && len(key) > 3 && strings.Contains(name, key) {
+						used[i] = true
+						return i
+					}
+				}
+			}
+		}
+		return -1
+	}
+
+	var columns statementColumns
+	columns.date = find(dateColumns)
+	columns.kind = find(kindColumns)
+	columns.debit = find(debitColumns)
+	columns.credit = find(creditColumns)
+	columns.amount = find(amountColumns)
+	columns.currency = find(currencyColumns)
+	columns.description = find(descriptionColumns)
+
+	ok := columns.date >= 0 && (columns.amount >= 0 || columns.debit >= 0 && columns.credit >= 0)
+	return columns, ok
+}
+
+// csvDelimiters are tried in turn until one gives a statement header.
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// csvStatement reads the rows of a CSV statement with delimiter, and returns
+// the ones after the header with the columns of the header.
+func csvStatement(data []byte, delimiter rune) ([][]string, statementColumns, bool) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, statementColumns{}, false
+	}
+	for i := 0; i < len(rows) && i < maxHeaderSearch; i++ {
+		if columns, ok := statementHeader(rows[i]); ok {
+			return rows[i+1:], columns, true
+		}
+	}
+	return nil, statementColumns{}, false
+}
+
+// ParseCSV reads a CSV bank statement. The header is looked for in the first
+// rows, and rows without a date or an amount, such as totals, are skipped.
+// It returns ErrNotStatement when no header is found.
+func ParseCSV(data []byte, currency CurrencyType) ([]Transaction, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var rows [][]string
+	var columns statementColumns
+	found := false
+	for _, delimiter := range csvDelimiters {
+		if rows, columns, found = csvStatement(data, delimiter); found {
+			break
+		}
+	}
+	if !found {
+		return nil, ErrNotStatement
+	}
+
+	cell := func(row []string, column int) string {
+		if column < 0 || column >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[column])
+	}
+
+	dates := make([]string, 0, len(rows))
+	for _, row := range rows {
+		dates = append(dates, cell(row, columns.date))
+	}
+	monthFirst := isMonthFirst(dates)
+
+	var transactions []Transaction
+	seen := map[string]int{}
+	for _, row := range rows {
+		date, err := parseStatementDate(cell(row, columns.date), monthFirst)
+		if err != nil {
+			continue
+		}
+
+		var amount float64
+		if columns.debit >= 0 && columns.credit >= 0 {
+			debit, _ := parseStatementAmount(cell(row, columns.debit))
+			credit, _ := parseStatementAmount(cell(row, columns.credit))
+			amount = math.Abs(credit) - math.Abs(debit)
+		} else if amount, err = parseStatementAmount(cell(row, columns.amount)); err != nil {
+			continue
+		}
+		if amount == 0 {
+			continue
+		}
+
+		transactionType := Income
+		if amount < 0 {
+			transactionType = Expense
+		}
+		if kind, ok := parseStatementType(cell(row, columns.kind)); ok {
+			transactionType = kind
+		}
+
+		transactionCurrency := currency
+		if code := cell(row, columns.currency); code != "" {
+			if parsed, err := ParseCurrency(code); err == nil {
+				transactionCurrency = parsed
+			}
+		}
+
+		transaction := Transaction{
+			Type:        transactionType,
+			Amount:      math.Abs(amount),
+			Currency:    transactionCurrency,
+			Description: cell(row, columns.description),
+			Date:        date,
+		}
+		transaction.ImportID = csvImportID(transaction, seen)
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// csvImportID identifies a CSV row by its content, counting identical rows
+// so that the second coffee of the day is not taken for the first.
+func csvImportID(transaction Transaction, seen map[string]int) string {
+	key := fmt.Sprintf("%s|%s|%.2f|%s|%s", transaction.Date.Format("2006-01-02"), transaction.Type,
+		transaction.Amount, transaction.Currency, strings.ToLower(transaction.Description))
+	sum := sha1.Sum([]byte(key))
+	id := hex.EncodeToString(sum[:8])
+	seen[id]++
+	return fmt.Sprintf("csv:%s:%d", id, seen[id])
+}
+
+// parseStatementType reads the debit or credit marker of a row.
+func parseStatementType(value string) (TransactionType, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "income", "credit", "cr", "c", "k", "kredit", "in", "deposit", "+":
+		return Income, true
+	case "expense", "debit", "dr", "db", "d", "out", "withdrawal", "-":
+		return Expense, true
+	default:
+		return "", false
+	}
+}
+
+// parseStatementAmount reads an amount written the way banks do, e.g.
+// "-1,234.56", "1.234,56", "(12.00)", "$12.00" or "1,000,000.00 CR". Debits
+// are negative.
+func parseStatementAmount(value string) (float64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	negative := false
+	switch {
+	case strings.HasSuffix(value, "DR"), strings.HasSuffix(value, "DB"):
+		negative = true
+		value = value[:len(value)-2]
+	case strings.HasSuffix(value, "CR"):
+		value = value[:len(value)-2]
+	}
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+	}
+
+	var digits strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',':
+			digits.WriteRune(r)
+		case r == '-':
+			negative = true
+		}
+	}
+	number := digits.String()
+
+	lastDot, lastComma := strings.LastIndex(number, "."), strings.LastIndex(number, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// The separator that comes last is the decimal one
+		if lastComma > lastDot {
+			number = strings.ReplaceAll(number, ".", "")
+			number = strings.Replace(number, ",", ".", 1)
+		} else {
+			number = strings.ReplaceAll(number, ",", "")
+		}
+	case lastComma >= 0:
+		number = decimalSeparator(number, ",")
+	case lastDot >= 0:
+		number = decimalSeparator(number, ".")
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %s", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// decimalSeparator reads a number with a single kind of separator. It is a
+// thousands separator when it appears more than once or is followed by
+// exactly three digits, as in "50.000", else a decimal one.
+func decimalSeparator(number string, separator string) string {
+	if strings.Count(number, separator) > 1 || len(number)-strings.LastIndex(number, separator) == 4 {
+		return strings.ReplaceAll(number, separator, "")
+	}
+	return strings.Replace(number, separator, ".", 1)
+}
+
+var numericDate = regexp.MustCompile(`^(\d{1,2})[/.-](\d{1,2})[/.-](\d{2,4})`)
+
+// isMonthFirst tells 01/02/2024 as January 2nd from February 1st by looking
+// for a day over 12 in the dates of the file. Days come first when nothing
+// tells.
+func isMonthFirst(dates []string) bool {
+	for _, date := range dates {
+		match := numericDate.FindStringSubmatch(date)
+		if match == nil {
+			continue
+		}
+		first, _ := strconv.Atoi(match[1])
+		second, _ := strconv.Atoi(match[2])
+		if first > 12 {
+			return false
+		}
+		if second > 12 {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	dayFirstLayouts   = []string{"02/01/2006", "2/1/2006", "02.01.2006", "2.1.2006", "02-01-2006", "2-1-2006", "02/01/06", "2/1/06"}
+	monthFirstLayouts = []string{"01/02/2006", "1/2/2006", "01.02.2006", "1.2.2006", "01-02-2006", "1-2-2006", "01/02/06", "1/2/06"}
+	otherLayouts      = []string{
+		"2006-01-02", "2006/01/02", "20060102", "02 Jan 2006", "2 Jan 2006", "02-Jan-2006", "2-Jan-2006",
+		"02 Jan 06", "02-Jan-06", "Jan 2, 2006", "January 2, 2006", "2 January 2006",
+	}
+)
+
+// parseStatementDate reads the date of a row in the timezone of the bot. The
+// time of day that some banks add is ignored.
+func parseStatementDate(value string, monthFirst bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(value) > 10 && value[10] == 'T' {
+		value = value[:10]
+	}
+	if date, clock, ok := strings.Cut(value, " "); ok && strings.Contains(clock, ":") {
+		value = date
+	}
+
+	layouts := append(append([]string{}, otherLayouts...), dayFirstLayouts...)
+	if monthFirst {
+		layouts = append(append([]string{}, otherLayouts...), monthFirstLayouts...)
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, config.Timezone); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s", value)
+}
+
+var ofxTag = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// ParseOFX reads the transactions of an OFX or QFX statement, either the
+// SGML of version 1 or the XML of version 2.
+func ParseOFX(data []byte, currency CurrencyType) ([]Transaction, error) {
+	var transactions []Transaction
+	var fields map[string]string
+	seen := map[string]int{}
+
+	for _, match := range ofxTag.FindAllStringSubmatch(string(data), -1) {
+		closing, tag := match[1] == "/", strings.ToUpper(match[2])
+		value := html.UnescapeString(strings.TrimSpace(match[3]))
+
+		switch {
+		case tag == "CURDEF" && !closing:
+			if parsed, err := ParseCurrency(value); err == nil {
+				currency = parsed
+			}
+		case tag == "STMTTRN" && !closing:
+			fields = map[string]string{}
+		case tag == "STMTTRN" && closing:
+			if transaction, ok := ofxTransaction(fields, currency, seen); ok {
+				transactions = append(transactions, transaction)
+			}
+			fields = nil
+		case fields != nil && !closing && value != "":
+			fields[tag] = value
+		}
+	}
+
+	if len(transactions) == 0 && !strings.Contains(strings.ToUpper(string(data)), "<OFX>") {
+		return nil, ErrNotStatement
+	}
+	return transactions, nil
+}
+
+func ofxTransaction(fields map[string]string, currency CurrencyType, seen map[string]int) (Transaction, bool) {
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(fields["TRNAMT"], ",", "."), 64)
+	if err != nil || amount == 0 {
+		return Transaction{}, false
+	}
+	date, err := parseOFXDate(fields["DTPOSTED"])
+	if err != nil {
+		return Transaction{}, false
+	}
+
+	transactionType := Income
+	if amount < 0 {
+		transactionType = Expense
+	}
+	description := fields["NAME"]
+	if memo := fields["MEMO"]; memo != "" && !strings.EqualFold(memo, description) {
+		description = strings.TrimSpace(description + " " + memo)
+	}
+	if code := fields["CURRENCY"]; code != "" {
+		if parsed, err := ParseCurrency(code); err == nil {
+			currency = parsed
+		}
+	}
+
+	transaction := Transaction{
+		Type:        transactionType,
+		Amount:      math.Abs(amount),
+		Currency:    currency,
+		Description: description,
+		Date:        date,
+	}
+	if id := fields["FITID"]; id != "" {
+		transaction.ImportID = "ofx:" + id
+	} else {
+		transaction.ImportID = csvImportID(transaction, seen)
+	}
+	return transaction, true
+}
+
+// parseOFXDate reads the day of an OFX date, e.g. 20240131120000[-5:EST].
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %s", value)
+	}
+	return time.ParseInLocation("20060102", value[:8], config.Timezone)
+}
+
+// Deduplicate drops the transactions the user already has: the ones imported
+// before, and the ones with the same day, type, amount and currency as an
+// existing transaction, e.g. added by hand. Each existing transaction stands
+// for a single one. It returns the new transactions and how many were
+// dropped.
+func Deduplicate(userID string, transactions []Transaction) ([]Transaction, int, error) {
+	if len(transactions) == 0 {
+		return nil, 0, nil
+	}
+	if store == nil {
+		return nil, 0, fmt.Errorf("the cash flow is not configured on this bot")
+	}
+
+	start, end := transactions[0].Date, transactions[0].Date
+	for _, t := range transactions {
+		if t.Date.Before(start) {
+			start = t.Date
+		}
+		if t.Date.After(end) {
+			end = t.Date
+		}
+	}
+	existing, err := store.GetTransactions(userID, start.AddDate(0, 0, -1), end.AddDate(0, 0, 2))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	imported := map[string]bool{}
+	matches := map[string]int{}
+	for _, t := range existing {
+		if t.ImportID != "" {
+			imported[t.ImportID] = true
+		}
+		matches[duplicateKey(t)]++
+	}
+
+	var fresh []Transaction
+	duplicates := 0
+	for _, t := range transactions {
+		key := duplicateKey(t)
+		if imported[t.ImportID] || matches[key] > 0 {
+			if matches[key] > 0 {
+				matches[key]--
+			}
+			duplicates++
+			continue
+		}
+		fresh = append(fresh, t)
+	}
+	return fresh, duplicates, nil
+}
+
+func duplicateKey(t Transaction) string {
+	return fmt.Sprintf("%s|%s|%.2f|%s", t.Date.In(config.Timezone).Format("2006-01-02"), t.Type, t.Amount, t.Currency)
+}
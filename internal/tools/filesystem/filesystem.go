@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"teo/internal/tools/attachment"
 	"time"
 )
 
@@ -73,6 +74,29 @@ func isAllowed(path string) (string, error) {
 	return "", fmt.Errorf("path '%s' (resolved to '%s') is not within allowed directories", path, absPath)
 }
 
+func (f *FileSystemTool) CallToolWithAttachments(arguments string) (string, []attachment.Attachment) {
+	var args FileSystemArgs
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return fmt.Sprintf("Error parsing arguments: %v", err), nil
+	}
+
+	if args.ToolName != "send_file" {
+		return f.CallTool(arguments), nil
+	}
+
+	absPath, err := isAllowed(args.Path)
+	if err != nil {
+		return fmt.Sprintf("Security error: %v", err), nil
+	}
+
+	file, err := attachment.Read(absPath)
+	if err != nil {
+		return fmt.Sprintf("Error reading file '%s': %v", args.Path, err), nil
+	}
+
+	return fmt.Sprintf("File '%s' will be sent to the user.", args.Path), []attachment.Attachment{file}
+}
+
 func (f *FileSystemTool) CallTool(arguments string) string {
 	var args FileSystemArgs
 	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
@@ -0,0 +1,97 @@
+package imagegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"teo/internal/tools/attachment"
+)
+
+type Image struct {
+	Data          []byte
+	MimeType      string
+	RevisedPrompt string
+	Provider      string
+	Model         string
+}
+
+// Generator is provided by the bot service at startup, tools cannot import
+// the provider package directly.
+type Generator func(prompt string) (*Image, error)
+
+var generator Generator
+
+func SetGenerator(g Generator) {
+	generator = g
+}
+
+type ImageGenTool struct{}
+
+type ImageGenArgs struct {
+	Prompt string `json:"prompt"`
+}
+
+func NewImageGenTool() *ImageGenTool {
+	return &ImageGenTool{}
+}
+
+func (t *ImageGenTool) CallTool(arguments string) string {
+	result, _ := t.CallToolWithAttachments(arguments)
+	return result
+}
+
+func (t *ImageGenTool) CallToolWithAttachments(arguments string) (string, []attachment.Attachment) {
+	var args ImageGenArgs
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return fmt.Sprintf("Error parsing arguments: %v", err), nil
+	}
+
+	args.Prompt = strings.TrimSpace(args.Prompt)
+	if args.Prompt == "" {
+		return "Error: 'prompt' argument is required.", nil
+	}
+
+	if generator == nil {
+		return "Error: image generation is not configured on this bot.", nil
+	}
+
+	image, err := generator(args.Prompt)
+	if err != nil {
+		return fmt.Sprintf("Error generating image: %v", err), nil
+	}
+
+	file := attachment.Attachment{
+		FileName: FileName(image.MimeType),
+		MimeType: image.MimeType,
+		Data:     image.Data,
+		Metadata: Metadata(args.Prompt, image),
+	}
+
+	result := "The image has been generated and will be sent to the user, do not describe it as a link."
+	if image.RevisedPrompt != "" {
+		result += "\nRevised prompt: " + image.RevisedPrompt
+	}
+
+	return result, []attachment.Attachment{file}
+}
+
+func FileName(mimeType string) string {
+	extension := ".png"
+	if extensions, err := mime.ExtensionsByType(mimeType); err == nil && len(extensions) > 0 {
+		extension = extensions[0]
+		if extension == ".jpe" || extension == ".jfif" {
+			extension = ".jpg"
+		}
+	}
+	return "image" + extension
+}
+
+func Metadata(prompt string, image *Image) map[string]string {
+	return map[string]string{
+		"prompt":         prompt,
+		"revised_prompt": image.RevisedPrompt,
+		"provider":       image.Provider,
+		"model":          image.Model,
+	}
+}
@@ -2,34 +2,52 @@ package notes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
-type NoteTool struct {
-	dataPath string
+var (
+	// ErrNoteNotFound is returned by a Store for a title the user has no note for.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrNoteExists is returned by a Store for a title the user already has a note for.
+	ErrNoteExists = errors.New("note already exists")
+)
+
+// Store keeps the notes of each user, one per title. It is provided by the
+// bot service at startup, tools cannot import the repositories directly.
+type Store interface {
+	CreateNote(userID string, note Note) error
+	GetNote(userID string, title string) (*Note, error)
+	UpdateNote(userID string, title string, content string) error
+	DeleteNote(userID string, title string) error
+	GetNotes(userID string) ([]Note, error)
+	// SearchNotes finds the notes with query in their title or content
+	SearchNotes(userID string, query string) ([]Note, error)
+	// GetNotesByDate lists the notes created from start to end
+	GetNotesByDate(userID string, start, end time.Time) ([]Note, error)
 }
 
-func NewNotesTool() *NoteTool {
-	workingDir, err := os.Getwd()
-	if err != nil {
-		log.Printf("Error getting working directory: %v\n", err)
-		return nil
-	}
+var store Store
 
-	dataDir := filepath.Join(workingDir, "data", "notes")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Printf("Error creating notes directory: %v\n", err)
-		return nil
-	}
+func SetStore(s Store) {
+	store = s
+}
+
+// NoteTool manages the notes of the user it was made for, the model cannot
+// pick another user.
+type NoteTool struct {
+	userID string
+}
 
-	return &NoteTool{
-		dataPath: dataDir,
+func NewNotesTool(userId int) *NoteTool {
+	tool := &NoteTool{}
+	if userId != 0 {
+		tool.userID = strconv.Itoa(userId)
 	}
+	return tool
 }
 
 type NoteArguments struct {
@@ -54,14 +72,13 @@ func (n *NoteTool) CallTool(arguments string) string {
 		return fmt.Sprintf("Error parsing arguments: %v", err)
 	}
 
-	userID, ok := args["user_id"].(string)
-	if !ok || userID == "" {
-		return "Error: user_id is required"
+	userID := n.userID
+	if userID == "" {
+		return "Error: notes are only available in a chat with a user."
 	}
 
-	userDataPath := filepath.Join(n.dataPath, userID)
-	if err := os.MkdirAll(userDataPath, 0755); err != nil {
-		return fmt.Sprintf("Error creating user notes directory: %v", err)
+	if store == nil {
+		return "Error: notes are not configured on this bot."
 	}
 
 	var noteArgs NoteArguments
@@ -75,19 +92,19 @@ func (n *NoteTool) CallTool(arguments string) string {
 
 	switch strings.ToUpper(noteArgs.Action) {
 	case "GET":
-		return n.getNotes(userDataPath)
+		return n.getNotes(userID)
 	case "GET_DETAIL":
-		return n.getNoteDetail(userDataPath, noteArgs.Title)
+		return n.getNoteDetail(userID, noteArgs.Title)
 	case "POST":
-		return n.saveNote(userDataPath, noteArgs.Title, noteArgs.Content)
+		return n.saveNote(userID, noteArgs.Title, noteArgs.Content)
 	case "PUT":
-		return n.updateNote(userDataPath, noteArgs.Title, noteArgs.Content)
+		return n.updateNote(userID, noteArgs.Title, noteArgs.Content)
 	case "DELETE":
-		return n.deleteNote(userDataPath, noteArgs.Title)
+		return n.deleteNote(userID, noteArgs.Title)
 	case "SEARCH":
-		return n.searchNotes(userDataPath, noteArgs.Search)
+		return n.searchNotes(userID, noteArgs.Search)
 	case "GET_BY_DATE":
-		return n.getNotesByDate(userDataPath, noteArgs.StartDate, noteArgs.EndDate)
+		return n.getNotesByDate(userID, noteArgs.StartDate, noteArgs.EndDate)
 	default:
 		return "Invalid action specified. Please use GET, GET_DETAIL, POST, PUT, DELETE, SEARCH, or GET_BY_DATE."
 	}
@@ -110,21 +127,10 @@ func (n *NoteTool) validateInput(args NoteArguments) error {
 	return nil
 }
 
-func (n *NoteTool) getNotes(userDataPath string) string {
-	files, err := os.ReadDir(userDataPath)
+func (n *NoteTool) getNotes(userID string) string {
+	notes, err := store.GetNotes(userID)
 	if err != nil {
-		return fmt.Sprintf("Error reading notes directory: %v", err)
-	}
-
-	var notes []Note
-	for _, file := range files {
-		if !file.IsDir() {
-			note, err := n.readNoteFile(userDataPath, file.Name())
-			if err != nil {
-				continue
-			}
-			notes = append(notes, note)
-		}
+		return fmt.Sprintf("Error reading notes: %v", err)
 	}
 
 	jsonNotes, err := json.Marshal(notes)
@@ -135,23 +141,8 @@ func (n *NoteTool) getNotes(userDataPath string) string {
 	return string(jsonNotes)
 }
 
-func (n *NoteTool) readNoteFile(userDataPath, filename string) (Note, error) {
-	filePath := filepath.Join(userDataPath, filename)
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return Note{}, err
-	}
-
-	var note Note
-	if err := json.Unmarshal(content, &note); err != nil {
-		return Note{}, err
-	}
-
-	return note, nil
-}
-
-func (n *NoteTool) getNoteDetail(userDataPath, title string) string {
-	note, err := n.readNoteFile(userDataPath, fmt.Sprintf("%s.json", title))
+func (n *NoteTool) getNoteDetail(userID, title string) string {
+	note, err := store.GetNote(userID, title)
 	if err != nil {
 		return fmt.Sprintf("Error reading note %s: %v", title, err)
 	}
@@ -164,13 +155,7 @@ func (n *NoteTool) getNoteDetail(userDataPath, title string) string {
 	return string(jsonNote)
 }
 
-func (n *NoteTool) saveNote(userDataPath, title, content string) string {
-	filePath := filepath.Join(userDataPath, fmt.Sprintf("%s.json", title))
-
-	if _, err := os.Stat(filePath); err == nil {
-		return fmt.Sprintf("Note with title '%s' already exists. Use PUT to update it.", title)
-	}
-
+func (n *NoteTool) saveNote(userID, title, content string) string {
 	note := Note{
 		Title:     title,
 		Content:   content,
@@ -178,73 +163,45 @@ func (n *NoteTool) saveNote(userDataPath, title, content string) string {
 		UpdatedAt: time.Now(),
 	}
 
-	jsonNote, err := json.Marshal(note)
-	if err != nil {
-		return fmt.Sprintf("Error marshaling note: %v", err)
+	err := store.CreateNote(userID, note)
+	if errors.Is(err, ErrNoteExists) {
+		return fmt.Sprintf("Note with title '%s' already exists. Use PUT to update it.", title)
 	}
-
-	if err := os.WriteFile(filePath, jsonNote, 0644); err != nil {
+	if err != nil {
 		return fmt.Sprintf("Error saving note: %v", err)
 	}
 
 	return fmt.Sprintf("Note '%s' has been saved successfully.", title)
 }
 
-func (n *NoteTool) updateNote(userDataPath, title, content string) string {
-	filePath := filepath.Join(userDataPath, fmt.Sprintf("%s.json", title))
-
-	note, err := n.readNoteFile(userDataPath, fmt.Sprintf("%s.json", title))
-	if err != nil {
+func (n *NoteTool) updateNote(userID, title, content string) string {
+	err := store.UpdateNote(userID, title, content)
+	if errors.Is(err, ErrNoteNotFound) {
 		return fmt.Sprintf("Note '%s' does not exist. Use POST to create it.", title)
 	}
-
-	note.Content = content
-	note.UpdatedAt = time.Now()
-
-	jsonNote, err := json.Marshal(note)
 	if err != nil {
-		return fmt.Sprintf("Error marshaling note: %v", err)
-	}
-
-	if err := os.WriteFile(filePath, jsonNote, 0644); err != nil {
 		return fmt.Sprintf("Error updating note: %v", err)
 	}
 
 	return fmt.Sprintf("Note '%s' has been updated successfully.", title)
 }
 
-func (n *NoteTool) deleteNote(userDataPath, title string) string {
-	filePath := filepath.Join(userDataPath, fmt.Sprintf("%s.json", title))
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+func (n *NoteTool) deleteNote(userID, title string) string {
+	err := store.DeleteNote(userID, title)
+	if errors.Is(err, ErrNoteNotFound) {
 		return fmt.Sprintf("Note '%s' does not exist.", title)
 	}
-
-	if err := os.Remove(filePath); err != nil {
+	if err != nil {
 		return fmt.Sprintf("Error deleting note: %v", err)
 	}
 
 	return fmt.Sprintf("Note '%s' has been deleted successfully.", title)
 }
 
-func (n *NoteTool) searchNotes(userDataPath, query string) string {
-	files, err := os.ReadDir(userDataPath)
+func (n *NoteTool) searchNotes(userID, query string) string {
+	results, err := store.SearchNotes(userID, query)
 	if err != nil {
-		return fmt.Sprintf("Error reading notes directory: %v", err)
-	}
-
-	var results []Note
-	for _, file := range files {
-		if !file.IsDir() {
-			note, err := n.readNoteFile(userDataPath, file.Name())
-			if err != nil {
-				continue
-			}
-			if strings.Contains(strings.ToLower(note.Title), strings.ToLower(query)) ||
-				strings.Contains(strings.ToLower(note.Content), strings.ToLower(query)) {
-				results = append(results, note)
-			}
-		}
+		return fmt.Sprintf("Error searching notes: %v", err)
 	}
 
 	jsonResults, err := json.Marshal(results)
@@ -255,7 +212,7 @@ func (n *NoteTool) searchNotes(userDataPath, query string) string {
 	return string(jsonResults)
 }
 
-func (n *NoteTool) getNotesByDate(userDataPath, startDate, endDate string) string {
+func (n *NoteTool) getNotesByDate(userID, startDate, endDate string) string {
 	start, err := time.Parse("2006-01-02", startDate)
 	if err != nil {
 		return fmt.Sprintf("Invalid start date format. Use YYYY-MM-DD: %v", err)
@@ -266,22 +223,9 @@ func (n *NoteTool) getNotesByDate(userDataPath, startDate, endDate string) strin
 		return fmt.Sprintf("Invalid end date format. Use YYYY-MM-DD: %v", err)
 	}
 
-	files, err := os.ReadDir(userDataPath)
+	results, err := store.GetNotesByDate(userID, start, end.Add(24*time.Hour))
 	if err != nil {
-		return fmt.Sprintf("Error reading notes directory: %v", err)
-	}
-
-	var results []Note
-	for _, file := range files {
-		if !file.IsDir() {
-			note, err := n.readNoteFile(userDataPath, file.Name())
-			if err != nil {
-				continue
-			}
-			if note.CreatedAt.After(start) && note.CreatedAt.Before(end.Add(24*time.Hour)) {
-				results = append(results, note)
-			}
-		}
+		return fmt.Sprintf("Error reading notes: %v", err)
 	}
 
 	jsonResults, err := json.Marshal(results)
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"teo/internal/tools/attachment"
 )
 
 type PythonTool struct{}
@@ -23,24 +24,35 @@ func NewPythonTool() *PythonTool {
 }
 
 func (p *PythonTool) CallTool(arguments string) string {
+	result, _ := p.CallToolWithAttachments(arguments)
+	return result
+}
+
+func (p *PythonTool) CallToolWithAttachments(arguments string) (string, []attachment.Attachment) {
 	var args PythonArgs
 	err := json.Unmarshal([]byte(arguments), &args)
 	if err != nil {
-		return fmt.Sprintf("Error parsing arguments: %v", err)
+		return fmt.Sprintf("Error parsing arguments: %v", err), nil
 	}
 
 	// Create temporary directory for Python code
 	tempDir, err := os.MkdirTemp("", "python-exec-*")
 	if err != nil {
-		return fmt.Sprintf("Error creating temp directory: %v", err)
+		return fmt.Sprintf("Error creating temp directory: %v", err), nil
 	}
 	defer os.RemoveAll(tempDir)
 
+	// Files written to TEO_OUTPUT_DIR are sent back to the user
+	outputDir := filepath.Join(tempDir, "outputs")
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Sprintf("Error creating output directory: %v", err), nil
+	}
+
 	// Create Python file
 	scriptPath := filepath.Join(tempDir, "script.py")
 	err = os.WriteFile(scriptPath, []byte(args.Code), 0644)
 	if err != nil {
-		return fmt.Sprintf("Error writing Python script: %v", err)
+		return fmt.Sprintf("Error writing Python script: %v", err), nil
 	}
 
 	// Determine python and pip executables
@@ -69,7 +81,7 @@ func (p *PythonTool) CallTool(arguments string) string {
 				cmd.Dir = tempDir
 				// Capture output to debug installation errors if needed
 				if output, err := cmd.CombinedOutput(); err != nil {
-					return fmt.Sprintf("Error installing package %s: %v\nOutput: %s", pkg, err, string(output))
+					return fmt.Sprintf("Error installing package %s: %v\nOutput: %s", pkg, err, string(output)), nil
 				}
 			}
 		}
@@ -77,14 +89,16 @@ func (p *PythonTool) CallTool(arguments string) string {
 
 	// Execute Python code
 	cmd := exec.Command(pythonExec, scriptPath)
+	cmd.Env = append(os.Environ(), "TEO_OUTPUT_DIR="+outputDir)
 	if args.Input != "" {
 		cmd.Stdin = strings.NewReader(args.Input)
 	}
 
 	output, err := cmd.CombinedOutput()
+	attachments := attachment.Collect(outputDir)
 	if err != nil {
-		return fmt.Sprintf("Error executing Python code: %v\nOutput: %s", err, string(output))
+		return fmt.Sprintf("Error executing Python code: %v\nOutput: %s", err, string(output)), attachments
 	}
 
-	return string(output)
+	return string(output), attachments
 }
@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JobRequest is a job as the model describes it. RunAt and Cron are parsed by
+// the store, in Timezone or the bot time zone.
+type JobRequest struct {
+	Name     string
+	Kind     string
+	Text     string
+	RunAt    string
+	Cron     string
+	Timezone string
+}
+
+type Job struct {
+	Id       string
+	Name     string
+	Kind     string
+	Text     string
+	Schedule string
+	Status   string
+	NextRun  time.Time
+}
+
+// Store is provided by the bot service at startup, tools cannot import the
+// repositories directly.
+type Store interface {
+	CreateJob(userId int, request JobRequest) (*Job, error)
+	ListJobs(userId int) ([]*Job, error)
+	// UpdateJob changes the fields of request that are set
+	UpdateJob(userId int, id string, request JobRequest) (*Job, error)
+	PauseJob(userId int, id string, paused bool) (*Job, error)
+	DeleteJob(userId int, id string) error
+}
+
+var store Store
+
+func SetStore(s Store) {
+	store = s
+}
+
+// SchedulerTool manages the jobs of the user it was made for, the model
+// cannot pick another user.
+type SchedulerTool struct {
+	userId int
+}
+
+type SchedulerArgs struct {
+	Action   string `json:"action"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Text     string `json:"text"`
+	RunAt    string `json:"run_at"`
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone"`
+	JobId    string `json:"job_id"`
+}
+
+func NewSchedulerTool(userId int) *SchedulerTool {
+	return &SchedulerTool{userId: userId}
+}
+
+func (t *SchedulerTool) CallTool(arguments string) string {
+	var args SchedulerArgs
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return fmt.Sprintf("Error parsing arguments: %v", err)
+	}
+
+	if store == nil {
+		return "Error: the scheduler is not configured on this bot."
+	}
+	if t.userId == 0 {
+		return "Error: the scheduler is only available in a chat with a user."
+	}
+
+	switch strings.ToLower(args.Action) {
+	case "create":
+		return t.create(args)
+	case "list":
+		return t.list(args)
+	case "update":
+		return t.update(args)
+	case "pause":
+		return t.pause(args, true)
+	case "resume":
+		return t.pause(args, false)
+	case "delete":
+		return t.delete(args)
+	default:
+		return "Error: 'action' must be one of create, list, update, pause, resume or delete."
+	}
+}
+
+func (t *SchedulerTool) create(args SchedulerArgs) string {
+	if strings.TrimSpace(args.Text) == "" {
+		return "Error: 'text' argument is required."
+	}
+	if args.RunAt == "" && args.Cron == "" {
+		return "Error: either 'run_at' or 'cron' is required."
+	}
+
+	job, err := store.CreateJob(t.userId, JobRequest{
+		Name:     args.Name,
+		Kind:     args.Kind,
+		Text:     args.Text,
+		RunAt:    args.RunAt,
+		Cron:     args.Cron,
+		Timezone: args.Timezone,
+	})
+	if err != nil {
+		return fmt.Sprintf("Error creating job: %v", err)
+	}
+
+	return fmt.Sprintf("Job %s scheduled (%s), next run at %s.", job.Id, job.Schedule, job.NextRun.Format(time.RFC1123))
+}
+
+func (t *SchedulerTool) list(args SchedulerArgs) string {
+	jobs, err := store.ListJobs(t.userId)
+	if err != nil {
+		return fmt.Sprintf("Error listing jobs: %v", err)
+	}
+	if len(jobs) == 0 {
+		return "No scheduled jobs."
+	}
+
+	var sb strings.Builder
+	for _, job := range jobs {
+		label := job.Text
+		if job.Name != "" {
+			label = job.Name + ": " + job.Text
+		}
+		sb.WriteString(fmt.Sprintf("- %s [%s, %s, %s] next run %s: %s\n", job.Id, job.Kind, job.Schedule, job.Status, job.NextRun.Format(time.RFC1123), label))
+	}
+	return sb.String()
+}
+
+func (t *SchedulerTool) update(args SchedulerArgs) string {
+	if args.JobId == "" {
+		return "Error: 'job_id' argument is required."
+	}
+
+	job, err := store.UpdateJob(t.userId, args.JobId, JobRequest{
+		Name:     args.Name,
+		Kind:     args.Kind,
+		Text:     args.Text,
+		RunAt:    args.RunAt,
+		Cron:     args.Cron,
+		Timezone: args.Timezone,
+	})
+	if err != nil {
+		return fmt.Sprintf("Error updating job: %v", err)
+	}
+
+	return fmt.Sprintf("Job %s updated (%s), next run at %s.", job.Id, job.Schedule, job.NextRun.Format(time.RFC1123))
+}
+
+func (t *SchedulerTool) pause(args SchedulerArgs, paused bool) string {
+	if args.JobId == "" {
+		return "Error: 'job_id' argument is required."
+	}
+
+	job, err := store.PauseJob(t.userId, args.JobId, paused)
+	if err != nil {
+		return fmt.Sprintf("Error updating job: %v", err)
+	}
+
+	if paused {
+		return fmt.Sprintf("Job %s paused.", job.Id)
+	}
+	return fmt.Sprintf("Job %s resumed, next run at %s.", job.Id, job.NextRun.Format(time.RFC1123))
+}
+
+func (t *SchedulerTool) delete(args SchedulerArgs) string {
+	if args.JobId == "" {
+		return "Error: 'job_id' argument is required."
+	}
+	if err := store.DeleteJob(t.userId, args.JobId); err != nil {
+		return fmt.Sprintf("Error deleting job: %v", err)
+	}
+	return fmt.Sprintf("Job %s deleted.", args.JobId)
+}
@@ -7,14 +7,17 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"teo/internal/tools/attachment"
 	"teo/internal/tools/bash"
 
-	// "teo/internal/tools/calendar"
-	// "teo/internal/tools/cashflow"
+	"teo/internal/tools/calendar"
+	"teo/internal/tools/cashflow"
 	// "teo/internal/tools/converter"
 	"teo/internal/tools/filesystem"
-	// "teo/internal/tools/notes"
+	"teo/internal/tools/imagegen"
+	"teo/internal/tools/notes"
 	"teo/internal/tools/python"
+	"teo/internal/tools/scheduler"
 	// "teo/internal/tools/scraping"
 	// "teo/internal/tools/tavily"
 	// "teo/internal/tools/weather"
@@ -24,6 +27,12 @@ type ToolsFactory interface {
 	CallTool(arguments string) string
 }
 
+// AttachmentToolsFactory is implemented by tools that can hand files back to
+// the user in addition to the text result given to the model.
+type AttachmentToolsFactory interface {
+	CallToolWithAttachments(arguments string) (string, []attachment.Attachment)
+}
+
 func GetTools() []map[string]interface{} {
 	workingDir, err := os.Getwd()
 	if err != nil {
@@ -59,19 +68,23 @@ type ToolsCalling struct {
 	toolsMap map[string]ToolsFactory
 }
 
-func NewTools(functionName string, arguments string) string {
+// NewTools runs a tool for the user of userId, which the bot sets from the
+// incoming message. Tools that keep data per user only ever see that user.
+func NewTools(userId int, functionName string, arguments string) (string, []attachment.Attachment) {
 	tools := &ToolsCalling{
 		toolsMap: map[string]ToolsFactory{
 			// "get_current_weather": weather.NewWeatherTool(),
 			// "scrape_web_data":     scraping.NewScrapingTool(),
-			// "notes":               notes.NewNotesTool(),
 			// "tavily_search":       tavily.NewTavilyTool(),
-			// "cash_flow":           cashflow.NewCashFlowTool(),
-			// "calendar":            calendar.NewCalendarTool(),
 			// "converter":           converter.NewConverterTool(),
 			"bash":           bash.NewBashTool(),
 			"filesystem":     filesystem.NewFileSystemTool(),
 			"execute_python": python.NewPythonTool(),
+			"generate_image": imagegen.NewImageGenTool(),
+			"schedule":       scheduler.NewSchedulerTool(userId),
+			"calendar":       calendar.NewCalendarTool(userId),
+			"cash_flow":      cashflow.NewCashFlowTool(userId),
+			"notes":          notes.NewNotesTool(userId),
 		},
 	}
 	log.Printf("Starting call to tool '%s' with arguments: %s", functionName, arguments)
@@ -80,11 +93,18 @@ func NewTools(functionName string, arguments string) string {
 	if !exists {
 		errMsg := fmt.Sprintf("Error: tool '%s' not available.", functionName)
 		log.Println(errMsg)
-		return errMsg
+		return errMsg, nil
 	}
 
-	res := tool.CallTool(arguments)
+	var res string
+	var attachments []attachment.Attachment
+	if attachmentTool, ok := tool.(AttachmentToolsFactory); ok {
+		res, attachments = attachmentTool.CallToolWithAttachments(arguments)
+		res += attachment.Note(attachments)
+	} else {
+		res = tool.CallTool(arguments)
+	}
 	log.Printf("Successfully called tool '%s'. Response: %s", functionName, res)
 
-	return res
+	return res, attachments
 }
@@ -1,9 +1,10 @@
 package utils
 
 import (
+	"teo/internal/config"
 	"time"
 )
 
 func GetCurrentTime() string {
-	return time.Now().Format("Monday, 02-Jan-2006 15:04:05")
+	return time.Now().In(config.Timezone).Format("Monday, 02-Jan-2006 15:04:05 MST")
 }
@@ -6,3 +6,18 @@ func GetImageCaption(caption string) string {
 	}
 	return "Explain this image"
 }
+
+func GetDocumentCaption(caption string, fileName string) string {
+	if caption != "" {
+		return caption
+	}
+	return "Summarize the document " + fileName
+}
+
+func GetUnsupportedImageCaption(caption string) string {
+	note := "[The user sent an image, but the current model cannot see images]"
+	if caption != "" {
+		return caption + "\n\n" + note
+	}
+	return note
+}
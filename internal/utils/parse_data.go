@@ -3,7 +3,13 @@ package utils
 import (
 	"fmt"
 	"strings"
+	"teo/internal/config"
+	"teo/internal/provider"
+	"teo/internal/quota"
 	"teo/internal/services/bot/model"
+	"teo/internal/tools/calendar"
+	"teo/internal/tools/cashflow"
+	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -16,9 +22,9 @@ func ListModels(user model.User, provider string, models []string) string {
 	for i := range models {
 		status := ""
 		if models[i] == user.Model {
-			status = " ✅*Actived*"
+			status = " ✅**Actived**"
 		}
-		result.WriteString(fmt.Sprintf("%d - %s%s\n", i, models[i], status))
+		result.WriteString(fmt.Sprintf("%d - %s%s\n", i, EscapeMarkdown(models[i]), status))
 	}
 	result.WriteString("\n\nUsage: /models <number>\nExample: /models 0")
 	return result.String()
@@ -26,22 +32,392 @@ func ListModels(user model.User, provider string, models []string) string {
 
 func EscapeMarkdown(text string) string {
 	replacer := strings.NewReplacer(
+		"\\", "\\\\",
 		"_", "\\_",
 		"*", "\\*",
 		"[", "\\[",
 		"`", "\\`",
+		"~", "\\~",
 	)
 	return replacer.Replace(text)
 }
 
 func CommandMe(res *model.User) string {
 	var me strings.Builder
-	me.WriteString("ℹ️ *About Me*\n")
-	me.WriteString(fmt.Sprintf("*ID:* %d\n", res.UserId))
-	me.WriteString(fmt.Sprintf("*Name:* %s\n", EscapeMarkdown(res.Name)))
-	me.WriteString("\n\n🛠️ *Config*\n")
-	me.WriteString(fmt.Sprintf("*System:* %s\n", EscapeMarkdown(res.System)))
-	me.WriteString(fmt.Sprintf("*Model:* %s\n", EscapeMarkdown(res.Model)))
+	me.WriteString("ℹ️ **About Me**\n")
+	me.WriteString(fmt.Sprintf("**ID:** %d\n", res.UserId))
+	me.WriteString(fmt.Sprintf("**Name:** %s\n", EscapeMarkdown(res.Name)))
+	me.WriteString("\n\n🛠️ **Config**\n")
+	me.WriteString(fmt.Sprintf("**System:** %s\n", EscapeMarkdown(res.System)))
+	me.WriteString(fmt.Sprintf("**Model:** %s\n", EscapeMarkdown(res.Model)))
 
 	return me.String()
 }
+
+func ListMemories(memories []*model.Memory) string {
+	var result strings.Builder
+	result.WriteString("🧠 **What I Remember**\n\n")
+	for i, memory := range memories {
+		result.WriteString(fmt.Sprintf("%d - %s\n", i, EscapeMarkdown(memory.Content)))
+	}
+	result.WriteString("\n\nUsage: /memory edit <number> <text>\n/memory forget <number>")
+	return result.String()
+}
+
+func ListJobs(jobs []*model.Job) string {
+	var result strings.Builder
+	result.WriteString("⏰ **Reminders**\n\n")
+	for i, job := range jobs {
+		location, err := time.LoadLocation(job.Timezone)
+		if err != nil {
+			location = config.Timezone
+		}
+		schedule := "once"
+		if job.Cron != "" {
+			schedule = "`" + job.Cron + "`"
+		}
+		result.WriteString(fmt.Sprintf("%d - %s\n    %s, next %s\n", i, EscapeMarkdown(job.Text), schedule, job.NextRun.In(location).Format("Mon, 02 Jan 2006 15:04 MST")))
+	}
+	result.WriteString("\n\nUsage: /reminders cancel <number>\nExample: /reminders cancel 0")
+	return result.String()
+}
+
+func ListTasks(jobs []*model.Job) string {
+	var result strings.Builder
+	result.WriteString("📋 **Tasks**\n\n")
+	for i, job := range jobs {
+		location, err := time.LoadLocation(job.Timezone)
+		if err != nil {
+			location = config.Timezone
+		}
+		name := job.Name
+		if name == "" {
+			name = job.Text
+			if len([]rune(name)) > 60 {
+				name = string([]rune(name)[:60]) + "…"
+			}
+		}
+		schedule := "once"
+		if job.Cron != "" {
+			schedule = "`" + job.Cron + "`"
+		}
+		next := "next " + job.NextRun.In(location).Format("Mon, 02 Jan 2006 15:04 MST")
+		if job.Status == model.JobPaused {
+			next = "⏸️ paused"
+		}
+		result.WriteString(fmt.Sprintf("%d - %s\n    %s, %s, %d runs\n", i, EscapeMarkdown(name), schedule, next, job.Runs))
+		if job.LastError != "" {
+			result.WriteString(fmt.Sprintf("    ⚠️ last run failed: %s\n", EscapeMarkdown(job.LastError)))
+		}
+	}
+	result.WriteString("\n\nUsage: /tasks history <number>\n/tasks pause <number>\nMore with /tasks help")
+	return result.String()
+}
+
+func ListJobRuns(job *model.Job, runs []*model.JobRun) string {
+	location, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		location = config.Timezone
+	}
+
+	var result strings.Builder
+	name := job.Name
+	if name == "" {
+		name = "Task"
+	}
+	result.WriteString(fmt.Sprintf("📋 **%s History**\n\n", EscapeMarkdown(name)))
+	for _, run := range runs {
+		status := "✅"
+		if run.Status == model.JobRunFailed {
+			status = "❌"
+		}
+		result.WriteString(fmt.Sprintf("%s %s, %s, %d tokens, %d tool calls\n", status,
+			run.StartedAt.In(location).Format("Mon, 02 Jan 2006 15:04 MST"),
+			run.FinishedAt.Sub(run.StartedAt).Round(time.Second), run.TotalTokens, run.ToolCalls))
+		detail := run.Output
+		if run.Error != "" {
+			detail = run.Error
+		}
+		detail = strings.Join(strings.Fields(detail), " ")
+		if len([]rune(detail)) > 120 {
+			detail = string([]rune(detail)[:120]) + "…"
+		}
+		if detail != "" {
+			result.WriteString(fmt.Sprintf("    %s\n", EscapeMarkdown(detail)))
+		}
+	}
+	return result.String()
+}
+
+func ListSchedules(schedules []calendar.Schedule, location *time.Location) string {
+	var result strings.Builder
+	result.WriteString("📅 **Upcoming Events**\n")
+	day := ""
+	for _, schedule := range schedules {
+		start := schedule.StartTime.In(location)
+		if current := start.Format("Monday, 02 January"); current != day {
+			day = current
+			result.WriteString(fmt.Sprintf("\n**%s**\n", day))
+		}
+		repeat := ""
+		if schedule.Recurrence != "" {
+			repeat = " 🔁"
+		}
+		result.WriteString(fmt.Sprintf("%s - %s %s%s\n", start.Format("15:04"), schedule.EndTime.In(location).Format("15:04"), EscapeMarkdown(schedule.Title), repeat))
+	}
+	result.WriteString(fmt.Sprintf("\n\nTimes in %s\nUsage: /calendar export\n/calendar timezone <zone>", location.String()))
+	return result.String()
+}
+
+func ListBudgets(statuses []cashflow.BudgetStatus) string {
+	var result strings.Builder
+	result.WriteString("💰 **Budgets of this month**\n\n")
+	for _, status := range statuses {
+		alert := ""
+		if status.Over {
+			alert = " ⚠️"
+		}
+		result.WriteString(fmt.Sprintf("%s: %s / %s %s (%.0f%%)%s\n", EscapeMarkdown(status.Budget.Category), cashflow.FormatAmount(status.Spent), cashflow.FormatAmount(status.Budget.Amount), status.Budget.Currency, status.Spent/status.Budget.Amount*100, alert))
+	}
+	result.WriteString("\n\nUsage: /cashflow report [YYYY-MM]")
+	return result.String()
+}
+
+var recurringUnits = map[string]string{
+	cashflow.Daily:   "days",
+	cashflow.Weekly:  "weeks",
+	cashflow.Monthly: "months",
+	cashflow.Yearly:  "years",
+}
+
+func ListRecurring(recurring []*model.RecurringTransaction) string {
+	var result strings.Builder
+	result.WriteString("🔁 **Recurring Transactions**\n\n")
+	for _, entry := range recurring {
+		sign := "-"
+		if entry.Type == string(cashflow.Income) {
+			sign = "+"
+		}
+		every := entry.Frequency
+		if entry.Interval > 1 {
+			every = fmt.Sprintf("every %d %s", entry.Interval, recurringUnits[entry.Frequency])
+		}
+		result.WriteString(fmt.Sprintf("%s%s %s %s, %s, next on %s\n", sign, cashflow.FormatAmount(entry.Amount), entry.Currency, EscapeMarkdown(entry.Category), every, entry.NextDate.Format("02 Jan 2006")))
+	}
+	result.WriteString("\n\nAsk me to add or remove a recurring entry.")
+	return result.String()
+}
+
+// ListImportPreview lists the first limit transactions of a bank statement
+// in plain text, expenses with a minus sign.
+func ListImportPreview(transactions []cashflow.Transaction, limit int) string {
+	var result strings.Builder
+	for i, t := range transactions {
+		if i == limit {
+			result.WriteString(fmt.Sprintf("... and %d more\n", len(transactions)-limit))
+			break
+		}
+		sign := "-"
+		if t.Type == cashflow.Income {
+			sign = "+"
+		}
+		description := []rune(t.Description)
+		if len(description) > 40 {
+			description = append(description[:39], '…')
+		}
+		result.WriteString(fmt.Sprintf("%s %s%s %s · %s · %s\n", t.Date.Format("02 Jan 2006"), sign, cashflow.FormatAmount(t.Amount), t.Currency, t.Category.Name, string(description)))
+	}
+	return result.String()
+}
+
+func ListDocuments(documents []*model.Document) string {
+	var result strings.Builder
+	result.WriteString("📚 **Knowledge Base**\n\n")
+	for i, document := range documents {
+		result.WriteString(fmt.Sprintf("%d - %s (%d parts)\n", i, EscapeMarkdown(document.FileName), document.Chunks))
+	}
+	result.WriteString("\n\nUsage: /docs remove <number>\nExample: /docs remove 0")
+	return result.String()
+}
+
+func ListBranches(branches []model.ConversationBranch, active int) string {
+	var result strings.Builder
+	result.WriteString("🌿 **Branches**\n\n")
+	for i, branch := range branches {
+		preview := "Original conversation"
+		if i > 0 && branch.ForkIndex < len(branch.Messages) {
+			preview = branchPreview(branch.Messages[branch.ForkIndex])
+		}
+
+		status := ""
+		if i == active {
+			status = " ✅**Active**"
+		}
+		result.WriteString(fmt.Sprintf("%d - %s (%d messages)%s\n", i, EscapeMarkdown(preview), len(branch.Messages), status))
+	}
+	result.WriteString("\n\nUsage: /branches <number>\nExample: /branches 0")
+	return result.String()
+}
+
+func branchPreview(message provider.Message) string {
+	text := ""
+	switch content := message.Content.(type) {
+	case string:
+		text = content
+	case []provider.ContentItem:
+		for _, item := range content {
+			if item.Type == "text" {
+				text = item.Text
+				break
+			}
+		}
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) > 60 {
+		text = string([]rune(text)[:60]) + "…"
+	}
+	if message.Role == "assistant" {
+		return "🤖 " + text
+	}
+	return "👤 " + text
+}
+
+type UsageRow struct {
+	Label   string
+	Summary *model.UsageSummary
+}
+
+type UsagePeriod struct {
+	Label string
+	Rows  []UsageRow
+}
+
+func ListUsage(title string, periods []UsagePeriod) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("📊 **%s**\n", title))
+	for _, period := range periods {
+		summaries := make([]*model.UsageSummary, 0, len(period.Rows))
+		for _, row := range period.Rows {
+			summaries = append(summaries, row.Summary)
+		}
+		total := sumUsage(summaries)
+
+		result.WriteString(fmt.Sprintf("\n**%s**\n", period.Label))
+		if total.Requests == 0 {
+			result.WriteString("No usage yet\n")
+			continue
+		}
+		result.WriteString(fmt.Sprintf("Total: %s\n", usageLine(total)))
+		for _, row := range period.Rows {
+			result.WriteString(fmt.Sprintf("• %s: %s\n", EscapeMarkdown(row.Label), usageLine(row.Summary)))
+		}
+	}
+	return result.String()
+}
+
+func usageLine(summary *model.UsageSummary) string {
+	return fmt.Sprintf("%d requests, %d tokens (%d in / %d out), $%.4f",
+		summary.Requests, summary.TotalTokens, summary.PromptTokens, summary.CompletionTokens, summary.Cost)
+}
+
+func ShowQuota(name string, limits quota.Quota, override bool) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("⏳ **Limits for %s**\n\n", EscapeMarkdown(name)))
+	result.WriteString(fmt.Sprintf("**Messages per minute:** %s\n", quotaLimit(limits.MessagesPerMinute)))
+	result.WriteString(fmt.Sprintf("**Tokens per day:** %s\n", quotaLimit(limits.TokensPerDay)))
+	result.WriteString(fmt.Sprintf("**Tool runs per hour:** %s\n", quotaLimit(limits.ToolsPerHour)))
+	if override {
+		result.WriteString("\nSet by an admin for this user.")
+	}
+	return result.String()
+}
+
+func ShowParams(params provider.GenerationParams) string {
+	var result strings.Builder
+	result.WriteString("🎛️ **Generation Parameters**\n\n")
+	result.WriteString(fmt.Sprintf("**Temperature:** %s\n", paramValue(params.Temperature)))
+	result.WriteString(fmt.Sprintf("**Top P:** %s\n", paramValue(params.TopP)))
+	result.WriteString(fmt.Sprintf("**Max tokens:** %s\n", paramValue(params.MaxTokens)))
+	result.WriteString(fmt.Sprintf("**Seed:** %s\n", paramValue(params.Seed)))
+	stop := "model default"
+	if len(params.Stop) > 0 {
+		var quoted []string
+		for _, sequence := range params.Stop {
+			quoted = append(quoted, "`"+sequence+"`")
+		}
+		stop = strings.Join(quoted, ", ")
+	}
+	result.WriteString(fmt.Sprintf("**Stop:** %s\n", stop))
+	effort := "model default"
+	if params.ReasoningEffort != "" {
+		effort = params.ReasoningEffort
+	}
+	result.WriteString(fmt.Sprintf("**Reasoning effort:** %s\n", effort))
+	result.WriteString("\nChange them with /params <name> <value>.")
+	return result.String()
+}
+
+func paramValue[T int | float64](value *T) string {
+	if value == nil {
+		return "model default"
+	}
+	return fmt.Sprintf("%v", *value)
+}
+
+func quotaLimit(limit int) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d", limit)
+}
+
+func ListUsers(users []*model.User, page int, perPage int) string {
+	pages := (len(users) + perPage - 1) / perPage
+	if pages == 0 {
+		pages = 1
+	}
+	if page > pages {
+		page = pages
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("👥 **Users** (%d)\n\n", len(users)))
+	start := (page - 1) * perPage
+	end := start + perPage
+	if end > len(users) {
+		end = len(users)
+	}
+	for _, user := range users[start:end] {
+		result.WriteString(fmt.Sprintf("%d - %s (%s)\n", user.UserId, EscapeMarkdown(user.Name), user.Role))
+	}
+	result.WriteString(fmt.Sprintf("\nPage %d of %d\n\nUsage: /users <page>\n/ban <user_id>\n/promote <user_id> <admin|member|banned>", page, pages))
+	return result.String()
+}
+
+func ShowStats(users int, roles map[string]int, activeToday int, today []*model.UsageSummary, allTime []*model.UsageSummary) string {
+	var result strings.Builder
+	result.WriteString("📈 **Stats**\n\n")
+	result.WriteString(fmt.Sprintf("**Users:** %d\n", users))
+	for _, role := range []string{model.RoleOwner, model.RoleAdmin, model.RoleMember, model.RolePending, model.RoleBanned} {
+		if roles[role] > 0 {
+			result.WriteString(fmt.Sprintf("• %s: %d\n", role, roles[role]))
+		}
+	}
+	result.WriteString(fmt.Sprintf("\n**Active today:** %d\n", activeToday))
+	result.WriteString(fmt.Sprintf("**Today:** %s\n", usageLine(sumUsage(today))))
+	result.WriteString(fmt.Sprintf("**All time:** %s\n", usageLine(sumUsage(allTime))))
+	return result.String()
+}
+
+func sumUsage(summaries []*model.UsageSummary) *model.UsageSummary {
+	var total model.UsageSummary
+	for _, summary := range summaries {
+		total.Requests += summary.Requests
+		total.PromptTokens += summary.PromptTokens
+		total.CompletionTokens += summary.CompletionTokens
+		total.TotalTokens += summary.TotalTokens
+		total.Cost += summary.Cost
+	}
+	return &total
+}
@@ -2,7 +2,13 @@ package utils
 
 func Watermark(content string, model string, active bool) string {
 	if active {
-		return content + "\n\n🤖 *" + model + "*"
+		return content + "\n\n🤖 **" + EscapeMarkdown(model) + "**"
 	}
 	return content
 }
+
+// FallbackWatermark tells the user that model answered because the one they
+// chose was unavailable. It is shown whether or not the watermark is enabled.
+func FallbackWatermark(content string, model string) string {
+	return content + "\n\n↪️ Answered by fallback model **" + EscapeMarkdown(model) + "**"
+}
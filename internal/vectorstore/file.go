@@ -0,0 +1,159 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+var unsafeNamespace = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// FileStore keeps every namespace in its own JSON file and searches it
+// in memory, which is enough for per-user collections of a few thousand
+// vectors without running a hosted vector database.
+type FileStore struct {
+	dataDir string
+	mu      sync.RWMutex
+	cache   map[string][]Record
+}
+
+func NewFileStore(dataDir string) (*FileStore, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating vector store directory: %v", err)
+	}
+
+	return &FileStore{
+		dataDir: dataDir,
+		cache:   map[string][]Record{},
+	}, nil
+}
+
+func (s *FileStore) path(namespace string) string {
+	return filepath.Join(s.dataDir, unsafeNamespace.ReplaceAllString(namespace, "_")+".json")
+}
+
+func (s *FileStore) load(namespace string) ([]Record, error) {
+	if records, ok := s.cache[namespace]; ok {
+		return records, nil
+	}
+
+	var records []Record
+	data, err := os.ReadFile(s.path(namespace))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading vector store: %v", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("error parsing vector store: %v", err)
+		}
+	}
+
+	s.cache[namespace] = records
+	return records, nil
+}
+
+func (s *FileStore) save(namespace string, records []Record) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("error marshaling vector store: %v", err)
+	}
+
+	path := s.path(namespace)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing vector store: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error writing vector store: %v", err)
+	}
+
+	s.cache[namespace] = records
+	return nil
+}
+
+func (s *FileStore) Upsert(namespace string, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.load(namespace)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(existing))
+	updated := make([]Record, len(existing))
+	copy(updated, existing)
+	for i, record := range updated {
+		index[record.ID] = i
+	}
+
+	for _, record := range records {
+		if i, ok := index[record.ID]; ok {
+			updated[i] = record
+			continue
+		}
+		index[record.ID] = len(updated)
+		updated = append(updated, record)
+	}
+
+	return s.save(namespace, updated)
+}
+
+func (s *FileStore) Search(namespace string, vector []float64, limit int) ([]Result, error) {
+	s.mu.Lock()
+	records, err := s.load(namespace)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return rank(records, vector, limit), nil
+}
+
+func (s *FileStore) List(namespace string) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(namespace)
+}
+
+func (s *FileStore) Delete(namespace string, ids []string) error {
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	return s.deleteWhere(namespace, func(record Record) bool {
+		return remove[record.ID]
+	})
+}
+
+func (s *FileStore) DeleteByMetadata(namespace string, key string, value string) error {
+	return s.deleteWhere(namespace, func(record Record) bool {
+		return record.Metadata[key] == value
+	})
+}
+
+func (s *FileStore) deleteWhere(namespace string, match func(Record) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.load(namespace)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]Record, 0, len(existing))
+	for _, record := range existing {
+		if !match(record) {
+			kept = append(kept, record)
+		}
+	}
+
+	return s.save(namespace, kept)
+}
@@ -0,0 +1,65 @@
+package vectorstore
+
+import (
+	"math"
+	"sort"
+)
+
+type Record struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Vector   []float64         `json:"vector"`
+}
+
+type Result struct {
+	Record
+	Score float64 `json:"score"`
+}
+
+type VectorStore interface {
+	Upsert(namespace string, records []Record) error
+	Search(namespace string, vector []float64, limit int) ([]Result, error)
+	List(namespace string) ([]Record, error)
+	Delete(namespace string, ids []string) error
+	DeleteByMetadata(namespace string, key string, value string) error
+}
+
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func rank(records []Record, vector []float64, limit int) []Result {
+	results := make([]Result, 0, len(records))
+	for _, record := range records {
+		results = append(results, Result{
+			Record: record,
+			Score:  CosineSimilarity(vector, record.Vector),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}